@@ -0,0 +1,119 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/arduino/aws-sitewise-integration/internal/parameters"
+	"github.com/arduino/aws-sitewise-integration/internal/utils"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// APIImportTrigger selects, through ImportTriggerEnv, the lambda handling on-demand imports from API Gateway
+const APIImportTrigger = "api"
+
+// apiImportRequest are the parameters of an on-demand import. They can be given as query parameters or as
+// JSON body, the latter taking precedence. Parameters not given are read from SSM as for scheduled runs.
+type apiImportRequest struct {
+	Tags          *string `json:"tags"`
+	Resolution    *string `json:"resolution"`
+	AlignEntities *bool   `json:"alignEntities"`
+	DryRun        bool    `json:"dryRun"`
+}
+
+// HandleAPIGatewayRequest runs an on-demand import, returning its result as JSON body.
+func HandleAPIGatewayRequest(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	req, err := parseAPIImportRequest(request)
+	if err != nil {
+		return apiResponse(http.StatusBadRequest, importResult{Message: err.Error()})
+	}
+
+	summary, errs, err := runImport(ctx, &SiteWiseImportTrigger{DryRun: req.DryRun}, runOptions{
+		tags:          req.Tags,
+		resolution:    req.Resolution,
+		alignEntities: req.AlignEntities,
+	})
+	if errors.Is(err, parameters.ErrRunInProgress) {
+		return apiResponse(http.StatusConflict, importResult{Message: err.Error()})
+	}
+	if errors.Is(err, errInvalidOverride) {
+		return apiResponse(http.StatusBadRequest, importResult{Message: err.Error()})
+	}
+	if err == nil && len(errs) > 0 && summary.ThingsProcessed == 0 {
+		err = errs[0]
+	}
+	if err != nil {
+		return apiResponse(http.StatusInternalServerError, importResult{Message: err.Error()})
+	}
+	return apiResponse(http.StatusOK, newImportResult(summary, errs))
+}
+
+func parseAPIImportRequest(request events.APIGatewayProxyRequest) (apiImportRequest, error) {
+	req := apiImportRequest{}
+	query := request.QueryStringParameters
+	if tags, ok := query["tags"]; ok {
+		req.Tags = &tags
+	}
+	if resolution, ok := query["resolution"]; ok {
+		req.Resolution = &resolution
+	}
+	if value, ok := query["alignEntities"]; ok {
+		align, err := strconv.ParseBool(value)
+		if err != nil {
+			return req, fmt.Errorf("invalid alignEntities: %s", value)
+		}
+		req.AlignEntities = &align
+	}
+	if value, ok := query["dryRun"]; ok {
+		dryRun, err := strconv.ParseBool(value)
+		if err != nil {
+			return req, fmt.Errorf("invalid dryRun: %s", value)
+		}
+		req.DryRun = dryRun
+	}
+	if request.Body != "" {
+		if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
+			return req, fmt.Errorf("invalid body: %w", err)
+		}
+	}
+	if req.Resolution != nil && !parameters.IsValidResolution(*req.Resolution) {
+		return req, fmt.Errorf("invalid resolution: %s", *req.Resolution)
+	}
+	if req.Tags != nil {
+		if err := utils.ValidateTags(*req.Tags); err != nil {
+			return req, err
+		}
+	}
+	return req, nil
+}
+
+func apiResponse(status int, result importResult) (events.APIGatewayProxyResponse, error) {
+	body, err := json.Marshal(result)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+	return events.APIGatewayProxyResponse{
+		StatusCode: status,
+		Headers:    map[string]string{"Content-Type": "application/json"},
+		Body:       string(body),
+	}, nil
+}
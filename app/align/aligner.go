@@ -17,10 +17,16 @@ package align
 
 import (
 	"context"
+	"errors"
+	"maps"
+	"slices"
+	"time"
 
 	"github.com/arduino/aws-sitewise-integration/business/entityalign"
+	"github.com/arduino/aws-sitewise-integration/business/propfilter"
 	"github.com/arduino/aws-sitewise-integration/business/tsalign"
 	"github.com/arduino/aws-sitewise-integration/internal/iot"
+	"github.com/arduino/aws-sitewise-integration/internal/metrics"
 	"github.com/arduino/aws-sitewise-integration/internal/sitewiseclient"
 	"github.com/arduino/aws-sitewise-integration/internal/utils"
 	iotclient "github.com/arduino/iot-client-go/v2"
@@ -30,61 +36,355 @@ import (
 type entityAligner struct {
 	logger     *logrus.Entry
 	sitewisecl *sitewiseclient.IotSiteWiseClient
-	iotcl      *iot.Client
+	orgs       []organization
+
+	importOpts     []tsalign.Option
+	alignOpts      []entityalign.Option
+	sitewiseOpts   []sitewiseclient.Option
+	discoveryCache *tsalign.DiscoveryCache
+	typesCache     *iot.PropertyTypesCache
+	fetchOnly      bool
+	metrics        metrics.Emitter
+	maxThings      int
+	thingsCursor   string
+}
+
+// organization is an Arduino organization swept by the aligner, with the client reading its things.
+type organization struct {
+	id    string
+	iotcl *iot.Client
+}
+
+// Option configures optional behaviours of the aligner.
+type Option func(*entityAligner)
+
+// WithImportOptions sets the options used to configure the time series import.
+func WithImportOptions(opts ...tsalign.Option) Option {
+	return func(a *entityAligner) {
+		a.importOpts = append(a.importOpts, opts...)
+	}
+}
+
+// WithDiscoveryCache enables reuse of SiteWise assets discovered in previous runs, if the last full
+// scan happened less than minScanInterval ago. The cache is invalidated every time entities are aligned.
+func WithDiscoveryCache(cache *tsalign.DiscoveryCache, minScanInterval time.Duration) Option {
+	return func(a *entityAligner) {
+		a.discoveryCache = cache
+		a.importOpts = append(a.importOpts, tsalign.WithDiscoveryCache(cache, minScanInterval))
+	}
+}
+
+// WithPropertyTypesCache enables reuse of property types definitions fetched in previous runs.
+// Definitions are fetched again when things have properties of types not found in the cache.
+func WithPropertyTypesCache(cache *iot.PropertyTypesCache) Option {
+	return func(a *entityAligner) {
+		a.typesCache = cache
+	}
+}
+
+// WithAlignOptions sets the options used to configure models and assets alignment.
+func WithAlignOptions(opts ...entityalign.Option) Option {
+	return func(a *entityAligner) {
+		a.alignOpts = append(a.alignOpts, opts...)
+	}
+}
+
+// WithPropertyFilter restricts the thing properties aligned and imported into SiteWise.
+func WithPropertyFilter(filter *propfilter.Filter) Option {
+	return func(a *entityAligner) {
+		a.alignOpts = append(a.alignOpts, entityalign.WithPropertyFilter(filter))
+		a.importOpts = append(a.importOpts, tsalign.WithPropertyFilter(filter))
+	}
+}
+
+// WithMinMaxAggregation enables alignment and import of min and max aggregations of numeric properties,
+// beside the default average.
+func WithMinMaxAggregation(enabled bool) Option {
+	return func(a *entityAligner) {
+		a.alignOpts = append(a.alignOpts, entityalign.WithMinMaxAggregation(enabled))
+		a.importOpts = append(a.importOpts, tsalign.WithMinMaxAggregation(enabled))
+	}
 }
 
-func New(key, secret, orgid string, logger *logrus.Entry) (*entityAligner, []error) {
+// WithUpdatedAtProperties enables alignment and import of the time ON_CHANGE properties last changed.
+func WithUpdatedAtProperties(enabled bool) Option {
+	return func(a *entityAligner) {
+		a.alignOpts = append(a.alignOpts, entityalign.WithUpdatedAtProperties(enabled))
+		a.importOpts = append(a.importOpts, tsalign.WithUpdatedAtProperties(enabled))
+	}
+}
+
+// WithAttributeProperties enables alignment and import of static thing properties as asset attributes,
+// rather than as measurements.
+func WithAttributeProperties(enabled bool) Option {
+	return func(a *entityAligner) {
+		a.alignOpts = append(a.alignOpts, entityalign.WithAttributeProperties(enabled))
+		a.importOpts = append(a.importOpts, tsalign.WithAttributeProperties(enabled))
+	}
+}
+
+// WithLocationCoordinates enables alignment and import of latitude and longitude of location properties,
+// as numeric properties beside the location string.
+func WithLocationCoordinates(enabled bool) Option {
+	return func(a *entityAligner) {
+		a.alignOpts = append(a.alignOpts, entityalign.WithLocationCoordinates(enabled))
+		a.importOpts = append(a.importOpts, tsalign.WithLocationCoordinates(enabled))
+	}
+}
+
+// WithFetchOnly makes the aligner only fetch data from Arduino IoT Cloud, for benchmarking.
+// Models and assets are not aligned and nothing is written to SiteWise.
+func WithFetchOnly(enabled bool) Option {
+	return func(a *entityAligner) {
+		a.fetchOnly = enabled
+		a.importOpts = append(a.importOpts, tsalign.WithFetchOnly(enabled))
+	}
+}
+
+// WithDryRun makes the aligner log the models, assets, aliases and data points it would write to SiteWise,
+// without writing them. SiteWise is still read, so that the logged plan is accurate.
+func WithDryRun(enabled bool) Option {
+	return func(a *entityAligner) {
+		a.sitewiseOpts = append(a.sitewiseOpts, sitewiseclient.WithDryRun(enabled))
+	}
+}
+
+// WithMetrics emits the metrics of each run at its end. A nil emitter disables metrics.
+func WithMetrics(emitter metrics.Emitter) Option {
+	return func(a *entityAligner) {
+		a.metrics = emitter
+	}
+}
+
+// WithSiteWiseOptions sets the options used to configure the SiteWise client.
+func WithSiteWiseOptions(opts ...sitewiseclient.Option) Option {
+	return func(a *entityAligner) {
+		a.sitewiseOpts = append(a.sitewiseOpts, opts...)
+	}
+}
+
+// New returns an aligner sweeping the given Arduino organizations, with a client per organization.
+// With no organization, things of the organization of the API key are aligned.
+func New(key, secret string, orgids []string, logger *logrus.Entry, opts ...Option) (*entityAligner, []error) {
+	aligner := &entityAligner{
+		logger: logger,
+	}
+	for _, opt := range opts {
+		opt(aligner)
+	}
+
 	// Init clients
-	sitewisecl, err := sitewiseclient.New(logger)
+	sitewisecl, err := sitewiseclient.New(logger, aligner.sitewiseOpts...)
 	if err != nil {
 		return nil, []error{err}
 	}
-	iotcl, err := iot.NewClient(key, secret, orgid)
-	if err != nil {
-		return nil, []error{err}
+	var iotOpts []iot.ClientOption
+	if aligner.typesCache != nil {
+		iotOpts = append(iotOpts, iot.WithPropertyTypesCache(aligner.typesCache))
+	}
+	if len(orgids) == 0 {
+		orgids = []string{""}
+	}
+	for _, orgid := range orgids {
+		// Each client has its own token source and X-Organization header
+		iotcl, err := iot.NewClient(key, secret, orgid, iotOpts...)
+		if err != nil {
+			return nil, []error{err}
+		}
+		aligner.orgs = append(aligner.orgs, organization{id: orgid, iotcl: iotcl})
 	}
+	aligner.sitewisecl = sitewisecl
+
+	return aligner, nil
+}
+
+// StartAlignAndImport aligns models and assets, if requested, and imports time series of things matching the
+// given tags, returning a summary of the import.
+func (a *entityAligner) StartAlignAndImport(ctx context.Context, tagsF *string, alignEntities bool, resolution, timeWindowMinutes int) (tsalign.ImportSummary, []error) {
+	summary, errs := a.alignAndImport(ctx, nil, tagsF, alignEntities, resolution, timeWindowMinutes)
+	a.emitMetrics(summary, errs)
+	return summary, errs
+}
 
-	return &entityAligner{
-		logger:     logger,
-		sitewisecl: sitewisecl,
-		iotcl:      iotcl,
-	}, nil
+// StartAlignAndImportForThings is like StartAlignAndImport, scoped to the things with the given ids.
+// Orphan assets pruning must be disabled, as things out of scope would be seen as deleted.
+func (a *entityAligner) StartAlignAndImportForThings(ctx context.Context, ids []string, tagsF *string, alignEntities bool, resolution, timeWindowMinutes int) (tsalign.ImportSummary, []error) {
+	if len(ids) == 0 {
+		return tsalign.ImportSummary{}, nil
+	}
+	summary, errs := a.alignAndImport(ctx, ids, tagsF, alignEntities, resolution, timeWindowMinutes)
+	a.emitMetrics(summary, errs)
+	return summary, errs
 }
 
-func (a *entityAligner) StartAlignAndImport(ctx context.Context, tagsF *string, alignEntities bool, resolution, timeWindowMinutes int) []error {
+// emitMetrics publishes the metrics of a run, if enabled. Failures are logged, not to fail the run.
+func (a *entityAligner) emitMetrics(summary tsalign.ImportSummary, errs []error) {
+	if a.metrics == nil {
+		return
+	}
+	err := a.metrics.Emit(metrics.Run{
+		ThingsProcessed: summary.ThingsProcessed,
+		PointsWritten:   summary.PointsWritten,
+		ThrottleRetries: summary.ThrottleRetries,
+		Errors:          int64(len(errs)),
+	})
+	if err != nil {
+		a.logger.Warnln("Error emitting run metrics: ", err)
+	}
+}
+
+func (a *entityAligner) alignAndImport(ctx context.Context, ids []string, tagsF *string, alignEntities bool, resolution, timeWindowMinutes int) (tsalign.ImportSummary, []error) {
+	if ids != nil {
+		a.logger.Infoln("Things - searching by ids: ", ids)
+	}
 	if tagsF == nil {
 		a.logger.Infoln("Things - searching with no filter")
 	} else {
 		a.logger.Infoln("Things - searching by tags: ", *tagsF)
 	}
-	things, err := a.iotcl.ThingList(ctx, nil, nil, true, utils.ParseTags(tagsF))
-	if err != nil {
-		return []error{err}
+	// Things of all the organizations are aligned together, not to prune assets of other organizations
+	var things []iotclient.ArduinoThing
+	thingsByOrg := make([]map[string]iotclient.ArduinoThing, len(a.orgs))
+	thingOrgs := make(map[string]string)
+	for i, org := range a.orgs {
+		if org.id != "" {
+			a.logger.Infoln("Things - organization: ", org.id)
+		}
+		orgThings, err := org.iotcl.ThingList(ctx, ids, nil, true, utils.ParseTags(tagsF))
+		if err != nil {
+			return tsalign.ImportSummary{}, []error{err}
+		}
+		thingsByOrg[i] = make(map[string]iotclient.ArduinoThing, len(orgThings))
+		for _, thing := range orgThings {
+			a.logger.Infoln("  Thing: ", thing.Id, thing.Name)
+			thingsByOrg[i][thing.Id] = thing
+			if org.id != "" {
+				thingOrgs[thing.Id] = org.id
+			}
+		}
+		things = append(things, orgThings...)
 	}
-	thingsMap := make(map[string]iotclient.ArduinoThing, len(things))
-	for _, thing := range things {
-		a.logger.Infoln("  Thing: ", thing.Id, thing.Name)
-		thingsMap[thing.Id] = thing
+	if ids == nil && a.maxThings > 0 {
+		total := len(things)
+		after := a.thingsCursor
+		things, a.thingsCursor = capThings(things, a.maxThings, after)
+		if len(things) < total {
+			a.logger.Infof("Things - processing %d of %d things, after thing %q", len(things), total, after)
+			selected := make(map[string]bool, len(things))
+			for _, thing := range things {
+				selected[thing.Id] = true
+			}
+			for _, orgThings := range thingsByOrg {
+				maps.DeleteFunc(orgThings, func(id string, _ iotclient.ArduinoThing) bool { return !selected[id] })
+			}
+		}
 	}
 
+	if alignEntities && a.fetchOnly {
+		a.logger.Infoln("Fetch only mode, skipping models and assets alignment")
+		alignEntities = false
+	}
+	var alignErrs []error
 	if alignEntities {
-		propertyDefintions, err := a.iotcl.PropertiesDefinition(ctx)
+		// Property types are the same for all the organizations
+		iotcl := a.orgs[0].iotcl
+		propertyDefintions, err := iotcl.PropertiesDefinition(ctx)
 		if err != nil {
-			return []error{err}
+			return tsalign.ImportSummary{}, []error{err}
+		}
+		if missing := missingPropertyTypes(things, propertyDefintions); len(missing) > 0 && a.typesCache != nil {
+			a.logger.Infoln("Property types not found in cached definitions, fetching them again: ", missing)
+			iotcl.InvalidatePropertiesDefinition()
+			propertyDefintions, err = iotcl.PropertiesDefinition(ctx)
+			if err != nil {
+				return tsalign.ImportSummary{}, []error{err}
+			}
 		}
 		a.logger.Debugln("Loaded # properties definition: ", len(propertyDefintions))
-		aligner := entityalign.New(a.sitewisecl, a.logger)
+		// Things requested by id are known, their assets are looked up without scanning all the models
+		alignOpts := append(slices.Clone(a.alignOpts), entityalign.WithThingOrganizations(thingOrgs), entityalign.WithExternalIdLookup(ids != nil))
+		aligner := entityalign.New(a.sitewisecl, a.logger, alignOpts...)
 		errs := aligner.Align(ctx, things, propertyDefintions)
-		if errs != nil {
-			return errs
+		if a.discoveryCache != nil {
+			a.discoveryCache.Invalidate()
+		}
+		skipped := skippedThings(errs)
+		if len(skipped) < len(errs) {
+			return tsalign.ImportSummary{}, errs
+		}
+		// Things left out of the alignment are not imported, the others are
+		alignErrs = errs
+		for _, orgThings := range thingsByOrg {
+			maps.DeleteFunc(orgThings, func(id string, _ iotclient.ArduinoThing) bool { return skipped[id] })
+		}
+	}
+
+	// Extract data points from thing and push to SiteWise, reading them with the client of their organization
+	var summary tsalign.ImportSummary
+	errs := alignErrs
+	for i, org := range a.orgs {
+		logger := a.logger
+		if len(a.orgs) > 1 {
+			logger = logger.WithField("organization_id", org.id)
 		}
+		tsAlignerClient := tsalign.New(a.sitewisecl, org.iotcl, logger, a.importOpts...)
+		orgSummary, orgErrs := tsAlignerClient.AlignTimeSeriesSamplesIntoSiteWise(ctx, timeWindowMinutes, thingsByOrg[i], resolution)
+		summary.Add(orgSummary)
+		errs = append(errs, orgErrs...)
+	}
+	if len(errs) == 0 {
+		return summary, nil
 	}
+	return summary, errs
+}
 
-	// Extract data points from thing and push to SiteWise
-	tsAlignerClient := tsalign.New(a.sitewisecl, a.iotcl, a.logger)
-	if err := tsAlignerClient.AlignTimeSeriesSamplesIntoSiteWise(ctx, timeWindowMinutes, thingsMap, resolution); err != nil {
-		return err
+// skippedThings returns the ids of the things of the SkippedThingError among the given errors
+func skippedThings(errs []error) map[string]bool {
+	skipped := make(map[string]bool)
+	for _, err := range errs {
+		var skippedErr *entityalign.SkippedThingError
+		if errors.As(err, &skippedErr) {
+			skipped[skippedErr.ThingID] = true
+		}
 	}
+	return skipped
+}
 
-	return nil
+// VerifyImport compares the latest SiteWise values of a sample of things matching the given tags, in each
+// organization, against the last values of their Arduino properties.
+func (a *entityAligner) VerifyImport(ctx context.Context, tagsF *string, sampleSize int, tolerance float64) (tsalign.VerifyResult, error) {
+	var result tsalign.VerifyResult
+	for _, org := range a.orgs {
+		things, err := org.iotcl.ThingList(ctx, nil, nil, true, utils.ParseTags(tagsF))
+		if err != nil {
+			return result, err
+		}
+		thingsMap := make(map[string]iotclient.ArduinoThing, len(things))
+		for _, thing := range things {
+			thingsMap[thing.Id] = thing
+		}
+		logger := a.logger
+		if org.id != "" {
+			logger = logger.WithField("organization_id", org.id)
+		}
+		orgResult, err := tsalign.New(a.sitewisecl, org.iotcl, logger, a.importOpts...).VerifyImport(ctx, thingsMap, sampleSize, tolerance)
+		result.Add(orgResult)
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// missingPropertyTypes returns the types of thing properties not found in the given definitions.
+func missingPropertyTypes(things []iotclient.ArduinoThing, definitions map[string]iotclient.ArduinoPropertytype) []string {
+	var missing []string
+	for _, thing := range things {
+		for _, property := range thing.Properties {
+			if _, ok := definitions[property.Type]; !ok && !slices.Contains(missing, property.Type) {
+				missing = append(missing, property.Type)
+			}
+		}
+	}
+	return missing
 }
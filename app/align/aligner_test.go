@@ -0,0 +1,115 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package align
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/arduino/aws-sitewise-integration/business/entityalign"
+	"github.com/arduino/aws-sitewise-integration/business/tsalign"
+	"github.com/arduino/aws-sitewise-integration/internal/metrics"
+	iotclient "github.com/arduino/iot-client-go/v2"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingEmitter struct {
+	runs []metrics.Run
+}
+
+func (e *recordingEmitter) Emit(run metrics.Run) error {
+	e.runs = append(e.runs, run)
+	return nil
+}
+
+func TestEmitMetrics(t *testing.T) {
+	emitter := &recordingEmitter{}
+	aligner := &entityAligner{logger: logrus.NewEntry(logrus.New())}
+	WithMetrics(emitter)(aligner)
+
+	summary := tsalign.ImportSummary{ThingsProcessed: 3, PropertiesImported: 7, PointsWritten: 120, PointsSkipped: 4, ThrottleRetries: 2}
+	aligner.emitMetrics(summary, []error{errors.New("thing1"), errors.New("thing2")})
+	assert.Equal(t, []metrics.Run{{ThingsProcessed: 3, PointsWritten: 120, ThrottleRetries: 2, Errors: 2}}, emitter.runs)
+
+	// Disabled
+	aligner = &entityAligner{logger: logrus.NewEntry(logrus.New())}
+	WithMetrics(nil)(aligner)
+	aligner.emitMetrics(summary, nil)
+}
+
+func TestMissingPropertyTypes(t *testing.T) {
+	things := []iotclient.ArduinoThing{
+		{Properties: []iotclient.ArduinoProperty{{Type: "FLOAT"}, {Type: "HEART_BEAT"}}},
+		{Properties: []iotclient.ArduinoProperty{{Type: "HEART_BEAT"}}},
+	}
+	definitions := map[string]iotclient.ArduinoPropertytype{"FLOAT": {Type: "FLOAT"}}
+	assert.Equal(t, []string{"HEART_BEAT"}, missingPropertyTypes(things, definitions))
+
+	definitions["HEART_BEAT"] = iotclient.ArduinoPropertytype{Type: "HEART_BEAT"}
+	assert.Empty(t, missingPropertyTypes(things, definitions))
+}
+
+func TestSkippedThings(t *testing.T) {
+	errs := []error{
+		&entityalign.SkippedThingError{ThingID: "thing1", Err: errors.New("too many properties")},
+		fmt.Errorf("aligning: %w", &entityalign.SkippedThingError{ThingID: "thing2", Err: errors.New("too many properties")}),
+		errors.New("model creation failed"),
+	}
+	assert.Equal(t, map[string]bool{"thing1": true, "thing2": true}, skippedThings(errs))
+	assert.Empty(t, skippedThings(nil))
+}
+
+func TestCapThings(t *testing.T) {
+	things := []iotclient.ArduinoThing{{Id: "e"}, {Id: "b"}, {Id: "d"}, {Id: "a"}, {Id: "c"}}
+	ids := func(things []iotclient.ArduinoThing) []string {
+		res := []string{}
+		for _, thing := range things {
+			res = append(res, thing.Id)
+		}
+		return res
+	}
+
+	// Successive runs go through all the things, in id order
+	capped, cursor := capThings(things, 2, "")
+	assert.Equal(t, []string{"a", "b"}, ids(capped))
+	assert.Equal(t, "b", cursor)
+	capped, cursor = capThings(things, 2, cursor)
+	assert.Equal(t, []string{"c", "d"}, ids(capped))
+	assert.Equal(t, "d", cursor)
+	capped, cursor = capThings(things, 2, cursor)
+	assert.Equal(t, []string{"e"}, ids(capped))
+	assert.Empty(t, cursor)
+
+	// Things deleted or added since the last run don't skip the following ones
+	capped, cursor = capThings([]iotclient.ArduinoThing{{Id: "e"}, {Id: "a"}, {Id: "c"}}, 2, "b")
+	assert.Equal(t, []string{"c", "e"}, ids(capped))
+	assert.Empty(t, cursor)
+	capped, cursor = capThings(append(slices.Clone(things), iotclient.ArduinoThing{Id: "a1"}), 2, "b")
+	assert.Equal(t, []string{"c", "d"}, ids(capped))
+	assert.Equal(t, "d", cursor)
+
+	// Cursor after all the things starts over
+	capped, cursor = capThings(things[:3], 2, "f")
+	assert.Equal(t, []string{"b", "d"}, ids(capped))
+	assert.Equal(t, "d", cursor)
+
+	capped, cursor = capThings(things, 0, "c")
+	assert.Len(t, capped, 5)
+	assert.Empty(t, cursor)
+}
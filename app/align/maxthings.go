@@ -0,0 +1,62 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package align
+
+import (
+	"slices"
+	"strings"
+
+	iotclient "github.com/arduino/iot-client-go/v2"
+)
+
+// ThingsCursorKey is the state store key of the id of the last thing processed by a capped run
+const ThingsCursorKey = "things-cursor"
+
+// WithMaxThings caps the number of things aligned and imported by a run, for a gradual rollout on large
+// organizations. Things are taken in id order after the one with the cursor id, so that successive runs go
+// through all of them, see ThingsCursor. Tag filters apply before the cap. Zero disables the cap.
+func WithMaxThings(maxThings int, cursor string) Option {
+	return func(a *entityAligner) {
+		a.maxThings = maxThings
+		a.thingsCursor = cursor
+	}
+}
+
+// ThingsCursor returns the id of the last thing processed by the run, the next run starting after it.
+// It is empty once all the things have been processed.
+func (a *entityAligner) ThingsCursor() string {
+	return a.thingsCursor
+}
+
+// capThings returns the window of at most maxThings things following the one with the cursor id, in id order,
+// along with the cursor of the next window. Things added or deleted since the last run don't move the window,
+// and a cursor after all the things starts over.
+func capThings(things []iotclient.ArduinoThing, maxThings int, cursor string) ([]iotclient.ArduinoThing, string) {
+	if maxThings <= 0 || len(things) <= maxThings {
+		return things, ""
+	}
+	sorted := slices.Clone(things)
+	slices.SortFunc(sorted, func(a, b iotclient.ArduinoThing) int { return strings.Compare(a.Id, b.Id) })
+	start := slices.IndexFunc(sorted, func(thing iotclient.ArduinoThing) bool { return thing.Id > cursor })
+	if start < 0 {
+		start = 0
+	}
+	end := min(start+maxThings, len(sorted))
+	if end == len(sorted) {
+		return sorted[start:], ""
+	}
+	return sorted[start:end], sorted[end-1].Id
+}
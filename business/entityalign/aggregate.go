@@ -0,0 +1,62 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package entityalign
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/arduino/aws-sitewise-integration/internal/iot"
+	iotclient "github.com/arduino/iot-client-go/v2"
+)
+
+// MinMaxAggregations are the aggregations imported as additional properties, beside the default average.
+var MinMaxAggregations = []string{"MIN", "MAX"}
+
+// AggregatePropertyName returns the name of the property holding the given aggregation of a numeric property.
+func AggregatePropertyName(propertyName, aggregation string) string {
+	return propertyName + "_" + strings.ToLower(aggregation)
+}
+
+// withAggregateProperties returns copies of things having, for each numeric property, an additional property
+// per min/max aggregation. These become regular properties of models and assets, so model keys stay consistent.
+func (a *aligner) withAggregateProperties(things []iotclient.ArduinoThing) []iotclient.ArduinoThing {
+	expanded := make([]iotclient.ArduinoThing, 0, len(things))
+	for _, thing := range things {
+		names := make([]string, 0, len(thing.Properties))
+		for _, prop := range thing.Properties {
+			names = append(names, prop.Name)
+		}
+
+		properties := slices.Clone(thing.Properties)
+		for _, prop := range thing.Properties {
+			if !iot.IsPropertyNumberType(prop.Type) {
+				continue
+			}
+			for _, aggregation := range MinMaxAggregations {
+				name := AggregatePropertyName(prop.Name, aggregation)
+				if slices.Contains(names, name) {
+					a.logger.Warnln("Thing ", thing.Id, " already has property ", name, ", not importing ", aggregation, " of ", prop.Name)
+					continue
+				}
+				properties = append(properties, iotclient.ArduinoProperty{Name: name, Type: prop.Type})
+			}
+		}
+		thing.Properties = properties
+		expanded = append(expanded, thing)
+	}
+	return expanded
+}
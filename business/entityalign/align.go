@@ -23,42 +23,276 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/arduino/aws-sitewise-integration/business/propfilter"
+	"github.com/arduino/aws-sitewise-integration/internal/aliasindex"
 	"github.com/arduino/aws-sitewise-integration/internal/sitewiseclient"
 	iotclient "github.com/arduino/iot-client-go/v2"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/iotsitewise"
 	"github.com/aws/aws-sdk-go-v2/service/iotsitewise/types"
 	"github.com/sirupsen/logrus"
 )
 
 const (
-	waitTimeForSitewiseUpdate = 15
-	alignParallelism          = 6
-	keySeparator              = ","
+	defaultAlignParallelism = 6
+	keySeparator            = ","
 )
 
 type aligner struct {
 	sitewisecl sitewiseclient.API
 	logger     *logrus.Entry
+
+	alignParallelism  int
+	propertyFilter    *propfilter.Filter
+	deviceHierarchy   bool
+	aliasIndex        aliasindex.API
+	pollOptions       sitewiseclient.PollOptions
+	pruneOrphans      bool
+	minMaxAggregation bool
+	updatedAt         bool
+	locationCoords    bool
+
+	attributeProperties bool
+
+	duplicateAssetPolicy DuplicateAssetPolicy
+
+	maxModelProperties       int
+	modelPropertyLimitPolicy ModelPropertyLimitPolicy
+
+	modelPropertyRemovalPolicy ModelPropertyRemovalPolicy
+	typeMismatchPolicy         TypeMismatchPolicy
+
+	nameTemplate  *NameTemplate
+	aliasTemplate *AliasTemplate
+
+	thingOrganizations map[string]string
+
+	externalIdLookup bool
+}
+
+// OrganizationTagKey is the key of the tag holding the Arduino organization of the things of created assets
+const OrganizationTagKey = "arduino:organization-id"
+
+// Number of names tried for a model, adding an increment to the thing name, before giving up
+const maxModelNameAttempts = 100
+
+// errNoFreeModelName is returned when all the names tried for a model are already taken
+var errNoFreeModelName = errors.New("no free model name found")
+
+// SkippedThingError is the error of a thing left out of the alignment, while the other things are aligned.
+// Its time series must not be imported.
+type SkippedThingError struct {
+	ThingID string
+	Err     error
+}
+
+func (e *SkippedThingError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *SkippedThingError) Unwrap() error {
+	return e.Err
+}
+
+// DefaultMaxModelProperties is the default SiteWise quota of properties per asset model
+const DefaultMaxModelProperties = 200
+
+// ModelPropertyLimitPolicy defines how things with more properties than an asset model can hold are handled
+type ModelPropertyLimitPolicy string
+
+const (
+	// ModelPropertyLimitError reports an error for the thing, without creating its model
+	ModelPropertyLimitError ModelPropertyLimitPolicy = "error"
+	// ModelPropertyLimitComposite moves properties exceeding the limit into composite models
+	ModelPropertyLimitComposite ModelPropertyLimitPolicy = "composite"
+)
+
+// DuplicateAssetPolicy defines which asset is used when more assets, under different models, have the same external id
+type DuplicateAssetPolicy string
+
+const (
+	// DuplicateAssetKeepLast uses the last discovered asset
+	DuplicateAssetKeepLast DuplicateAssetPolicy = "last"
+	// DuplicateAssetPreferExpectedModel uses the asset under the model matching the thing properties, if any
+	DuplicateAssetPreferExpectedModel DuplicateAssetPolicy = "expected-model"
+)
+
+// Option configures optional behaviours of the entity aligner.
+type Option func(*aligner)
+
+// WithAlignParallelism sets the number of models and assets aligned concurrently. Values below 1 keep the default.
+func WithAlignParallelism(parallelism int) Option {
+	return func(a *aligner) {
+		if parallelism > 0 {
+			a.alignParallelism = parallelism
+		}
+	}
+}
+
+// WithNameTemplate sets the template composing the names of thing assets and of the models created from them.
+// Default is the thing name.
+func WithNameTemplate(tmpl *NameTemplate) Option {
+	return func(a *aligner) {
+		a.nameTemplate = tmpl
+	}
+}
+
+// WithAliasTemplate sets the template composing the aliases of thing properties. Default is PropertyAlias.
+// Time series must be imported with the same template.
+func WithAliasTemplate(tmpl *AliasTemplate) Option {
+	return func(a *aligner) {
+		a.aliasTemplate = tmpl
+	}
+}
+
+// WithPropertyFilter restricts the thing properties mapped into SiteWise models and assets.
+func WithPropertyFilter(filter *propfilter.Filter) Option {
+	return func(a *aligner) {
+		a.propertyFilter = filter
+	}
+}
+
+// WithDeviceHierarchy enables creation of device assets, having thing assets of the device as children.
+func WithDeviceHierarchy(enabled bool) Option {
+	return func(a *aligner) {
+		a.deviceHierarchy = enabled
+	}
+}
+
+// WithAliasIndex writes the alias to thing property mapping of each aligned asset to the given index.
+func WithAliasIndex(index aliasindex.API) Option {
+	return func(a *aligner) {
+		a.aliasIndex = index
+	}
+}
+
+// WithThingOrganizations tags the assets created for things with their Arduino organization,
+// given by thing ID. Things without organization are not tagged.
+func WithThingOrganizations(organizations map[string]string) Option {
+	return func(a *aligner) {
+		a.thingOrganizations = organizations
+	}
+}
+
+// WithPruneOrphans enables deletion of SiteWise assets whose thing no longer exists on Arduino IoT Cloud.
+// Things passed to Align must be the complete set of things, otherwise assets of live things are deleted.
+func WithPruneOrphans(enabled bool) Option {
+	return func(a *aligner) {
+		a.pruneOrphans = enabled
+	}
+}
+
+// WithDuplicateAssetPolicy sets how assets with the same external id are resolved. Default is DuplicateAssetKeepLast.
+func WithDuplicateAssetPolicy(policy DuplicateAssetPolicy) Option {
+	return func(a *aligner) {
+		a.duplicateAssetPolicy = policy
+	}
+}
+
+// WithMinMaxAggregation adds to models and assets, for each numeric property, the properties holding
+// its min and max aggregations. See AggregatePropertyName.
+func WithMinMaxAggregation(enabled bool) Option {
+	return func(a *aligner) {
+		a.minMaxAggregation = enabled
+	}
+}
+
+// WithUpdatedAtProperties adds to models and assets, for each ON_CHANGE property, the property holding
+// the time its value last changed. See UpdatedAtPropertyName.
+func WithUpdatedAtProperties(enabled bool) Option {
+	return func(a *aligner) {
+		a.updatedAt = enabled
+	}
 }
 
-func New(sitewisecl sitewiseclient.API, logger *logrus.Entry) *aligner {
-	return &aligner{
-		sitewisecl: sitewisecl,
-		logger:     logger,
+// WithLocationCoordinates adds to models and assets, for each location property, the properties holding
+// its latitude and longitude. See LocationCoordinatePropertyName.
+func WithLocationCoordinates(enabled bool) Option {
+	return func(a *aligner) {
+		a.locationCoords = enabled
 	}
 }
 
+// WithModelPropertyLimit sets the maximum number of properties of an asset model and how things exceeding
+// it are handled. Default is DefaultMaxModelProperties with ModelPropertyLimitError.
+func WithModelPropertyLimit(maxProperties int, policy ModelPropertyLimitPolicy) Option {
+	return func(a *aligner) {
+		if maxProperties > 0 {
+			a.maxModelProperties = maxProperties
+		}
+		a.modelPropertyLimitPolicy = policy
+	}
+}
+
+// WithPollOptions sets how long to wait for created or updated models and assets to become active.
+// Default is sitewiseclient.DefaultPollOptions.
+func WithPollOptions(opts sitewiseclient.PollOptions) Option {
+	return func(a *aligner) {
+		a.pollOptions = opts
+	}
+}
+
+func New(sitewisecl sitewiseclient.API, logger *logrus.Entry, opts ...Option) *aligner {
+	a := &aligner{
+		sitewisecl:           sitewisecl,
+		logger:               logger,
+		alignParallelism:     defaultAlignParallelism,
+		pollOptions:          sitewiseclient.DefaultPollOptions,
+		duplicateAssetPolicy: DuplicateAssetKeepLast,
+
+		maxModelProperties:       DefaultMaxModelProperties,
+		modelPropertyLimitPolicy: ModelPropertyLimitError,
+
+		modelPropertyRemovalPolicy: ModelPropertyRemovalNone,
+		typeMismatchPolicy:         TypeMismatchLog,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
 func (a *aligner) Align(ctx context.Context, things []iotclient.ArduinoThing, propertyDefinitions map[string]iotclient.ArduinoPropertytype) []error {
 	a.logger.Infoln("=====> Aligning entities")
+	// Filtered out properties must not be part of models, so that model keys stay clean
+	things = a.propertyFilter.FilterThings(things)
+	// Properties sharing a name would collapse into the same model property and alias
+	things = a.withUniquePropertyNames(things)
+	if a.minMaxAggregation {
+		things = a.withAggregateProperties(things)
+	}
+	if a.updatedAt {
+		things = a.withUpdatedAtProperties(things)
+	}
+	if a.locationCoords {
+		things = a.withLocationCoordinateProperties(things)
+	}
+	// Left out before any change on SiteWise, rather than failing in the middle of models creation
+	things, limitErrs := a.checkModelPropertyLimit(things)
 	thingsMap := toThingMap(things)
+
 	uomMap := extractUomMap(propertyDefinitions)
-	models, modelDefinitions, err := a.getSiteWiseModels(ctx)
-	if err != nil {
-		return []error{err}
+	var models map[string]*string
+	var modelDefinitions map[string]*iotsitewise.DescribeAssetModelOutput
+	var assets map[string]assetDefintion
+	found := false
+	if a.canLookupByExternalId() {
+		var err error
+		models, modelDefinitions, assets, found, err = a.lookupByExternalId(ctx, things)
+		if err != nil {
+			return []error{err}
+		}
 	}
-	assets, err := a.getSiteWiseAssets(ctx, models)
-	if err != nil {
-		return []error{err}
+	if !found {
+		var err error
+		models, modelDefinitions, err = a.getSiteWiseModels(ctx)
+		if err != nil {
+			return []error{err}
+		}
+		assets, err = a.getSiteWiseAssets(ctx, models, thingsMap)
+		if err != nil {
+			return []error{err}
+		}
 	}
 
 	a.logger.Infoln("=====> Discovered models:")
@@ -66,6 +300,13 @@ func (a *aligner) Align(ctx context.Context, things []iotclient.ArduinoThing, pr
 		a.logger.Infoln("  Model ["+*v+"] - key:", k)
 	}
 
+	if a.modelPropertyRemovalPolicy == ModelPropertyRemovalUnused || a.modelPropertyRemovalPolicy == ModelPropertyRemovalAll {
+		a.logger.Infoln("=====> Removing properties dropped by things from models")
+		if errs := a.removeDroppedModelProperties(ctx, thingsMap, models, modelDefinitions, assets); len(errs) > 0 {
+			return errs
+		}
+	}
+
 	// Align model assests with things for new properties added
 	a.logger.Infoln("=====> Aligning already created models with things")
 	models, errs := a.alignAlreadyCreatedModels(ctx, thingsMap, models, modelDefinitions, assets, uomMap)
@@ -76,13 +317,39 @@ func (a *aligner) Align(ctx context.Context, things []iotclient.ArduinoThing, pr
 	// Align not discovered models
 	a.logger.Infoln("=====> Create newly discovered models")
 	models, errs = a.alignModels(ctx, things, models, uomMap)
-	if len(errs) > 0 {
-		return errs
+	for _, err := range errs {
+		if !errors.Is(err, errNoFreeModelName) {
+			return errs
+		}
 	}
+	// Things with no model are skipped by the assets alignment, and reported once the others are aligned
+	modelErrs := append(limitErrs, errs...)
 
 	// All models are created, now create assets. These can be done in parallel.
 	a.logger.Infoln("=====> Aligning and create assets")
-	return a.alignAssets(ctx, things, models, assets)
+	errs = a.alignAssets(ctx, things, models, assets)
+	if len(errs) > 0 {
+		return append(modelErrs, errs...)
+	}
+
+	if a.pruneOrphans {
+		a.logger.Infoln("=====> Pruning assets of deleted things")
+		errs = a.pruneOrphanAssets(ctx, thingsMap, assets)
+		if len(errs) > 0 {
+			return append(modelErrs, errs...)
+		}
+	}
+
+	if a.deviceHierarchy {
+		errs = a.alignDeviceHierarchy(ctx, things, models)
+		if len(errs) > 0 {
+			return append(modelErrs, errs...)
+		}
+	}
+	if len(modelErrs) > 0 {
+		return modelErrs
+	}
+	return nil
 }
 
 func (a *aligner) alignAlreadyCreatedModels(
@@ -94,7 +361,19 @@ func (a *aligner) alignAlreadyCreatedModels(
 	uomMap map[string][]string) (map[string]*string, []error) {
 
 	modelsToWait := []*string{}
+	// Things sharing a model, but not the type of a property, would flip its unit at every run
+	unitConflicts := a.conflictingModelProperties(assets, thingsMap, thingPropertiesMap)
+	for modelId, names := range unitConflicts {
+		a.logger.Warnln("Things of model ", modelId, " have different types for properties ", names, ". Their units are not updated.")
+	}
+	// Properties whose data type differs among the things of a model can't match all of them, recreating them
+	// with the data type of a thing would break the other ones
+	typeConflicts := a.conflictingModelProperties(assets, thingsMap, a.modelPropertyDataTypes)
+	for modelId, names := range typeConflicts {
+		a.logger.Errorln("Things of model ", modelId, " have different data types for properties ", names, ". They are not recreated: restore the same property types on all the things.")
+	}
 
+	var err error
 	for _, asset := range assets {
 		a.logger.Debugln("Asset: ", asset.assetId, " - model: ", asset.modelId, " - thing: ", asset.thingId)
 		// Get associated thing
@@ -112,6 +391,20 @@ func (a *aligner) alignAlreadyCreatedModels(
 			continue
 		}
 		if len(descModel.AssetModelProperties) > 0 {
+			// Models already updated by this run are checked on the next one
+			if !slices.ContainsFunc(modelsToWait, func(id *string) bool { return *id == asset.modelId }) {
+				aligned, changed, err := a.alignPropertyTypes(ctx, descModel, thing, uomMap, typeConflicts[asset.modelId])
+				if err != nil {
+					a.logger.Errorln("Error recreating model properties for asset: ", asset.assetId, err)
+					return models, []error{err}
+				}
+				if changed {
+					modelDefinitions[asset.modelId] = aligned
+					models[thingKey] = aligned.AssetModelId
+					modelsToWait = append(modelsToWait, aligned.AssetModelId)
+					continue
+				}
+			}
 			modelKey, ok := buildModelKeyFromModel(descModel)
 			if !ok {
 				continue
@@ -119,10 +412,13 @@ func (a *aligner) alignAlreadyCreatedModels(
 			// Check if model key is the same as thing key
 			if modelKey != thingKey && thingKey != "" && modelKey != "" {
 				if isThingContainedInModel(modelKey, thingKey) {
-					a.logger.Infoln("Thing is contained into given model, skipping model update. Model: ", descModel.AssetModelId, " - key: ", modelKey, " - thing: ", thing.Id)
+					a.logger.Infoln("Thing is contained into given model, skipping model properties update. Model: ", descModel.AssetModelId, " - key: ", modelKey, " - thing: ", thing.Id)
+					if modelsToWait, err = a.updateModelUnits(ctx, descModel, thing, uomMap, unitConflicts[asset.modelId], modelsToWait); err != nil {
+						return models, []error{err}
+					}
 				} else {
 					a.logger.Warnln("Model and thing are not aligned. Model(key): ", modelKey, " - Thing(key): ", thingKey)
-					err := a.sitewisecl.UpdateAssetModelProperties(ctx, descModel, thingPropertiesMap(thing), uomMap)
+					err := a.sitewisecl.UpdateAssetModelProperties(ctx, descModel, a.modelPropertyTypes(thing), uomMap)
 					if err != nil {
 						a.logger.Errorln("Error updating model properties for asset: ", asset.assetId, err)
 						return models, []error{err}
@@ -132,6 +428,8 @@ func (a *aligner) alignAlreadyCreatedModels(
 				}
 
 				models[thingKey] = descModel.AssetModelId
+			} else if modelsToWait, err = a.updateModelUnits(ctx, descModel, thing, uomMap, unitConflicts[asset.modelId], modelsToWait); err != nil {
+				return models, []error{err}
 			}
 			continue
 		} else {
@@ -145,10 +443,61 @@ func (a *aligner) alignAlreadyCreatedModels(
 	return models, nil
 }
 
+// updateModelUnits updates the model when units of its properties differ from the ones of the thing property types.
+// Models already updated, and waited for, are skipped, as well as the given conflicting properties.
+func (a *aligner) updateModelUnits(ctx context.Context, descModel *iotsitewise.DescribeAssetModelOutput, thing iotclient.ArduinoThing, uomMap map[string][]string, conflicts []string, modelsToWait []*string) ([]*string, error) {
+	props := thingPropertiesMap(thing)
+	for _, name := range conflicts {
+		delete(props, name)
+	}
+	if !sitewiseclient.AssetModelUnitsChanged(descModel, props, uomMap) {
+		return modelsToWait, nil
+	}
+	if slices.ContainsFunc(modelsToWait, func(id *string) bool { return *id == *descModel.AssetModelId }) {
+		return modelsToWait, nil
+	}
+	a.logger.Infoln("Units of model properties changed, updating model: ", *descModel.AssetModelId, " - thing: ", thing.Id)
+	if err := a.sitewisecl.UpdateAssetModelProperties(ctx, descModel, props, uomMap); err != nil {
+		a.logger.Errorln("Error updating units of model: ", *descModel.AssetModelId, err)
+		return modelsToWait, err
+	}
+	return append(modelsToWait, descModel.AssetModelId), nil
+}
+
+// conflictingModelProperties returns, by model id, the sorted names of the properties whose type differs
+// among the things of the assets of the model.
+func (a *aligner) conflictingModelProperties(assets map[string]assetDefintion, thingsMap map[string]iotclient.ArduinoThing, propertyTypes func(iotclient.ArduinoThing) map[string]string) map[string][]string {
+	modelTypes := make(map[string]map[string]string)
+	conflicts := make(map[string][]string)
+	for _, asset := range assets {
+		thing, ok := thingsMap[asset.thingId]
+		if !ok {
+			continue
+		}
+		if modelTypes[asset.modelId] == nil {
+			modelTypes[asset.modelId] = make(map[string]string)
+		}
+		for name, ptype := range propertyTypes(thing) {
+			known, ok := modelTypes[asset.modelId][name]
+			if !ok {
+				modelTypes[asset.modelId][name] = ptype
+				continue
+			}
+			if known != ptype && !slices.Contains(conflicts[asset.modelId], name) {
+				conflicts[asset.modelId] = append(conflicts[asset.modelId], name)
+			}
+		}
+	}
+	for _, names := range conflicts {
+		slices.Sort(names)
+	}
+	return conflicts
+}
+
 func (a *aligner) modelUpdater(ctx context.Context, modelsToWait []*string) {
 	if len(modelsToWait) > 0 {
 		var wg sync.WaitGroup
-		tokens := make(chan struct{}, alignParallelism)
+		tokens := make(chan struct{}, a.alignParallelism)
 
 		for _, modelId := range modelsToWait {
 
@@ -160,7 +509,9 @@ func (a *aligner) modelUpdater(ctx context.Context, modelsToWait []*string) {
 				defer wg.Done()
 
 				a.logger.Infof("Wait for model [%s] to be active...\n", mlId)
-				a.sitewisecl.PollForModelActiveStatus(ctx, mlId, waitTimeForSitewiseUpdate)
+				if err := a.sitewisecl.PollForModelActiveStatusWithOptions(ctx, mlId, a.pollOptions); err != nil {
+					a.logger.Warnf("Model [%s] not active: %v\n", mlId, err)
+				}
 			}(*modelId)
 		}
 
@@ -172,11 +523,10 @@ func (a *aligner) modelUpdater(ctx context.Context, modelsToWait []*string) {
 func (a *aligner) alignModels(ctx context.Context, things []iotclient.ArduinoThing, models map[string]*string, uomMap map[string][]string) (map[string]*string, []error) {
 	// Understand if there are models to create
 	modelsToWait := []*string{}
+	creations := &modelCreations{}
+	var errs []error
 	for _, thing := range things {
-		propsTypeMap := make(map[string]string, len(thing.Properties))
-		for _, prop := range thing.Properties {
-			propsTypeMap[prop.Name] = prop.Type
-		}
+		propsTypeMap := a.modelPropertyTypes(thing)
 
 		key := buildModelKeyFromMap(propsTypeMap)
 		a.logger.Debugln("Searching for model with key: ", key)
@@ -184,46 +534,140 @@ func (a *aligner) alignModels(ctx context.Context, things []iotclient.ArduinoThi
 		// Discover thing properties
 		_, ok := models[key]
 		if !ok {
-			a.logger.Infoln("Model not found for thing: ", thing.Id, thing.Name, ". Creating it.")
-			var createdModel *iotsitewise.CreateAssetModelOutput
-			var err error
-			var modelName string
-			for i := 0; i < 100; i++ {
-				modelName = composeModelName(thing.Name, i)
-				createdModel, err = a.sitewisecl.CreateAssetModel(ctx, modelName, propsTypeMap, uomMap)
+			modelId, created, err := creations.create(key, func() (*string, error) {
+				a.logger.Infoln("Model not found for thing: ", thing.Id, thing.Name, ". Creating it.")
+				name, err := a.nameTemplate.AssetName(thing)
 				if err != nil {
-					var errConflicc *types.ResourceAlreadyExistsException
-					if errors.As(err, &errConflicc) {
-						a.logger.Infoln("  Model already exists with the same name, retry")
-						continue
-					}
-					return models, []error{err}
+					return nil, fmt.Errorf("composing asset name of thing %s: %w", thing.Id, err)
 				}
-				// If model is created, exit the loop
-				break
+				return a.createModel(ctx, name, propsTypeMap, uomMap)
+			})
+			if errors.Is(err, errNoFreeModelName) {
+				// Other things can still be aligned
+				errs = append(errs, fmt.Errorf("thing %s: %w", thing.Id, err))
+				continue
 			}
-
-			modelsToWait = append(modelsToWait, createdModel.AssetModelId)
-			models[key] = createdModel.AssetModelId
+			if err != nil {
+				return models, []error{err}
+			}
+			if created {
+				modelsToWait = append(modelsToWait, modelId)
+			}
+			models[key] = modelId
 		}
 
 	}
 
 	a.modelUpdater(ctx, modelsToWait)
 
-	return models, nil
+	return models, errs
+}
+
+// createModel creates a model named after the thing, adding an increment to the name in case of conflicts.
+// The model external id is derived from its properties, see modelExternalId. If a model with the same
+// properties already holds it, that model is returned rather than creating another one.
+func (a *aligner) createModel(ctx context.Context, thingName string, propsTypeMap map[string]string, uomMap map[string][]string) (*string, error) {
+	key := buildModelKeyFromMap(propsTypeMap)
+	externalId := modelExternalId(key)
+	var modelName string
+	for i := 0; i < maxModelNameAttempts; i++ {
+		modelName = composeModelName(thingName, i)
+		var createdModel *iotsitewise.CreateAssetModelOutput
+		var err error
+		if len(propsTypeMap) > a.maxModelProperties {
+			createdModel, err = a.sitewisecl.CreateSplitAssetModel(ctx, modelName, externalId, propsTypeMap, a.maxModelProperties, uomMap)
+		} else {
+			createdModel, err = a.sitewisecl.CreateAssetModel(ctx, modelName, externalId, propsTypeMap, uomMap)
+		}
+		if err != nil {
+			var errConflicc *types.ResourceAlreadyExistsException
+			if !errors.As(err, &errConflicc) {
+				return nil, err
+			}
+			if externalId != "" {
+				existing, found, err := a.modelByExternalId(ctx, externalId)
+				if err != nil {
+					return nil, err
+				}
+				if found {
+					if existingKey, ok := buildModelKeyFromModel(existing); ok && existingKey == key {
+						a.logger.Infoln("  Model already exists with the same external id, using it: ", *existing.AssetModelId)
+						return existing.AssetModelId, nil
+					}
+					// The model holding the external id got other properties since its creation
+					a.logger.Infoln("  External id held by a model with other properties, creating model without it")
+					externalId = ""
+					i--
+					continue
+				}
+			}
+			a.logger.Infoln("  Model already exists with the same name, retry")
+			continue
+		}
+		return createdModel.AssetModelId, nil
+	}
+	a.logger.Errorln("No free model name found for thing: ", thingName, " - last attempted name: ", modelName)
+	return nil, fmt.Errorf("%w for %s, last attempted: %s", errNoFreeModelName, thingName, modelName)
+}
+
+// checkModelPropertyLimit detects things with more properties than an asset model can hold. Unless the
+// policy allows splitting their properties into composite models, they are left out of the returned things
+// and reported as SkippedThingError.
+func (a *aligner) checkModelPropertyLimit(things []iotclient.ArduinoThing) ([]iotclient.ArduinoThing, []error) {
+	var errs []error
+	kept := make([]iotclient.ArduinoThing, 0, len(things))
+	for _, thing := range things {
+		if len(thing.Properties) <= a.maxModelProperties {
+			kept = append(kept, thing)
+			continue
+		}
+		if a.modelPropertyLimitPolicy == ModelPropertyLimitComposite {
+			a.logger.Warnf("Thing %s (%s) has %d properties, above the limit of %d per model: exceeding properties go into composite models\n",
+				thing.Id, thing.Name, len(thing.Properties), a.maxModelProperties)
+			kept = append(kept, thing)
+			continue
+		}
+		errs = append(errs, &SkippedThingError{ThingID: thing.Id, Err: fmt.Errorf("thing %s (%s) has %d properties, above the limit of %d properties per asset model",
+			thing.Id, thing.Name, len(thing.Properties), a.maxModelProperties)})
+	}
+	return kept, errs
+}
+
+// indexAliases records the aliases associated to the asset. Index failures don't affect the alignment.
+func (a *aligner) indexAliases(ctx context.Context, thingId, assetId string, propsAliasMap map[string]string) {
+	if a.aliasIndex == nil {
+		return
+	}
+	entries := make([]aliasindex.Entry, 0, len(propsAliasMap))
+	for name, alias := range propsAliasMap {
+		entries = append(entries, aliasindex.Entry{
+			Alias:        alias,
+			ThingId:      thingId,
+			PropertyName: name,
+			AssetId:      assetId,
+		})
+	}
+	if err := a.aliasIndex.Put(ctx, entries); err != nil {
+		a.logger.Warnln("Error writing aliases to index for thing: ", thingId, err)
+	}
 }
 
 func (a *aligner) alignAssets(ctx context.Context, things []iotclient.ArduinoThing, models map[string]*string, assets map[string]assetDefintion) []error {
 	var wg sync.WaitGroup
-	tokens := make(chan struct{}, alignParallelism)
+	tokens := make(chan struct{}, a.alignParallelism)
 	errorChannel := make(chan error, len(things))
 
+	// Fail before associating any alias, rather than having things overwrite each other's associations
+	if err := checkAliasCollisions(things, a.aliasTemplate.Alias); err != nil {
+		a.logger.Errorln(err)
+		return []error{err}
+	}
+
 	for _, thing := range things {
 		propsAliasMap := make(map[string]string, len(thing.Properties))
 		propsTypeMap := make(map[string]string, len(thing.Properties))
 		for _, prop := range thing.Properties {
-			propsAliasMap[prop.Name] = PropertyAlias(thing.Id, prop.Name)
+			propsAliasMap[prop.Name] = a.aliasTemplate.Alias(thing, prop.Name)
 			propsTypeMap[prop.Name] = prop.Type
 		}
 
@@ -247,15 +691,30 @@ func (a *aligner) alignAssets(ctx context.Context, things []iotclient.ArduinoThi
 			defer func() { <-tokens }()
 			defer wg.Done()
 
+			assetName, err := a.nameTemplate.AssetName(thing)
+			if err != nil {
+				a.logger.Errorln("Error composing asset name for thing: ", thing.Id, thing.Name, err)
+				errorChannel <- err
+				return
+			}
+
 			var assetId *string
 			asset, ok := assets[thing.Id]
 			if ok {
 				a.logger.Debugln("Thing is already aligned, skipping creation. ID: ", thing.Id)
 				assetId = &asset.assetId
+				if asset.assetName != assetName {
+					a.logger.Infoln("Renaming asset for thing: ", thing.Id, " - from: ", asset.assetName, " - to: ", assetName)
+					if err := a.sitewisecl.UpdateAssetName(ctx, asset.assetId, assetName); err != nil {
+						a.logger.Errorln("Error renaming asset for thing: ", thing.Id, thing.Name, err)
+						errorChannel <- err
+						return
+					}
+				}
 			} else {
 				// Create asset
 				a.logger.Infoln("Creating asset for thing: ", thing.Id)
-				assetObj, err := a.sitewisecl.CreateAsset(ctx, thing.Name, modelIdentifier, thing.Id)
+				assetObj, err := a.sitewisecl.CreateAsset(ctx, assetName, modelIdentifier, thing.Id, a.assetTags(thing.Id))
 				if err != nil {
 					a.logger.Errorln("Error creating asset for thing: ", thing.Id, thing.Name, err)
 					errorChannel <- err
@@ -264,14 +723,18 @@ func (a *aligner) alignAssets(ctx context.Context, things []iotclient.ArduinoThi
 				assetId = assetObj.AssetId
 
 				// Wait for asset to be active before updating properties...
-				a.sitewisecl.PollForAssetActiveStatus(ctx, *assetId, waitTimeForSitewiseUpdate)
+				if err := a.sitewisecl.PollForAssetActiveStatusWithOptions(ctx, *assetId, a.pollOptions); err != nil {
+					a.logger.Warnf("Asset [%s] not active: %v\n", *assetId, err)
+				}
 			}
 
-			err := a.sitewisecl.UpdateAssetProperties(ctx, *assetId, propsAliasMap)
+			err = a.sitewisecl.UpdateAssetProperties(ctx, *assetId, propsAliasMap)
 			if err != nil {
 				a.logger.Errorln("Error updating asset properties for thing: ", thing.Id, thing.Name, err)
 				errorChannel <- err
+				return
 			}
+			a.indexAliases(ctx, thing.Id, *assetId, propsAliasMap)
 		}(*modelId)
 	}
 
@@ -307,86 +770,138 @@ func PropertyAlias(thingId, propertyName string) string {
 	return fmt.Sprintf("/%s/%s", thingId, propertyName)
 }
 
+// checkAliasCollisions returns an error if the alias function maps distinct thing properties to the same alias.
+func checkAliasCollisions(things []iotclient.ArduinoThing, alias func(thing iotclient.ArduinoThing, propertyName string) string) error {
+	type thingProperty struct {
+		thingId, name string
+	}
+	claims := make(map[string]thingProperty)
+	for _, thing := range things {
+		for _, prop := range thing.Properties {
+			propertyAlias := alias(thing, prop.Name)
+			claim, ok := claims[propertyAlias]
+			if ok && claim != (thingProperty{thing.Id, prop.Name}) {
+				return fmt.Errorf("alias collision: %s is produced by property %s of thing %s and property %s of thing %s",
+					propertyAlias, claim.name, claim.thingId, prop.Name, thing.Id)
+			}
+			claims[propertyAlias] = thingProperty{thing.Id, prop.Name}
+		}
+	}
+	return nil
+}
+
 type assetDefintion struct {
-	assetId string
-	modelId string
-	thingId string
+	assetId   string
+	assetName string
+	modelId   string
+	thingId   string
 }
 
-func (a *aligner) getSiteWiseAssets(ctx context.Context, models map[string]*string) (map[string]assetDefintion, error) {
-	discoveredAssets := make(map[string]assetDefintion)
+func (a *aligner) getSiteWiseAssets(ctx context.Context, models map[string]*string, thingsMap map[string]iotclient.ArduinoThing) (map[string]assetDefintion, error) {
+	candidates := make(map[string][]assetDefintion)
 	a.logger.Infoln("=====> Get SiteWise assets")
 	for _, modelId := range models {
-		next := true
-		var token *string
-		for next {
-			var assets *iotsitewise.ListAssetsOutput
-			var err error
-			if token == nil {
-				assets, err = a.sitewisecl.ListAssets(ctx, modelId)
-			} else {
-				assets, err = a.sitewisecl.ListAssetsNext(ctx, modelId, token)
-			}
-			if err != nil {
-				return nil, err
-			}
-			if assets.NextToken == nil {
-				next = false
-			} else {
-				token = assets.NextToken
+		assets, err := a.sitewisecl.ListAllAssetsForModel(ctx, modelId)
+		if err != nil {
+			return nil, err
+		}
+
+		// Discover assets. Keep only the one with externalId. ExternalId is mapped to thingId
+		for _, asset := range assets {
+			if asset.ExternalId != nil {
+				candidates[*asset.ExternalId] = append(candidates[*asset.ExternalId], assetDefintion{
+					assetId:   *asset.Id,
+					assetName: aws.ToString(asset.Name),
+					modelId:   *modelId,
+					thingId:   *asset.ExternalId,
+				})
 			}
+		}
+	}
+
+	discoveredAssets := make(map[string]assetDefintion, len(candidates))
+	for externalId, assets := range candidates {
+		if len(assets) > 1 {
+			discoveredAssets[externalId] = a.resolveDuplicateAssets(externalId, assets, models, thingsMap)
+		} else {
+			discoveredAssets[externalId] = assets[0]
+		}
+	}
+	return discoveredAssets, nil
+}
 
-			// Discover assets. Keep only the one with externalId. ExternalId is mapped to thingId
-			for _, asset := range assets.AssetSummaries {
-				if asset.ExternalId != nil {
-					discoveredAssets[*asset.ExternalId] = assetDefintion{
-						assetId: *asset.Id,
-						modelId: *modelId,
-						thingId: *asset.ExternalId,
+// resolveDuplicateAssets picks one of the assets sharing the same external id, according to the configured policy.
+func (a *aligner) resolveDuplicateAssets(externalId string, assets []assetDefintion, models map[string]*string, thingsMap map[string]iotclient.ArduinoThing) assetDefintion {
+	duplicates := make([]string, 0, len(assets))
+	for _, asset := range assets {
+		duplicates = append(duplicates, fmt.Sprintf("%s (model %s)", asset.assetId, asset.modelId))
+	}
+	a.logger.Warnf("Found %d assets with external id %s: %s\n", len(assets), externalId, strings.Join(duplicates, ", "))
+
+	selected := assets[len(assets)-1]
+	if a.duplicateAssetPolicy == DuplicateAssetPreferExpectedModel {
+		if thing, ok := thingsMap[externalId]; ok {
+			if expected, ok := models[buildModelKeyFromThing(thing)]; ok {
+				for _, asset := range assets {
+					if asset.modelId == *expected {
+						selected = asset
+						break
 					}
 				}
 			}
 		}
 	}
-	return discoveredAssets, nil
+	a.logger.Warnf("Using asset %s for external id %s\n", selected.assetId, externalId)
+	return selected
 }
 
 func (a *aligner) getSiteWiseModels(ctx context.Context) (map[string]*string, map[string]*iotsitewise.DescribeAssetModelOutput, error) {
 	discoveredModels := make(map[string]*string)
 	modelDefinitions := make(map[string]*iotsitewise.DescribeAssetModelOutput)
 	a.logger.Infoln("=====> Get SiteWise models")
-	next := true
-	var token *string
-	for next {
-		var models *iotsitewise.ListAssetModelsOutput
-		var err error
-		if token == nil {
-			models, err = a.sitewisecl.ListAssetModels(ctx)
-		} else {
-			models, err = a.sitewisecl.ListAssetModelsNext(ctx, token)
-		}
-		if err != nil {
-			return nil, nil, err
-		}
-		if models.NextToken == nil {
-			next = false
-		} else {
-			token = models.NextToken
-		}
+	models, err := a.sitewisecl.ListAllAssetModels(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
 
-		// Discover models
-		for _, model := range models.AssetModelSummaries {
-			descModel, err := a.sitewisecl.DescribeAssetModel(ctx, model.Id)
+	// Discover models, describing them in parallel. Descriptions are collected by position, so that models
+	// sharing a key are resolved in list order as when describing them one by one.
+	descriptions := make([]*iotsitewise.DescribeAssetModelOutput, len(models))
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var describeErr error
+	tokens := make(chan struct{}, a.alignParallelism)
+	for i, model := range models {
+		tokens <- struct{}{}
+		wg.Add(1)
+		go func(i int, modelId *string) {
+			defer func() { <-tokens }()
+			defer wg.Done()
+			descModel, err := a.sitewisecl.DescribeAssetModel(ctx, modelId)
 			if err != nil {
-				return nil, nil, err
+				mu.Lock()
+				if describeErr == nil {
+					describeErr = err
+				}
+				mu.Unlock()
+				return
 			}
-			modelDefinitions[*model.Id] = descModel
+			descriptions[i] = descModel
+		}(i, model.Id)
+	}
+	wg.Wait()
+	if describeErr != nil {
+		return nil, nil, describeErr
+	}
 
-			if len(descModel.AssetModelProperties) > 0 {
-				key, ok := buildModelKeyFromModel(descModel)
-				if ok {
-					discoveredModels[key] = model.Id
-				}
+	for i, model := range models {
+		descModel := descriptions[i]
+		modelDefinitions[*model.Id] = descModel
+
+		if len(descModel.AssetModelProperties) > 0 {
+			key, ok := buildModelKeyFromModel(descModel)
+			if ok {
+				discoveredModels[key] = model.Id
 			}
 		}
 	}
@@ -395,7 +910,7 @@ func (a *aligner) getSiteWiseModels(ctx context.Context) (map[string]*string, ma
 
 func buildModelKeyFromModel(descModel *iotsitewise.DescribeAssetModelOutput) (string, bool) {
 	props := make([]string, 0, len(descModel.AssetModelProperties))
-	for _, prop := range descModel.AssetModelProperties {
+	for _, prop := range sitewiseclient.ModelProperties(descModel) {
 		if prop.Type != nil && *prop.Name != "" && prop.Type.Measurement != nil { // Check if property is a measurement, not an aggregate
 			props = append(props, *prop.Name)
 		}
@@ -430,6 +945,15 @@ func buildModelKeyFromThing(thing iotclient.ArduinoThing) string {
 	return buildModelKeyFromMap(propsTypeMap)
 }
 
+// assetTags returns the tags of the asset created for the given thing, nil if none.
+func (a *aligner) assetTags(thingId string) map[string]string {
+	organization, ok := a.thingOrganizations[thingId]
+	if !ok || organization == "" {
+		return nil
+	}
+	return map[string]string{OrganizationTagKey: organization}
+}
+
 func toThingMap(things []iotclient.ArduinoThing) map[string]iotclient.ArduinoThing {
 	thingMap := make(map[string]iotclient.ArduinoThing, len(things))
 	for _, thing := range things {
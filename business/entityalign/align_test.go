@@ -17,10 +17,21 @@ package entityalign
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/arduino/aws-sitewise-integration/internal/aliasindex"
+	aliasIndexMocks "github.com/arduino/aws-sitewise-integration/internal/aliasindex/mocks"
+	"github.com/arduino/aws-sitewise-integration/internal/sitewiseclient"
 	sitewiseMocks "github.com/arduino/aws-sitewise-integration/internal/sitewiseclient/mocks"
 	iotclient "github.com/arduino/iot-client-go/v2"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/iotsitewise"
 	"github.com/aws/aws-sdk-go-v2/service/iotsitewise/types"
 	"github.com/sirupsen/logrus"
@@ -79,7 +90,7 @@ func TestAlign_AlignAlreadyCreatedModelsIfNeeded(t *testing.T) {
 	}
 
 	swclient.On("UpdateAssetModelProperties", ctx, mock.Anything, thingPropertiesMap(thingsMap[thingId]), mock.Anything).Return(nil)
-	swclient.On("PollForModelActiveStatus", ctx, modelId, mock.Anything).Return(true)
+	swclient.On("PollForModelActiveStatusWithOptions", ctx, modelId, mock.Anything).Return(nil)
 
 	models := make(map[string]*string)
 	models["temperature"] = toPtr(modelId)
@@ -191,10 +202,10 @@ func TestAlign_AlignModelsIfRequired(t *testing.T) {
 	}
 
 	// Create model
-	swclient.On("CreateAssetModel", ctx, "Thing Model from (thing1)", modelDefinitions, mock.Anything).Return(&iotsitewise.CreateAssetModelOutput{
+	swclient.On("CreateAssetModel", ctx, "Thing Model from (thing1)", mock.Anything, modelDefinitions, mock.Anything).Return(&iotsitewise.CreateAssetModelOutput{
 		AssetModelId: &modelId,
 	}, nil)
-	swclient.On("PollForModelActiveStatus", ctx, modelId, 15).Return(true)
+	swclient.On("PollForModelActiveStatusWithOptions", ctx, modelId, sitewiseclient.DefaultPollOptions).Return(nil)
 
 	models := make(map[string]*string) // Empty models
 	uomMap := make(map[string][]string)
@@ -205,6 +216,132 @@ func TestAlign_AlignModelsIfRequired(t *testing.T) {
 	assert.Equal(t, 1, len(models))
 }
 
+func TestAlign_ThingsSharingKeyCreateOneModel(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	modelId := "03ba45c2-eab3-44ed-a68f-94a26d41df4c"
+	swclient := sitewiseMocks.NewAPI(t)
+
+	things := []iotclient.ArduinoThing{}
+	for _, name := range []string{"thing1", "thing2"} {
+		things = append(things, iotclient.ArduinoThing{
+			Id:         name,
+			Name:       name,
+			Properties: []iotclient.ArduinoProperty{{Name: "temperature", Type: "INT"}},
+		})
+	}
+
+	swclient.On("CreateAssetModel", ctx, "Thing Model from (thing1)", mock.Anything, map[string]string{"temperature": "INT"}, mock.Anything).Return(&iotsitewise.CreateAssetModelOutput{
+		AssetModelId: &modelId,
+	}, nil).Once()
+	swclient.On("PollForModelActiveStatusWithOptions", ctx, modelId, sitewiseclient.DefaultPollOptions).Return(nil).Once()
+
+	models := make(map[string]*string)
+	aligner := New(swclient, logger)
+	_, errs := aligner.alignModels(ctx, things, models, make(map[string][]string))
+	assert.Nil(t, errs)
+	assert.Equal(t, 1, len(models))
+	assert.Equal(t, modelId, *models["temperature"])
+}
+
+func TestAlign_ModelNamesExhaustedSkipsThing(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+	modelId := "03ba45c2-eab3-44ed-a68f-94a26d41df4c"
+
+	things := []iotclient.ArduinoThing{
+		{Id: "thing1", Name: "thing1", Properties: []iotclient.ArduinoProperty{{Name: "temperature", Type: "INT"}}},
+		{Id: "thing2", Name: "thing2", Properties: []iotclient.ArduinoProperty{{Name: "pressure", Type: "INT"}}},
+	}
+
+	// All the names of the first model are taken
+	swclient := sitewiseMocks.NewAPI(t)
+	swclient.On("CreateAssetModel", ctx, mock.MatchedBy(func(name string) bool {
+		return strings.HasPrefix(name, "Thing Model from (thing1)")
+	}), mock.Anything, mock.Anything, mock.Anything).Return(nil, &types.ResourceAlreadyExistsException{}).Times(maxModelNameAttempts)
+	swclient.On("DescribeAssetModel", ctx, toPtr("externalId:"+modelExternalId("temperature"))).Return(nil, &types.ResourceNotFoundException{}).Times(maxModelNameAttempts)
+	swclient.On("CreateAssetModel", ctx, "Thing Model from (thing2)", mock.Anything, mock.Anything, mock.Anything).Return(&iotsitewise.CreateAssetModelOutput{
+		AssetModelId: &modelId,
+	}, nil).Once()
+	swclient.On("PollForModelActiveStatusWithOptions", ctx, modelId, sitewiseclient.DefaultPollOptions).Return(nil).Once()
+
+	models, errs := New(swclient, logger).alignModels(ctx, things, map[string]*string{}, nil)
+	if assert.Len(t, errs, 1) {
+		assert.ErrorIs(t, errs[0], errNoFreeModelName)
+		assert.Contains(t, errs[0].Error(), "Thing Model from (thing1) - 99")
+	}
+	assert.Equal(t, map[string]*string{"pressure": &modelId}, models)
+}
+
+func TestGetSiteWiseModels_parallelDescribes(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+	const modelCount, describeLatency = 50, 20 * time.Millisecond
+
+	swclient := sitewiseMocks.NewAPI(t)
+	summaries := make([]types.AssetModelSummary, 0, modelCount)
+	for i := 0; i < modelCount; i++ {
+		modelId := fmt.Sprintf("model-%d", i)
+		summaries = append(summaries, types.AssetModelSummary{Id: toPtr(modelId)})
+		swclient.On("DescribeAssetModel", ctx, toPtr(modelId)).Return(&iotsitewise.DescribeAssetModelOutput{
+			AssetModelId: toPtr(modelId),
+			AssetModelProperties: []types.AssetModelProperty{
+				{Name: toPtr(fmt.Sprintf("property_%d", i)), Type: &types.PropertyType{Measurement: &types.Measurement{}}},
+			},
+		}, nil).After(describeLatency).Once()
+	}
+	swclient.On("ListAllAssetModels", ctx).Return(summaries, nil).Once()
+
+	start := time.Now()
+	models, modelDefinitions, err := New(swclient, logger, WithAlignParallelism(10)).getSiteWiseModels(ctx)
+	elapsed := time.Since(start)
+	assert.NoError(t, err)
+	assert.Len(t, models, modelCount)
+	assert.Len(t, modelDefinitions, modelCount)
+	for i := 0; i < modelCount; i++ {
+		modelId := fmt.Sprintf("model-%d", i)
+		assert.Equal(t, modelId, *models[fmt.Sprintf("property_%d", i)])
+		assert.Equal(t, modelId, *modelDefinitions[modelId].AssetModelId)
+	}
+	// Describing one model at a time would take modelCount * describeLatency
+	t.Logf("described %d models in %v", modelCount, elapsed)
+	assert.Less(t, elapsed, modelCount*describeLatency/2)
+}
+
+func TestModelCreations_createOncePerKey(t *testing.T) {
+	creations := &modelCreations{}
+	modelId := "03ba45c2-eab3-44ed-a68f-94a26d41df4c"
+
+	var calls, createdCount atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id, created, err := creations.create("temperature", func() (*string, error) {
+				calls.Add(1)
+				return &modelId, nil
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, modelId, *id)
+			if created {
+				createdCount.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, int32(1), createdCount.Load())
+
+	// Creation errors are returned to all callers of the key
+	_, _, err := creations.create("pressure", func() (*string, error) { return nil, errors.New("failed") })
+	assert.EqualError(t, err, "failed")
+	_, created, err := creations.create("pressure", func() (*string, error) { return &modelId, nil })
+	assert.False(t, created)
+	assert.EqualError(t, err, "failed")
+}
+
 func TestAlign_AlignAssetsIfRequired(t *testing.T) {
 
 	ctx := context.Background()
@@ -242,9 +379,10 @@ func TestAlign_AlignAssetsIfRequired(t *testing.T) {
 
 	assetsDefinitions := make(map[string]assetDefintion)
 	assetsDefinitions[thingId] = assetDefintion{
-		assetId: "e9e11559-ceca-4c2f-875d-76c1068a45f4",
-		modelId: modelId,
-		thingId: thingId,
+		assetId:   "e9e11559-ceca-4c2f-875d-76c1068a45f4",
+		assetName: "thing1",
+		modelId:   modelId,
+		thingId:   thingId,
 	}
 
 	aligner := New(swclient, logger)
@@ -253,6 +391,113 @@ func TestAlign_AlignAssetsIfRequired(t *testing.T) {
 	assert.Equal(t, 1, len(models))
 }
 
+func TestLookupByExternalId(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	thingId := "bb831f04-0940-4ea6-9c24-83668e372919"
+	newThingId := "cc831f04-0940-4ea6-9c24-83668e372920"
+	modelId := "03ba45c2-eab3-44ed-a68f-94a26d41df4c"
+	assetId := "e9e11559-ceca-4c2f-875d-76c1068a45f4"
+	thing := iotclient.ArduinoThing{Id: thingId, Properties: []iotclient.ArduinoProperty{{Name: "temperature", Type: "INT"}}}
+	model := &iotsitewise.DescribeAssetModelOutput{
+		AssetModelId: &modelId,
+		AssetModelProperties: []types.AssetModelProperty{
+			{Name: toPtr("temperature"), Type: &types.PropertyType{Measurement: &types.Measurement{}}},
+		},
+	}
+
+	swclient := sitewiseMocks.NewAPI(t)
+	swclient.On("DescribeAsset", ctx, "externalId:"+thingId).Return(&iotsitewise.DescribeAssetOutput{
+		AssetId: &assetId, AssetName: toPtr("thing"), AssetModelId: &modelId, AssetExternalId: &thingId,
+	}, nil)
+	swclient.On("DescribeAssetModel", ctx, &modelId).Return(model, nil).Once()
+	swclient.On("DescribeAsset", ctx, "externalId:"+newThingId).Return(nil, &types.ResourceNotFoundException{})
+
+	aligner := New(swclient, logger, WithExternalIdLookup(true))
+	assert.True(t, aligner.canLookupByExternalId())
+	models, modelDefinitions, assets, found, err := aligner.lookupByExternalId(ctx, []iotclient.ArduinoThing{thing})
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, map[string]*string{"temperature": &modelId}, models)
+	assert.Equal(t, model, modelDefinitions[modelId])
+	assert.Equal(t, assetDefintion{assetId: assetId, assetName: "thing", modelId: modelId, thingId: thingId}, assets[thingId])
+
+	// Things without asset are resolved to the model with the external id of their properties
+	newThing := iotclient.ArduinoThing{Id: newThingId, Properties: thing.Properties}
+	swclient.On("DescribeAssetModel", ctx, toPtr("externalId:"+modelExternalId("temperature"))).Return(model, nil).Once()
+	models, _, assets, found, err = aligner.lookupByExternalId(ctx, []iotclient.ArduinoThing{newThing})
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, map[string]*string{"temperature": &modelId}, models)
+	assert.Empty(t, assets)
+
+	// Otherwise models are scanned
+	swclient.On("DescribeAssetModel", ctx, toPtr("externalId:"+modelExternalId("temperature"))).Return(nil, &types.ResourceNotFoundException{}).Once()
+	_, _, _, found, err = aligner.lookupByExternalId(ctx, []iotclient.ArduinoThing{newThing})
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	// Pruning needs the assets of all things
+	assert.False(t, New(swclient, logger, WithExternalIdLookup(true), WithPruneOrphans(true)).canLookupByExternalId())
+}
+
+func TestCreateModel_reusesModelWithExternalId(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+	modelId := "03ba45c2-eab3-44ed-a68f-94a26d41df4c"
+	externalId := modelExternalId("temperature")
+	properties := map[string]string{"temperature": "INT"}
+
+	// A model with the same properties created by an earlier run holds the external id
+	swclient := sitewiseMocks.NewAPI(t)
+	swclient.On("CreateAssetModel", ctx, "Thing Model from (thing1)", externalId, properties, mock.Anything).Return(nil, &types.ResourceAlreadyExistsException{}).Once()
+	swclient.On("DescribeAssetModel", ctx, toPtr("externalId:"+externalId)).Return(&iotsitewise.DescribeAssetModelOutput{
+		AssetModelId: &modelId,
+		AssetModelProperties: []types.AssetModelProperty{
+			{Name: toPtr("temperature"), Type: &types.PropertyType{Measurement: &types.Measurement{}}},
+		},
+	}, nil).Once()
+	created, err := New(swclient, logger).createModel(ctx, "thing1", properties, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, &modelId, created)
+
+	// The model holding it got other properties since, a model without external id is created
+	swclient = sitewiseMocks.NewAPI(t)
+	swclient.On("CreateAssetModel", ctx, "Thing Model from (thing1)", externalId, properties, mock.Anything).Return(nil, &types.ResourceAlreadyExistsException{}).Once()
+	swclient.On("DescribeAssetModel", ctx, toPtr("externalId:"+externalId)).Return(&iotsitewise.DescribeAssetModelOutput{
+		AssetModelId: toPtr("other"),
+		AssetModelProperties: []types.AssetModelProperty{
+			{Name: toPtr("pressure"), Type: &types.PropertyType{Measurement: &types.Measurement{}}},
+			{Name: toPtr("temperature"), Type: &types.PropertyType{Measurement: &types.Measurement{}}},
+		},
+	}, nil).Once()
+	swclient.On("CreateAssetModel", ctx, "Thing Model from (thing1)", "", properties, mock.Anything).Return(&iotsitewise.CreateAssetModelOutput{AssetModelId: &modelId}, nil).Once()
+	created, err = New(swclient, logger).createModel(ctx, "thing1", properties, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, &modelId, created)
+}
+
+func TestAlign_AliasCollisionsRejected(t *testing.T) {
+	things := []iotclient.ArduinoThing{
+		{Id: "bb831f04-0940-4ea6-9c24-83668e372919", Properties: []iotclient.ArduinoProperty{{Name: "temperature"}}},
+		{Id: "cc831f04-0940-4ea6-9c24-83668e372920", Properties: []iotclient.ArduinoProperty{{Name: "temperature"}}},
+	}
+
+	// Aliases namespaced by thing id never collide
+	var defaultAliases *AliasTemplate
+	assert.NoError(t, checkAliasCollisions(things, defaultAliases.Alias))
+
+	// A formatter ignoring the thing id maps both things on the same alias
+	byName := func(thing iotclient.ArduinoThing, propertyName string) string { return "/" + propertyName }
+	err := checkAliasCollisions(things, byName)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "/temperature")
+		assert.Contains(t, err.Error(), things[0].Id)
+		assert.Contains(t, err.Error(), things[1].Id)
+	}
+}
+
 func TestAlign_CreateAssetsWhenMissing(t *testing.T) {
 
 	ctx := context.Background()
@@ -283,10 +528,11 @@ func TestAlign_CreateAssetsWhenMissing(t *testing.T) {
 	alias["temperature"] = "/bb831f04-0940-4ea6-9c24-83668e372919/temperature"
 
 	// Create model
-	swclient.On("CreateAsset", ctx, "thing1", modelId, thingId).Return(&iotsitewise.CreateAssetOutput{
+	// Assets are tagged with the organization of their thing
+	swclient.On("CreateAsset", ctx, "thing1", modelId, thingId, map[string]string{OrganizationTagKey: "org1"}).Return(&iotsitewise.CreateAssetOutput{
 		AssetId: &assetId,
 	}, nil)
-	swclient.On("PollForAssetActiveStatus", ctx, "e9e11559-ceca-4c2f-875d-76c1068a45f4", 15).Return(true)
+	swclient.On("PollForAssetActiveStatusWithOptions", ctx, "e9e11559-ceca-4c2f-875d-76c1068a45f4", sitewiseclient.DefaultPollOptions).Return(nil)
 	swclient.On("UpdateAssetProperties", ctx, "e9e11559-ceca-4c2f-875d-76c1068a45f4", alias).Return(nil)
 
 	models := make(map[string]*string)
@@ -295,8 +541,909 @@ func TestAlign_CreateAssetsWhenMissing(t *testing.T) {
 	// No assests
 	assetsDefinitions := make(map[string]assetDefintion)
 
+	aligner := New(swclient, logger, WithThingOrganizations(map[string]string{thingId: "org1"}))
+	errs := aligner.alignAssets(ctx, things, models, assetsDefinitions)
+	assert.Nil(t, errs)
+	assert.Equal(t, 1, len(models))
+}
+
+func TestAlign_DeviceHierarchyCreatesDeviceModelAndAsset(t *testing.T) {
+
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	// Static id definitions
+	thingId := "bb831f04-0940-4ea6-9c24-83668e372919"
+	modelId := "03ba45c2-eab3-44ed-a68f-94a26d41df4c"
+	assetId := "e9e11559-ceca-4c2f-875d-76c1068a45f4"
+	deviceId := "5e6a0b25-7d58-4c36-8b2c-6f0e0d3c9a51"
+	deviceModelId := "1f6f4e0b-0c9e-4d6e-9a63-2a3f0ad0d6a2"
+	deviceAssetId := "8d1b2c3a-4e5f-4a6b-8c7d-9e0f1a2b3c4d"
+
+	// Mocks
+	swclient := sitewiseMocks.NewAPI(t)
+
+	things := []iotclient.ArduinoThing{
+		{
+			Id:         thingId,
+			Name:       "thing1",
+			DeviceId:   toPtr(deviceId),
+			DeviceName: toPtr("board"),
+		},
+	}
+	models := map[string]*string{"temperature": &modelId}
+
+	swclient.On("ListAllAssetsForModel", ctx, &modelId).Return([]types.AssetSummary{{Id: &assetId, ExternalId: &thingId}}, nil)
+	swclient.On("DescribeAssetModel", ctx, mock.MatchedBy(func(id *string) bool {
+		return *id == "externalId:"+deviceModelExternalId
+	})).Return(nil, &types.ResourceNotFoundException{Message: toPtr("not found")})
+	swclient.On("CreateHierarchyAssetModel", ctx, deviceModelName, deviceModelExternalId, mock.MatchedBy(func(h []types.AssetModelHierarchyDefinition) bool {
+		return len(h) == 1 && *h[0].ChildAssetModelId == modelId && *h[0].ExternalId == hierarchyExternalId(modelId)
+	})).Return(&iotsitewise.CreateAssetModelOutput{AssetModelId: &deviceModelId}, nil)
+	swclient.On("PollForModelActiveStatusWithOptions", ctx, deviceModelId, sitewiseclient.DefaultPollOptions).Return(nil)
+	swclient.On("DescribeAsset", ctx, "externalId:device-"+deviceId).Return(nil, &types.ResourceNotFoundException{Message: toPtr("not found")})
+	swclient.On("CreateAsset", ctx, "Device board ("+deviceId+")", deviceModelId, "device-"+deviceId, map[string]string(nil)).Return(&iotsitewise.CreateAssetOutput{
+		AssetId: &deviceAssetId,
+	}, nil)
+	swclient.On("PollForAssetActiveStatusWithOptions", ctx, deviceAssetId, sitewiseclient.DefaultPollOptions).Return(nil)
+	swclient.On("GetParentAssetId", ctx, assetId).Return(nil, nil)
+	swclient.On("AssociateAssets", ctx, deviceAssetId, "externalId:"+hierarchyExternalId(modelId), assetId).Return(nil).Once()
+
+	aligner := New(swclient, logger, WithDeviceHierarchy(true))
+	errs := aligner.alignDeviceHierarchy(ctx, things, models)
+	assert.Nil(t, errs)
+}
+
+func TestAlign_DeviceHierarchyAlreadyAligned(t *testing.T) {
+
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	// Static id definitions
+	thingId := "bb831f04-0940-4ea6-9c24-83668e372919"
+	modelId := "03ba45c2-eab3-44ed-a68f-94a26d41df4c"
+	assetId := "e9e11559-ceca-4c2f-875d-76c1068a45f4"
+	deviceId := "5e6a0b25-7d58-4c36-8b2c-6f0e0d3c9a51"
+	deviceModelId := "1f6f4e0b-0c9e-4d6e-9a63-2a3f0ad0d6a2"
+	deviceAssetId := "8d1b2c3a-4e5f-4a6b-8c7d-9e0f1a2b3c4d"
+
+	// Mocks
+	swclient := sitewiseMocks.NewAPI(t)
+
+	things := []iotclient.ArduinoThing{
+		{Id: thingId, Name: "thing1", DeviceId: toPtr(deviceId)},
+	}
+	models := map[string]*string{"temperature": &modelId}
+
+	swclient.On("ListAllAssetsForModel", ctx, &modelId).Return([]types.AssetSummary{{Id: &assetId, ExternalId: &thingId}}, nil)
+	swclient.On("DescribeAssetModel", ctx, mock.Anything).Return(&iotsitewise.DescribeAssetModelOutput{
+		AssetModelId: &deviceModelId,
+		AssetModelHierarchies: []types.AssetModelHierarchy{
+			{ChildAssetModelId: &modelId, ExternalId: toPtr(hierarchyExternalId(modelId))},
+		},
+	}, nil)
+	swclient.On("DescribeAsset", ctx, "externalId:device-"+deviceId).Return(&iotsitewise.DescribeAssetOutput{
+		AssetId: &deviceAssetId,
+	}, nil)
+	swclient.On("GetParentAssetId", ctx, assetId).Return(&deviceAssetId, nil)
+
+	aligner := New(swclient, logger, WithDeviceHierarchy(true))
+	errs := aligner.alignDeviceHierarchy(ctx, things, models)
+	assert.Nil(t, errs)
+}
+
+func TestAlign_AssetAliasesWrittenToIndex(t *testing.T) {
+
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	// Static id definitions
+	thingId := "bb831f04-0940-4ea6-9c24-83668e372919"
+	modelId := "03ba45c2-eab3-44ed-a68f-94a26d41df4c"
+	assetId := "e9e11559-ceca-4c2f-875d-76c1068a45f4"
+
+	// Mocks
+	swclient := sitewiseMocks.NewAPI(t)
+	index := aliasIndexMocks.NewAPI(t)
+
+	things := []iotclient.ArduinoThing{
+		{
+			Id:         thingId,
+			Name:       "thing1",
+			Properties: []iotclient.ArduinoProperty{{Name: "temperature", Type: "INT"}},
+		},
+	}
+
+	alias := map[string]string{"temperature": "/bb831f04-0940-4ea6-9c24-83668e372919/temperature"}
+	swclient.On("UpdateAssetProperties", ctx, assetId, alias).Return(nil)
+	index.On("Put", ctx, []aliasindex.Entry{
+		{
+			Alias:        "/bb831f04-0940-4ea6-9c24-83668e372919/temperature",
+			ThingId:      thingId,
+			PropertyName: "temperature",
+			AssetId:      assetId,
+		},
+	}).Return(nil).Once()
+
+	models := map[string]*string{"temperature": &modelId}
+	assetsDefinitions := map[string]assetDefintion{
+		thingId: {assetId: assetId, assetName: "thing1", modelId: modelId, thingId: thingId},
+	}
+
+	aligner := New(swclient, logger, WithAliasIndex(index))
+	errs := aligner.alignAssets(ctx, things, models, assetsDefinitions)
+	assert.Nil(t, errs)
+}
+
+func TestAlign_AliasIndexNotWrittenOnUpdateFailure(t *testing.T) {
+
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	// Static id definitions
+	thingId := "bb831f04-0940-4ea6-9c24-83668e372919"
+	modelId := "03ba45c2-eab3-44ed-a68f-94a26d41df4c"
+	assetId := "e9e11559-ceca-4c2f-875d-76c1068a45f4"
+
+	// Mocks
+	swclient := sitewiseMocks.NewAPI(t)
+	index := aliasIndexMocks.NewAPI(t)
+
+	things := []iotclient.ArduinoThing{
+		{
+			Id:         thingId,
+			Name:       "thing1",
+			Properties: []iotclient.ArduinoProperty{{Name: "temperature", Type: "INT"}},
+		},
+	}
+
+	swclient.On("UpdateAssetProperties", ctx, assetId, mock.Anything).Return(errors.New("update failed"))
+
+	models := map[string]*string{"temperature": &modelId}
+	assetsDefinitions := map[string]assetDefintion{
+		thingId: {assetId: assetId, assetName: "thing1", modelId: modelId, thingId: thingId},
+	}
+
+	aligner := New(swclient, logger, WithAliasIndex(index))
+	errs := aligner.alignAssets(ctx, things, models, assetsDefinitions)
+	assert.Len(t, errs, 1)
+	index.AssertNotCalled(t, "Put", mock.Anything, mock.Anything)
+}
+
+func TestAlign_PruneOrphanAssets(t *testing.T) {
+
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	// Static id definitions
+	liveThingId := "bb831f04-0940-4ea6-9c24-83668e372919"
+	deletedThingId := "6c0f7a3e-1d2b-4c5e-8f9a-0b1c2d3e4f5a"
+	modelId := "03ba45c2-eab3-44ed-a68f-94a26d41df4c"
+
+	// Mocks
+	swclient := sitewiseMocks.NewAPI(t)
+
+	thingsMap := map[string]iotclient.ArduinoThing{
+		liveThingId: {Id: liveThingId, Name: "thing1"},
+	}
+	assets := map[string]assetDefintion{
+		liveThingId:      {assetId: "live-asset", modelId: modelId, thingId: liveThingId},
+		deletedThingId:   {assetId: "orphan-asset", modelId: modelId, thingId: deletedThingId},
+		"manual-asset-1": {assetId: "manual-asset", modelId: modelId, thingId: "manual-asset-1"},
+	}
+
+	swclient.On("DisassociateFromParent", ctx, "orphan-asset", modelId).Return(nil).Once()
+	swclient.On("DeleteAsset", ctx, "orphan-asset").Return(nil).Once()
+
+	aligner := New(swclient, logger, WithPruneOrphans(true))
+	errs := aligner.pruneOrphanAssets(ctx, thingsMap, assets)
+	assert.Nil(t, errs)
+	swclient.AssertNotCalled(t, "DeleteAsset", ctx, "live-asset")
+	swclient.AssertNotCalled(t, "DeleteAsset", ctx, "manual-asset")
+}
+
+func TestAlign_DuplicateExternalIdAssets(t *testing.T) {
+
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	// Static id definitions
+	thingId := "bb831f04-0940-4ea6-9c24-83668e372919"
+	expectedModelId := "03ba45c2-eab3-44ed-a68f-94a26d41df4c"
+	otherModelId := "7a1d5e2f-3b4c-4d5e-9f6a-7b8c9d0e1f2a"
+	expectedAssetId := "e9e11559-ceca-4c2f-875d-76c1068a45f4"
+	otherAssetId := "2c3d4e5f-6a7b-4c8d-9e0f-1a2b3c4d5e6f"
+
+	// Mocks
+	swclient := sitewiseMocks.NewAPI(t)
+
+	thingsMap := map[string]iotclient.ArduinoThing{
+		thingId: {
+			Id:         thingId,
+			Name:       "thing1",
+			Properties: []iotclient.ArduinoProperty{{Name: "temperature", Type: "INT"}},
+		},
+	}
+	models := map[string]*string{
+		"temperature":          &expectedModelId,
+		"humidity,temperature": &otherModelId,
+	}
+
+	swclient.On("ListAllAssetsForModel", ctx, &expectedModelId).Return([]types.AssetSummary{{Id: &expectedAssetId, ExternalId: &thingId}}, nil)
+	swclient.On("ListAllAssetsForModel", ctx, &otherModelId).Return([]types.AssetSummary{{Id: &otherAssetId, ExternalId: &thingId}}, nil)
+
+	aligner := New(swclient, logger, WithDuplicateAssetPolicy(DuplicateAssetPreferExpectedModel))
+	// Models are visited in random order: repeat to make sure the resolution doesn't depend on it
+	for i := 0; i < 10; i++ {
+		assets, err := aligner.getSiteWiseAssets(ctx, models, thingsMap)
+		assert.Nil(t, err)
+		assert.Len(t, assets, 1)
+		assert.Equal(t, expectedAssetId, assets[thingId].assetId)
+		assert.Equal(t, expectedModelId, assets[thingId].modelId)
+	}
+
+	// Default policy keeps one of them
+	aligner = New(swclient, logger)
+	assets, err := aligner.getSiteWiseAssets(ctx, models, thingsMap)
+	assert.Nil(t, err)
+	assert.Len(t, assets, 1)
+	assert.Contains(t, []string{expectedAssetId, otherAssetId}, assets[thingId].assetId)
+}
+
+func TestAlign_RenameAssetWhenThingNameChanges(t *testing.T) {
+
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	// Static id definitions
+	thingId := "bb831f04-0940-4ea6-9c24-83668e372919"
+	modelId := "03ba45c2-eab3-44ed-a68f-94a26d41df4c"
+	assetId := "e9e11559-ceca-4c2f-875d-76c1068a45f4"
+
+	// Mocks
+	swclient := sitewiseMocks.NewAPI(t)
+
+	things := []iotclient.ArduinoThing{
+		{
+			Id:         thingId,
+			Name:       "renamed-thing",
+			Properties: []iotclient.ArduinoProperty{{Name: "temperature", Type: "INT"}},
+		},
+	}
+
+	swclient.On("UpdateAssetName", ctx, assetId, "renamed-thing").Return(nil).Once()
+	swclient.On("UpdateAssetProperties", ctx, assetId, mock.Anything).Return(nil)
+
+	models := map[string]*string{"temperature": &modelId}
+	assetsDefinitions := map[string]assetDefintion{
+		thingId: {assetId: assetId, assetName: "thing1", modelId: modelId, thingId: thingId},
+	}
+
 	aligner := New(swclient, logger)
 	errs := aligner.alignAssets(ctx, things, models, assetsDefinitions)
 	assert.Nil(t, errs)
+}
+
+func TestAlign_MinMaxAggregationCreatesAggregateProperties(t *testing.T) {
+
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	// Static id definitions
+	thingId := "bb831f04-0940-4ea6-9c24-83668e372919"
+	modelId := "03ba45c2-eab3-44ed-a68f-94a26d41df4c"
+
+	// Mocks
+	swclient := sitewiseMocks.NewAPI(t)
+
+	things := []iotclient.ArduinoThing{
+		{
+			Id:   thingId,
+			Name: "thing1",
+			Properties: []iotclient.ArduinoProperty{
+				{Name: "temperature", Type: "FLOAT"},
+				{Name: "on", Type: "STATUS"},
+				{Name: "msg", Type: "CHARSTRING"},
+			},
+		},
+	}
+
+	aligner := New(swclient, logger, WithMinMaxAggregation(true))
+	expanded := aligner.withAggregateProperties(things)
+
+	// Source things are not modified
+	assert.Len(t, things[0].Properties, 3)
+
+	swclient.On("CreateAssetModel", ctx, mock.Anything, mock.Anything, map[string]string{
+		"temperature":     "FLOAT",
+		"temperature_min": "FLOAT",
+		"temperature_max": "FLOAT",
+		"on":              "STATUS",
+		"msg":             "CHARSTRING",
+	}, mock.Anything).Return(&iotsitewise.CreateAssetModelOutput{AssetModelId: &modelId}, nil).Once()
+	swclient.On("PollForModelActiveStatusWithOptions", ctx, modelId, mock.Anything).Return(nil)
+
+	models, errs := aligner.alignModels(ctx, expanded, map[string]*string{}, nil)
+	assert.Nil(t, errs)
+	assert.Equal(t, &modelId, models["msg,on,temperature,temperature_max,temperature_min"])
+}
+
+func TestAlign_UpdatedAtPropertiesCreated(t *testing.T) {
+
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	// Static id definitions
+	thingId := "bb831f04-0940-4ea6-9c24-83668e372919"
+	modelId := "03ba45c2-eab3-44ed-a68f-94a26d41df4c"
+
+	// Mocks
+	swclient := sitewiseMocks.NewAPI(t)
+
+	things := []iotclient.ArduinoThing{
+		{
+			Id:   thingId,
+			Name: "thing1",
+			Properties: []iotclient.ArduinoProperty{
+				{Name: "temperature", Type: "FLOAT", UpdateStrategy: "TIMED"},
+				{Name: "on", Type: "STATUS", UpdateStrategy: "ON_CHANGE"},
+				// Already defined by the thing, not overridden
+				{Name: "msg", Type: "CHARSTRING", UpdateStrategy: "ON_CHANGE"},
+				{Name: "msg_updated_at", Type: "CHARSTRING", UpdateStrategy: "TIMED"},
+			},
+		},
+	}
+
+	aligner := New(swclient, logger, WithUpdatedAtProperties(true))
+	expanded := aligner.withUpdatedAtProperties(things)
+	assert.Len(t, things[0].Properties, 4)
+
+	swclient.On("CreateAssetModel", ctx, mock.Anything, mock.Anything, map[string]string{
+		"temperature":    "FLOAT",
+		"on":             "STATUS",
+		"on_updated_at":  "INT",
+		"msg":            "CHARSTRING",
+		"msg_updated_at": "CHARSTRING",
+	}, mock.Anything).Return(&iotsitewise.CreateAssetModelOutput{AssetModelId: &modelId}, nil).Once()
+	swclient.On("PollForModelActiveStatusWithOptions", ctx, modelId, mock.Anything).Return(nil)
+
+	models, errs := aligner.alignModels(ctx, expanded, map[string]*string{}, nil)
+	assert.Nil(t, errs)
+	assert.Equal(t, &modelId, models["msg,msg_updated_at,on,on_updated_at,temperature"])
+}
+
+func TestAlign_LocationCoordinateProperties(t *testing.T) {
+	logger := logrus.NewEntry(logrus.New())
+
+	things := []iotclient.ArduinoThing{
+		{
+			Id:   "bb831f04-0940-4ea6-9c24-83668e372919",
+			Name: "thing1",
+			Properties: []iotclient.ArduinoProperty{
+				{Name: "temperature", Type: "FLOAT"},
+				{Name: "position", Type: "LOCATION"},
+				// Already defined by the thing, not overridden
+				{Name: "position_lng", Type: "CHARSTRING"},
+			},
+		},
+	}
+
+	aligner := New(sitewiseMocks.NewAPI(t), logger, WithLocationCoordinates(true))
+	expanded := aligner.withLocationCoordinateProperties(things)
+	assert.Len(t, things[0].Properties, 3)
+	assert.Equal(t, []iotclient.ArduinoProperty{
+		{Name: "temperature", Type: "FLOAT"},
+		{Name: "position", Type: "LOCATION"},
+		{Name: "position_lng", Type: "CHARSTRING"},
+		{Name: "position_lat", Type: "FLOAT"},
+	}, expanded[0].Properties)
+}
+
+func TestAlign_ModelPropertyLimitExceeded(t *testing.T) {
+	logger := logrus.NewEntry(logrus.New())
+
+	swclient := sitewiseMocks.NewAPI(t)
+
+	things := []iotclient.ArduinoThing{
+		{
+			Id:   "bb831f04-0940-4ea6-9c24-83668e372919",
+			Name: "thing1",
+			Properties: []iotclient.ArduinoProperty{
+				{Name: "temperature", Type: "FLOAT"},
+				{Name: "pressure", Type: "FLOAT"},
+				{Name: "humidity", Type: "FLOAT"},
+			},
+		},
+		{
+			Id:         "cc831f04-0940-4ea6-9c24-83668e372920",
+			Name:       "thing2",
+			Properties: []iotclient.ArduinoProperty{{Name: "temperature", Type: "FLOAT"}},
+		},
+	}
+
+	// Only the thing over the limit is left out of the alignment
+	aligner := New(swclient, logger, WithModelPropertyLimit(2, ModelPropertyLimitError))
+	kept, errs := aligner.checkModelPropertyLimit(things)
+	assert.Equal(t, things[1:], kept)
+	if assert.Len(t, errs, 1) {
+		assert.EqualError(t, errs[0], "thing bb831f04-0940-4ea6-9c24-83668e372919 (thing1) has 3 properties, above the limit of 2 properties per asset model")
+		var skipped *SkippedThingError
+		assert.ErrorAs(t, errs[0], &skipped)
+		assert.Equal(t, "bb831f04-0940-4ea6-9c24-83668e372919", skipped.ThingID)
+	}
+}
+
+func TestAlign_ModelPropertyLimitSplitIntoCompositeModels(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	modelId := "03ba45c2-eab3-44ed-a68f-94a26d41df4c"
+	swclient := sitewiseMocks.NewAPI(t)
+
+	things := []iotclient.ArduinoThing{
+		{
+			Id:   "bb831f04-0940-4ea6-9c24-83668e372919",
+			Name: "thing1",
+			Properties: []iotclient.ArduinoProperty{
+				{Name: "temperature", Type: "FLOAT"},
+				{Name: "pressure", Type: "FLOAT"},
+				{Name: "humidity", Type: "FLOAT"},
+			},
+		},
+	}
+	properties := map[string]string{"temperature": "FLOAT", "pressure": "FLOAT", "humidity": "FLOAT"}
+
+	swclient.On("CreateSplitAssetModel", ctx, "Thing Model from (thing1)", mock.Anything, properties, 2, mock.Anything).Return(&iotsitewise.CreateAssetModelOutput{
+		AssetModelId: &modelId,
+	}, nil).Once()
+	swclient.On("PollForModelActiveStatusWithOptions", ctx, modelId, mock.Anything).Return(nil).Once()
+
+	aligner := New(swclient, logger, WithModelPropertyLimit(2, ModelPropertyLimitComposite))
+	kept, limitErrs := aligner.checkModelPropertyLimit(things)
+	assert.Empty(t, limitErrs)
+	assert.Equal(t, things, kept)
+	models, errs := aligner.alignModels(ctx, things, map[string]*string{}, nil)
+	assert.Nil(t, errs)
+	assert.Equal(t, &modelId, models["humidity,pressure,temperature"])
+}
+
+func TestBuildModelKeyFromModel_includesCompositeModelProperties(t *testing.T) {
+	measurement := &types.PropertyType{Measurement: &types.Measurement{}}
+	model := &iotsitewise.DescribeAssetModelOutput{
+		AssetModelProperties: []types.AssetModelProperty{{Name: toPtr("humidity"), Type: measurement}},
+		AssetModelCompositeModels: []types.AssetModelCompositeModel{
+			{Name: toPtr("properties_1"), Properties: []types.AssetModelProperty{{Name: toPtr("temperature"), Type: measurement}}},
+		},
+	}
+	key, ok := buildModelKeyFromModel(model)
+	assert.True(t, ok)
+	assert.Equal(t, "humidity,temperature", key)
+}
+
+func TestNew_alignParallelism(t *testing.T) {
+	logger := logrus.NewEntry(logrus.New())
+
+	assert.Equal(t, defaultAlignParallelism, New(nil, logger).alignParallelism)
+	assert.Equal(t, 2, New(nil, logger, WithAlignParallelism(2)).alignParallelism)
+	assert.Equal(t, defaultAlignParallelism, New(nil, logger, WithAlignParallelism(-1)).alignParallelism)
+}
+
+func TestUniquePropertyNames(t *testing.T) {
+	thing := iotclient.ArduinoThing{
+		Id: "bb831f04-0940-4ea6-9c24-83668e372919",
+		Properties: []iotclient.ArduinoProperty{
+			{Id: "p3", Name: "temperature", Type: "FLOAT"},
+			{Id: "p1", Name: "temperature", Type: "FLOAT"},
+			{Id: "p2", Name: "pressure", Type: "FLOAT"},
+			{Id: "p4", Name: "temperature", Type: "INT"},
+		},
+	}
+
+	unique, renamed := UniquePropertyNames(thing)
+	names := []string{}
+	for _, p := range unique.Properties {
+		names = append(names, p.Name)
+	}
+	// Lowest id keeps the name, whatever the order of properties
+	assert.Equal(t, []string{"temperature_p3", "temperature", "pressure", "temperature_p4"}, names)
+	assert.Equal(t, map[string][]string{"temperature": {"temperature_p3", "temperature_p4"}}, renamed)
+	assert.Equal(t, "temperature", thing.Properties[0].Name, "original thing must not be changed")
+
+	_, renamed = UniquePropertyNames(iotclient.ArduinoThing{Properties: []iotclient.ArduinoProperty{{Id: "p1", Name: "temperature"}}})
+	assert.Empty(t, renamed)
+}
+
+func TestAlign_DuplicatePropertyNamesGetOwnModelProperties(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	modelId := "03ba45c2-eab3-44ed-a68f-94a26d41df4c"
+	swclient := sitewiseMocks.NewAPI(t)
+
+	things := []iotclient.ArduinoThing{
+		{
+			Id:   "bb831f04-0940-4ea6-9c24-83668e372919",
+			Name: "thing1",
+			Properties: []iotclient.ArduinoProperty{
+				{Id: "p1", Name: "temperature", Type: "FLOAT"},
+				{Id: "p2", Name: "temperature", Type: "INT"},
+			},
+		},
+	}
+
+	swclient.On("CreateAssetModel", ctx, "Thing Model from (thing1)", mock.Anything, map[string]string{"temperature": "FLOAT", "temperature_p2": "INT"}, mock.Anything).Return(&iotsitewise.CreateAssetModelOutput{
+		AssetModelId: &modelId,
+	}, nil).Once()
+	swclient.On("PollForModelActiveStatusWithOptions", ctx, modelId, mock.Anything).Return(nil).Once()
+
+	aligner := New(swclient, logger)
+	models, errs := aligner.alignModels(ctx, aligner.withUniquePropertyNames(things), map[string]*string{}, nil)
+	assert.Nil(t, errs)
+	assert.Equal(t, &modelId, models["temperature,temperature_p2"])
+	assert.NoError(t, checkAliasCollisions(aligner.withUniquePropertyNames(things), aligner.aliasTemplate.Alias))
+}
+
+func TestParseNameTemplate(t *testing.T) {
+	thing := iotclient.ArduinoThing{Id: "bb831f04-0940-4ea6-9c24-83668e372919", Name: "thing1"}
+
+	tmpl, err := ParseNameTemplate("{{.Stack}}/factory-1/{{.ThingName}}", "prod")
+	assert.NoError(t, err)
+	name, err := tmpl.AssetName(thing)
+	assert.NoError(t, err)
+	assert.Equal(t, "prod/factory-1/thing1", name)
+
+	var noTemplate *NameTemplate
+	name, err = noTemplate.AssetName(thing)
+	assert.NoError(t, err)
+	assert.Equal(t, "thing1", name)
+
+	_, err = ParseNameTemplate("{{.ThingName", "prod")
+	assert.Error(t, err)
+	_, err = ParseNameTemplate("{{.DeviceName}}", "prod")
+	assert.Error(t, err)
+	_, err = ParseNameTemplate("{{if false}}{{.ThingName}}{{end}}", "prod")
+	assert.Error(t, err)
+}
+
+func TestParseAliasTemplate(t *testing.T) {
+	thing := iotclient.ArduinoThing{Id: "bb831f04-0940-4ea6-9c24-83668e372919", Name: "thing1"}
+
+	tmpl, err := ParseAliasTemplate("/factory-1/{{.ThingName}}/{{.PropertyName}}")
+	assert.NoError(t, err)
+	assert.Equal(t, "/factory-1/thing1/temperature", tmpl.Alias(thing, "temperature"))
+
+	var noTemplate *AliasTemplate
+	assert.Equal(t, PropertyAlias(thing.Id, "temperature"), noTemplate.Alias(thing, "temperature"))
+
+	// Prefixes namespace both templated and default aliases
+	assert.Equal(t, "/arduino/prod/factory-1/thing1/temperature", tmpl.WithPrefix("/arduino/prod/").Alias(thing, "temperature"))
+	assert.Equal(t, "/arduino/prod/bb831f04-0940-4ea6-9c24-83668e372919/temperature", noTemplate.WithPrefix("arduino/prod").Alias(thing, "temperature"))
+	assert.Nil(t, noTemplate.WithPrefix(" / "))
+	assert.Equal(t, tmpl, tmpl.WithPrefix(""))
+
+	_, err = ParseAliasTemplate("/{{.ThingName")
+	assert.Error(t, err)
+	_, err = ParseAliasTemplate("/{{.DeviceName}}/{{.PropertyName}}")
+	assert.Error(t, err)
+	_, err = ParseAliasTemplate("{{if false}}{{.PropertyName}}{{end}}")
+	assert.Error(t, err)
+}
+
+func TestAlign_AssetNamesFromTemplate(t *testing.T) {
+
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	// Static id definitions
+	thingId := "bb831f04-0940-4ea6-9c24-83668e372919"
+	renamedThingId := "2c7e0bd4-8c9c-4b6e-a5f5-33b8a6a2b0d1"
+	modelId := "03ba45c2-eab3-44ed-a68f-94a26d41df4c"
+	assetId := "e9e11559-ceca-4c2f-875d-76c1068a45f4"
+	renamedAssetId := "7c1b4f0e-6a2d-4d8e-9f3a-1b2c3d4e5f60"
+
+	// Mocks
+	swclient := sitewiseMocks.NewAPI(t)
+
+	things := []iotclient.ArduinoThing{
+		{
+			Id:         thingId,
+			Name:       "thing1",
+			Properties: []iotclient.ArduinoProperty{{Name: "temperature", Type: "INT"}},
+		},
+		{
+			Id:         renamedThingId,
+			Name:       "thing2",
+			Properties: []iotclient.ArduinoProperty{{Name: "temperature", Type: "INT"}},
+		},
+	}
+
+	swclient.On("CreateAsset", ctx, "prod/thing1", modelId, thingId, map[string]string(nil)).Return(&iotsitewise.CreateAssetOutput{
+		AssetId: &assetId,
+	}, nil)
+	swclient.On("PollForAssetActiveStatusWithOptions", ctx, assetId, sitewiseclient.DefaultPollOptions).Return(nil)
+	swclient.On("UpdateAssetName", ctx, renamedAssetId, "prod/thing2").Return(nil).Once()
+	swclient.On("UpdateAssetProperties", ctx, mock.Anything, mock.Anything).Return(nil)
+
+	models := map[string]*string{"temperature": &modelId}
+	assetsDefinitions := map[string]assetDefintion{
+		renamedThingId: {assetId: renamedAssetId, assetName: "thing2", modelId: modelId, thingId: renamedThingId},
+	}
+
+	tmpl, err := ParseNameTemplate("{{.Stack}}/{{.ThingName}}", "prod")
+	assert.NoError(t, err)
+	aligner := New(swclient, logger, WithNameTemplate(tmpl))
+	errs := aligner.alignAssets(ctx, things, models, assetsDefinitions)
+	assert.Nil(t, errs)
+}
+
+func TestAlign_RemoveDroppedModelProperties(t *testing.T) {
+
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	modelId := "03ba45c2-eab3-44ed-a68f-94a26d41df4c"
+	thing1 := iotclient.ArduinoThing{
+		Id:         "bb831f04-0940-4ea6-9c24-83668e372919",
+		Properties: []iotclient.ArduinoProperty{{Name: "temperature", Type: "FLOAT"}},
+	}
+	thing2 := iotclient.ArduinoThing{
+		Id:         "2c7e0bd4-8c9c-4b6e-a5f5-33b8a6a2b0d1",
+		Properties: []iotclient.ArduinoProperty{{Name: "temperature", Type: "FLOAT"}, {Name: "humidity", Type: "FLOAT"}},
+	}
+	measurement := &types.PropertyType{Measurement: &types.Measurement{}}
+	model := &iotsitewise.DescribeAssetModelOutput{
+		AssetModelId: &modelId,
+		AssetModelProperties: []types.AssetModelProperty{
+			{Id: aws.String("p1"), Name: aws.String("temperature"), Type: measurement},
+			{Id: aws.String("p2"), Name: aws.String("humidity"), Type: measurement},
+			{Id: aws.String("p3"), Name: aws.String("pressure"), Type: measurement},
+			{Id: aws.String("p4"), Name: aws.String("voltage"), Type: measurement},
+		},
+	}
+	updatedModel := &iotsitewise.DescribeAssetModelOutput{
+		AssetModelId: &modelId,
+		AssetModelProperties: []types.AssetModelProperty{
+			{Id: aws.String("p1"), Name: aws.String("temperature"), Type: measurement},
+			{Id: aws.String("p2"), Name: aws.String("humidity"), Type: measurement},
+			{Id: aws.String("p4"), Name: aws.String("voltage"), Type: measurement},
+		},
+	}
+	assets := map[string]assetDefintion{
+		thing1.Id: {assetId: "asset1", modelId: modelId, thingId: thing1.Id},
+		thing2.Id: {assetId: "asset2", modelId: modelId, thingId: thing2.Id},
+	}
+
+	swclient := sitewiseMocks.NewAPI(t)
+	// pressure holds no data, voltage holds data on asset2
+	swclient.On("GetLatestAssetPropertyValue", ctx, mock.Anything, "p3").Return(nil, time.Time{}, sitewiseclient.ErrNoValue)
+	swclient.On("GetLatestAssetPropertyValue", ctx, "asset1", "p4").Return(nil, time.Time{}, sitewiseclient.ErrNoValue)
+	swclient.On("GetLatestAssetPropertyValue", ctx, "asset2", "p4").Return(&types.Variant{DoubleValue: aws.Float64(3.3)}, time.Now(), nil)
+	swclient.On("RemoveAssetModelProperties", ctx, model, []string{"pressure"}).Return(nil).Once()
+	swclient.On("PollForModelActiveStatusWithOptions", ctx, modelId, sitewiseclient.DefaultPollOptions).Return(nil)
+	swclient.On("DescribeModel", ctx, modelId).Return(updatedModel, nil)
+
+	models := map[string]*string{"humidity,pressure,temperature,voltage": &modelId}
+	modelDefinitions := map[string]*iotsitewise.DescribeAssetModelOutput{modelId: model}
+
+	aligner := New(swclient, logger, WithModelPropertyRemoval(ModelPropertyRemovalUnused))
+	errs := aligner.removeDroppedModelProperties(ctx, toThingMap([]iotclient.ArduinoThing{thing1, thing2}), models, modelDefinitions, assets)
+	assert.Nil(t, errs)
+	assert.Equal(t, map[string]*string{"humidity,temperature,voltage": &modelId}, models)
+	assert.Equal(t, updatedModel, modelDefinitions[modelId])
+}
+
+func TestAlign_RemoveDroppedModelPropertiesSkipsModelsOfUnknownThings(t *testing.T) {
+
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	modelId := "03ba45c2-eab3-44ed-a68f-94a26d41df4c"
+	thing := iotclient.ArduinoThing{
+		Id:         "bb831f04-0940-4ea6-9c24-83668e372919",
+		Properties: []iotclient.ArduinoProperty{{Name: "temperature", Type: "FLOAT"}},
+	}
+	measurement := &types.PropertyType{Measurement: &types.Measurement{}}
+	model := &iotsitewise.DescribeAssetModelOutput{
+		AssetModelId: &modelId,
+		AssetModelProperties: []types.AssetModelProperty{
+			{Id: aws.String("p1"), Name: aws.String("temperature"), Type: measurement},
+			{Id: aws.String("p2"), Name: aws.String("humidity"), Type: measurement},
+		},
+	}
+	// Second asset belongs to a thing filtered out, which may still have humidity
+	assets := map[string]assetDefintion{
+		thing.Id:                               {assetId: "asset1", modelId: modelId, thingId: thing.Id},
+		"2c7e0bd4-8c9c-4b6e-a5f5-33b8a6a2b0d1": {assetId: "asset2", modelId: modelId, thingId: "2c7e0bd4-8c9c-4b6e-a5f5-33b8a6a2b0d1"},
+	}
+
+	// No calls expected
+	swclient := sitewiseMocks.NewAPI(t)
+
+	models := map[string]*string{"humidity,temperature": &modelId}
+	modelDefinitions := map[string]*iotsitewise.DescribeAssetModelOutput{modelId: model}
+
+	aligner := New(swclient, logger, WithModelPropertyRemoval(ModelPropertyRemovalAll))
+	errs := aligner.removeDroppedModelProperties(ctx, toThingMap([]iotclient.ArduinoThing{thing}), models, modelDefinitions, assets)
+	assert.Nil(t, errs)
+	assert.Equal(t, map[string]*string{"humidity,temperature": &modelId}, models)
+}
+
+func TestAlign_AlignedModelUpdatedOnUnitChange(t *testing.T) {
+
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	modelId := "03ba45c2-eab3-44ed-a68f-94a26d41df4c"
+	thing1 := iotclient.ArduinoThing{
+		Id:         "bb831f04-0940-4ea6-9c24-83668e372919",
+		Properties: []iotclient.ArduinoProperty{{Name: "temperature", Type: "TEMPERATURE_C"}},
+	}
+	thing2 := iotclient.ArduinoThing{
+		Id:         "2c7e0bd4-8c9c-4b6e-a5f5-33b8a6a2b0d1",
+		Properties: []iotclient.ArduinoProperty{{Name: "temperature", Type: "TEMPERATURE_C"}},
+	}
+	modelDefinitions := map[string]*iotsitewise.DescribeAssetModelOutput{
+		modelId: {
+			AssetModelId: &modelId,
+			AssetModelProperties: []types.AssetModelProperty{
+				{
+					Id:       toPtr("p1"),
+					DataType: types.PropertyDataTypeDouble,
+					Name:     toPtr("temperature"),
+					Type:     &types.PropertyType{Measurement: &types.Measurement{}},
+				},
+			},
+		},
+	}
+	assets := map[string]assetDefintion{
+		thing1.Id: {assetId: "asset1", modelId: modelId, thingId: thing1.Id},
+		thing2.Id: {assetId: "asset2", modelId: modelId, thingId: thing2.Id},
+	}
+	uomMap := map[string][]string{"TEMPERATURE_C": {"Cel"}}
+
+	// Model shared by both assets is updated once
+	swclient := sitewiseMocks.NewAPI(t)
+	swclient.On("UpdateAssetModelProperties", ctx, modelDefinitions[modelId], thingPropertiesMap(thing1), uomMap).Return(nil).Once()
+	swclient.On("PollForModelActiveStatusWithOptions", ctx, modelId, mock.Anything).Return(nil).Once()
+
+	models := map[string]*string{"temperature": toPtr(modelId)}
+
+	aligner := New(swclient, logger)
+	_, errs := aligner.alignAlreadyCreatedModels(ctx, toThingMap([]iotclient.ArduinoThing{thing1, thing2}), models, modelDefinitions, assets, uomMap)
+	assert.Nil(t, errs)
 	assert.Equal(t, 1, len(models))
 }
+
+func TestAlign_AlignedModelUnitsConflict(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	modelId := "03ba45c2-eab3-44ed-a68f-94a26d41df4c"
+	// Things sharing the model by property name, with different units
+	thing1 := iotclient.ArduinoThing{
+		Id:         "bb831f04-0940-4ea6-9c24-83668e372919",
+		Properties: []iotclient.ArduinoProperty{{Name: "temperature", Type: "TEMPERATURE_C"}},
+	}
+	thing2 := iotclient.ArduinoThing{
+		Id:         "2c7e0bd4-8c9c-4b6e-a5f5-33b8a6a2b0d1",
+		Properties: []iotclient.ArduinoProperty{{Name: "temperature", Type: "TEMPERATURE_F"}},
+	}
+	modelDefinitions := map[string]*iotsitewise.DescribeAssetModelOutput{
+		modelId: {
+			AssetModelId: &modelId,
+			AssetModelProperties: []types.AssetModelProperty{
+				{
+					Id:       toPtr("p1"),
+					DataType: types.PropertyDataTypeDouble,
+					Name:     toPtr("temperature"),
+					Type:     &types.PropertyType{Measurement: &types.Measurement{}},
+					Unit:     toPtr("Cel"),
+				},
+			},
+		},
+	}
+	assets := map[string]assetDefintion{
+		thing1.Id: {assetId: "asset1", modelId: modelId, thingId: thing1.Id},
+		thing2.Id: {assetId: "asset2", modelId: modelId, thingId: thing2.Id},
+	}
+	uomMap := map[string][]string{"TEMPERATURE_C": {"Cel"}, "TEMPERATURE_F": {"[degF]"}}
+
+	// No update expected: the mock fails on unexpected calls
+	swclient := sitewiseMocks.NewAPI(t)
+	models := map[string]*string{"temperature": toPtr(modelId)}
+
+	aligner := New(swclient, logger)
+	_, errs := aligner.alignAlreadyCreatedModels(ctx, toThingMap([]iotclient.ArduinoThing{thing1, thing2}), models, modelDefinitions, assets, uomMap)
+	assert.Nil(t, errs)
+	assert.Equal(t, map[string][]string{modelId: {"temperature"}}, aligner.conflictingModelProperties(assets, toThingMap([]iotclient.ArduinoThing{thing1, thing2}), thingPropertiesMap))
+}
+
+func TestAlign_PropertyTypeMismatch(t *testing.T) {
+
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	modelId := "03ba45c2-eab3-44ed-a68f-94a26d41df4c"
+	// Property changed from INT to CHARSTRING after the model creation
+	thing := iotclient.ArduinoThing{
+		Id:         "bb831f04-0940-4ea6-9c24-83668e372919",
+		Properties: []iotclient.ArduinoProperty{{Name: "status", Type: "CHARSTRING"}},
+	}
+	model := &iotsitewise.DescribeAssetModelOutput{
+		AssetModelId: &modelId,
+		AssetModelProperties: []types.AssetModelProperty{
+			{Id: toPtr("p1"), DataType: types.PropertyDataTypeDouble, Name: toPtr("status"), Type: &types.PropertyType{Measurement: &types.Measurement{}}},
+		},
+	}
+	recreated := &iotsitewise.DescribeAssetModelOutput{AssetModelId: &modelId}
+	assets := map[string]assetDefintion{thing.Id: {assetId: "asset1", modelId: modelId, thingId: thing.Id}}
+
+	t.Run("log", func(t *testing.T) {
+		// No calls expected
+		swclient := sitewiseMocks.NewAPI(t)
+		models := map[string]*string{"status": &modelId}
+		_, errs := New(swclient, logger).alignAlreadyCreatedModels(ctx, toThingMap([]iotclient.ArduinoThing{thing}), models,
+			map[string]*iotsitewise.DescribeAssetModelOutput{modelId: model}, assets, nil)
+		assert.Nil(t, errs)
+	})
+
+	t.Run("recreate", func(t *testing.T) {
+		swclient := sitewiseMocks.NewAPI(t)
+		swclient.On("RemoveAssetModelProperties", ctx, model, []string{"status"}).Return(nil).Once()
+		swclient.On("PollForModelActiveStatusWithOptions", ctx, modelId, mock.Anything).Return(nil).Twice()
+		swclient.On("DescribeAssetModel", ctx, &modelId).Return(recreated, nil).Once()
+		swclient.On("UpdateAssetModelProperties", ctx, recreated, thingPropertiesMap(thing), mock.Anything).Return(nil).Once()
+
+		models := map[string]*string{"status": &modelId}
+		modelDefinitions := map[string]*iotsitewise.DescribeAssetModelOutput{modelId: model}
+		_, errs := New(swclient, logger, WithTypeMismatchPolicy(TypeMismatchRecreate)).alignAlreadyCreatedModels(ctx,
+			toThingMap([]iotclient.ArduinoThing{thing}), models, modelDefinitions, assets, nil)
+		assert.Nil(t, errs)
+		assert.Equal(t, recreated, modelDefinitions[modelId])
+	})
+
+	t.Run("recreate with attributes", func(t *testing.T) {
+		withAttribute := thing
+		withAttribute.Properties = append(slices.Clone(thing.Properties),
+			iotclient.ArduinoProperty{Name: "serial", Type: "CHARSTRING", UpdateStrategy: "ON_CHANGE", Persist: aws.Bool(false)})
+		swclient := sitewiseMocks.NewAPI(t)
+		swclient.On("RemoveAssetModelProperties", ctx, model, []string{"status"}).Return(nil).Once()
+		swclient.On("PollForModelActiveStatusWithOptions", ctx, modelId, mock.Anything).Return(nil).Twice()
+		swclient.On("DescribeAssetModel", ctx, &modelId).Return(recreated, nil).Once()
+		// Properties are added again as defined in the model
+		swclient.On("UpdateAssetModelProperties", ctx, recreated, map[string]string{
+			"status": "CHARSTRING",
+			"serial": sitewiseclient.AttributeType("CHARSTRING"),
+		}, mock.Anything).Return(nil).Once()
+
+		models := map[string]*string{"status": &modelId}
+		_, errs := New(swclient, logger, WithTypeMismatchPolicy(TypeMismatchRecreate), WithAttributeProperties(true)).alignAlreadyCreatedModels(ctx,
+			toThingMap([]iotclient.ArduinoThing{withAttribute}), models, map[string]*iotsitewise.DescribeAssetModelOutput{modelId: model}, assets, nil)
+		assert.Nil(t, errs)
+	})
+
+	t.Run("conflicting types among things", func(t *testing.T) {
+		// Another thing of the model still has the INT property: no calls expected
+		other := iotclient.ArduinoThing{
+			Id:         "cc831f04-0940-4ea6-9c24-83668e372920",
+			Properties: []iotclient.ArduinoProperty{{Name: "status", Type: "INT"}},
+		}
+		swclient := sitewiseMocks.NewAPI(t)
+		models := map[string]*string{"status": &modelId}
+		bothAssets := map[string]assetDefintion{
+			thing.Id: {assetId: "asset1", modelId: modelId, thingId: thing.Id},
+			other.Id: {assetId: "asset2", modelId: modelId, thingId: other.Id},
+		}
+		_, errs := New(swclient, logger, WithTypeMismatchPolicy(TypeMismatchRecreate)).alignAlreadyCreatedModels(ctx,
+			toThingMap([]iotclient.ArduinoThing{thing, other}), models, map[string]*iotsitewise.DescribeAssetModelOutput{modelId: model}, bothAssets, nil)
+		assert.Nil(t, errs)
+	})
+}
+
+func TestParseTypeMismatchPolicy(t *testing.T) {
+	policy, err := ParseTypeMismatchPolicy("recreate")
+	assert.NoError(t, err)
+	assert.Equal(t, TypeMismatchRecreate, policy)
+	_, err = ParseTypeMismatchPolicy("Recreate")
+	assert.Error(t, err)
+}
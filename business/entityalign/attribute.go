@@ -0,0 +1,45 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package entityalign
+
+import (
+	"github.com/arduino/aws-sitewise-integration/internal/iot"
+	"github.com/arduino/aws-sitewise-integration/internal/sitewiseclient"
+	iotclient "github.com/arduino/iot-client-go/v2"
+)
+
+// WithAttributeProperties defines the properties of models holding static thing properties, such as device
+// configurations, as attributes rather than measurements. See iot.IsAttributeProperty.
+// Model properties already defined as measurements are left as they are.
+func WithAttributeProperties(enabled bool) Option {
+	return func(a *aligner) {
+		a.attributeProperties = enabled
+	}
+}
+
+// modelPropertyTypes returns the types of the thing properties by name, as defined in its model
+func (a *aligner) modelPropertyTypes(thing iotclient.ArduinoThing) map[string]string {
+	props := thingPropertiesMap(thing)
+	if !a.attributeProperties {
+		return props
+	}
+	for _, prop := range thing.Properties {
+		if iot.IsAttributeProperty(prop) {
+			props[prop.Name] = sitewiseclient.AttributeType(prop.Type)
+		}
+	}
+	return props
+}
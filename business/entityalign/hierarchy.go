@@ -0,0 +1,200 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package entityalign
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	iotclient "github.com/arduino/iot-client-go/v2"
+	"github.com/aws/aws-sdk-go-v2/service/iotsitewise"
+	"github.com/aws/aws-sdk-go-v2/service/iotsitewise/types"
+)
+
+const (
+	deviceModelName       = "Arduino Device Model"
+	deviceModelExternalId = "arduino-device-model"
+	externalIdReference   = "externalId:"
+)
+
+func deviceAssetExternalId(deviceId string) string {
+	return "device-" + deviceId
+}
+
+// Device model has a hierarchy for each thing model, identified by an external id derived from the child model id
+func hierarchyExternalId(thingModelId string) string {
+	return "things-" + thingModelId
+}
+
+func isNotFound(err error) bool {
+	var errNotFound *types.ResourceNotFoundException
+	return errors.As(err, &errNotFound)
+}
+
+// alignDeviceHierarchy creates a device asset for every Arduino device hosting imported things,
+// associating thing assets as children of their device asset.
+func (a *aligner) alignDeviceHierarchy(ctx context.Context, things []iotclient.ArduinoThing, models map[string]*string) []error {
+	thingsByDevice := make(map[string][]iotclient.ArduinoThing)
+	deviceNames := make(map[string]string)
+	for _, thing := range things {
+		if thing.DeviceId == nil || *thing.DeviceId == "" {
+			continue
+		}
+		thingsByDevice[*thing.DeviceId] = append(thingsByDevice[*thing.DeviceId], thing)
+		if thing.DeviceName != nil && *thing.DeviceName != "" {
+			deviceNames[*thing.DeviceId] = *thing.DeviceName
+		}
+	}
+	if len(thingsByDevice) == 0 {
+		return nil
+	}
+
+	a.logger.Infoln("=====> Aligning device hierarchy")
+	assets, err := a.getSiteWiseAssets(ctx, models, toThingMap(things))
+	if err != nil {
+		return []error{err}
+	}
+
+	// Every thing model needs an hierarchy on the device model
+	childModels := make(map[string]bool)
+	for _, asset := range assets {
+		childModels[asset.modelId] = true
+	}
+	deviceModelId, err := a.alignDeviceModel(ctx, childModels)
+	if err != nil {
+		return []error{err}
+	}
+
+	errs := []error{}
+	for deviceId, deviceThings := range thingsByDevice {
+		deviceAssetId, err := a.alignDeviceAsset(ctx, deviceId, deviceNames[deviceId], deviceModelId, a.assetTags(deviceThings[0].Id))
+		if err != nil {
+			a.logger.Errorln("Error aligning asset for device: ", deviceId, err)
+			errs = append(errs, err)
+			continue
+		}
+
+		for _, thing := range deviceThings {
+			asset, ok := assets[thing.Id]
+			if !ok {
+				a.logger.Debugln("Asset not found for thing: ", thing.Id, ". Skipping association.")
+				continue
+			}
+			parentId, err := a.sitewisecl.GetParentAssetId(ctx, asset.assetId)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if parentId != nil {
+				if *parentId != deviceAssetId {
+					a.logger.Warnln("Thing asset ", asset.assetId, " is associated to another parent asset: ", *parentId, ". Skipping association with device ", deviceId)
+				}
+				continue
+			}
+			a.logger.Infoln("Associating thing asset ", asset.assetId, " to device asset ", deviceAssetId)
+			err = a.sitewisecl.AssociateAssets(ctx, deviceAssetId, externalIdReference+hierarchyExternalId(asset.modelId), asset.assetId)
+			if err != nil {
+				a.logger.Errorln("Error associating thing asset to device asset: ", asset.assetId, err)
+				errs = append(errs, err)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// alignDeviceModel makes sure the device model exists and has an hierarchy for every given thing model
+func (a *aligner) alignDeviceModel(ctx context.Context, childModels map[string]bool) (string, error) {
+	deviceModel, err := a.sitewisecl.DescribeAssetModel(ctx, strPtr(externalIdReference+deviceModelExternalId))
+	if err != nil && !isNotFound(err) {
+		return "", err
+	}
+
+	existingHierarchies := make(map[string]bool)
+	if deviceModel != nil {
+		for _, h := range deviceModel.AssetModelHierarchies {
+			if h.ChildAssetModelId != nil {
+				existingHierarchies[*h.ChildAssetModelId] = true
+			}
+		}
+	}
+	missing := []types.AssetModelHierarchyDefinition{}
+	for modelId := range childModels {
+		if !existingHierarchies[modelId] {
+			missing = append(missing, types.AssetModelHierarchyDefinition{
+				Name:              strPtr(fmt.Sprintf("Things (%s)", modelId)),
+				ChildAssetModelId: strPtr(modelId),
+				ExternalId:        strPtr(hierarchyExternalId(modelId)),
+			})
+		}
+	}
+
+	var deviceModelId string
+	if deviceModel == nil {
+		a.logger.Infoln("Creating device model")
+		var created *iotsitewise.CreateAssetModelOutput
+		created, err = a.sitewisecl.CreateHierarchyAssetModel(ctx, deviceModelName, deviceModelExternalId, missing)
+		if err != nil {
+			return "", err
+		}
+		deviceModelId = *created.AssetModelId
+	} else {
+		deviceModelId = *deviceModel.AssetModelId
+		if len(missing) == 0 {
+			return deviceModelId, nil
+		}
+		a.logger.Infoln("Adding ", len(missing), " hierarchies to device model")
+		if err = a.sitewisecl.AddAssetModelHierarchies(ctx, deviceModel, missing); err != nil {
+			return "", err
+		}
+	}
+
+	a.modelUpdater(ctx, []*string{&deviceModelId})
+	return deviceModelId, nil
+}
+
+// alignDeviceAsset returns the asset of the given device, creating it with the given tags if it doesn't exist yet
+func (a *aligner) alignDeviceAsset(ctx context.Context, deviceId, deviceName, deviceModelId string, tags map[string]string) (string, error) {
+	externalId := deviceAssetExternalId(deviceId)
+	asset, err := a.sitewisecl.DescribeAsset(ctx, externalIdReference+externalId)
+	if err == nil {
+		return *asset.AssetId, nil
+	}
+	if !isNotFound(err) {
+		return "", err
+	}
+
+	name := fmt.Sprintf("Device (%s)", deviceId)
+	if deviceName != "" {
+		name = fmt.Sprintf("Device %s (%s)", deviceName, deviceId)
+	}
+	a.logger.Infoln("Creating asset for device: ", deviceId)
+	created, err := a.sitewisecl.CreateAsset(ctx, name, deviceModelId, externalId, tags)
+	if err != nil {
+		return "", err
+	}
+	if err := a.sitewisecl.PollForAssetActiveStatusWithOptions(ctx, *created.AssetId, a.pollOptions); err != nil {
+		a.logger.Warnf("Device asset [%s] not active: %v\n", *created.AssetId, err)
+	}
+	return *created.AssetId, nil
+}
+
+func strPtr(val string) *string {
+	return &val
+}
@@ -0,0 +1,70 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package entityalign
+
+import (
+	"slices"
+
+	"github.com/arduino/aws-sitewise-integration/internal/iot"
+	iotclient "github.com/arduino/iot-client-go/v2"
+)
+
+// Coordinates of location properties
+const (
+	LocationLatitude  = "lat"
+	LocationLongitude = "lng"
+)
+
+// LocationCoordinates are the coordinates of location properties imported as additional numeric properties.
+var LocationCoordinates = []string{LocationLatitude, LocationLongitude}
+
+// Type of the properties holding location coordinates
+const locationCoordinatePropertyType = "FLOAT"
+
+// LocationCoordinatePropertyName returns the name of the property holding the given coordinate of a location property.
+func LocationCoordinatePropertyName(propertyName, coordinate string) string {
+	return propertyName + "_" + coordinate
+}
+
+// withLocationCoordinateProperties returns copies of things having, for each location property, an additional
+// property per coordinate. These become regular properties of models and assets.
+func (a *aligner) withLocationCoordinateProperties(things []iotclient.ArduinoThing) []iotclient.ArduinoThing {
+	expanded := make([]iotclient.ArduinoThing, 0, len(things))
+	for _, thing := range things {
+		names := make([]string, 0, len(thing.Properties))
+		for _, prop := range thing.Properties {
+			names = append(names, prop.Name)
+		}
+
+		properties := slices.Clone(thing.Properties)
+		for _, prop := range thing.Properties {
+			if !iot.IsPropertyLocation(prop.Type) {
+				continue
+			}
+			for _, coordinate := range LocationCoordinates {
+				name := LocationCoordinatePropertyName(prop.Name, coordinate)
+				if slices.Contains(names, name) {
+					a.logger.Warnln("Thing ", thing.Id, " already has property ", name, ", not importing ", coordinate, " of ", prop.Name)
+					continue
+				}
+				properties = append(properties, iotclient.ArduinoProperty{Name: name, Type: locationCoordinatePropertyType})
+			}
+		}
+		thing.Properties = properties
+		expanded = append(expanded, thing)
+	}
+	return expanded
+}
@@ -0,0 +1,119 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package entityalign
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	iotclient "github.com/arduino/iot-client-go/v2"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iotsitewise"
+)
+
+// Prefix of the external ids of thing models
+const modelExternalIdPrefix = "thing-model-"
+
+// modelExternalId returns the external id of the model created for the given model key. Being derived from the
+// property set, it identifies the model as long as its properties don't change. Models created by versions
+// setting no external id are found by scanning models only.
+func modelExternalId(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return modelExternalIdPrefix + hex.EncodeToString(sum[:])
+}
+
+// modelByExternalId describes the model with the given external id, returning false if there is none.
+func (a *aligner) modelByExternalId(ctx context.Context, externalId string) (*iotsitewise.DescribeAssetModelOutput, bool, error) {
+	model, err := a.sitewisecl.DescribeAssetModel(ctx, aws.String(externalIdReference+externalId))
+	if isNotFound(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return model, true, nil
+}
+
+// WithExternalIdLookup resolves the models and assets of the things being aligned by asset external id,
+// in a call per thing, rather than describing all the models of the account. It applies when all the things
+// have an asset whose model matches their properties, as in incremental runs on known things. Otherwise, and
+// when pruning orphans, removing model properties or aligning the device hierarchy, all models are scanned.
+func WithExternalIdLookup(enabled bool) Option {
+	return func(a *aligner) {
+		a.externalIdLookup = enabled
+	}
+}
+
+// canLookupByExternalId reports whether the alignment can do without the models and assets of other things
+func (a *aligner) canLookupByExternalId() bool {
+	return a.externalIdLookup && !a.pruneOrphans && !a.deviceHierarchy && a.modelPropertyRemovalPolicy == ModelPropertyRemovalNone
+}
+
+// lookupByExternalId returns the models and assets of the given things, looking up assets by external id.
+// Things with no asset yet are resolved to the model with the external id of their properties, if any.
+// It returns false if any thing can't be resolved, or has an asset whose model doesn't match its properties.
+func (a *aligner) lookupByExternalId(ctx context.Context, things []iotclient.ArduinoThing) (map[string]*string, map[string]*iotsitewise.DescribeAssetModelOutput, map[string]assetDefintion, bool, error) {
+	models := make(map[string]*string)
+	modelDefinitions := make(map[string]*iotsitewise.DescribeAssetModelOutput)
+	assets := make(map[string]assetDefintion, len(things))
+	for _, thing := range things {
+		asset, err := a.sitewisecl.DescribeAsset(ctx, externalIdReference+thing.Id)
+		if isNotFound(err) {
+			key := buildModelKeyFromThing(thing)
+			model, found, err := a.modelByExternalId(ctx, modelExternalId(key))
+			if err != nil {
+				return nil, nil, nil, false, err
+			}
+			if !found {
+				a.logger.Infoln("No asset nor model found by external id for thing: ", thing.Id, ". Scanning models.")
+				return nil, nil, nil, false, nil
+			}
+			if modelKey, ok := buildModelKeyFromModel(model); !ok || modelKey != key {
+				a.logger.Infoln("Model found by external id doesn't match properties of thing: ", thing.Id, ". Scanning models.")
+				return nil, nil, nil, false, nil
+			}
+			models[key] = model.AssetModelId
+			modelDefinitions[aws.ToString(model.AssetModelId)] = model
+			continue
+		}
+		if err != nil {
+			return nil, nil, nil, false, err
+		}
+		modelId := aws.ToString(asset.AssetModelId)
+		descModel, ok := modelDefinitions[modelId]
+		if !ok {
+			descModel, err = a.sitewisecl.DescribeAssetModel(ctx, asset.AssetModelId)
+			if err != nil {
+				return nil, nil, nil, false, err
+			}
+			modelDefinitions[modelId] = descModel
+		}
+		key, ok := buildModelKeyFromModel(descModel)
+		if !ok || key != buildModelKeyFromThing(thing) {
+			a.logger.Infoln("Model of asset doesn't match properties of thing: ", thing.Id, ". Scanning models.")
+			return nil, nil, nil, false, nil
+		}
+		models[key] = asset.AssetModelId
+		assets[thing.Id] = assetDefintion{
+			assetId:   aws.ToString(asset.AssetId),
+			assetName: aws.ToString(asset.AssetName),
+			modelId:   modelId,
+			thingId:   thing.Id,
+		}
+	}
+	return models, modelDefinitions, assets, true, nil
+}
@@ -0,0 +1,42 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package entityalign
+
+import "sync"
+
+// modelCreations guards models creation, so that each model key is created exactly once,
+// even when things sharing the same key are aligned concurrently.
+type modelCreations struct {
+	byKey sync.Map // model key -> *modelCreation
+}
+
+type modelCreation struct {
+	once    sync.Once
+	modelId *string
+	err     error
+}
+
+// create runs createFn once per key. Calls for a key already being created wait for the first one
+// and return its result. created reports whether createFn was run by this call.
+func (m *modelCreations) create(key string, createFn func() (*string, error)) (modelId *string, created bool, err error) {
+	v, _ := m.byKey.LoadOrStore(key, &modelCreation{})
+	c := v.(*modelCreation)
+	c.once.Do(func() {
+		created = true
+		c.modelId, c.err = createFn()
+	})
+	return c.modelId, created, c.err
+}
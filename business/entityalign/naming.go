@@ -0,0 +1,148 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package entityalign
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	iotclient "github.com/arduino/iot-client-go/v2"
+)
+
+// NameFields are the fields available to an asset name template
+type NameFields struct {
+	ThingName string
+	ThingID   string
+	Stack     string
+}
+
+// NameTemplate composes the names of thing assets, and of the models created from them.
+// A nil template uses the thing name.
+type NameTemplate struct {
+	tmpl  *template.Template
+	stack string
+}
+
+// ParseNameTemplate parses a Go text/template composing asset names, e.g. 'prod/factory-1/{{.ThingName}}'.
+// See NameFields for the available fields. The template is rendered against a sample thing, so that
+// unknown fields or templates producing empty names are reported here rather than when naming assets.
+func ParseNameTemplate(text, stack string) (*NameTemplate, error) {
+	tmpl, err := template.New("asset-name").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid asset name template: %w", err)
+	}
+	t := &NameTemplate{tmpl: tmpl, stack: stack}
+	if _, err := t.render("sample-thing", "00000000-0000-0000-0000-000000000000"); err != nil {
+		return nil, fmt.Errorf("invalid asset name template: %w", err)
+	}
+	return t, nil
+}
+
+// AssetName returns the name of the asset of the given thing
+func (t *NameTemplate) AssetName(thing iotclient.ArduinoThing) (string, error) {
+	if t == nil {
+		return thing.Name, nil
+	}
+	return t.render(thing.Name, thing.Id)
+}
+
+func (t *NameTemplate) render(thingName, thingID string) (string, error) {
+	var sb strings.Builder
+	if err := t.tmpl.Execute(&sb, NameFields{ThingName: thingName, ThingID: thingID, Stack: t.stack}); err != nil {
+		return "", err
+	}
+	name := strings.TrimSpace(sb.String())
+	if name == "" {
+		return "", errors.New("template produces an empty name")
+	}
+	return name, nil
+}
+
+// AliasFields are the fields available to a property alias template
+type AliasFields struct {
+	ThingID      string
+	ThingName    string
+	PropertyName string
+}
+
+// AliasTemplate composes the SiteWise aliases of thing properties. A nil template uses PropertyAlias.
+// The same template must be used to align assets and to import time series, or data points are written
+// to aliases not associated to any asset property.
+type AliasTemplate struct {
+	tmpl *template.Template
+	// Namespace of all the aliases, e.g. '/arduino/prod'. Empty for none.
+	prefix string
+}
+
+// ParseAliasTemplate parses a Go text/template composing property aliases, e.g. '/factory-1/{{.ThingName}}/{{.PropertyName}}'.
+// See AliasFields for the available fields. As for asset names, the template is checked against a sample property.
+func ParseAliasTemplate(text string) (*AliasTemplate, error) {
+	tmpl, err := template.New("property-alias").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid property alias template: %w", err)
+	}
+	t := &AliasTemplate{tmpl: tmpl}
+	if _, err := t.render("00000000-0000-0000-0000-000000000000", "sample-thing", "sample_property"); err != nil {
+		return nil, fmt.Errorf("invalid property alias template: %w", err)
+	}
+	return t, nil
+}
+
+// WithPrefix returns a template namespacing aliases under the given prefix, e.g. '/arduino/prod', so that
+// integrations sharing a SiteWise account don't collide. A nil template gets a prefix for the default aliases.
+func (t *AliasTemplate) WithPrefix(prefix string) *AliasTemplate {
+	prefix = strings.Trim(strings.TrimSpace(prefix), "/")
+	if prefix == "" {
+		return t
+	}
+	prefixed := &AliasTemplate{prefix: "/" + prefix}
+	if t != nil {
+		prefixed.tmpl = t.tmpl
+	}
+	return prefixed
+}
+
+// Alias returns the alias of the given thing property, within the prefix if any. Properties whose alias
+// can't be rendered fall back to the default alias.
+func (t *AliasTemplate) Alias(thing iotclient.ArduinoThing, propertyName string) string {
+	if t == nil {
+		return PropertyAlias(thing.Id, propertyName)
+	}
+	alias := PropertyAlias(thing.Id, propertyName)
+	if t.tmpl != nil {
+		if rendered, err := t.render(thing.Id, thing.Name, propertyName); err == nil {
+			alias = rendered
+		}
+	}
+	if t.prefix == "" {
+		return alias
+	}
+	return t.prefix + "/" + strings.TrimPrefix(alias, "/")
+}
+
+func (t *AliasTemplate) render(thingID, thingName, propertyName string) (string, error) {
+	var sb strings.Builder
+	if err := t.tmpl.Execute(&sb, AliasFields{ThingID: thingID, ThingName: thingName, PropertyName: propertyName}); err != nil {
+		return "", err
+	}
+	alias := strings.TrimSpace(sb.String())
+	if alias == "" {
+		return "", errors.New("template produces an empty alias")
+	}
+	return alias, nil
+}
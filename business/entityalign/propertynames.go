@@ -0,0 +1,78 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package entityalign
+
+import (
+	"cmp"
+	"slices"
+
+	iotclient "github.com/arduino/iot-client-go/v2"
+)
+
+// DisambiguatedPropertyName returns the name given to a property sharing its name with another property of the same thing.
+func DisambiguatedPropertyName(propertyName, propertyId string) string {
+	return propertyName + "_" + propertyId
+}
+
+// UniquePropertyNames returns a copy of the thing where properties sharing their name with another property are
+// renamed with DisambiguatedPropertyName, so that each one gets its own model property and alias. Among properties
+// with the same name, the one with the lowest id keeps it, so that names are stable across runs.
+// Renamed properties are returned beside the thing, by original name.
+func UniquePropertyNames(thing iotclient.ArduinoThing) (iotclient.ArduinoThing, map[string][]string) {
+	byName := make(map[string][]iotclient.ArduinoProperty, len(thing.Properties))
+	for _, prop := range thing.Properties {
+		byName[prop.Name] = append(byName[prop.Name], prop)
+	}
+
+	renamed := map[string][]string{}
+	newNames := map[string]string{}
+	for name, props := range byName {
+		if len(props) < 2 {
+			continue
+		}
+		slices.SortFunc(props, func(a, b iotclient.ArduinoProperty) int { return cmp.Compare(a.Id, b.Id) })
+		for _, prop := range props[1:] {
+			newName := DisambiguatedPropertyName(name, prop.Id)
+			newNames[prop.Id] = newName
+			renamed[name] = append(renamed[name], newName)
+		}
+	}
+	if len(newNames) == 0 {
+		return thing, nil
+	}
+
+	properties := slices.Clone(thing.Properties)
+	for i, prop := range properties {
+		if newName, ok := newNames[prop.Id]; ok {
+			properties[i].Name = newName
+		}
+	}
+	thing.Properties = properties
+	return thing, renamed
+}
+
+// withUniquePropertyNames disambiguates property names of things, warning about the renamed properties.
+func (a *aligner) withUniquePropertyNames(things []iotclient.ArduinoThing) []iotclient.ArduinoThing {
+	unique := make([]iotclient.ArduinoThing, 0, len(things))
+	for _, thing := range things {
+		thing, renamed := UniquePropertyNames(thing)
+		for name, newNames := range renamed {
+			a.logger.Warnln("Thing ", thing.Id, " has more properties named ", name, ", aligned as: ", newNames)
+		}
+		unique = append(unique, thing)
+	}
+	return unique
+}
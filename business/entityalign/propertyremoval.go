@@ -0,0 +1,153 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package entityalign
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/arduino/aws-sitewise-integration/internal/sitewiseclient"
+	iotclient "github.com/arduino/iot-client-go/v2"
+	"github.com/aws/aws-sdk-go-v2/service/iotsitewise"
+)
+
+// ModelPropertyRemovalPolicy defines how model properties no longer present on the things using the model are handled
+type ModelPropertyRemovalPolicy string
+
+const (
+	// ModelPropertyRemovalNone keeps model properties dropped by things
+	ModelPropertyRemovalNone ModelPropertyRemovalPolicy = "none"
+	// ModelPropertyRemovalUnused removes dropped properties holding no data. Properties holding data are kept, with a warning
+	ModelPropertyRemovalUnused ModelPropertyRemovalPolicy = "unused"
+	// ModelPropertyRemovalAll removes dropped properties, with a warning for the ones holding data
+	ModelPropertyRemovalAll ModelPropertyRemovalPolicy = "all"
+)
+
+// WithModelPropertyRemoval sets how model properties dropped by things are handled. Default is ModelPropertyRemovalNone.
+func WithModelPropertyRemoval(policy ModelPropertyRemovalPolicy) Option {
+	return func(a *aligner) {
+		a.modelPropertyRemovalPolicy = policy
+	}
+}
+
+// removeDroppedModelProperties removes from models the properties that none of the things using them have anymore.
+// Models having assets of things not being aligned are left untouched, as their properties are unknown.
+func (a *aligner) removeDroppedModelProperties(
+	ctx context.Context,
+	thingsMap map[string]iotclient.ArduinoThing,
+	models map[string]*string,
+	modelDefinitions map[string]*iotsitewise.DescribeAssetModelOutput,
+	assets map[string]assetDefintion) []error {
+
+	modelAssets := make(map[string][]assetDefintion)
+	for _, asset := range assets {
+		modelAssets[asset.modelId] = append(modelAssets[asset.modelId], asset)
+	}
+
+	for modelId, assetsOfModel := range modelAssets {
+		descModel, ok := modelDefinitions[modelId]
+		if !ok {
+			continue
+		}
+		thingProperties, ok := thingPropertiesOfAssets(thingsMap, assetsOfModel)
+		if !ok {
+			a.logger.Infoln("Model has assets of things not being aligned, skipping removal of dropped properties. Model: ", modelId)
+			continue
+		}
+
+		var toRemove []string
+		for _, prop := range sitewiseclient.ModelProperties(descModel) {
+			if prop.Name == nil || prop.Id == nil || prop.Type == nil || prop.Type.Measurement == nil {
+				continue
+			}
+			if _, ok := thingProperties[*prop.Name]; ok {
+				continue
+			}
+			withData, err := a.assetsWithData(ctx, assetsOfModel, *prop.Id)
+			if err != nil {
+				return []error{err}
+			}
+			if len(withData) > 0 {
+				if a.modelPropertyRemovalPolicy != ModelPropertyRemovalAll {
+					a.logger.Warnf("Property %s of model %s is no longer on its things but holds data on assets %v, keeping it\n", *prop.Name, modelId, withData)
+					continue
+				}
+				a.logger.Warnf("Removing property %s of model %s, holding data on assets %v\n", *prop.Name, modelId, withData)
+			}
+			toRemove = append(toRemove, *prop.Name)
+		}
+		if len(toRemove) == 0 {
+			continue
+		}
+
+		a.logger.Infoln("Removing dropped properties from model: ", modelId, " - properties: ", toRemove)
+		if err := a.sitewisecl.RemoveAssetModelProperties(ctx, descModel, toRemove); err != nil {
+			a.logger.Errorln("Error removing properties from model: ", modelId, err)
+			return []error{err}
+		}
+		if err := a.sitewisecl.PollForModelActiveStatusWithOptions(ctx, modelId, a.pollOptions); err != nil {
+			a.logger.Warnf("Model [%s] not active: %v\n", modelId, err)
+		}
+		updatedModel, err := a.sitewisecl.DescribeModel(ctx, modelId)
+		if err != nil {
+			return []error{err}
+		}
+		modelDefinitions[modelId] = updatedModel
+
+		// Model is now found by the key of its remaining properties
+		if oldKey, ok := buildModelKeyFromModel(descModel); ok && models[oldKey] != nil && *models[oldKey] == modelId {
+			delete(models, oldKey)
+		}
+		if newKey, ok := buildModelKeyFromModel(updatedModel); ok {
+			models[newKey] = updatedModel.AssetModelId
+		}
+	}
+	return nil
+}
+
+// thingPropertiesOfAssets returns the union of the properties of the things of the given assets.
+// It returns false if any of the things is unknown.
+func thingPropertiesOfAssets(thingsMap map[string]iotclient.ArduinoThing, assets []assetDefintion) (map[string]string, bool) {
+	props := make(map[string]string)
+	for _, asset := range assets {
+		thing, ok := thingsMap[asset.thingId]
+		if !ok {
+			return nil, false
+		}
+		for name, ptype := range thingPropertiesMap(thing) {
+			props[name] = ptype
+		}
+	}
+	return props, true
+}
+
+// assetsWithData returns the ids of the assets holding a value for the given property
+func (a *aligner) assetsWithData(ctx context.Context, assets []assetDefintion, propertyId string) ([]string, error) {
+	var withData []string
+	for _, asset := range assets {
+		_, _, err := a.sitewisecl.GetLatestAssetPropertyValue(ctx, asset.assetId, propertyId)
+		if errors.Is(err, sitewiseclient.ErrNoValue) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		withData = append(withData, asset.assetId)
+	}
+	slices.Sort(withData)
+	return withData, nil
+}
@@ -0,0 +1,53 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package entityalign
+
+import (
+	"context"
+	"regexp"
+
+	iotclient "github.com/arduino/iot-client-go/v2"
+)
+
+// Assets created by the integration have the thing ID, a UUID, as external id
+var thingIdPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// pruneOrphanAssets deletes assets mapped to things that no longer exist. Only assets created by the integration
+// are considered: assets without external id are never listed, and assets whose external id is not a thing ID are skipped.
+func (a *aligner) pruneOrphanAssets(ctx context.Context, thingsMap map[string]iotclient.ArduinoThing, assets map[string]assetDefintion) []error {
+	var errs []error
+	for externalId, asset := range assets {
+		if _, ok := thingsMap[externalId]; ok {
+			continue
+		}
+		if !thingIdPattern.MatchString(externalId) {
+			a.logger.Debugln("Asset external id is not a thing ID, not pruning it: ", asset.assetId, externalId)
+			continue
+		}
+
+		a.logger.Infoln("Deleting asset of deleted thing: ", asset.assetId, " - thing: ", externalId)
+		if err := a.sitewisecl.DisassociateFromParent(ctx, asset.assetId, asset.modelId); err != nil {
+			a.logger.Errorln("Error disassociating asset: ", asset.assetId, err)
+			errs = append(errs, err)
+			continue
+		}
+		if err := a.sitewisecl.DeleteAsset(ctx, asset.assetId); err != nil {
+			a.logger.Errorln("Error deleting asset: ", asset.assetId, err)
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,100 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package entityalign
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/arduino/aws-sitewise-integration/internal/sitewiseclient"
+	iotclient "github.com/arduino/iot-client-go/v2"
+	"github.com/aws/aws-sdk-go-v2/service/iotsitewise"
+)
+
+// TypeMismatchPolicy defines how model properties whose data type no longer matches the thing property type are handled.
+// SiteWise can't change the data type of a property, so it can only be removed and added again with the new type.
+type TypeMismatchPolicy string
+
+const (
+	// TypeMismatchLog leaves the property as is, logging an error. Values of the new type are rejected by SiteWise
+	TypeMismatchLog TypeMismatchPolicy = "log"
+	// TypeMismatchRecreate removes the property from the model and adds it again with the new type.
+	// The data of the property is lost on all the assets of the model, and its alias is associated again
+	TypeMismatchRecreate TypeMismatchPolicy = "recreate"
+)
+
+// ParseTypeMismatchPolicy returns the type mismatch policy of the given value.
+func ParseTypeMismatchPolicy(value string) (TypeMismatchPolicy, error) {
+	switch policy := TypeMismatchPolicy(value); policy {
+	case TypeMismatchLog, TypeMismatchRecreate:
+		return policy, nil
+	}
+	return "", fmt.Errorf("invalid type mismatch policy %q, expected %s or %s", value, TypeMismatchLog, TypeMismatchRecreate)
+}
+
+// WithTypeMismatchPolicy sets how model properties whose type differs from the thing property type are handled.
+// Default is TypeMismatchLog.
+func WithTypeMismatchPolicy(policy TypeMismatchPolicy) Option {
+	return func(a *aligner) {
+		a.typeMismatchPolicy = policy
+	}
+}
+
+// modelPropertyDataTypes returns the data types of the model properties of the thing, by name.
+func (a *aligner) modelPropertyDataTypes(thing iotclient.ArduinoThing) map[string]string {
+	dataTypes := make(map[string]string)
+	for name, ptype := range a.modelPropertyTypes(thing) {
+		dataTypes[name] = string(sitewiseclient.PropertyDataType(ptype))
+	}
+	return dataTypes
+}
+
+// alignPropertyTypes detects the model properties whose data type differs from the one of the thing property, and
+// recreates them if the policy allows it. The given conflicting properties, whose data type differs among the things
+// of the model, are never recreated. It returns the current model definition, and whether the model was changed.
+func (a *aligner) alignPropertyTypes(ctx context.Context, descModel *iotsitewise.DescribeAssetModelOutput, thing iotclient.ArduinoThing, uomMap map[string][]string, conflicts []string) (*iotsitewise.DescribeAssetModelOutput, bool, error) {
+	props := a.modelPropertyTypes(thing)
+	mismatches := slices.DeleteFunc(sitewiseclient.PropertyTypeMismatches(descModel, props), func(name string) bool {
+		return slices.Contains(conflicts, name)
+	})
+	if len(mismatches) == 0 {
+		return descModel, false, nil
+	}
+	modelId := *descModel.AssetModelId
+	if a.typeMismatchPolicy != TypeMismatchRecreate {
+		a.logger.Errorln("Properties of model ", modelId, " have a data type not matching the one of thing ", thing.Id, ": ", mismatches,
+			". Their values are rejected by SiteWise: set the type mismatch policy to recreate them, losing their data, or restore the property types on the thing")
+		return descModel, false, nil
+	}
+
+	a.logger.Warnln("Recreating properties of model ", modelId, " with the data type of thing ", thing.Id, ", their data is lost: ", mismatches)
+	if err := a.sitewisecl.RemoveAssetModelProperties(ctx, descModel, mismatches); err != nil {
+		return descModel, false, fmt.Errorf("removing properties %v of model %s: %w", mismatches, modelId, err)
+	}
+	// Properties can be added again with the same name only once the removal is applied
+	if err := a.sitewisecl.PollForModelActiveStatusWithOptions(ctx, modelId, a.pollOptions); err != nil {
+		return descModel, true, err
+	}
+	descModel, err := a.sitewisecl.DescribeAssetModel(ctx, &modelId)
+	if err != nil {
+		return nil, true, err
+	}
+	if err := a.sitewisecl.UpdateAssetModelProperties(ctx, descModel, props, uomMap); err != nil {
+		return descModel, true, fmt.Errorf("adding properties %v to model %s: %w", mismatches, modelId, err)
+	}
+	return descModel, true, nil
+}
@@ -0,0 +1,58 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package entityalign
+
+import (
+	"slices"
+
+	iotclient "github.com/arduino/iot-client-go/v2"
+)
+
+// Type of the properties holding update times, as epoch seconds
+const updatedAtPropertyType = "INT"
+
+// UpdatedAtPropertyName returns the name of the property holding the time an ON_CHANGE property last changed.
+func UpdatedAtPropertyName(propertyName string) string {
+	return propertyName + "_updated_at"
+}
+
+// withUpdatedAtProperties returns copies of things having, for each ON_CHANGE property, an additional property
+// holding the time its value last changed. These become regular properties of models and assets.
+func (a *aligner) withUpdatedAtProperties(things []iotclient.ArduinoThing) []iotclient.ArduinoThing {
+	expanded := make([]iotclient.ArduinoThing, 0, len(things))
+	for _, thing := range things {
+		names := make([]string, 0, len(thing.Properties))
+		for _, prop := range thing.Properties {
+			names = append(names, prop.Name)
+		}
+
+		properties := slices.Clone(thing.Properties)
+		for _, prop := range thing.Properties {
+			if prop.UpdateStrategy != "ON_CHANGE" {
+				continue
+			}
+			name := UpdatedAtPropertyName(prop.Name)
+			if slices.Contains(names, name) {
+				a.logger.Warnln("Thing ", thing.Id, " already has property ", name, ", not importing update time of ", prop.Name)
+				continue
+			}
+			properties = append(properties, iotclient.ArduinoProperty{Name: name, Type: updatedAtPropertyType})
+		}
+		thing.Properties = properties
+		expanded = append(expanded, thing)
+	}
+	return expanded
+}
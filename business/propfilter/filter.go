@@ -0,0 +1,165 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package propfilter
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/arduino/aws-sitewise-integration/internal/iot"
+	iotclient "github.com/arduino/iot-client-go/v2"
+)
+
+// Category groups Arduino property types by the kind of value they carry
+type Category string
+
+const (
+	Numeric  Category = "numeric"
+	String   Category = "string"
+	Bool     Category = "bool"
+	Location Category = "location"
+)
+
+// CategoryOf returns the category of the given Arduino property type
+func CategoryOf(pType string) (Category, bool) {
+	switch {
+	case iot.IsPropertyNumberType(pType):
+		return Numeric, true
+	case iot.IsPropertyString(pType):
+		return String, true
+	case iot.IsPropertyBool(pType):
+		return Bool, true
+	case iot.IsPropertyLocation(pType):
+		return Location, true
+	}
+	return "", false
+}
+
+// ParseCategories parses a comma separated list of categories (e.g. "numeric,bool")
+func ParseCategories(categories string) ([]Category, error) {
+	parsed := []Category{}
+	for _, c := range strings.Split(categories, ",") {
+		c = strings.ToLower(strings.TrimSpace(c))
+		if c == "" {
+			continue
+		}
+		category := Category(c)
+		switch category {
+		case Numeric, String, Bool, Location:
+			parsed = append(parsed, category)
+		default:
+			return nil, fmt.Errorf("invalid property category: %s", c)
+		}
+	}
+	return parsed, nil
+}
+
+// ParseNamePatterns parses a comma separated list of property name glob patterns (e.g. "temp*,debug_?")
+func ParseNamePatterns(patterns string) ([]string, error) {
+	parsed := []string{}
+	for _, p := range strings.Split(patterns, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid property name pattern: %s", p)
+		}
+		parsed = append(parsed, p)
+	}
+	return parsed, nil
+}
+
+// Filter selects which thing properties are imported into SiteWise.
+// A nil Filter, or a Filter without criteria, allows every property.
+type Filter struct {
+	categories []Category
+	included   []string
+	excluded   []string
+}
+
+// Option configures optional criteria of the filter.
+type Option func(*Filter)
+
+// WithIncludedNames allows only properties whose name matches any of the given glob patterns.
+func WithIncludedNames(patterns []string) Option {
+	return func(f *Filter) {
+		f.included = patterns
+	}
+}
+
+// WithExcludedNames rejects properties whose name matches any of the given glob patterns, even if included.
+func WithExcludedNames(patterns []string) Option {
+	return func(f *Filter) {
+		f.excluded = patterns
+	}
+}
+
+func New(categories []Category, opts ...Option) *Filter {
+	f := &Filter{categories: categories}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// Allows returns true if the property has to be imported
+func (f *Filter) Allows(name, pType string) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.categories) > 0 {
+		category, ok := CategoryOf(pType)
+		if !ok || !slices.Contains(f.categories, category) {
+			return false
+		}
+	}
+	if len(f.included) > 0 && !matchesAny(f.included, name) {
+		return false
+	}
+	return !matchesAny(f.excluded, name)
+}
+
+func matchesAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		// Patterns are validated when parsed
+		if matched, _ := path.Match(p, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterThings returns a copy of things, keeping only allowed properties
+func (f *Filter) FilterThings(things []iotclient.ArduinoThing) []iotclient.ArduinoThing {
+	if f == nil {
+		return things
+	}
+	filtered := make([]iotclient.ArduinoThing, 0, len(things))
+	for _, thing := range things {
+		properties := make([]iotclient.ArduinoProperty, 0, len(thing.Properties))
+		for _, prop := range thing.Properties {
+			if f.Allows(prop.Name, prop.Type) {
+				properties = append(properties, prop)
+			}
+		}
+		thing.Properties = properties
+		filtered = append(filtered, thing)
+	}
+	return filtered
+}
@@ -0,0 +1,129 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package propfilter
+
+import (
+	"testing"
+
+	iotclient "github.com/arduino/iot-client-go/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilter_categories(t *testing.T) {
+	properties := map[string]string{
+		"temperature": "TEMPERATURE_C",
+		"counter":     "INT",
+		"message":     "CHARSTRING",
+		"switch":      "STATUS",
+		"position":    "LOCATION",
+		"color":       "COLOR_HSB",
+	}
+
+	tests := []struct {
+		category Category
+		expected []string
+	}{
+		{Numeric, []string{"temperature", "counter"}},
+		{String, []string{"message"}},
+		{Bool, []string{"switch"}},
+		{Location, []string{"position"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.category), func(t *testing.T) {
+			f := New([]Category{tt.category})
+			allowed := []string{}
+			for name, pType := range properties {
+				if f.Allows(name, pType) {
+					allowed = append(allowed, name)
+				}
+			}
+			assert.ElementsMatch(t, tt.expected, allowed)
+		})
+	}
+}
+
+func TestFilter_noCriteriaAllowsAll(t *testing.T) {
+	var nilFilter *Filter
+	assert.True(t, nilFilter.Allows("color", "COLOR_HSB"))
+	assert.True(t, New(nil).Allows("color", "COLOR_HSB"))
+}
+
+func TestFilter_filterThings(t *testing.T) {
+	things := []iotclient.ArduinoThing{
+		{
+			Id: "thing",
+			Properties: []iotclient.ArduinoProperty{
+				{Name: "temperature", Type: "FLOAT"},
+				{Name: "message", Type: "CHARSTRING"},
+			},
+		},
+	}
+	filtered := New([]Category{Numeric}).FilterThings(things)
+	assert.Equal(t, 1, len(filtered))
+	assert.Equal(t, 1, len(filtered[0].Properties))
+	assert.Equal(t, "temperature", filtered[0].Properties[0].Name)
+	// Source things are left untouched
+	assert.Equal(t, 2, len(things[0].Properties))
+}
+
+func TestParseCategories(t *testing.T) {
+	categories, err := ParseCategories(" numeric, Bool ,")
+	assert.Nil(t, err)
+	assert.Equal(t, []Category{Numeric, Bool}, categories)
+
+	_, err = ParseCategories("numeric,complex")
+	assert.NotNil(t, err)
+}
+
+func TestFilter_namePatterns(t *testing.T) {
+	names := []string{"temperature", "temperature_debug", "debug_counter", "humidity", "status_msg"}
+
+	tests := []struct {
+		name     string
+		filter   *Filter
+		expected []string
+	}{
+		{"include only", New(nil, WithIncludedNames([]string{"temp*", "humidity"})), []string{"temperature", "temperature_debug", "humidity"}},
+		{"exclude only", New(nil, WithExcludedNames([]string{"*debug*"})), []string{"temperature", "humidity", "status_msg"}},
+		{"exclude wins over include", New(nil, WithIncludedNames([]string{"temp*", "status_???"}), WithExcludedNames([]string{"*_debug"})), []string{"temperature", "status_msg"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed := []string{}
+			for _, name := range names {
+				if tt.filter.Allows(name, "FLOAT") {
+					allowed = append(allowed, name)
+				}
+			}
+			assert.Equal(t, tt.expected, allowed)
+		})
+	}
+
+	// Name patterns add to categories
+	f := New([]Category{Numeric}, WithExcludedNames([]string{"counter"}))
+	assert.True(t, f.Allows("temperature", "FLOAT"))
+	assert.False(t, f.Allows("counter", "INT"))
+	assert.False(t, f.Allows("message", "CHARSTRING"))
+}
+
+func TestParseNamePatterns(t *testing.T) {
+	patterns, err := ParseNamePatterns(" temp* , debug_?,")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"temp*", "debug_?"}, patterns)
+
+	_, err = ParseNamePatterns("temp[")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,111 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package tsalign
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	iotclient "github.com/arduino/iot-client-go/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// Aggregations that can be configured per property
+var supportedAggregations = []string{"AVG", "MIN", "MAX", "LAST"}
+
+// ParseAggregationOverrides parses a comma separated list of property aggregations
+// (e.g. "energy=MAX,alarm=LAST"), returning aggregations keyed by property name.
+func ParseAggregationOverrides(overrides string) (map[string]string, error) {
+	parsed := map[string]string{}
+	for _, o := range strings.Split(overrides, ",") {
+		o = strings.TrimSpace(o)
+		if o == "" {
+			continue
+		}
+		name, aggregation, ok := strings.Cut(o, "=")
+		name = strings.TrimSpace(name)
+		aggregation = strings.ToUpper(strings.TrimSpace(aggregation))
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid aggregation override: %s", o)
+		}
+		if !slices.Contains(supportedAggregations, aggregation) {
+			return nil, fmt.Errorf("invalid aggregation %s for property %s", aggregation, name)
+		}
+		parsed[name] = aggregation
+	}
+	return parsed, nil
+}
+
+// populateOverriddenTSDataIntoSiteWise imports time series of properties with an aggregation override,
+// querying each of them with its own aggregation.
+func (a *TsAligner) populateOverriddenTSDataIntoSiteWise(
+	ctx context.Context,
+	thingID string,
+	mappedProperties *mappedProperties,
+	resolution int,
+	from, to time.Time) ([]string, error) {
+
+	if len(mappedProperties.AggregationOverrides) == 0 {
+		return nil, nil
+	}
+
+	batched, err := fetchWithRetry(ctx, a, thingID, func() (*iotclient.ArduinoSeriesBatch, error) {
+		return a.iotcl.GetTimeSeriesByProperties(ctx, mappedProperties.AggregationOverrides, from, to, int64(resolution))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if a.fetchOnly {
+		var points int64
+		for _, response := range batched.Responses {
+			points += response.CountValues
+		}
+		a.fetchStats.record(points)
+		return nil, nil
+	}
+
+	propertiesImported := []string{}
+	batch := a.newAliasBatch()
+	for _, response := range batched.Responses {
+		if response.CountValues == 0 {
+			continue
+		}
+
+		propertyID := strings.Replace(response.Query, "property.", "", 1)
+		alias := mappedProperties.PropertiesToImportAliases[propertyID]
+		if _, ok := mappedProperties.AggregationOverrides[propertyID]; !ok || alias == "" {
+			a.logger.WithFields(logrus.Fields{logFieldThingID: thingID, logFieldPropertyID: propertyID}).Debug("Not mapped property. Skipping import.")
+			a.counters.skipped.Add(response.CountValues)
+			continue
+		}
+
+		c := toChunk(response)
+		c.values = transformValues(mappedProperties.Transforms[propertyID], c.values)
+		a.logger.WithFields(logrus.Fields{logFieldThingID: thingID, logFieldPropertyAlias: alias, logFieldPoints: len(c.ts), logFieldAggregation: mappedProperties.AggregationOverrides[propertyID]}).Debug("Importing data points")
+		if err := batch.addNumeric(ctx, alias, c.ts, c.values); err != nil {
+			return nil, err
+		}
+		a.counters.written.Add(int64(len(c.ts)))
+		propertiesImported = append(propertiesImported, propertyID)
+	}
+	if err := batch.flush(ctx); err != nil {
+		return nil, err
+	}
+	return propertiesImported, nil
+}
@@ -0,0 +1,128 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package tsalign
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/arduino/aws-sitewise-integration/internal/sitewiseclient"
+	iotclient "github.com/arduino/iot-client-go/v2"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iotsitewise"
+)
+
+// WithAttributeProperties makes the aligner set static thing properties, such as device configurations, as
+// asset attributes rather than importing their time series. An attribute is written only when its value
+// differs from the one in SiteWise. Properties are set as attributes only when their asset model property is an
+// attribute, e.g. not when the model was created before enabling attributes. See iot.IsAttributeProperty.
+func WithAttributeProperties(enabled bool) Option {
+	return func(a *TsAligner) {
+		a.attributeProperties = enabled
+	}
+}
+
+// assetAttribute is the asset property holding the value of a thing property imported as attribute
+type assetAttribute struct {
+	propertyId string
+	alias      string
+	transform  Transform
+}
+
+// modelAttributes keeps, for the duration of a run, the names of the attribute properties of asset models,
+// so that each model is described once.
+type modelAttributes struct {
+	mu     sync.Mutex
+	models map[string]*modelAttributeNames
+}
+
+type modelAttributeNames struct {
+	once  sync.Once
+	names []string
+	err   error
+}
+
+func (m *modelAttributes) reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.models = nil
+}
+
+func (m *modelAttributes) get(modelId string) *modelAttributeNames {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.models == nil {
+		m.models = make(map[string]*modelAttributeNames)
+	}
+	if m.models[modelId] == nil {
+		m.models[modelId] = &modelAttributeNames{}
+	}
+	return m.models[modelId]
+}
+
+// modelAttributeNames returns the names of the attribute properties of the model of the asset. Models are
+// described only when thing properties are set as attributes.
+func (a *TsAligner) modelAttributeNames(ctx context.Context, describedAsset *iotsitewise.DescribeAssetOutput) ([]string, error) {
+	if !a.attributeProperties || describedAsset.AssetModelId == nil {
+		return nil, nil
+	}
+	modelId := *describedAsset.AssetModelId
+	entry := a.modelAttributes.get(modelId)
+	entry.once.Do(func() {
+		model, err := a.sitewisecl.DescribeAssetModel(ctx, &modelId)
+		if err != nil {
+			entry.err = fmt.Errorf("describing asset model %s: %w", modelId, err)
+			return
+		}
+		for _, prop := range sitewiseclient.ModelProperties(model) {
+			if prop.Type != nil && prop.Type.Attribute != nil {
+				entry.names = append(entry.names, aws.ToString(prop.Name))
+			}
+		}
+	})
+	return entry.names, entry.err
+}
+
+// populateAttributes sets the attributes of the asset whose value changed on Arduino IoT Cloud
+func (a *TsAligner) populateAttributes(
+	ctx context.Context,
+	assetId string,
+	propertiesMap map[string]iotclient.ArduinoProperty,
+	attributes map[string]assetAttribute) error {
+
+	for propertyId, attribute := range attributes {
+		property, ok := propertiesMap[propertyId]
+		if !ok || property.LastValue == nil {
+			continue
+		}
+		value := writtenValue(property, attribute.transform)
+		current, _, err := a.sitewisecl.GetLatestAssetPropertyValue(ctx, assetId, attribute.propertyId)
+		if err != nil && !errors.Is(err, sitewiseclient.ErrNoValue) {
+			return err
+		}
+		if err == nil && variantMatches(value, current, 0) {
+			continue
+		}
+		a.logger.WithField(logFieldPropertyAlias, attribute.alias).Debugln("Setting attribute - name ", property.Name, " - value: ", value)
+		if err := a.sitewisecl.SetAssetPropertyAttribute(ctx, attribute.alias, value); err != nil {
+			return err
+		}
+		a.counters.written.Add(1)
+	}
+	return nil
+}
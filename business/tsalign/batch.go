@@ -0,0 +1,91 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package tsalign
+
+import (
+	"context"
+
+	"github.com/arduino/aws-sitewise-integration/internal/sitewiseclient"
+)
+
+// AliasBatchEntries is the number of SiteWise entries, each holding up to 10 values of an alias, accumulated
+// across aliases before writing them with a single request.
+const AliasBatchEntries = 10
+
+// Values of an alias held by a SiteWise entry
+const valuesPerEntry = 10
+
+// WithAliasBatching accumulates the series of the properties of a thing, writing them together rather than one
+// request per property. It cuts SiteWise requests for things with many properties with few samples each.
+func WithAliasBatching(enabled bool) Option {
+	return func(a *TsAligner) {
+		a.aliasBatching = enabled
+	}
+}
+
+// aliasBatch collects the series of a thing import. With alias batching, series are written every AliasBatchEntries
+// entries and on flush, otherwise as soon as they are added.
+type aliasBatch struct {
+	sitewisecl sitewiseclient.API
+	enabled    bool
+	points     []sitewiseclient.DataPoint
+	entries    int
+}
+
+func (a *TsAligner) newAliasBatch() *aliasBatch {
+	return &aliasBatch{sitewisecl: a.sitewisecl, enabled: a.aliasBatching}
+}
+
+func (b *aliasBatch) addNumeric(ctx context.Context, alias string, ts []int64, values []float64) error {
+	if !b.enabled {
+		return b.sitewisecl.PopulateTimeSeriesByAlias(ctx, alias, ts, values)
+	}
+	return addSeries(ctx, b, alias, ts, values)
+}
+
+func (b *aliasBatch) addSampled(ctx context.Context, alias string, ts []int64, values []any) error {
+	if !b.enabled {
+		return b.sitewisecl.PopulateSampledSamplesTimeSeriesByAlias(ctx, alias, ts, values)
+	}
+	return addSeries(ctx, b, alias, ts, values)
+}
+
+func addSeries[V any](ctx context.Context, b *aliasBatch, alias string, ts []int64, values []V) error {
+	entries := (len(ts) + valuesPerEntry - 1) / valuesPerEntry
+	if b.entries > 0 && b.entries+entries > AliasBatchEntries {
+		if err := b.flush(ctx); err != nil {
+			return err
+		}
+	}
+	for i := range ts {
+		b.points = append(b.points, sitewiseclient.DataPoint{PropertyAlias: alias, Ts: ts[i], Value: values[i]})
+	}
+	b.entries += entries
+	if b.entries >= AliasBatchEntries {
+		return b.flush(ctx)
+	}
+	return nil
+}
+
+// flush writes the accumulated series
+func (b *aliasBatch) flush(ctx context.Context) error {
+	if len(b.points) == 0 {
+		return nil
+	}
+	points := b.points
+	b.points, b.entries = nil, 0
+	return b.sitewisecl.PopulateArbitrarySamplesByAlias(ctx, points)
+}
@@ -0,0 +1,252 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package tsalign
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/arduino/aws-sitewise-integration/internal/objectstore"
+	"github.com/arduino/aws-sitewise-integration/internal/sitewiseclient"
+	iotclient "github.com/arduino/iot-client-go/v2"
+	"github.com/aws/aws-sdk-go-v2/service/iotsitewise/types"
+	"github.com/sirupsen/logrus"
+)
+
+// ImportMode defines how imported data points are written to SiteWise
+type ImportMode string
+
+const (
+	// ImportStreaming writes data points with BatchPutAssetPropertyValue requests, as they are fetched
+	ImportStreaming ImportMode = "streaming"
+	// ImportBulk stages data points as CSV files on S3 and writes them with a single bulk import job at the
+	// end of the run. Meant for backfilling long time windows.
+	ImportBulk ImportMode = "bulk"
+)
+
+// Defaults of bulk imports
+const (
+	defaultBulkRowsPerFile  = 100000
+	defaultBulkPollInterval = 10 * time.Second
+	// Leaves time to report the outcome within the Lambda timeout
+	defaultBulkMaxWait  = 10 * time.Minute
+	maxLoggedBulkErrors = 10
+)
+
+// BulkImportConfig configures imports through SiteWise bulk import jobs.
+type BulkImportConfig struct {
+	Store  objectstore.API
+	Bucket string
+	// Prefix of the keys of the CSV files written to the bucket
+	Prefix string
+	// Role assumed by SiteWise to read the CSV files and write error reports to the bucket
+	RoleArn string
+	// Maximum data points per CSV file. Values below 1 keep the default.
+	RowsPerFile int
+	// Delay between job status checks. Values below 1 keep the default.
+	PollInterval time.Duration
+	// Maximum time to wait for the job to end. Values below 1 keep the default.
+	MaxWait time.Duration
+	// Stage only data points too old to be streamed to SiteWise, streaming the others
+	OutOfWindowOnly bool
+}
+
+// WithBulkImport makes the aligner write data points through a SiteWise bulk import job instead of
+// streaming them: points are staged as CSV files on S3 and the job is awaited at the end of the run.
+// Thing checkpoints advance only if the job completes.
+func WithBulkImport(cfg BulkImportConfig) Option {
+	return func(a *TsAligner) {
+		if cfg.RowsPerFile < 1 {
+			cfg.RowsPerFile = defaultBulkRowsPerFile
+		}
+		if cfg.PollInterval < 1 {
+			cfg.PollInterval = defaultBulkPollInterval
+		}
+		if cfg.MaxWait < 1 {
+			cfg.MaxWait = defaultBulkMaxWait
+		}
+		a.bulk = &bulkImporter{BulkImportConfig: cfg, now: time.Now}
+	}
+}
+
+// bulkImporter stages data points written during a run and imports them with a bulk import job.
+type bulkImporter struct {
+	BulkImportConfig
+	sitewisecl sitewiseclient.API
+	staged     *bulkRecorder
+	now        func() time.Time
+}
+
+// wrap makes data points written through the returned client to be staged for the bulk import job.
+func (b *bulkImporter) wrap(sitewisecl sitewiseclient.API) sitewiseclient.API {
+	b.sitewisecl = sitewisecl
+	b.staged = &bulkRecorder{API: sitewisecl}
+	return b.staged
+}
+
+// run writes the data points staged during the run as CSV files, then starts the bulk import job and waits
+// for its completion. It returns the number of data points rejected by the job, reported in its error reports.
+func (b *bulkImporter) run(ctx context.Context, logger *logrus.Entry) (int64, error) {
+	rows := b.staged.take()
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	started := b.now().UTC()
+	var files []string
+	for i := 0; i < len(rows); i += b.RowsPerFile {
+		body, err := encodeBulkRows(rows[i:min(i+b.RowsPerFile, len(rows))])
+		if err != nil {
+			return 0, err
+		}
+		key := path.Join(b.Prefix, started.Format("20060102T150405Z"), fmt.Sprintf("part-%04d.csv", len(files)))
+		if err := b.Store.Put(ctx, b.Bucket, key, body); err != nil {
+			return 0, err
+		}
+		files = append(files, key)
+	}
+
+	job, err := b.sitewisecl.CreateDataBulkImportJob(ctx, int(started.Unix()), b.Bucket, files, b.RoleArn)
+	if err != nil {
+		return 0, fmt.Errorf("creating bulk import job: %w", err)
+	}
+	if job.JobId == nil {
+		return 0, nil
+	}
+	jobId := *job.JobId
+	logger.Infof("=====> Bulk import job %s started - %d data points in %d files", jobId, len(rows), len(files))
+
+	status, err := b.sitewisecl.WaitForBulkImportJob(ctx, jobId, sitewiseclient.PollOptions{Interval: b.PollInterval, MaxWait: b.MaxWait})
+	if err != nil {
+		return 0, err
+	}
+	switch status {
+	case types.JobStatusCompleted:
+		logger.Infof("=====> Bulk import job %s completed", jobId)
+		return 0, nil
+	case types.JobStatusCompletedWithFailures:
+		reportsLocation := fmt.Sprintf("s3://%s/%s", b.Bucket, sitewiseclient.BulkImportErrorReportPrefix)
+		rejected, err := FetchBulkImportErrors(ctx, b.Store, b.Bucket, jobId)
+		if err != nil {
+			return 0, fmt.Errorf("bulk import job %s completed with failures, see error reports in %s: %w", jobId, reportsLocation, err)
+		}
+		for _, row := range rejected[:min(len(rejected), maxLoggedBulkErrors)] {
+			logger.Warnln("Data point rejected by bulk import job:", row)
+		}
+		return int64(len(rejected)), fmt.Errorf("bulk import job %s completed with %d of %d data points rejected, see error reports in %s",
+			jobId, len(rejected), len(rows), reportsLocation)
+	default:
+		return 0, fmt.Errorf("bulk import job %s ended with status %s", jobId, status)
+	}
+}
+
+// bulkRecorder stages data points as bulk import CSV rows instead of writing them to SiteWise.
+// Any other call goes to the wrapped client.
+type bulkRecorder struct {
+	sitewiseclient.API
+
+	mu   sync.Mutex
+	rows [][]string
+	// Data types of property aliases, derived from the type of their thing property
+	dataTypes map[string]string
+}
+
+// setPropertyTypes records the data types of the imported properties of a thing, so that their values are
+// staged with the data type of the asset model property.
+func (r *bulkRecorder) setPropertyTypes(aliases map[string]string, properties map[string]iotclient.ArduinoProperty) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.dataTypes == nil {
+		r.dataTypes = map[string]string{}
+	}
+	for propertyID, alias := range aliases {
+		if p, ok := properties[propertyID]; ok && p.Type != "" {
+			r.dataTypes[alias] = bulkDataType(p.Type)
+		}
+	}
+}
+
+// row returns the bulk import row of a data point. Values of aliases with no recorded data type, e.g. aggregates
+// and coordinates, get the data type of the value.
+func (r *bulkRecorder) row(alias string, ts int64, value any, quality types.Quality) ([]string, bool) {
+	r.mu.Lock()
+	dataType, ok := r.dataTypes[alias]
+	r.mu.Unlock()
+	if !ok {
+		dataType = valueDataType(value)
+	}
+	return bulkRow(alias, dataType, ts, value, quality)
+}
+
+func (r *bulkRecorder) add(rows ...[]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rows = append(r.rows, rows...)
+}
+
+func (r *bulkRecorder) take() [][]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rows := r.rows
+	r.rows = nil
+	return rows
+}
+
+func (r *bulkRecorder) PopulateTimeSeriesByAlias(ctx context.Context, propertyAlias string, ts []int64, values []float64) error {
+	if len(ts) != len(values) {
+		return fmt.Errorf("timestamps and values must have the same length")
+	}
+	rows := make([][]string, 0, len(ts))
+	for i := range ts {
+		if row, ok := r.row(propertyAlias, ts[i], values[i], types.QualityGood); ok {
+			rows = append(rows, row)
+		}
+	}
+	r.add(rows...)
+	return nil
+}
+
+func (r *bulkRecorder) PopulateSampledSamplesTimeSeriesByAlias(ctx context.Context, propertyAlias string, ts []int64, values []any) error {
+	if len(ts) != len(values) {
+		return fmt.Errorf("timestamps and values must have the same length")
+	}
+	rows := make([][]string, 0, len(ts))
+	for i := range ts {
+		if row, ok := r.row(propertyAlias, ts[i], values[i], types.QualityGood); ok {
+			rows = append(rows, row)
+		}
+	}
+	r.add(rows...)
+	return nil
+}
+
+func (r *bulkRecorder) PopulateArbitrarySamplesByAlias(ctx context.Context, points []sitewiseclient.DataPoint) error {
+	rows := make([][]string, 0, len(points))
+	for _, p := range points {
+		quality := types.QualityGood
+		if p.Quality != "" {
+			quality = p.Quality
+		}
+		if row, ok := r.row(p.PropertyAlias, p.Ts, p.Value, quality); ok {
+			rows = append(rows, row)
+		}
+	}
+	r.add(rows...)
+	return nil
+}
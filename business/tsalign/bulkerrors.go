@@ -0,0 +1,89 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package tsalign
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"strings"
+
+	"github.com/arduino/aws-sitewise-integration/internal/objectstore"
+	"github.com/arduino/aws-sitewise-integration/internal/sitewiseclient"
+)
+
+// BulkImportRowError is a data point rejected by a bulk import job, as found in its error report.
+type BulkImportRowError struct {
+	// Row is the rejected CSV row: ALIAS, DATA_TYPE, TIMESTAMP_SECONDS, TIMESTAMP_NANO_OFFSET, QUALITY, VALUE
+	Row          []string
+	ErrorCode    string
+	ErrorMessage string
+}
+
+func (e BulkImportRowError) String() string {
+	return fmt.Sprintf("%s (%s: %s)", strings.Join(e.Row, ","), e.ErrorCode, e.ErrorMessage)
+}
+
+// FetchBulkImportErrors reads the error reports written by the bulk import job under the error report prefix
+// of the bucket, returning the rejected rows. Error reports are CSV files repeating each rejected row followed
+// by the error code and message.
+func FetchBulkImportErrors(ctx context.Context, store objectstore.API, bucket, jobId string) ([]BulkImportRowError, error) {
+	keys, err := store.List(ctx, bucket, sitewiseclient.BulkImportErrorReportPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var rejected []BulkImportRowError
+	for _, key := range keys {
+		if !strings.Contains(key, jobId) || strings.HasSuffix(key, "/") {
+			continue
+		}
+		report, err := store.Get(ctx, bucket, key)
+		if err != nil {
+			return nil, err
+		}
+		rows, err := parseBulkImportErrors(report)
+		if err != nil {
+			return nil, fmt.Errorf("parsing error report s3://%s/%s: %w", bucket, key, err)
+		}
+		rejected = append(rejected, rows...)
+	}
+	return rejected, nil
+}
+
+func parseBulkImportErrors(report []byte) ([]BulkImportRowError, error) {
+	r := csv.NewReader(bytes.NewReader(report))
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var rejected []BulkImportRowError
+	for _, record := range records {
+		if len(record) < 3 || strings.EqualFold(record[len(record)-2], "ERROR_CODE") {
+			// Header or empty line
+			continue
+		}
+		rejected = append(rejected, BulkImportRowError{
+			Row:          record[:len(record)-2],
+			ErrorCode:    record[len(record)-2],
+			ErrorMessage: record[len(record)-1],
+		})
+	}
+	return rejected, nil
+}
@@ -0,0 +1,139 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package tsalign
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/arduino/aws-sitewise-integration/internal/iot"
+	"github.com/aws/aws-sdk-go-v2/service/iotsitewise/types"
+)
+
+// Values of the DATA_TYPE column of bulk import CSV files
+const (
+	bulkDouble = "DOUBLE"
+	bulkString = "STRING"
+)
+
+// Rows of bulk import CSV files have columns ALIAS, DATA_TYPE, TIMESTAMP_SECONDS, TIMESTAMP_NANO_OFFSET, QUALITY,
+// VALUE, in the order declared by sitewiseclient.CreateDataBulkImportJob. A malformed row fails the whole job.
+
+// bulkDataType returns the DATA_TYPE of values of an Arduino property type. It must match the data type of asset
+// model properties: numbers and booleans are DOUBLE, anything else is STRING. See sitewiseclient.mapType.
+func bulkDataType(propertyType string) string {
+	if iot.IsPropertyNumberType(propertyType) || iot.IsPropertyBool(propertyType) {
+		return bulkDouble
+	}
+	return bulkString
+}
+
+// valueDataType returns the DATA_TYPE of a value whose property type is not known, as bulkDataType would for
+// the property type of the value.
+func valueDataType(value any) string {
+	switch value.(type) {
+	case bool, int, int32, int64, float32, float64:
+		return bulkDouble
+	}
+	return bulkString
+}
+
+// encodeBulkValue formats a sample value as the given data type. Booleans are written as 1 and 0, composite
+// values (e.g. locations) as JSON. NaN and infinite values are rejected by SiteWise.
+func encodeBulkValue(dataType string, value any) (string, bool) {
+	switch v := value.(type) {
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return "", false
+		}
+		return formatDouble(v), true
+	case float32:
+		return encodeBulkValue(dataType, float64(v))
+	case int:
+		return strconv.Itoa(v), true
+	case int32:
+		return strconv.FormatInt(int64(v), 10), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case bool:
+		if dataType == bulkDouble {
+			if v {
+				return "1", true
+			}
+			return "0", true
+		}
+		return strconv.FormatBool(v), true
+	case string:
+		if dataType == bulkDouble {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return "", false
+			}
+			return encodeBulkValue(dataType, f)
+		}
+		return v, true
+	case map[string]any:
+		if dataType == bulkDouble {
+			return "", false
+		}
+		j, err := json.Marshal(v)
+		if err != nil {
+			return "", false
+		}
+		return string(j), true
+	}
+	return "", false
+}
+
+// bulkRow returns the bulk import row of a data point of the given data type, false if the value can't be
+// represented with it.
+func bulkRow(alias, dataType string, ts int64, value any, quality types.Quality) ([]string, bool) {
+	encoded, ok := encodeBulkValue(dataType, value)
+	if !ok {
+		return nil, false
+	}
+	return bulkCSVRow(alias, dataType, time.Unix(ts, 0), quality, encoded), true
+}
+
+// bulkCSVRow splits the timestamp in epoch seconds and nanosecond offset within the second, always in [0, 999999999].
+func bulkCSVRow(alias, dataType string, ts time.Time, quality types.Quality, value string) []string {
+	return []string{
+		alias,
+		dataType,
+		strconv.FormatInt(ts.Unix(), 10),
+		strconv.Itoa(ts.Nanosecond()),
+		string(quality),
+		value,
+	}
+}
+
+func formatDouble(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func encodeBulkRows(rows [][]string) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.WriteAll(rows); err != nil {
+		return nil, fmt.Errorf("encoding bulk import rows: %w", err)
+	}
+	return buf.Bytes(), nil
+}
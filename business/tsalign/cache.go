@@ -0,0 +1,69 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package tsalign
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/iotsitewise"
+)
+
+type discoveredAsset struct {
+	assetId     string
+	assetName   string
+	thingId     string
+	description *iotsitewise.DescribeAssetOutput
+}
+
+// DiscoveryCache keeps the SiteWise assets (and their property descriptions) found by a full scan,
+// so that runs close in time can skip the discovery of models and assets.
+// It is meant to live across invocations of a warm Lambda.
+type DiscoveryCache struct {
+	mu        sync.Mutex
+	scannedAt time.Time
+	assets    []*discoveredAsset
+	now       func() time.Time
+}
+
+func NewDiscoveryCache() *DiscoveryCache {
+	return &DiscoveryCache{now: time.Now}
+}
+
+// Invalidate drops cached assets, forcing a full scan on next run. It must be called every time
+// models or assets are aligned.
+func (c *DiscoveryCache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.assets = nil
+	c.scannedAt = time.Time{}
+}
+
+func (c *DiscoveryCache) get(minScanInterval time.Duration) ([]*discoveredAsset, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.scannedAt.IsZero() || minScanInterval <= 0 || c.now().Sub(c.scannedAt) >= minScanInterval {
+		return nil, false
+	}
+	return c.assets, true
+}
+
+func (c *DiscoveryCache) set(assets []*discoveredAsset) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.assets = assets
+	c.scannedAt = c.now()
+}
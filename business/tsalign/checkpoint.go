@@ -0,0 +1,188 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package tsalign
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/arduino/aws-sitewise-integration/internal/statestore"
+)
+
+// Prefix of the state store keys of thing checkpoints, followed by the thing id
+const checkpointKeyPrefix = "checkpoint/"
+
+// Checkpoints keeps, per thing, the end of the last time window successfully imported, so that
+// following runs start from there instead of importing again the same samples.
+// It is meant to live across invocations of a warm Lambda.
+type Checkpoints struct {
+	mu     sync.Mutex
+	things map[string]time.Time
+}
+
+func NewCheckpoints() *Checkpoints {
+	return &Checkpoints{things: map[string]time.Time{}}
+}
+
+// Get returns the checkpoint of the given thing, if any.
+func (c *Checkpoints) Get(thingID string) (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, ok := c.things[thingID]
+	return t, ok
+}
+
+// advance moves the checkpoint of the thing forward to the given time. Checkpoints never move back.
+func (c *Checkpoints) advance(thingID string, to time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if current, ok := c.things[thingID]; ok && !to.After(current) {
+		return
+	}
+	c.things[thingID] = to
+}
+
+// window returns the time window to import for the thing, starting from its checkpoint
+// when that falls within [from, to). With a catch up window, a checkpoint before from moves the start
+// back to catch up missed runs, down to maxCatchUp before to.
+func (c *Checkpoints) window(thingID string, from, to time.Time, maxCatchUp time.Duration) (time.Time, time.Time) {
+	checkpoint, ok := c.Get(thingID)
+	if !ok || !checkpoint.Before(to) {
+		return from, to
+	}
+	if maxCatchUp > 0 {
+		catchUpFrom := to.Add(-maxCatchUp)
+		if from.Before(catchUpFrom) {
+			catchUpFrom = from
+		}
+		if checkpoint.After(catchUpFrom) {
+			return checkpoint, to
+		}
+		return catchUpFrom, to
+	}
+	if checkpoint.After(from) {
+		return checkpoint, to
+	}
+	return from, to
+}
+
+// update advances the checkpoint of a thing after its import, returning whether it advanced. Failed
+// fetches never advance it, while a successful fetch returning no data advances it only if advanceOnEmpty
+// is set.
+func (c *Checkpoints) update(thingID string, to time.Time, importedProperties []string, err error, advanceOnEmpty bool) bool {
+	if !checkpointAdvances(importedProperties, err, advanceOnEmpty) {
+		return false
+	}
+	c.advance(thingID, to)
+	return true
+}
+
+// checkpointAdvances tells whether a thing import moves its checkpoint forward, see update.
+func checkpointAdvances(importedProperties []string, err error, advanceOnEmpty bool) bool {
+	if err != nil {
+		return false
+	}
+	return len(importedProperties) > 0 || advanceOnEmpty
+}
+
+// pendingCheckpoints collects the checkpoints of things whose data points are staged for a bulk import job,
+// to be advanced only once the job completes.
+type pendingCheckpoints struct {
+	mu     sync.Mutex
+	things map[string]time.Time
+}
+
+func newPendingCheckpoints() *pendingCheckpoints {
+	return &pendingCheckpoints{things: map[string]time.Time{}}
+}
+
+func (p *pendingCheckpoints) add(thingID string, to time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.things[thingID] = to
+}
+
+func (p *pendingCheckpoints) take() map[string]time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	things := p.things
+	p.things = map[string]time.Time{}
+	return things
+}
+
+// WithCheckpointStore persists thing checkpoints in the given state store, so that they survive cold starts
+// and are shared by Lambda instances. They are read before and written after each thing import, or once
+// the job completes with bulk import. A thing whose checkpoint is older than the run time window, e.g. after
+// missed runs, is imported from its checkpoint, up to maxCatchUp in the past. Requires WithCheckpoints.
+func WithCheckpointStore(store statestore.Store, maxCatchUp time.Duration) Option {
+	return func(a *TsAligner) {
+		a.checkpointStore = store
+		a.maxCatchUp = maxCatchUp
+	}
+}
+
+// catchUpWindow returns how far in the past a thing import can start from its stored checkpoint, in
+// whole buckets so that aggregated windows stay aligned.
+func (a *TsAligner) catchUpWindow(resolution int) time.Duration {
+	if a.checkpointStore == nil {
+		return 0
+	}
+	if bucket := a.bucketSize(resolution); bucket > 0 {
+		return a.maxCatchUp.Truncate(bucket)
+	}
+	return a.maxCatchUp
+}
+
+// loadCheckpoint advances the checkpoint of the thing to the stored one, if more recent. Store errors
+// are logged: the thing is imported from its in memory checkpoint, if any.
+func (a *TsAligner) loadCheckpoint(ctx context.Context, thingID string) {
+	if a.checkpointStore == nil {
+		return
+	}
+	value, ok, err := a.checkpointStore.GetState(ctx, checkpointKeyPrefix+thingID)
+	if err != nil {
+		a.logger.Warnln("Error reading stored checkpoint:", err)
+		return
+	}
+	if !ok {
+		return
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		a.logger.Warnln("Error reading stored checkpoint:", fmt.Errorf("invalid checkpoint of thing %s: %w", thingID, err))
+		return
+	}
+	a.checkpoints.advance(thingID, t)
+}
+
+// commitCheckpoint advances the checkpoint of the thing and stores it.
+func (a *TsAligner) commitCheckpoint(ctx context.Context, thingID string, t time.Time) {
+	a.checkpoints.advance(thingID, t)
+	a.storeCheckpoint(ctx, thingID, t)
+}
+
+// storeCheckpoint writes the checkpoint of the thing. Store errors are logged: at worst the next run
+// imports again the same window.
+func (a *TsAligner) storeCheckpoint(ctx context.Context, thingID string, t time.Time) {
+	if a.checkpointStore == nil {
+		return
+	}
+	if err := a.checkpointStore.PutState(ctx, checkpointKeyPrefix+thingID, t.UTC().Format(time.RFC3339Nano)); err != nil {
+		a.logger.Warnln("Error storing checkpoint:", err)
+	}
+}
@@ -0,0 +1,90 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package tsalign
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/iotsitewise/types"
+)
+
+// importTokens bounds the number of things imported concurrently. When adaptive, the effective
+// bound is adjusted AIMD-style: halved when SiteWise throttles and increased by one every limit
+// successful imports, never exceeding the channel capacity.
+type importTokens struct {
+	tokens chan struct{}
+
+	adaptive bool
+	mu       sync.Mutex
+	cond     *sync.Cond
+	limit    float64
+	inFlight int
+}
+
+func newImportTokens(concurrency int, adaptive bool) *importTokens {
+	t := &importTokens{
+		tokens:   make(chan struct{}, concurrency),
+		adaptive: adaptive,
+		limit:    float64(concurrency),
+	}
+	t.cond = sync.NewCond(&t.mu)
+	return t
+}
+
+func (t *importTokens) acquire() {
+	t.tokens <- struct{}{}
+	if !t.adaptive {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for t.inFlight >= int(t.limit) {
+		t.cond.Wait()
+	}
+	t.inFlight++
+}
+
+// release returns the token, adapting the concurrency to the outcome of the import.
+func (t *importTokens) release(err error) {
+	if t.adaptive {
+		t.mu.Lock()
+		t.inFlight--
+		if isThrottling(err) {
+			t.limit = max(1, t.limit/2)
+		} else {
+			t.limit = min(float64(cap(t.tokens)), t.limit+1/t.limit)
+		}
+		t.cond.Broadcast()
+		t.mu.Unlock()
+	}
+	<-t.tokens
+}
+
+// current returns the effective concurrency.
+func (t *importTokens) current() int {
+	if !t.adaptive {
+		return cap(t.tokens)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return int(t.limit)
+}
+
+func isThrottling(err error) bool {
+	var throttling *types.ThrottlingException
+	return errors.As(err, &throttling)
+}
@@ -0,0 +1,61 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package tsalign
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/arduino/aws-sitewise-integration/internal/deadletter"
+)
+
+// WithDeadLetters sends a record of each thing whose import failed, after retries, to the given sink,
+// so that failures can be imported again on their own. Nil sends nothing.
+func WithDeadLetters(sink deadletter.API) Option {
+	return func(a *TsAligner) {
+		a.deadLetters = sink
+	}
+}
+
+// deadLetterRecords returns the records of the things whose import failed among the errors of a run.
+func deadLetterRecords(errs []error, now time.Time) []deadletter.Record {
+	var records []deadletter.Record
+	for _, err := range errs {
+		var thingErr *ThingImportError
+		if errors.As(err, &thingErr) {
+			records = append(records, deadletter.Record{ThingID: thingErr.ThingID, Error: thingErr.Err.Error(), Timestamp: now.UTC()})
+		}
+	}
+	return records
+}
+
+// sendDeadLetters sends the records of failed things, if a sink is set. Sink errors are logged: the
+// failures are reported by the run anyway.
+func (a *TsAligner) sendDeadLetters(ctx context.Context, errs []error) {
+	if a.deadLetters == nil {
+		return
+	}
+	records := deadLetterRecords(errs, time.Now())
+	if len(records) == 0 {
+		return
+	}
+	if err := a.deadLetters.Send(ctx, records); err != nil {
+		a.logger.Error("Error sending dead letter records: ", err)
+		return
+	}
+	a.logger.Infoln("=====> Dead letter records sent for ", len(records), " things")
+}
@@ -0,0 +1,51 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package tsalign
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WithDisplayLocation sets the timezone of the times written in logs. Time windows and requests stay in UTC.
+// Logged data point timestamps, epoch seconds by default, are written as times in the given timezone.
+func WithDisplayLocation(loc *time.Location) Option {
+	return func(a *TsAligner) {
+		a.displayLocation = loc
+	}
+}
+
+// displayTime formats the time for logs, in the display timezone if set, otherwise in UTC
+func (a *TsAligner) displayTime(t time.Time) string {
+	if a.displayLocation == nil {
+		return t.UTC().String()
+	}
+	return t.In(a.displayLocation).String()
+}
+
+// joinTs formats data point timestamps for logs
+func (a *TsAligner) joinTs(ts []int64) string {
+	tsarr := make([]string, 0, len(ts))
+	for _, v := range ts {
+		if a.displayLocation == nil {
+			tsarr = append(tsarr, strconv.FormatInt(v, 10))
+		} else {
+			tsarr = append(tsarr, time.Unix(v, 0).In(a.displayLocation).Format(time.RFC3339))
+		}
+	}
+	return strings.Join(tsarr, ",")
+}
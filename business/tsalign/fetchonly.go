@@ -0,0 +1,40 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package tsalign
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// fetchStats counts the time series fetched from Arduino IoT Cloud in fetch-only mode.
+type fetchStats struct {
+	requests atomic.Int64
+	points   atomic.Int64
+}
+
+func (s *fetchStats) record(points int64) {
+	s.requests.Add(1)
+	s.points.Add(points)
+}
+
+// throughput returns the fetched points per second over the given elapsed time.
+func (s *fetchStats) throughput(elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(s.points.Load()) / elapsed.Seconds()
+}
@@ -0,0 +1,55 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package tsalign
+
+import (
+	"context"
+	"sync"
+
+	"github.com/arduino/aws-sitewise-integration/internal/sitewiseclient"
+)
+
+// lastValueCollector gathers last value data points of all things imported in a run,
+// so that they can be written with the minimum number of batches.
+type lastValueCollector struct {
+	mu     sync.Mutex
+	points []sitewiseclient.DataPoint
+}
+
+func (c *lastValueCollector) add(points []sitewiseclient.DataPoint) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.points = append(c.points, points...)
+}
+
+func (c *lastValueCollector) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.points)
+}
+
+// flush writes all collected data points. The client splits them in batches compliant with SiteWise limits.
+func (c *lastValueCollector) flush(ctx context.Context, sitewisecl sitewiseclient.API) error {
+	c.mu.Lock()
+	points := c.points
+	c.points = nil
+	c.mu.Unlock()
+
+	if len(points) == 0 {
+		return nil
+	}
+	return sitewisecl.PopulateArbitrarySamplesByAlias(ctx, points)
+}
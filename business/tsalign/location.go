@@ -0,0 +1,143 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package tsalign
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/arduino/aws-sitewise-integration/business/entityalign"
+	"github.com/sirupsen/logrus"
+)
+
+// Keys of the coordinates in Arduino location values
+const (
+	locationLatKey = "lat"
+	locationLngKey = "lon"
+)
+
+// parseLocation decodes an Arduino location value, an object with "lat" and "lon" numeric fields.
+// The object can also come JSON encoded.
+func parseLocation(value any) (float64, float64, error) {
+	if encoded, ok := value.(string); ok {
+		var decoded map[string]any
+		if err := json.Unmarshal([]byte(encoded), &decoded); err != nil {
+			return 0, 0, fmt.Errorf("invalid location %q: %w", encoded, err)
+		}
+		value = decoded
+	}
+	fields, ok := value.(map[string]any)
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid location %v: not an object", value)
+	}
+	lat, err := locationCoordinate(fields, locationLatKey, 90)
+	if err != nil {
+		return 0, 0, err
+	}
+	lng, err := locationCoordinate(fields, locationLngKey, 180)
+	if err != nil {
+		return 0, 0, err
+	}
+	return lat, lng, nil
+}
+
+func locationCoordinate(fields map[string]any, key string, limit float64) (float64, error) {
+	var coordinate float64
+	switch v := fields[key].(type) {
+	case float64:
+		coordinate = v
+	case string:
+		parsed, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid location %s %q", key, v)
+		}
+		coordinate = parsed
+	case nil:
+		return 0, fmt.Errorf("invalid location: missing %s", key)
+	default:
+		return 0, fmt.Errorf("invalid location %s %v", key, v)
+	}
+	if coordinate < -limit || coordinate > limit {
+		return 0, fmt.Errorf("invalid location %s %v: out of range", key, coordinate)
+	}
+	return coordinate, nil
+}
+
+// formatLocation returns the canonical "lat,long" string of a location
+func formatLocation(lat, lng float64) string {
+	return strconv.FormatFloat(lat, 'f', -1, 64) + "," + strconv.FormatFloat(lng, 'f', -1, 64)
+}
+
+// locationSamples holds location samples normalized to canonical strings, along with their coordinates
+type locationSamples struct {
+	chunk chunkAnyValue
+	lat   []float64
+	lng   []float64
+}
+
+// normalizeLocations converts location samples to canonical strings. Malformed samples are skipped with a warning.
+func (a *TsAligner) normalizeLocations(thingID, alias string, c chunkAnyValue) locationSamples {
+	samples := locationSamples{}
+	for i, value := range c.values {
+		lat, lng, err := parseLocation(value)
+		if err != nil {
+			a.logger.WithFields(logrus.Fields{logFieldThingID: thingID, logFieldPropertyAlias: alias}).Warnln("Skipping location sample at", c.ts[i], "-", err)
+			a.counters.skipped.Add(1)
+			continue
+		}
+		samples.chunk.ts = append(samples.chunk.ts, c.ts[i])
+		samples.chunk.values = append(samples.chunk.values, formatLocation(lat, lng))
+		samples.lat = append(samples.lat, lat)
+		samples.lng = append(samples.lng, lng)
+	}
+	return samples
+}
+
+// coordinates returns the values of the given coordinate, one of entityalign.LocationCoordinates
+func (s locationSamples) coordinates(coordinate string) []float64 {
+	if coordinate == entityalign.LocationLatitude {
+		return s.lat
+	}
+	return s.lng
+}
+
+// populateLocationSeries writes normalized location samples and, if mapped, their coordinates.
+// It returns the number of location samples written.
+func (a *TsAligner) populateLocationSeries(
+	ctx context.Context,
+	thingID, propertyID, alias string,
+	c chunkAnyValue,
+	mappedProperties *mappedProperties) (int, error) {
+
+	locations := a.normalizeLocations(thingID, alias, c)
+	if len(locations.chunk.ts) == 0 {
+		return 0, nil
+	}
+	a.logger.WithFields(logrus.Fields{logFieldThingID: thingID, logFieldPropertyAlias: alias, logFieldPoints: len(locations.chunk.ts)}).Debugln("Importing location data points - ts:", a.joinTs(locations.chunk.ts))
+	if err := a.sitewisecl.PopulateSampledSamplesTimeSeriesByAlias(ctx, alias, locations.chunk.ts, locations.chunk.values); err != nil {
+		return 0, err
+	}
+	a.counters.written.Add(int64(len(locations.chunk.ts)))
+	for coordinate, coordinateAlias := range mappedProperties.CoordinateAliases[propertyID] {
+		if err := a.sitewisecl.PopulateTimeSeriesByAlias(ctx, coordinateAlias, locations.chunk.ts, locations.coordinates(coordinate)); err != nil {
+			return 0, err
+		}
+		a.counters.written.Add(int64(len(locations.chunk.ts)))
+	}
+	return len(locations.chunk.ts), nil
+}
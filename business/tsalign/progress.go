@@ -0,0 +1,99 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package tsalign
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// WithProgressLog logs the progress of the import every given number of things and every given interval,
+// so that long runs show how far they got and stuck ones can be told apart. Zero disables either.
+func WithProgressLog(everyThings int, interval time.Duration) Option {
+	return func(a *TsAligner) {
+		a.progressEvery = everyThings
+		a.progressInterval = interval
+	}
+}
+
+// progress counts the things imported by the concurrent thing imports of a run, and logs how many are done.
+type progress struct {
+	logger   *logrus.Entry
+	total    int
+	every    int64
+	interval time.Duration
+	done     atomic.Int64
+	failed   atomic.Int64
+	stop     chan struct{}
+	stopped  sync.WaitGroup
+}
+
+// startProgress starts reporting the progress of the import of total things. Nil if progress logs are disabled.
+func (a *TsAligner) startProgress(total int) *progress {
+	if a.progressEvery <= 0 && a.progressInterval <= 0 {
+		return nil
+	}
+	p := &progress{logger: a.logger, total: total, every: int64(a.progressEvery), interval: a.progressInterval, stop: make(chan struct{})}
+	if p.interval > 0 {
+		// Logged on a timer rather than on completions, so that a run stuck on some things keeps logging
+		p.stopped.Add(1)
+		go func() {
+			defer p.stopped.Done()
+			ticker := time.NewTicker(p.interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-p.stop:
+					return
+				case <-ticker.C:
+					p.log(p.done.Load())
+				}
+			}
+		}()
+	}
+	return p
+}
+
+// thingDone records the end of the import of a thing. Safe for concurrent use, and on nil.
+func (p *progress) thingDone(failed bool) {
+	if p == nil {
+		return
+	}
+	if failed {
+		p.failed.Add(1)
+	}
+	done := p.done.Add(1)
+	if p.every > 0 && done%p.every == 0 {
+		p.log(done)
+	}
+}
+
+// finish stops the progress timer, once all the things are done.
+func (p *progress) finish() {
+	if p == nil {
+		return
+	}
+	close(p.stop)
+	p.stopped.Wait()
+}
+
+func (p *progress) log(done int64) {
+	p.logger.WithFields(logrus.Fields{logFieldThingsDone: done, logFieldThingsTotal: p.total, logFieldThingsFailed: p.failed.Load()}).
+		Infof("=====> Progress - processed %d of %d things (%d errors so far)", done, p.total, p.failed.Load())
+}
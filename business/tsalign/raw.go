@@ -0,0 +1,158 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package tsalign
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/arduino/aws-sitewise-integration/internal/iot"
+	iotclient "github.com/arduino/iot-client-go/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// computeRawTimeWindow returns the import time window for raw import. As samples are not aggregated, there are
+// no buckets to align to: the window just ends at now, less the clock skew tolerance.
+func computeRawTimeWindow(now time.Time, timeWindowInMinutes int, clockSkewTolerance time.Duration) (time.Time, time.Time) {
+	to := now.Add(-clockSkewTolerance).Truncate(time.Second).UTC()
+	from := to.Add(-time.Duration(timeWindowInMinutes) * time.Minute)
+	return from, to
+}
+
+// populateRawTSDataIntoSiteWise imports the samples of all the mapped properties as stored on Arduino IoT Cloud,
+// without aggregation. Numeric samples are written as doubles, string based ones as sampled values.
+func (a *TsAligner) populateRawTSDataIntoSiteWise(
+	ctx context.Context,
+	thingID string,
+	mappedProperties *mappedProperties,
+	from, to time.Time) ([]string, error) {
+
+	propertyIDs := slices.Concat(mappedProperties.PropertiesToImport, mappedProperties.CharPropertiesToImport)
+	if len(propertyIDs) == 0 {
+		return nil, nil
+	}
+
+	batched, err := fetchWithRetry(ctx, a, thingID, func() (*iotclient.ArduinoSeriesRawBatch, error) {
+		return a.iotcl.GetRawTimeSeriesByProperties(ctx, propertyIDs, from, to)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if a.fetchOnly {
+		var points int64
+		for _, response := range batched.Responses {
+			points += response.CountValues
+		}
+		a.fetchStats.record(points)
+		return nil, nil
+	}
+
+	propertiesImported := []string{}
+	batch := a.newAliasBatch()
+	for _, response := range batched.Responses {
+		if response.CountValues == 0 {
+			continue
+		}
+
+		propertyID := strings.Replace(response.Query, "property.", "", 1)
+		alias := mappedProperties.PropertiesToImportAliases[propertyID]
+		if alias == "" {
+			a.logger.WithFields(logrus.Fields{logFieldThingID: thingID, logFieldPropertyID: propertyID}).Debug("Not mapped property. Skipping import.")
+			a.counters.skipped.Add(response.CountValues)
+			continue
+		}
+		if response.CountValues >= iot.RawSeriesLimit {
+			a.logger.WithFields(logrus.Fields{logFieldThingID: thingID, logFieldPropertyAlias: alias}).Warnf("Raw samples limit of %d reached, later samples in the time window are not imported. Reduce the time window.", iot.RawSeriesLimit)
+		}
+
+		c := toRawChunk(response)
+		a.logger.WithFields(logrus.Fields{logFieldThingID: thingID, logFieldPropertyAlias: alias, logFieldPoints: len(c.ts)}).Debug("Importing raw data points")
+		if slices.Contains(mappedProperties.LocationProperties, propertyID) {
+			written, err := a.populateLocationSeries(ctx, thingID, propertyID, alias, c, mappedProperties)
+			if err != nil {
+				return nil, err
+			}
+			if written > 0 {
+				propertiesImported = append(propertiesImported, propertyID)
+			}
+			continue
+		}
+		var written int
+		if slices.Contains(mappedProperties.PropertiesToImport, propertyID) {
+			ts, values := toNumericValues(c)
+			if len(ts) == 0 {
+				continue
+			}
+			values = transformValues(mappedProperties.Transforms[propertyID], values)
+			err = batch.addNumeric(ctx, alias, ts, values)
+			written = len(ts)
+		} else {
+			err = batch.addSampled(ctx, alias, c.ts, c.values)
+			written = len(c.ts)
+		}
+		if err != nil {
+			return nil, err
+		}
+		a.counters.written.Add(int64(written))
+		propertiesImported = append(propertiesImported, propertyID)
+	}
+	if err := batch.flush(ctx); err != nil {
+		return nil, err
+	}
+	return propertiesImported, nil
+}
+
+// toRawChunk converts raw samples to a chunk. SiteWise timestamps are written with second precision, so only
+// the last sample of each second is kept.
+func toRawChunk(response iotclient.ArduinoSeriesRawResponse) chunkAnyValue {
+	unixTimes := make([]int64, len(response.Times))
+	for j := 0; j < len(response.Times); j++ {
+		unixTimes[j] = response.Times[j].Unix()
+	}
+	ts, values := dedupeTimestamps(unixTimes, response.Values)
+	return chunkAnyValue{
+		ts:     ts,
+		values: values,
+	}
+}
+
+// toNumericValues returns the samples of the chunk that can be written as doubles. Booleans are written as 0 and 1.
+func toNumericValues(c chunkAnyValue) ([]int64, []float64) {
+	ts := make([]int64, 0, len(c.ts))
+	values := make([]float64, 0, len(c.values))
+	for i, v := range c.values {
+		var value float64
+		switch n := v.(type) {
+		case float64:
+			value = n
+		case int:
+			value = float64(n)
+		case int64:
+			value = float64(n)
+		case bool:
+			if n {
+				value = 1
+			}
+		default:
+			continue
+		}
+		ts = append(ts, c.ts[i])
+		values = append(values, value)
+	}
+	return ts, values
+}
@@ -0,0 +1,86 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package tsalign
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// ImportSummary reports what a time series import run wrote to SiteWise.
+type ImportSummary struct {
+	ThingsProcessed    int64 `json:"thingsProcessed"`
+	PropertiesImported int64 `json:"propertiesImported"`
+	PointsWritten      int64 `json:"pointsWritten"`
+	PointsSkipped      int64 `json:"pointsSkipped"`
+	ThrottleRetries    int64 `json:"throttleRetries"`
+	// Data points rejected by the bulk import job, not counted as written
+	PointsRejected int64 `json:"pointsRejected,omitempty"`
+}
+
+// Add accumulates the summary of another import, as for runs sweeping more organizations.
+func (s *ImportSummary) Add(other ImportSummary) {
+	s.ThingsProcessed += other.ThingsProcessed
+	s.PropertiesImported += other.PropertiesImported
+	s.PointsWritten += other.PointsWritten
+	s.PointsSkipped += other.PointsSkipped
+	s.ThrottleRetries += other.ThrottleRetries
+	s.PointsRejected += other.PointsRejected
+}
+
+// ThingImportError is the error importing the time series of a thing, among the errors of a run.
+type ThingImportError struct {
+	ThingID string
+	Err     error
+}
+
+func (e *ThingImportError) Error() string {
+	return fmt.Sprintf("importing thing %s: %v", e.ThingID, e.Err)
+}
+
+func (e *ThingImportError) Unwrap() error {
+	return e.Err
+}
+
+// importCounters accumulates the import summary across the concurrent thing imports of a run.
+type importCounters struct {
+	things     atomic.Int64
+	properties atomic.Int64
+	written    atomic.Int64
+	skipped    atomic.Int64
+	throttled  atomic.Int64
+	rejected   atomic.Int64
+}
+
+func (c *importCounters) reset() {
+	c.things.Store(0)
+	c.properties.Store(0)
+	c.written.Store(0)
+	c.skipped.Store(0)
+	c.throttled.Store(0)
+	c.rejected.Store(0)
+}
+
+func (c *importCounters) summary() ImportSummary {
+	return ImportSummary{
+		ThingsProcessed:    c.things.Load(),
+		PropertiesImported: c.properties.Load(),
+		PointsWritten:      c.written.Load(),
+		PointsSkipped:      c.skipped.Load(),
+		ThrottleRetries:    c.throttled.Load(),
+		PointsRejected:     c.rejected.Load(),
+	}
+}
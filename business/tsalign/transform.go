@@ -0,0 +1,144 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package tsalign
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Transform converts values of a numeric property before they are written to SiteWise, e.g. to change
+// their unit of measure.
+type Transform interface {
+	Apply(value float64) float64
+}
+
+// LinearTransform scales and offsets values: Scale*x + Offset.
+type LinearTransform struct {
+	Scale  float64
+	Offset float64
+}
+
+func (t LinearTransform) Apply(value float64) float64 {
+	return t.Scale*value + t.Offset
+}
+
+func (t LinearTransform) String() string {
+	return fmt.Sprintf("%s*x%+g", strconv.FormatFloat(t.Scale, 'g', -1, 64), t.Offset)
+}
+
+// ParseValueTransforms parses a comma separated list of linear transforms of property values
+// (e.g. "temperature=1.8*x+32,voltage=0.0048*x"), returning transforms keyed by property name.
+func ParseValueTransforms(transforms string) (map[string]Transform, error) {
+	parsed := map[string]Transform{}
+	for _, t := range strings.Split(transforms, ",") {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		name, expression, ok := strings.Cut(t, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid value transform: %s", t)
+		}
+		transform, err := parseLinearTransform(expression)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value transform for property %s: %w", name, err)
+		}
+		parsed[name] = transform
+	}
+	return parsed, nil
+}
+
+// parseLinearTransform parses expressions like "x", "-x", "1.8*x", "x+32" or "1.8*x-32".
+func parseLinearTransform(expression string) (LinearTransform, error) {
+	expression = strings.ReplaceAll(expression, " ", "")
+	if strings.Count(expression, "x") != 1 {
+		return LinearTransform{}, fmt.Errorf("expression %q is not in the scale*x+offset form", expression)
+	}
+	scaleExpr, offsetExpr, _ := strings.Cut(expression, "x")
+
+	t := LinearTransform{Scale: 1}
+	switch {
+	case scaleExpr == "":
+	case scaleExpr == "-":
+		t.Scale = -1
+	case strings.HasSuffix(scaleExpr, "*"):
+		scale, err := parseFiniteFloat(strings.TrimSuffix(scaleExpr, "*"))
+		if err != nil {
+			return LinearTransform{}, err
+		}
+		t.Scale = scale
+	default:
+		return LinearTransform{}, fmt.Errorf("expression %q is not in the scale*x+offset form", expression)
+	}
+	if offsetExpr != "" {
+		if offsetExpr[0] != '+' && offsetExpr[0] != '-' {
+			return LinearTransform{}, fmt.Errorf("expression %q is not in the scale*x+offset form", expression)
+		}
+		offset, err := parseFiniteFloat(offsetExpr)
+		if err != nil {
+			return LinearTransform{}, err
+		}
+		t.Offset = offset
+	}
+	return t, nil
+}
+
+func parseFiniteFloat(s string) (float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	return f, nil
+}
+
+// WithValueTransforms sets transforms applied to values of numeric properties, by property name, before
+// they are written to SiteWise. Transforms of non numeric properties are ignored. See ParseValueTransforms.
+func WithValueTransforms(transforms map[string]Transform) Option {
+	return func(a *TsAligner) {
+		a.valueTransforms = transforms
+	}
+}
+
+// transformedAggregation returns the aggregation of transformed values: a transform with a negative scale
+// turns the minimum of the values into the maximum of the transformed ones, and vice versa.
+func transformedAggregation(t Transform, aggregation string) string {
+	if linear, ok := t.(LinearTransform); !ok || linear.Scale >= 0 {
+		return aggregation
+	}
+	switch aggregation {
+	case "MIN":
+		return "MAX"
+	case "MAX":
+		return "MIN"
+	}
+	return aggregation
+}
+
+// transformValues returns values converted by the transform, if any.
+func transformValues(t Transform, values []float64) []float64 {
+	if t == nil {
+		return values
+	}
+	transformed := make([]float64, len(values))
+	for i, v := range values {
+		transformed[i] = t.Apply(v)
+	}
+	return transformed
+}
@@ -17,6 +17,7 @@ package tsalign
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"slices"
 	"strings"
@@ -27,154 +28,493 @@ import (
 	"math/big"
 
 	"github.com/arduino/aws-sitewise-integration/business/entityalign"
+	"github.com/arduino/aws-sitewise-integration/business/propfilter"
+	"github.com/arduino/aws-sitewise-integration/internal/deadletter"
 	"github.com/arduino/aws-sitewise-integration/internal/iot"
 	"github.com/arduino/aws-sitewise-integration/internal/sitewiseclient"
+	"github.com/arduino/aws-sitewise-integration/internal/statestore"
 	iotclient "github.com/arduino/iot-client-go/v2"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/iotsitewise"
+	"github.com/aws/aws-sdk-go-v2/service/iotsitewise/types"
 	"github.com/sirupsen/logrus"
 )
 
-const importConcurrency = 10
-const retryCount = 5
+// Default number of things imported concurrently
+const defaultImportConcurrency = 10
+
+// Default attempts of IoT API requests failing because of rate limiting, and base delay between them
+const (
+	defaultRateLimitRetries = 5
+	defaultRateLimitBackoff = time.Second
+	maxRateLimitBackoffExp  = 5
+)
+
+// Aggregations queried when min/max import is enabled. The default one is imported into the property itself.
+const defaultAggregation = "AVG"
+
+var importedAggregations = append([]string{defaultAggregation}, entityalign.MinMaxAggregations...)
+
+// Fields of structured log entries, named consistently so that JSON logs can be queried by them
+const (
+	logFieldThingID       = "thing_id"
+	logFieldAssetID       = "asset_id"
+	logFieldPropertyID    = "property_id"
+	logFieldPropertyAlias = "property_alias"
+	logFieldPoints        = "points"
+	logFieldAggregation   = "aggregation"
+	logFieldThingsDone    = "things_done"
+	logFieldThingsTotal   = "things_total"
+	logFieldThingsFailed  = "things_failed"
+)
 
 type TsAligner struct {
 	sitewisecl sitewiseclient.API
 	iotcl      iot.API
 	logger     *logrus.Entry
+
+	importConcurrency       int
+	rateLimitRetries        int
+	rateLimitBackoff        time.Duration
+	adaptiveConcurrency     bool
+	parallelPropertyImport  bool
+	discoveryCache          *DiscoveryCache
+	minScanInterval         time.Duration
+	nilLastValuePlaceholder bool
+	propertyFilter          *propfilter.Filter
+	clockSkewTolerance      time.Duration
+	windowOverlap           time.Duration
+	displayLocation         *time.Location
+	minMaxAggregation       bool
+	partialAssetPolicy      PartialAssetPolicy
+	batchLastValues         bool
+	fetchOnly               bool
+	fetchStats              fetchStats
+	rawImport               bool
+	updatedAt               bool
+	locationCoords          bool
+	aggregationOverrides    map[string]string
+	valueTransforms         map[string]Transform
+	aliasTemplate           *entityalign.AliasTemplate
+	aliasBatching           bool
+	attributeProperties     bool
+	modelAttributes         modelAttributes
+	counters                importCounters
+	checkpoints             *Checkpoints
+	advanceEmptyCheckpoints bool
+	checkpointStore         statestore.Store
+	deadLetters             deadletter.API
+	progressEvery           int
+	progressInterval        time.Duration
+	maxCatchUp              time.Duration
+	lastValueOnly           bool
+	lastValuePolicy         LastValuePolicy
+	bulk                    *bulkImporter
+	thingTimeout            time.Duration
+	// Clock of the run time windows
+	now func() time.Time
 }
 
-func New(sitewisecl sitewiseclient.API, iotcl iot.API, logger *logrus.Entry) *TsAligner {
-	return &TsAligner{sitewisecl: sitewisecl, iotcl: iotcl, logger: logger}
+// PartialAssetPolicy defines how assets being updated, whose description may miss properties, are imported
+type PartialAssetPolicy string
+
+const (
+	// PartialAssetImport imports the properties found in the asset description
+	PartialAssetImport PartialAssetPolicy = "import"
+	// PartialAssetDefer waits for the asset update to complete, skipping the asset for this run if it doesn't
+	PartialAssetDefer PartialAssetPolicy = "defer"
+)
+
+// LastValuePolicy defines which properties get their last value written when their series has no samples
+// in the imported time window
+type LastValuePolicy string
+
+const (
+	// LastValueOnChange carries the last value of ON_CHANGE properties
+	LastValueOnChange LastValuePolicy = "on-change"
+	// LastValuePeriodic carries the last value of periodic properties too, so that dashboards don't show gaps
+	// while devices don't report
+	LastValuePeriodic LastValuePolicy = "periodic"
+)
+
+// Option configures optional behaviours of the time series aligner.
+type Option func(*TsAligner)
+
+// WithImportConcurrency sets the number of things imported concurrently. Values below 1 keep the default.
+func WithImportConcurrency(concurrency int) Option {
+	return func(a *TsAligner) {
+		if concurrency > 0 {
+			a.importConcurrency = concurrency
+		}
+	}
 }
 
-func (a *TsAligner) getAllModels(ctx context.Context) ([]*iotsitewise.ListAssetModelsOutput, error) {
-	results := []*iotsitewise.ListAssetModelsOutput{}
-	var nextToken *string
-	models, err := a.sitewisecl.ListAssetModels(ctx)
-	if err != nil {
-		return nil, err
+// WithAdaptiveConcurrency makes the number of things imported concurrently adapt to SiteWise throttling:
+// it's halved when imports are throttled and slowly increased back, up to the import concurrency, when they succeed.
+func WithAdaptiveConcurrency(enabled bool) Option {
+	return func(a *TsAligner) {
+		a.adaptiveConcurrency = enabled
 	}
-	results = append(results, models)
-	for models.NextToken != nil {
-		models, err = a.sitewisecl.ListAssetModelsNext(ctx, nextToken)
-		if err != nil {
-			return nil, err
+}
+
+// WithRateLimitRetries sets the attempts of IoT API requests failing because of rate limiting.
+// Values below 1 keep the default.
+func WithRateLimitRetries(attempts int) Option {
+	return func(a *TsAligner) {
+		if attempts > 0 {
+			a.rateLimitRetries = attempts
+		}
+	}
+}
+
+// WithRateLimitBackoff sets the base delay between attempts of rate limited IoT API requests, doubled
+// at every attempt. Zero disables the delay.
+func WithRateLimitBackoff(base time.Duration) Option {
+	return func(a *TsAligner) {
+		a.rateLimitBackoff = base
+	}
+}
+
+// WithParallelPropertyImport makes numeric and string based properties of the same thing
+// to be imported concurrently instead of sequentially.
+func WithParallelPropertyImport(enabled bool) Option {
+	return func(a *TsAligner) {
+		a.parallelPropertyImport = enabled
+	}
+}
+
+// WithDiscoveryCache makes the aligner reuse assets discovered by a previous full SiteWise scan,
+// as long as the scan happened less than minScanInterval ago.
+func WithDiscoveryCache(cache *DiscoveryCache, minScanInterval time.Duration) Option {
+	return func(a *TsAligner) {
+		a.discoveryCache = cache
+		a.minScanInterval = minScanInterval
+	}
+}
+
+// WithNilLastValuePlaceholder makes the aligner write a bad quality placeholder point for ON_CHANGE
+// properties without a last value, instead of skipping them.
+func WithNilLastValuePlaceholder(enabled bool) Option {
+	return func(a *TsAligner) {
+		a.nilLastValuePlaceholder = enabled
+	}
+}
+
+// WithPropertyFilter restricts the thing properties imported into SiteWise.
+func WithPropertyFilter(filter *propfilter.Filter) Option {
+	return func(a *TsAligner) {
+		a.propertyFilter = filter
+	}
+}
+
+// WithClockSkewTolerance moves the end of the import time window back by the given tolerance, so that
+// buckets not yet finalized on Arduino IoT Cloud because of clock skew are not imported.
+func WithClockSkewTolerance(tolerance time.Duration) Option {
+	return func(a *TsAligner) {
+		a.clockSkewTolerance = tolerance
+	}
+}
+
+// WithWindowOverlap moves the start of the import time window of each thing back by the given overlap, so
+// that samples landing on the boundary between runs, or arriving late, are imported by the next run too.
+// Overlapping samples are written again with the same timestamps, replacing the previous values.
+// For aggregated imports the overlap is rounded up to whole resolution buckets.
+func WithWindowOverlap(overlap time.Duration) Option {
+	return func(a *TsAligner) {
+		a.windowOverlap = overlap
+	}
+}
+
+// WithMinMaxAggregation imports, beside the average, min and max of numeric properties into their
+// aggregate properties, if present in the asset. See entityalign.AggregatePropertyName.
+func WithMinMaxAggregation(enabled bool) Option {
+	return func(a *TsAligner) {
+		a.minMaxAggregation = enabled
+	}
+}
+
+// WithBatchedLastValues makes last values of ON_CHANGE properties of all things to be collected during the run
+// and written together at its end, in fully packed batches, instead of once per thing.
+func WithBatchedLastValues(enabled bool) Option {
+	return func(a *TsAligner) {
+		a.batchLastValues = enabled
+	}
+}
+
+// WithFetchOnly makes the aligner fetch time series from Arduino IoT Cloud without writing anything
+// to SiteWise, reporting the number of fetched points and the fetch throughput. Meant for benchmarking.
+func WithFetchOnly(enabled bool) Option {
+	return func(a *TsAligner) {
+		a.fetchOnly = enabled
+	}
+}
+
+// WithRawImport makes the aligner import samples as stored on Arduino IoT Cloud, without aggregation.
+// The resolution is ignored and the time window is not aligned to resolution buckets.
+func WithRawImport(enabled bool) Option {
+	return func(a *TsAligner) {
+		a.rawImport = enabled
+	}
+}
+
+// WithUpdatedAtProperties makes the aligner write, in the last value phase, the time ON_CHANGE properties
+// last changed into their update time properties, if present in the asset. See entityalign.UpdatedAtPropertyName.
+func WithUpdatedAtProperties(enabled bool) Option {
+	return func(a *TsAligner) {
+		a.updatedAt = enabled
+	}
+}
+
+// WithLocationCoordinates makes the aligner import latitude and longitude of location properties into their
+// coordinate properties, if present in the asset. See entityalign.LocationCoordinatePropertyName.
+func WithLocationCoordinates(enabled bool) Option {
+	return func(a *TsAligner) {
+		a.locationCoords = enabled
+	}
+}
+
+// WithAliasTemplate sets the template composing the aliases of thing properties, as used to align assets.
+func WithAliasTemplate(tmpl *entityalign.AliasTemplate) Option {
+	return func(a *TsAligner) {
+		a.aliasTemplate = tmpl
+	}
+}
+
+// WithAggregationOverrides sets the aggregation used for numeric properties, by property name,
+// in place of the default average. See ParseAggregationOverrides.
+func WithAggregationOverrides(overrides map[string]string) Option {
+	return func(a *TsAligner) {
+		a.aggregationOverrides = overrides
+	}
+}
+
+// WithCheckpoints makes each thing import start from the end of its last imported window, if more recent
+// than the start of the run time window. If advanceOnEmpty is set, checkpoints advance also when the
+// series query succeeds but returns no data (e.g. device offline), so the same empty window isn't queried again.
+func WithCheckpoints(checkpoints *Checkpoints, advanceOnEmpty bool) Option {
+	return func(a *TsAligner) {
+		a.checkpoints = checkpoints
+		a.advanceEmptyCheckpoints = advanceOnEmpty
+	}
+}
+
+// WithPartialAssetPolicy sets how assets being updated are imported. Default is PartialAssetImport.
+func WithPartialAssetPolicy(policy PartialAssetPolicy) Option {
+	return func(a *TsAligner) {
+		a.partialAssetPolicy = policy
+	}
+}
+
+// WithThingTimeout sets the time budget of the import of each thing, so that a thing whose requests hang is
+// abandoned and reported as an error instead of holding its import slot. Zero disables the timeout.
+func WithThingTimeout(timeout time.Duration) Option {
+	return func(a *TsAligner) {
+		a.thingTimeout = timeout
+	}
+}
+
+// WithLastValueOnly makes the aligner skip time series, writing only the last value of each property,
+// whatever its update strategy, as known by Arduino IoT Cloud. Points are timestamped with the time the value
+// last changed, when known. Meant to minimize ingestion costs when history isn't needed.
+func WithLastValueOnly(enabled bool) Option {
+	return func(a *TsAligner) {
+		a.lastValueOnly = enabled
+	}
+}
+
+// WithLastValuePolicy sets which properties without samples in the time window get their last value written.
+// Default is LastValueOnChange.
+func WithLastValuePolicy(policy LastValuePolicy) Option {
+	return func(a *TsAligner) {
+		a.lastValuePolicy = policy
+	}
+}
+
+func New(sitewisecl sitewiseclient.API, iotcl iot.API, logger *logrus.Entry, opts ...Option) *TsAligner {
+	a := &TsAligner{
+		sitewisecl:         sitewisecl,
+		iotcl:              iotcl,
+		logger:             logger,
+		importConcurrency:  defaultImportConcurrency,
+		rateLimitRetries:   defaultRateLimitRetries,
+		rateLimitBackoff:   defaultRateLimitBackoff,
+		partialAssetPolicy: PartialAssetImport,
+		lastValuePolicy:    LastValueOnChange,
+		now:                time.Now,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	var backfill sitewiseclient.API
+	if a.bulk != nil {
+		backfill = a.bulk.wrap(a.sitewisecl)
+		if !a.bulk.OutOfWindowOnly {
+			a.sitewisecl = backfill
 		}
-		results = append(results, models)
 	}
-	return results, nil
+	a.sitewisecl = a.ingestionWindow(a.sitewisecl, backfill)
+	return a
 }
 
+// AlignTimeSeriesSamplesIntoSiteWise imports time series of the given things into their SiteWise assets,
+// returning a summary of what was written along with the errors of the run.
 func (a *TsAligner) AlignTimeSeriesSamplesIntoSiteWise(
 	ctx context.Context,
 	timeWindowInMinutes int,
 	thingsMap map[string]iotclient.ArduinoThing,
-	resolution int) []error {
+	resolution int) (ImportSummary, []error) {
+
+	a.counters.reset()
+	a.modelAttributes.reset()
 
 	var wg sync.WaitGroup
-	tokens := make(chan struct{}, importConcurrency)
+	tokens := newImportTokens(a.importConcurrency, a.adaptiveConcurrency)
 	errorChannel := make(chan error, len(thingsMap))
 
-	from, to := computeTimeAlignment(resolution, timeWindowInMinutes)
-
-	a.logger.Infoln("=====> Align perf data - time window ", timeWindowInMinutes, " minutes - from ", from, " to ", to, " - resolution ", resolution, " seconds")
-	allModels, err := a.getAllModels(ctx)
+	var from, to time.Time
+	if a.lastValueOnly {
+		a.logger.Infoln("=====> Align perf data - last values only")
+	} else if a.rawImport {
+		from, to = computeRawTimeWindow(a.now(), timeWindowInMinutes, a.clockSkewTolerance)
+		a.logger.Infoln("=====> Align perf data - time window ", timeWindowInMinutes, " minutes - from ", a.displayTime(from), " to ", a.displayTime(to), " - raw samples")
+	} else {
+		from, to = computeTimeAlignment(a.now(), resolution, timeWindowInMinutes, a.clockSkewTolerance)
+		a.logger.Infoln("=====> Align perf data - time window ", timeWindowInMinutes, " minutes - from ", a.displayTime(from), " to ", a.displayTime(to), " - resolution ", resolution, " seconds")
+	}
+	if a.windowOverlap > 0 && !a.lastValueOnly {
+		a.logger.Infoln("=====> Time window start moved back by ", from.Sub(overlapWindowStart(from, a.windowOverlap, a.bucketSize(resolution))), " to overlap the previous run")
+	}
+	if !from.IsZero() && from.Before(a.now().Add(-ingestionMaxAge)) && a.bulk == nil {
+		a.logger.Warnln("=====> Time window starts more than 7 days ago, older data points will be dropped. Enable bulk import to backfill them.")
+	}
+	assets, err := a.discoverAssets(ctx)
 	if err != nil {
-		return []error{err}
+		return a.counters.summary(), []error{err}
 	}
 
-	for _, models := range allModels {
-		for _, model := range models.AssetModelSummaries {
-			continueimport := true
-			var nextToken *string
-			for continueimport {
-				var assets *iotsitewise.ListAssetsOutput
-				if nextToken != nil {
-					assets, err = a.sitewisecl.ListAssetsNext(ctx, model.Id, nextToken)
-				} else {
-					assets, err = a.sitewisecl.ListAssets(ctx, model.Id)
-				}
-				if err != nil {
-					return []error{err}
-				}
-
-				for _, asset := range assets.AssetSummaries {
-					if asset.ExternalId == nil {
-						a.logger.Warn("Asset external id not found, skipping it: ", *asset.Name)
-						continue
-					}
-					// Asset external id is mapped on Thing ID
-					thing, ok := thingsMap[*asset.ExternalId]
-					if !ok {
-						a.logger.Debug("Thing not found, not detected by import filters: ", *asset.ExternalId)
-						continue
-					}
-					propertiesMap := make(map[string]iotclient.ArduinoProperty, len(thing.Properties))
-					for _, p := range thing.Properties {
-						propertiesMap[p.Id] = p
-					}
+	start := time.Now()
+	var lastValues *lastValueCollector
+	if a.batchLastValues && !a.fetchOnly {
+		lastValues = &lastValueCollector{}
+	}
+	var pending *pendingCheckpoints
+	if a.checkpoints != nil && a.bulk != nil && !a.fetchOnly {
+		pending = newPendingCheckpoints()
+	}
+	toImport := 0
+	for _, asset := range assets {
+		if _, ok := thingsMap[asset.thingId]; ok {
+			toImport++
+		}
+	}
+	progress := a.startProgress(toImport)
 
-					tokens <- struct{}{}
-					wg.Add(1)
+	for _, asset := range assets {
+		// Asset external id is mapped on Thing ID
+		thing, ok := thingsMap[asset.thingId]
+		if !ok {
+			a.logger.Debug("Thing not found, not detected by import filters: ", asset.thingId)
+			continue
+		}
+		propertiesMap := make(map[string]iotclient.ArduinoProperty, len(thing.Properties))
+		for _, p := range thing.Properties {
+			propertiesMap[p.Id] = p
+		}
 
-					go func(assetId, assetName, externalId string, propertiesMap map[string]iotclient.ArduinoProperty) {
-						defer func() { <-tokens }()
-						defer wg.Done()
+		tokens.acquire()
+		wg.Add(1)
 
-						describedAsset, err := a.sitewisecl.DescribeAsset(ctx, assetId)
-						if err != nil {
-							a.logger.Error("Error describing asset: ", assetId, err)
-							return
-						}
+		go func(asset *discoveredAsset, propertiesMap map[string]iotclient.ArduinoProperty) {
+			var err error
+			defer func() { tokens.release(err) }()
+			defer wg.Done()
+			defer func() { progress.thingDone(err != nil) }()
 
-						mappedProperties := a.mapPropertiesToImport(describedAsset, thing, assetName)
-
-						importedProperties := []string{}
-						if len(mappedProperties.PropertiesToImport) > 0 {
-							p, err := a.populateTSDataIntoSiteWise(ctx, externalId, mappedProperties, resolution, from, to)
-							if err != nil {
-								a.logger.Error("Error populating time series data: ", err)
-								errorChannel <- err
-								return
-							}
-							if len(p) > 0 {
-								importedProperties = append(importedProperties, p...)
-							}
-						}
+			thingCtx, cancel := a.thingContext(ctx)
+			defer cancel()
 
-						if len(mappedProperties.CharPropertiesToImport) > 0 {
-							p, err := a.populateCharTSDataIntoSiteWise(ctx, externalId, mappedProperties, resolution, from, to)
-							if err != nil {
-								a.logger.Error("Error populating string based time series data: ", err)
-								errorChannel <- err
-								return
-							}
-							if len(p) > 0 {
-								importedProperties = append(importedProperties, p...)
-							}
-						}
+			description, ok, err := a.describeAsset(thingCtx, asset)
+			if err == nil && !ok && a.thingTimedOut(ctx, thingCtx) {
+				err = thingCtx.Err()
+			}
+			if err != nil {
+				a.logger.WithFields(logrus.Fields{logFieldThingID: asset.thingId, logFieldAssetID: asset.assetId}).Error("Error describing asset: ", err)
+				err = a.thingImportError(ctx, thingCtx, asset.thingId, err)
+				errorChannel <- err
+				return
+			}
+			if !ok {
+				// Deferred to the next run
+				return
+			}
 
-						// Check if there are properties that have been imported (on_change - import last value)
-						err = a.populateLastValueForOnChangeProperties(ctx, propertiesMap, importedProperties, mappedProperties.PropertiesToImportAliases)
-						if err != nil {
-							a.logger.Error("Error populating last values time series data: ", err)
-							errorChannel <- err
-							return
-						}
+			attributeNames, err := a.modelAttributeNames(thingCtx, description)
+			if err != nil {
+				a.logger.WithFields(logrus.Fields{logFieldThingID: asset.thingId, logFieldAssetID: asset.assetId}).Error("Error describing asset model: ", err)
+				errorChannel <- a.thingImportError(ctx, thingCtx, asset.thingId, err)
+				return
+			}
+			mappedProperties := a.mapPropertiesToImport(description, thing, asset.assetName, attributeNames)
+			if a.bulk != nil {
+				a.bulk.staged.setPropertyTypes(mappedProperties.PropertiesToImportAliases, propertiesMap)
+			}
 
-					}(*asset.Id, *asset.Name, *asset.ExternalId, propertiesMap)
+			// In last value only mode no series is imported, so that all properties get their last value
+			var importedProperties []string
+			if !a.lastValueOnly {
+				thingFrom, thingTo := from, to
+				if a.checkpoints != nil {
+					a.loadCheckpoint(thingCtx, asset.thingId)
+					thingFrom, thingTo = a.checkpoints.window(asset.thingId, from, to, a.catchUpWindow(resolution))
 				}
-
-				nextToken = assets.NextToken
-				if nextToken == nil {
-					continueimport = false
+				thingFrom = overlapWindowStart(thingFrom, a.windowOverlap, a.bucketSize(resolution))
+				importedProperties, err = a.populateThingTSDataIntoSiteWise(thingCtx, asset.thingId, mappedProperties, resolution, thingFrom, thingTo)
+				if a.checkpoints != nil && !a.fetchOnly && checkpointAdvances(importedProperties, err, a.advanceEmptyCheckpoints) {
+					if pending != nil {
+						// Staged data points are written only when the bulk import job completes
+						pending.add(asset.thingId, thingTo)
+					} else {
+						// Series are written when populateThingTSDataIntoSiteWise returns without errors
+						a.commitCheckpoint(thingCtx, asset.thingId, thingTo)
+					}
+				}
+				if err != nil {
+					errorChannel <- a.thingImportError(ctx, thingCtx, asset.thingId, err)
+					return
 				}
 			}
-		}
+			a.counters.things.Add(1)
+			a.counters.properties.Add(int64(len(importedProperties)))
+
+			if a.fetchOnly {
+				return
+			}
+
+			if err = a.populateAttributes(thingCtx, asset.assetId, propertiesMap, mappedProperties.Attributes); err != nil {
+				a.logger.Error("Error setting attributes: ", err)
+				errorChannel <- a.thingImportError(ctx, thingCtx, asset.thingId, err)
+				return
+			}
+
+			// Check if there are properties that have been imported (on_change - import last value)
+			if lastValues != nil {
+				lastValues.add(a.lastValuePoints(propertiesMap, importedProperties, mappedProperties.PropertiesToImportAliases, mappedProperties.Transforms))
+				lastValues.add(updatedAtPoints(propertiesMap, mappedProperties.UpdatedAtAliases))
+				return
+			}
+			err = a.populateLastValueForOnChangeProperties(thingCtx, propertiesMap, importedProperties, mappedProperties.PropertiesToImportAliases, mappedProperties.Transforms, mappedProperties.UpdatedAtAliases)
+			if err != nil {
+				a.logger.Error("Error populating last values time series data: ", err)
+				errorChannel <- a.thingImportError(ctx, thingCtx, asset.thingId, err)
+				return
+			}
+
+		}(asset, propertiesMap)
 	}
 
 	// Wait for all routines termination
 	wg.Wait()
+	progress.finish()
 	close(errorChannel)
 
 	// Check if there were errors
@@ -184,50 +524,377 @@ func (a *TsAligner) AlignTimeSeriesSamplesIntoSiteWise(
 			errorsToReturn = append(errorsToReturn, err)
 		}
 	}
+	a.sendDeadLetters(ctx, errorsToReturn)
+
+	if lastValues != nil {
+		pending := lastValues.len()
+		if err := lastValues.flush(ctx, a.sitewisecl); err != nil {
+			a.logger.Error("Error populating last values time series data: ", err)
+			errorsToReturn = append(errorsToReturn, err)
+		} else {
+			a.counters.written.Add(int64(pending))
+		}
+	}
+	if a.bulk != nil && !a.fetchOnly {
+		// Staged data points, last values included, are imported all together
+		rejected, err := a.bulk.run(ctx, a.logger)
+		if err != nil {
+			a.logger.Error("Error running bulk import job: ", err)
+			errorsToReturn = append(errorsToReturn, err)
+		} else if pending != nil {
+			for thingID, to := range pending.take() {
+				a.commitCheckpoint(ctx, thingID, to)
+			}
+		}
+		a.counters.written.Add(-rejected)
+		a.counters.rejected.Add(rejected)
+	}
+	if a.fetchOnly {
+		elapsed := time.Since(start)
+		a.logger.Infof("=====> Fetch only - %d series requests, %d data points in %s (%.1f points/s)",
+			a.fetchStats.requests.Load(), a.fetchStats.points.Load(), elapsed, a.fetchStats.throughput(elapsed))
+	}
+	if a.adaptiveConcurrency {
+		a.logger.Infoln("=====> Adaptive import concurrency at end of run: ", tokens.current())
+	}
+	summary := a.counters.summary()
+	a.logger.Infof("=====> Import summary - %d things, %d properties, %d data points written, %d skipped, %d throttle retries",
+		summary.ThingsProcessed, summary.PropertiesImported, summary.PointsWritten, summary.PointsSkipped, summary.ThrottleRetries)
+	if summary.PointsRejected > 0 {
+		a.logger.Warnf("=====> Backfill incomplete - %d data points rejected by the bulk import job", summary.PointsRejected)
+	}
 	if len(errorsToReturn) > 0 {
 		a.logger.Warnln("=====> Detected execution errors...")
-		return errorsToReturn
+		return summary, errorsToReturn
 	}
 
-	return nil
+	return summary, nil
+}
+
+// thingContext returns the context of the import of a thing, bounded by the thing timeout if set.
+func (a *TsAligner) thingContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if a.thingTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, a.thingTimeout)
+}
+
+// thingTimedOut tells whether the import of a thing ran out of its own time budget, rather than of the run one.
+func (a *TsAligner) thingTimedOut(ctx, thingCtx context.Context) bool {
+	return ctx.Err() == nil && errors.Is(thingCtx.Err(), context.DeadlineExceeded)
+}
+
+// thingImportError wraps the error importing a thing, reporting whether the thing timed out.
+func (a *TsAligner) thingImportError(ctx, thingCtx context.Context, thingID string, err error) error {
+	if a.thingTimedOut(ctx, thingCtx) {
+		a.logger.WithField(logFieldThingID, thingID).Warnln("Thing import timed out after", a.thingTimeout)
+		err = fmt.Errorf("timed out after %s: %w", a.thingTimeout, err)
+	}
+	return &ThingImportError{ThingID: thingID, Err: err}
+}
+
+// discoverAssets returns the SiteWise assets mapped on Arduino things. If a discovery cache is configured and
+// the last full scan is more recent than the configured scan interval, cached assets are returned.
+func (a *TsAligner) discoverAssets(ctx context.Context) ([]*discoveredAsset, error) {
+	if a.discoveryCache != nil {
+		if assets, ok := a.discoveryCache.get(a.minScanInterval); ok {
+			a.logger.Infoln("=====> Using cached SiteWise assets discovery: ", len(assets), " assets")
+			return assets, nil
+		}
+	}
+
+	models, err := a.sitewisecl.ListAllAssetModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	discovered := []*discoveredAsset{}
+	for _, model := range models {
+		assets, err := a.sitewisecl.ListAllAssetsForModel(ctx, model.Id)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, asset := range assets {
+			if asset.ExternalId == nil {
+				a.logger.Warn("Asset external id not found, skipping it: ", *asset.Name)
+				continue
+			}
+			discovered = append(discovered, &discoveredAsset{
+				assetId:   *asset.Id,
+				assetName: *asset.Name,
+				thingId:   *asset.ExternalId,
+			})
+		}
+	}
+
+	if a.discoveryCache != nil {
+		a.discoveryCache.set(discovered)
+	}
+	return discovered, nil
+}
+
+// describeAsset returns the description of the asset, reusing the cached one if any. Descriptions of assets
+// being updated are never cached, as they can miss properties. It returns false, with no error, if the import of
+// the asset is deferred to the next run.
+func (a *TsAligner) describeAsset(ctx context.Context, asset *discoveredAsset) (*iotsitewise.DescribeAssetOutput, bool, error) {
+	if asset.description != nil {
+		return asset.description, true, nil
+	}
+	description, err := a.sitewisecl.DescribeAsset(ctx, asset.assetId)
+	if err != nil {
+		return nil, false, fmt.Errorf("describing asset %s: %w", asset.assetId, err)
+	}
+	if !isAssetUpdating(description) {
+		asset.description = description
+		return description, true, nil
+	}
+
+	if a.partialAssetPolicy != PartialAssetDefer {
+		a.logger.WithFields(logrus.Fields{logFieldThingID: asset.thingId, logFieldAssetID: asset.assetId}).Warn("Asset is being updated, importing properties found in its description")
+		return description, true, nil
+	}
+	assetLogger := a.logger.WithFields(logrus.Fields{logFieldThingID: asset.thingId, logFieldAssetID: asset.assetId})
+	assetLogger.Info("Asset is being updated, waiting for completion")
+	if err := a.sitewisecl.PollForAssetActiveStatusWithOptions(ctx, asset.assetId, sitewiseclient.DefaultPollOptions); err != nil {
+		assetLogger.Warnln("Asset still being updated, deferring import to next run: ", err)
+		return nil, false, nil
+	}
+	description, err = a.sitewisecl.DescribeAsset(ctx, asset.assetId)
+	if err != nil {
+		return nil, false, fmt.Errorf("describing asset %s: %w", asset.assetId, err)
+	}
+	if isAssetUpdating(description) {
+		assetLogger.Warn("Asset still being updated, deferring import to next run")
+		return nil, false, nil
+	}
+	asset.description = description
+	return description, true, nil
+}
+
+func isAssetUpdating(description *iotsitewise.DescribeAssetOutput) bool {
+	if description.AssetStatus == nil {
+		return false
+	}
+	state := description.AssetStatus.State
+	return state == types.AssetStateCreating || state == types.AssetStateUpdating
 }
 
 type mappedProperties struct {
 	PropertiesToImport        []string
 	CharPropertiesToImport    []string
 	PropertiesToImportAliases map[string]string
+	// Aliases of aggregate properties, by property id and aggregation
+	AggregateAliases map[string]map[string]string
+	// Aliases of update time properties, by property id
+	UpdatedAtAliases map[string]string
+	// Ids of location properties, whose values are normalized
+	LocationProperties []string
+	// Aliases of location coordinate properties, by property id and coordinate
+	CoordinateAliases map[string]map[string]string
+	// Aggregations overriding the default one, by property id
+	AggregationOverrides map[string]string
+	// Transforms of numeric values, by property id
+	Transforms map[string]Transform
+	// Names of thing properties with no matching asset property, whose data can't be imported
+	UnmappedProperties []string
+	// Asset properties of thing properties imported as attributes, by property id
+	Attributes map[string]assetAttribute
 }
 
-func (a *TsAligner) mapPropertiesToImport(describedAsset *iotsitewise.DescribeAssetOutput, thing iotclient.ArduinoThing, assetName string) *mappedProperties {
+// mapPropertiesToImport maps the thing properties to the properties of its asset. Properties named in
+// attributeNames, the attribute properties of the asset model, are set as attributes.
+func (a *TsAligner) mapPropertiesToImport(describedAsset *iotsitewise.DescribeAssetOutput, thing iotclient.ArduinoThing, assetName string, attributeNames []string) *mappedProperties {
+	// Names are disambiguated as done by the alignment, on filtered properties
+	thing, _ = entityalign.UniquePropertyNames(a.propertyFilter.FilterThings([]iotclient.ArduinoThing{thing})[0])
 	propertiesToImport := []string{}
 	charPropertiesToImport := []string{}
-	propertiesToImportAliases := make(map[string]string, len(describedAsset.AssetProperties))
-	for _, prop := range describedAsset.AssetProperties {
+	// Properties exceeding the model limit are held by composite models
+	assetProperties := sitewiseclient.AssetProperties(describedAsset)
+	propertiesToImportAliases := make(map[string]string, len(assetProperties))
+	aggregateAliases := make(map[string]map[string]string)
+	updatedAtAliases := make(map[string]string)
+	locationProperties := []string{}
+	coordinateAliases := make(map[string]map[string]string)
+	aggregationOverrides := make(map[string]string)
+	transforms := make(map[string]Transform)
+	attributes := make(map[string]assetAttribute)
+	assetPropertyNames := make([]string, 0, len(assetProperties))
+	for _, prop := range assetProperties {
+		assetPropertyNames = append(assetPropertyNames, *prop.Name)
+	}
+	for _, prop := range assetProperties {
 		for _, thingProperty := range thing.Properties {
+			if !a.propertyFilter.Allows(thingProperty.Name, thingProperty.Type) {
+				continue
+			}
 			if *prop.Name == thingProperty.Name {
-				a.logger.Debugln("  Importing TS for: ", assetName, *prop.Name, " thingPropertyId: ", thingProperty.Id)
+				if a.attributeProperties && slices.Contains(attributeNames, *prop.Name) {
+					a.logger.WithFields(logrus.Fields{logFieldThingID: thing.Id, logFieldPropertyID: thingProperty.Id}).Debugln("Setting attribute for: ", assetName, *prop.Name)
+					attribute := assetAttribute{propertyId: *prop.Id, alias: a.aliasTemplate.Alias(thing, *prop.Name)}
+					if iot.IsPropertyNumberType(thingProperty.Type) {
+						attribute.transform = a.valueTransforms[thingProperty.Name]
+					}
+					attributes[thingProperty.Id] = attribute
+					continue
+				}
+				a.logger.WithFields(logrus.Fields{logFieldThingID: thing.Id, logFieldPropertyID: thingProperty.Id}).Debugln("Importing TS for: ", assetName, *prop.Name)
 				if iot.IsPropertyString(thingProperty.Type) || iot.IsPropertyLocation(thingProperty.Type) {
 					charPropertiesToImport = append(charPropertiesToImport, thingProperty.Id)
+					if iot.IsPropertyLocation(thingProperty.Type) {
+						locationProperties = append(locationProperties, thingProperty.Id)
+					}
 				} else {
 					propertiesToImport = append(propertiesToImport, thingProperty.Id)
+					if aggregation, ok := a.aggregationOverrides[thingProperty.Name]; ok && iot.IsPropertyNumberType(thingProperty.Type) {
+						aggregationOverrides[thingProperty.Id] = aggregation
+					}
+					if transform, ok := a.valueTransforms[thingProperty.Name]; ok && iot.IsPropertyNumberType(thingProperty.Type) {
+						transforms[thingProperty.Id] = transform
+					}
+				}
+				propertiesToImportAliases[thingProperty.Id] = a.aliasTemplate.Alias(thing, *prop.Name)
+				if a.minMaxAggregation && iot.IsPropertyNumberType(thingProperty.Type) {
+					for _, aggregation := range entityalign.MinMaxAggregations {
+						name := entityalign.AggregatePropertyName(thingProperty.Name, aggregation)
+						if !slices.Contains(assetPropertyNames, name) {
+							continue
+						}
+						if aggregateAliases[thingProperty.Id] == nil {
+							aggregateAliases[thingProperty.Id] = make(map[string]string)
+						}
+						aggregateAliases[thingProperty.Id][aggregation] = a.aliasTemplate.Alias(thing, name)
+					}
+				}
+				if a.updatedAt && thingProperty.UpdateStrategy == "ON_CHANGE" {
+					name := entityalign.UpdatedAtPropertyName(thingProperty.Name)
+					if slices.Contains(assetPropertyNames, name) {
+						updatedAtAliases[thingProperty.Id] = a.aliasTemplate.Alias(thing, name)
+					}
+				}
+				if a.locationCoords && iot.IsPropertyLocation(thingProperty.Type) {
+					for _, coordinate := range entityalign.LocationCoordinates {
+						name := entityalign.LocationCoordinatePropertyName(thingProperty.Name, coordinate)
+						if !slices.Contains(assetPropertyNames, name) {
+							continue
+						}
+						// The asset property holds the series of the thing property with that name
+						if slices.ContainsFunc(thing.Properties, func(p iotclient.ArduinoProperty) bool { return p.Name == name }) {
+							a.logger.WithFields(logrus.Fields{logFieldThingID: thing.Id, logFieldPropertyID: thingProperty.Id}).Warnln("Thing already has property", name, "- not importing", coordinate, "of", thingProperty.Name)
+							continue
+						}
+						if coordinateAliases[thingProperty.Id] == nil {
+							coordinateAliases[thingProperty.Id] = make(map[string]string)
+						}
+						coordinateAliases[thingProperty.Id][coordinate] = a.aliasTemplate.Alias(thing, name)
+					}
 				}
-				propertiesToImportAliases[thingProperty.Id] = entityalign.PropertyAlias(thing.Id, *prop.Name)
 			}
 		}
 	}
+	// Properties added to the thing after the last alignment, or removed from the model out of band
+	unmappedProperties := []string{}
+	for _, thingProperty := range thing.Properties {
+		if a.propertyFilter.Allows(thingProperty.Name, thingProperty.Type) && !slices.Contains(assetPropertyNames, thingProperty.Name) {
+			unmappedProperties = append(unmappedProperties, thingProperty.Name)
+		}
+	}
+	if len(unmappedProperties) > 0 {
+		a.logger.WithFields(logrus.Fields{logFieldThingID: thing.Id}).Warnln("Thing properties not found on asset", assetName,
+			"- their data is not imported until models and assets are aligned again:", unmappedProperties)
+	}
 	return &mappedProperties{
 		PropertiesToImport:        propertiesToImport,
 		CharPropertiesToImport:    charPropertiesToImport,
 		PropertiesToImportAliases: propertiesToImportAliases,
+		AggregateAliases:          aggregateAliases,
+		UpdatedAtAliases:          updatedAtAliases,
+		LocationProperties:        locationProperties,
+		CoordinateAliases:         coordinateAliases,
+		AggregationOverrides:      aggregationOverrides,
+		Transforms:                transforms,
+		UnmappedProperties:        unmappedProperties,
+		Attributes:                attributes,
 	}
 }
 
-func computeTimeAlignment(resolutionSeconds, timeWindowInMinutes int) (time.Time, time.Time) {
+// populateThingTSDataIntoSiteWise imports numeric and string based time series of a thing, returning
+// the merged list of imported properties. Imports run concurrently if parallel property import is enabled.
+func (a *TsAligner) populateThingTSDataIntoSiteWise(
+	ctx context.Context,
+	thingID string,
+	mappedProperties *mappedProperties,
+	resolution int,
+	from, to time.Time) ([]string, error) {
+
+	if a.rawImport {
+		// A single raw query covers both numeric and string based properties
+		importedProperties, err := a.populateRawTSDataIntoSiteWise(ctx, thingID, mappedProperties, from, to)
+		if err != nil {
+			a.logger.Error("Error populating raw time series data: ", err)
+		}
+		return importedProperties, err
+	}
+
+	var numericImported, charImported []string
+	var numericErr, charErr error
+
+	importNumeric := func() {
+		if len(mappedProperties.PropertiesToImport) > 0 {
+			numericImported, numericErr = a.populateTSDataIntoSiteWise(ctx, thingID, mappedProperties, resolution, from, to)
+			if numericErr != nil {
+				a.logger.Error("Error populating time series data: ", numericErr)
+			}
+		}
+	}
+	importChar := func() {
+		if len(mappedProperties.CharPropertiesToImport) > 0 {
+			charImported, charErr = a.populateCharTSDataIntoSiteWise(ctx, thingID, mappedProperties, resolution, from, to)
+			if charErr != nil {
+				a.logger.Error("Error populating string based time series data: ", charErr)
+			}
+		}
+	}
+
+	if a.parallelPropertyImport {
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			importNumeric()
+		}()
+		go func() {
+			defer wg.Done()
+			importChar()
+		}()
+		wg.Wait()
+	} else {
+		importNumeric()
+		if numericErr == nil {
+			importChar()
+		}
+	}
+
+	if numericErr != nil {
+		return nil, numericErr
+	}
+	if charErr != nil {
+		return nil, charErr
+	}
+
+	importedProperties := make([]string, 0, len(numericImported)+len(charImported))
+	importedProperties = append(importedProperties, numericImported...)
+	importedProperties = append(importedProperties, charImported...)
+	return importedProperties, nil
+}
+
+func computeTimeAlignment(now time.Time, resolutionSeconds, timeWindowInMinutes int, clockSkewTolerance time.Duration) (time.Time, time.Time) {
 	// Compute time alignment
 	if resolutionSeconds <= 60 {
 		resolutionSeconds = 300 // Align to 5 minutes
 	}
-	to := time.Now().Truncate(time.Duration(resolutionSeconds) * time.Second).UTC()
+	// Skew is removed before truncating, so that the window still ends on a bucket boundary
+	to := now.Add(-clockSkewTolerance).Truncate(time.Duration(resolutionSeconds) * time.Second).UTC()
 	if resolutionSeconds <= 900 {
 		// Shift time window to avoid missing data
 		to = to.Add(-time.Duration(300) * time.Second)
@@ -236,15 +903,64 @@ func computeTimeAlignment(resolutionSeconds, timeWindowInMinutes int) (time.Time
 	return from, to
 }
 
-func randomRateLimitingSleep() {
-	// Random sleep to avoid rate limiting (1s + random(0-500ms))
-	n, err := rand.Int(rand.Reader, big.NewInt(500))
-	if err != nil {
-		fmt.Println("Error:", err)
+// overlapWindowStart moves the start of a time window back by the overlap, rounded up to whole buckets
+// so that aggregated buckets stay aligned. A zero bucket size doesn't round the overlap.
+func overlapWindowStart(from time.Time, overlap, bucket time.Duration) time.Time {
+	if overlap <= 0 {
+		return from
+	}
+	if bucket > 0 {
+		overlap = (overlap + bucket - 1) / bucket * bucket
+	}
+	return from.Add(-overlap)
+}
+
+// bucketSize returns the duration of the buckets of imported samples, zero for raw samples
+func (a *TsAligner) bucketSize(resolutionSeconds int) time.Duration {
+	if a.rawImport {
+		return 0
+	}
+	return time.Duration(resolutionSeconds) * time.Second
+}
+
+// fetchWithRetry runs a request to Arduino IoT Cloud, retrying it while rate limited up to the configured retries.
+func fetchWithRetry[T any](ctx context.Context, a *TsAligner, thingID string, fetch func() (T, error)) (T, error) {
+	var result T
+	var err error
+	for i := 0; i < a.rateLimitRetries; i++ {
+		result, err = fetch()
+		if !errors.Is(err, iot.ErrRateLimited) || i == a.rateLimitRetries-1 {
+			break
+		}
+		// This is due to a rate limit on the IoT API, we need to wait a bit before retrying
+		a.logger.WithField(logFieldThingID, thingID).Info("Rate limit reached. Waiting before retrying.")
+		a.rateLimitingSleep(ctx, i)
+	}
+	return result, err
+}
+
+// rateLimitingSleep waits before retrying a rate limited request, backing off exponentially on the attempt index.
+// It returns early if ctx is done, the retried request failing right away.
+func (a *TsAligner) rateLimitingSleep(ctx context.Context, attempt int) {
+	a.counters.throttled.Add(1)
+	if a.rateLimitBackoff <= 0 {
 		return
 	}
-	randomSleep := n.Int64() + 1000
-	time.Sleep(time.Duration(randomSleep) * time.Millisecond)
+	select {
+	case <-ctx.Done():
+	case <-time.After(rateLimitingDelay(a.rateLimitBackoff, attempt)):
+	}
+}
+
+// rateLimitingDelay returns base * 2^attempt, capped to base * 2^maxRateLimitBackoffExp, plus a random
+// jitter of up to half the base delay, so that throttled imports don't retry all at the same time.
+func rateLimitingDelay(base time.Duration, attempt int) time.Duration {
+	delay := base << min(attempt, maxRateLimitBackoffExp)
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(base/2)+1))
+	if err != nil {
+		return delay
+	}
+	return delay + time.Duration(n.Int64())
 }
 
 func (a *TsAligner) populateTSDataIntoSiteWise(
@@ -255,22 +971,24 @@ func (a *TsAligner) populateTSDataIntoSiteWise(
 	from, to time.Time) ([]string, error) {
 
 	propertiesImported := []string{}
-	var batched *iotclient.ArduinoSeriesBatch
-	var err error
-	var retry bool
-	for i := 0; i < retryCount; i++ {
-		batched, retry, err = a.iotcl.GetTimeSeriesByThing(ctx, thingID, from, to, int64(resolution))
-		if !retry {
-			break
-		} else {
-			// This is due to a rate limit on the IoT API, we need to wait a bit before retrying
-			a.logger.Infof("Rate limit reached for thing %s. Waiting before retrying.\n", thingID)
-			randomRateLimitingSleep()
+	batched, err := fetchWithRetry(ctx, a, thingID, func() (*iotclient.ArduinoSeriesBatch, error) {
+		if a.minMaxAggregation {
+			return a.iotcl.GetAggregatedTimeSeriesByThing(ctx, thingID, from, to, int64(resolution), importedAggregations)
 		}
-	}
+		return a.iotcl.GetTimeSeriesByThing(ctx, thingID, from, to, int64(resolution), "")
+	})
 	if err != nil {
 		return nil, err
 	}
+	if a.fetchOnly {
+		var points int64
+		for _, response := range batched.Responses {
+			points += response.CountValues
+		}
+		a.fetchStats.record(points)
+		return a.populateOverriddenTSDataIntoSiteWise(ctx, thingID, mappedProperties, resolution, from, to)
+	}
+	batch := a.newAliasBatch()
 	for _, response := range batched.Responses {
 		if response.CountValues == 0 {
 			continue
@@ -278,28 +996,61 @@ func (a *TsAligner) populateTSDataIntoSiteWise(
 
 		propertyID := strings.Replace(response.Query, "property.", "", 1)
 		if !slices.Contains(mappedProperties.PropertiesToImport, propertyID) {
-			propertiesImported = append(propertiesImported, propertyID)
+			a.logger.WithFields(logrus.Fields{logFieldThingID: thingID, logFieldPropertyID: propertyID}).Debug("Not mapped property. Skipping import.")
+			a.counters.skipped.Add(response.CountValues)
+			continue
 		}
-		if !slices.Contains(mappedProperties.PropertiesToImport, propertyID) {
-			a.logger.Debugf("Not mapped property %s. Skipping import.\n", propertyID)
+		aggregation := strings.ToUpper(aws.ToString(response.Aggregation))
+		if aggregation != "" && aggregation != defaultAggregation {
+			// Min/max go to their own aggregate property, when the asset has it
+			aggregation = transformedAggregation(mappedProperties.Transforms[propertyID], aggregation)
+			alias, ok := mappedProperties.AggregateAliases[propertyID][aggregation]
+			if !ok {
+				a.logger.WithFields(logrus.Fields{logFieldThingID: thingID, logFieldPropertyID: propertyID, logFieldAggregation: aggregation}).Debug("No aggregate property on the asset. Skipping import.")
+				a.counters.skipped.Add(response.CountValues)
+				continue
+			}
+			c := toChunk(response)
+			c.values = transformValues(mappedProperties.Transforms[propertyID], c.values)
+			a.logger.WithFields(logrus.Fields{logFieldThingID: thingID, logFieldPropertyAlias: alias, logFieldPoints: len(c.ts), logFieldAggregation: aggregation}).Debug("Importing data points")
+			if err := batch.addNumeric(ctx, alias, c.ts, c.values); err != nil {
+				return nil, err
+			}
+			a.counters.written.Add(int64(len(c.ts)))
+			continue
+		}
+		if _, ok := mappedProperties.AggregationOverrides[propertyID]; ok {
+			// Imported with its own aggregation
 			continue
 		}
+
 		alias := mappedProperties.PropertiesToImportAliases[propertyID]
 		if alias == "" {
-			a.logger.Warn("Alias not found. Skipping import.")
+			a.logger.WithFields(logrus.Fields{logFieldThingID: thingID, logFieldPropertyID: propertyID}).Warn("Alias not found. Skipping import.")
+			a.counters.skipped.Add(response.CountValues)
 			continue
 		}
 
-		chunks := partitionResults(response)
-		for _, c := range chunks {
-			a.logger.Debugln("  Importing ", len(c.ts), " data points for: ", alias, " - ts:", joinTs(c.ts))
-			erri := a.sitewisecl.PopulateTimeSeriesByAlias(ctx, alias, c.ts, c.values)
-			if erri != nil {
-				return nil, err
-			}
+		// Samples are split in batches compliant with SiteWise API limits by the client
+		c := toChunk(response)
+		c.values = transformValues(mappedProperties.Transforms[propertyID], c.values)
+		a.logger.WithFields(logrus.Fields{logFieldThingID: thingID, logFieldPropertyAlias: alias, logFieldPoints: len(c.ts)}).Debugln("Importing data points - ts:", a.joinTs(c.ts))
+		err = batch.addNumeric(ctx, alias, c.ts, c.values)
+		if err != nil {
+			return nil, err
 		}
+		a.counters.written.Add(int64(len(c.ts)))
+		propertiesImported = append(propertiesImported, propertyID)
 	}
-	return propertiesImported, nil
+	if err := batch.flush(ctx); err != nil {
+		return nil, err
+	}
+
+	overridden, err := a.populateOverriddenTSDataIntoSiteWise(ctx, thingID, mappedProperties, resolution, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return append(propertiesImported, overridden...), nil
 }
 
 type chunk struct {
@@ -307,35 +1058,36 @@ type chunk struct {
 	values []float64
 }
 
-// To be coherent with SiteWise API, we need to partition the results in chunks of 10 elements
-func partitionResults(response iotclient.ArduinoSeriesResponse) []chunk {
-	chunks := []chunk{}
-	for i := 0; i < len(response.Times); i += 10 {
-		end := i + 10
-		if end > len(response.Times) {
-			end = len(response.Times)
-		}
-
-		times := response.Times[i:end]
-		unixTimes := make([]int64, len(times))
-		for j := 0; j < len(times); j++ {
-			unixTimes[j] = times[j].Unix()
-		}
-		c := chunk{
-			ts:     unixTimes,
-			values: response.Values[i:end],
-		}
-		chunks = append(chunks, c)
+func toChunk(response iotclient.ArduinoSeriesResponse) chunk {
+	unixTimes := make([]int64, len(response.Times))
+	for j := 0; j < len(response.Times); j++ {
+		unixTimes[j] = response.Times[j].Unix()
+	}
+	ts, values := dedupeTimestamps(unixTimes, response.Values)
+	return chunk{
+		ts:     ts,
+		values: values,
 	}
-	return chunks
 }
 
-func joinTs(ts []int64) string {
-	tsarr := []string{}
-	for _, v := range ts {
-		tsarr = append(tsarr, fmt.Sprintf("%d", v))
+// dedupeTimestamps collapses points sharing the same timestamp, e.g. from overlapping windows or samples within
+// the same second, keeping the latest value: SiteWise would only keep the last write anyway. Points keep the
+// order of the first occurrence of their timestamp.
+func dedupeTimestamps[V any](ts []int64, values []V) ([]int64, []V) {
+	n := min(len(ts), len(values))
+	index := make(map[int64]int, n)
+	dedupedTs := make([]int64, 0, n)
+	dedupedValues := make([]V, 0, n)
+	for i := 0; i < n; i++ {
+		if j, ok := index[ts[i]]; ok {
+			dedupedValues[j] = values[i]
+			continue
+		}
+		index[ts[i]] = len(dedupedTs)
+		dedupedTs = append(dedupedTs, ts[i])
+		dedupedValues = append(dedupedValues, values[i])
 	}
-	return strings.Join(tsarr, ",")
+	return dedupedTs, dedupedValues
 }
 
 func (a *TsAligner) populateCharTSDataIntoSiteWise(
@@ -346,23 +1098,21 @@ func (a *TsAligner) populateCharTSDataIntoSiteWise(
 	from, to time.Time) ([]string, error) {
 
 	propertiesImported := []string{}
-	var batched *iotclient.ArduinoSeriesBatchSampled
-	var err error
-	var retry bool
-	for i := 0; i < retryCount; i++ {
-		// ctx context.Context, propertiesToImport []string, from, to time.Time, interval int32
-		batched, retry, err = a.iotcl.GetTimeSeriesSampling(ctx, mappedProperties.CharPropertiesToImport, from, to, int32(resolution))
-		if !retry {
-			break
-		} else {
-			// This is due to a rate limit on the IoT API, we need to wait a bit before retrying
-			a.logger.Infof("Rate limit reached for thing %s. Waiting before retrying.\n", thingID)
-			randomRateLimitingSleep()
-		}
-	}
+	batched, err := fetchWithRetry(ctx, a, thingID, func() (*iotclient.ArduinoSeriesBatchSampled, error) {
+		return a.iotcl.GetTimeSeriesSampling(ctx, mappedProperties.CharPropertiesToImport, from, to, int32(resolution))
+	})
 	if err != nil {
 		return nil, err
 	}
+	if a.fetchOnly {
+		var points int64
+		for _, response := range batched.Responses {
+			points += response.CountValues
+		}
+		a.fetchStats.record(points)
+		return nil, nil
+	}
+	batch := a.newAliasBatch()
 	for _, response := range batched.Responses {
 		if response.CountValues == 0 {
 			continue
@@ -370,26 +1120,40 @@ func (a *TsAligner) populateCharTSDataIntoSiteWise(
 
 		propertyID := strings.Replace(response.Query, "property.", "", 1)
 		if !slices.Contains(mappedProperties.CharPropertiesToImport, propertyID) {
-			propertiesImported = append(propertiesImported, propertyID)
-		}
-		if !slices.Contains(mappedProperties.CharPropertiesToImport, propertyID) {
-			a.logger.Debugf("Not mapped property %s. Skipping import.\n", propertyID)
+			a.logger.WithFields(logrus.Fields{logFieldThingID: thingID, logFieldPropertyID: propertyID}).Debug("Not mapped property. Skipping import.")
+			a.counters.skipped.Add(response.CountValues)
 			continue
 		}
+
 		alias := mappedProperties.PropertiesToImportAliases[propertyID]
 		if alias == "" {
-			a.logger.Warn("Alias not found. Skipping import.")
+			a.logger.WithFields(logrus.Fields{logFieldThingID: thingID, logFieldPropertyID: propertyID}).Warn("Alias not found. Skipping import.")
+			a.counters.skipped.Add(response.CountValues)
 			continue
 		}
 
-		chunks := partitionSampledResults(response)
-		for _, c := range chunks {
-			a.logger.Debugln("  Importing ", len(c.ts), " data points for: ", alias, " - ts:", joinTs(c.ts))
-			erri := a.sitewisecl.PopulateSampledSamplesTimeSeriesByAlias(ctx, alias, c.ts, c.values)
-			if erri != nil {
+		// Samples are split in batches compliant with SiteWise API limits by the client
+		c := toSampledChunk(response)
+		if slices.Contains(mappedProperties.LocationProperties, propertyID) {
+			written, err := a.populateLocationSeries(ctx, thingID, propertyID, alias, c, mappedProperties)
+			if err != nil {
 				return nil, err
 			}
+			if written > 0 {
+				propertiesImported = append(propertiesImported, propertyID)
+			}
+			continue
 		}
+		a.logger.WithFields(logrus.Fields{logFieldThingID: thingID, logFieldPropertyAlias: alias, logFieldPoints: len(c.ts)}).Debugln("Importing data points - ts:", a.joinTs(c.ts))
+		err = batch.addSampled(ctx, alias, c.ts, c.values)
+		if err != nil {
+			return nil, err
+		}
+		a.counters.written.Add(int64(len(c.ts)))
+		propertiesImported = append(propertiesImported, propertyID)
+	}
+	if err := batch.flush(ctx); err != nil {
+		return nil, err
 	}
 	return propertiesImported, nil
 }
@@ -399,27 +1163,24 @@ type chunkAnyValue struct {
 	values []any
 }
 
-// To be coherent with SiteWise API, we need to partition the results in chunks of 10 elements
-func partitionSampledResults(response iotclient.ArduinoSeriesSampledResponse) []chunkAnyValue {
-	chunks := []chunkAnyValue{}
-	for i := 0; i < len(response.Times); i += 10 {
-		end := i + 10
-		if end > len(response.Times) {
-			end = len(response.Times)
-		}
+func toSampledChunk(response iotclient.ArduinoSeriesSampledResponse) chunkAnyValue {
+	unixTimes := make([]int64, len(response.Times))
+	for j := 0; j < len(response.Times); j++ {
+		unixTimes[j] = response.Times[j].Unix()
+	}
+	ts, values := dedupeTimestamps(unixTimes, response.Values)
+	return chunkAnyValue{
+		ts:     ts,
+		values: values,
+	}
+}
 
-		times := response.Times[i:end]
-		unixTimes := make([]int64, len(times))
-		for j := 0; j < len(times); j++ {
-			unixTimes[j] = times[j].Unix()
-		}
-		c := chunkAnyValue{
-			ts:     unixTimes,
-			values: response.Values[i:end],
-		}
-		chunks = append(chunks, c)
+// placeholderValue returns the zero value matching the SiteWise data type of the given property type
+func placeholderValue(pType string) any {
+	if iot.IsPropertyString(pType) || iot.IsPropertyLocation(pType) {
+		return ""
 	}
-	return chunks
+	return 0.0
 }
 
 func isLastValueAllowedPropertyType(pType string) bool {
@@ -430,34 +1191,113 @@ func (a *TsAligner) populateLastValueForOnChangeProperties(
 	ctx context.Context,
 	propertiesMap map[string]iotclient.ArduinoProperty,
 	importedProperties []string,
-	propertiesToImportAliases map[string]string) error {
+	propertiesToImportAliases map[string]string,
+	transforms map[string]Transform,
+	updatedAtAliases map[string]string) error {
+
+	lastValuesToImport := a.lastValuePoints(propertiesMap, importedProperties, propertiesToImportAliases, transforms)
+	lastValuesToImport = append(lastValuesToImport, updatedAtPoints(propertiesMap, updatedAtAliases)...)
+	if len(lastValuesToImport) > 0 {
+		err := a.sitewisecl.PopulateArbitrarySamplesByAlias(ctx, lastValuesToImport)
+		if err != nil {
+			a.logger.Error("Error populating last values time series data: ", err)
+			return err
+		}
+		a.counters.written.Add(int64(len(lastValuesToImport)))
+	}
+
+	return nil
+}
+
+// lastValuePoints returns the last values of properties without samples in the imported time window, as per
+// the last value policy. Properties with samples are skipped, so that no sample is overwritten by an older value.
+func (a *TsAligner) lastValuePoints(
+	propertiesMap map[string]iotclient.ArduinoProperty,
+	importedProperties []string,
+	propertiesToImportAliases map[string]string,
+	transforms map[string]Transform) []sitewiseclient.DataPoint {
 
 	lastValuesToImport := []sitewiseclient.DataPoint{}
 	now := time.Now().UTC()
 	for propertyId, alias := range propertiesToImportAliases {
 		if !slices.Contains(importedProperties, propertyId) {
 			property, ok := propertiesMap[propertyId]
-			if !ok || property.LastValue == nil || property.UpdateStrategy != "ON_CHANGE" {
+			if !ok || !a.carriesLastValue(property) {
+				continue
+			}
+			if property.LastValue == nil {
+				if a.nilLastValuePlaceholder && isLastValueAllowedPropertyType(property.Type) {
+					a.logger.WithField(logFieldPropertyAlias, alias).Debugln("Importing placeholder for nil last value - name ", property.Name)
+					lastValuesToImport = append(lastValuesToImport, sitewiseclient.DataPoint{
+						PropertyAlias: alias,
+						Ts:            now.Unix(),
+						Value:         placeholderValue(property.Type),
+						Quality:       types.QualityBad,
+					})
+				}
 				continue
 			}
 
+			if iot.IsPropertyLocation(property.Type) {
+				lat, lng, err := parseLocation(property.LastValue)
+				if err != nil {
+					a.logger.WithField(logFieldPropertyAlias, alias).Warnln("Skipping last value of location property", property.Name, "-", err)
+					continue
+				}
+				property.LastValue = formatLocation(lat, lng)
+			}
 			if isLastValueAllowedPropertyType(property.Type) {
-				a.logger.Debugln("  + Importing last value for: ", alias, " - name ", property.Name, " - last value: ", property.UpdateStrategy, " - ", property.LastValue)
+				a.logger.WithField(logFieldPropertyAlias, alias).Debugln("Importing last value - name ", property.Name, " - last value: ", property.UpdateStrategy, " - ", property.LastValue)
+				ts := now
+				if a.lastValueOnly && property.ValueUpdatedAt != nil {
+					// Values last updated out of the ingestion window would be dropped: they are still current
+					if position(property.ValueUpdatedAt.Unix(), now) == 0 {
+						ts = *property.ValueUpdatedAt
+					} else {
+						a.logger.WithField(logFieldPropertyAlias, alias).Debugln("Last value updated out of the ingestion window, written at the current time - updated at:", *property.ValueUpdatedAt)
+					}
+				}
+				if value, ok := property.LastValue.(float64); ok && transforms[propertyId] != nil {
+					property.LastValue = transforms[propertyId].Apply(value)
+				}
 				lastValuesToImport = append(lastValuesToImport, sitewiseclient.DataPoint{
 					PropertyAlias: alias,
-					Ts:            now.Unix(),
+					Ts:            ts.Unix(),
 					Value:         property.LastValue,
 				})
 			}
 		}
 	}
-	if len(lastValuesToImport) > 0 {
-		err := a.sitewisecl.PopulateArbitrarySamplesByAlias(ctx, lastValuesToImport)
-		if err != nil {
-			a.logger.Error("Error populating last values time series data: ", err)
-			return err
-		}
+	return lastValuesToImport
+}
+
+// carriesLastValue tells if the last value of the property is written when the property has no samples
+func (a *TsAligner) carriesLastValue(property iotclient.ArduinoProperty) bool {
+	if a.lastValueOnly || property.UpdateStrategy == "ON_CHANGE" {
+		return true
 	}
+	return a.lastValuePolicy == LastValuePeriodic && isPeriodicUpdateStrategy(property.UpdateStrategy)
+}
 
-	return nil
+// isPeriodicUpdateStrategy tells if the property reports its value at a fixed interval
+func isPeriodicUpdateStrategy(strategy string) bool {
+	return strategy == "TIMED" || strategy == "PERIODIC"
+}
+
+// updatedAtPoints returns, for each property with an update time alias, the time its value last changed as epoch seconds.
+func updatedAtPoints(propertiesMap map[string]iotclient.ArduinoProperty, updatedAtAliases map[string]string) []sitewiseclient.DataPoint {
+	points := []sitewiseclient.DataPoint{}
+	now := time.Now().UTC()
+	for propertyId, alias := range updatedAtAliases {
+		property, ok := propertiesMap[propertyId]
+		if !ok || property.ValueUpdatedAt == nil {
+			continue
+		}
+		points = append(points, sitewiseclient.DataPoint{
+			PropertyAlias: alias,
+			Ts:            now.Unix(),
+			Value:         float64(property.ValueUpdatedAt.Unix()),
+		})
+	}
+	return points
 }
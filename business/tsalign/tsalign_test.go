@@ -17,13 +17,30 @@ package tsalign
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/arduino/aws-sitewise-integration/business/entityalign"
+	"github.com/arduino/aws-sitewise-integration/business/propfilter"
+	"github.com/arduino/aws-sitewise-integration/internal/deadletter"
+	deadLetterMocks "github.com/arduino/aws-sitewise-integration/internal/deadletter/mocks"
+	"github.com/arduino/aws-sitewise-integration/internal/iot"
 	iotapiMocks "github.com/arduino/aws-sitewise-integration/internal/iot/mocks"
+	objectstoreMocks "github.com/arduino/aws-sitewise-integration/internal/objectstore/mocks"
+	"github.com/arduino/aws-sitewise-integration/internal/sitewiseclient"
 	sitewiseMocks "github.com/arduino/aws-sitewise-integration/internal/sitewiseclient/mocks"
+	"github.com/arduino/aws-sitewise-integration/internal/statestore"
 	iotclient "github.com/arduino/iot-client-go/v2"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/iotsitewise"
 	"github.com/aws/aws-sdk-go-v2/service/iotsitewise/types"
 	"github.com/sirupsen/logrus"
@@ -31,29 +48,58 @@ import (
 	"github.com/stretchr/testify/mock"
 )
 
-func TestPartitionData(t *testing.T) {
+func TestToChunk(t *testing.T) {
+	response := generateSamples(35)
+	c := toChunk(response)
+	assert.Equal(t, 35, len(c.ts))
+	assert.Equal(t, 35, len(c.values))
+	for i := range response.Times {
+		assert.Equal(t, response.Times[i].Unix(), c.ts[i])
+		assert.Equal(t, response.Values[i], c.values[i])
+	}
+}
 
-	response := generateSamples(10)
-	partitions := partitionResults(response)
-	assert.Equal(t, 1, len(partitions))
-	assert.Equal(t, 10, len(partitions[0].ts))
-	assert.Equal(t, 10, len(partitions[0].values))
+func TestToChunk_duplicateTimestamps(t *testing.T) {
+	base := time.Date(2024, 5, 5, 13, 49, 42, 0, time.UTC)
+	c := toSampledChunk(iotclient.ArduinoSeriesSampledResponse{
+		Times:  []time.Time{base, base.Add(300 * time.Millisecond), base.Add(time.Minute), base},
+		Values: []interface{}{"a", "b", "c", "d"},
+	})
+	assert.Equal(t, []int64{base.Unix(), base.Add(time.Minute).Unix()}, c.ts)
+	assert.Equal(t, []any{"d", "c"}, c.values)
+}
 
-	response = generateSamples(35)
-	partitions = partitionResults(response)
-	assert.Equal(t, 4, len(partitions))
-	assert.Equal(t, 10, len(partitions[0].ts))
-	assert.Equal(t, 10, len(partitions[0].values))
-	assert.Equal(t, 5, len(partitions[3].ts))
-	assert.Equal(t, 5, len(partitions[3].values))
+func TestTSExtraction_duplicateTimestampsWrittenOnce(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
 
-	response = generateSamples(60)
-	partitions = partitionResults(response)
-	assert.Equal(t, 6, len(partitions))
-	for _, p := range partitions {
-		assert.Equal(t, 10, len(p.ts))
-		assert.Equal(t, 10, len(p.values))
+	thingId := "bb831f04-0940-4ea6-9c24-83668e372919"
+	propertyId := "c86f4ed9-7f52-4bd3-bdc6-b2936bec68ac"
+	alias := "/" + thingId + "/temperature"
+	mapped := &mappedProperties{
+		PropertiesToImport:        []string{propertyId},
+		PropertiesToImportAliases: map[string]string{propertyId: alias},
 	}
+
+	// Overlapping windows repeat a bucket
+	bucket := time.Now().Truncate(5 * time.Minute).Add(-10 * time.Minute)
+	times := []time.Time{bucket.Add(-5 * time.Minute), bucket, bucket, bucket.Add(5 * time.Minute)}
+	arclient := iotapiMocks.NewAPI(t)
+	arclient.On("GetTimeSeriesByThing", ctx, thingId, mock.Anything, mock.Anything, int64(300), "").Return(&iotclient.ArduinoSeriesBatch{
+		Responses: []iotclient.ArduinoSeriesResponse{
+			{Query: "property." + propertyId, Times: times, Values: []float64{1.0, 2.0, 2.5, 3.0}, CountValues: 4},
+		},
+	}, nil).Once()
+	swclient := sitewiseMocks.NewAPI(t)
+	swclient.On("PopulateTimeSeriesByAlias", ctx, alias,
+		[]int64{times[0].Unix(), times[1].Unix(), times[3].Unix()}, []float64{1.0, 2.5, 3.0}).Return(nil).Once()
+
+	tsAligner := New(swclient, arclient, logger)
+	from, to := computeTimeAlignment(time.Now(), 300, 60, 0)
+	imported, err := tsAligner.populateTSDataIntoSiteWise(ctx, thingId, mapped, 300, from, to)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{propertyId}, imported)
+	assert.Equal(t, int64(3), tsAligner.counters.summary().PointsWritten)
 }
 
 func generateSamples(howMany int) iotclient.ArduinoSeriesResponse {
@@ -106,20 +152,16 @@ func TestTSExtraction_extractSamplesForDefinedThings(t *testing.T) {
 	}
 
 	// API mocks
-	swclient.On("ListAssetModels", ctx).Return(&iotsitewise.ListAssetModelsOutput{
-		AssetModelSummaries: []types.AssetModelSummary{
-			{
-				Id: &modelId,
-			},
+	swclient.On("ListAllAssetModels", ctx).Return([]types.AssetModelSummary{
+		{
+			Id: &modelId,
 		},
 	}, nil).Once()
-	swclient.On("ListAssets", ctx, &modelId).Return(&iotsitewise.ListAssetsOutput{
-		AssetSummaries: []types.AssetSummary{
-			{
-				Id:         &assetId,
-				Name:       toPtr("test"),
-				ExternalId: &thingId,
-			},
+	swclient.On("ListAllAssetsForModel", ctx, &modelId).Return([]types.AssetSummary{
+		{
+			Id:         &assetId,
+			Name:       toPtr("test"),
+			ExternalId: &thingId,
 		},
 	}, nil).Once()
 	swclient.On("DescribeAsset", ctx, assetId).Return(&iotsitewise.DescribeAssetOutput{
@@ -156,7 +198,7 @@ func TestTSExtraction_extractSamplesForDefinedThings(t *testing.T) {
 	samples := iotclient.ArduinoSeriesBatch{
 		Responses: responses,
 	}
-	arclient.On("GetTimeSeriesByThing", ctx, thingId, mock.Anything, mock.Anything, int64(300)).Return(&samples, false, nil)
+	arclient.On("GetTimeSeriesByThing", ctx, thingId, mock.Anything, mock.Anything, int64(300), "").Return(&samples, nil)
 	arclient.On("GetTimeSeriesSampling", ctx, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(&iotclient.ArduinoSeriesBatchSampled{
 		Responses: []iotclient.ArduinoSeriesSampledResponse{
 			{
@@ -166,13 +208,2060 @@ func TestTSExtraction_extractSamplesForDefinedThings(t *testing.T) {
 				CountValues: 2,
 			},
 		},
-	}, false, nil)
+	}, nil)
 
 	tsAligner := New(swclient, arclient, logger)
-	errs := tsAligner.AlignTimeSeriesSamplesIntoSiteWise(ctx, 60, thingsMap, 300)
+	_, errs := tsAligner.AlignTimeSeriesSamplesIntoSiteWise(ctx, 60, thingsMap, 300)
+	assert.Nil(t, errs)
+}
+
+func TestTSExtraction_parallelPropertyImportMergesImportedProperties(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	thingId := "bb831f04-0940-4ea6-9c24-83668e372919"
+	propertyId := "c86f4ed9-7f52-4bd3-bdc6-b2936bec68ac"
+	propertyIdString := "a86f4ed9-7f52-4bd3-bdc6-b2936bec67de"
+
+	swclient := sitewiseMocks.NewAPI(t)
+	arclient := iotapiMocks.NewAPI(t)
+
+	now := time.Now()
+	arclient.On("GetTimeSeriesByThing", ctx, thingId, mock.Anything, mock.Anything, int64(300), "").Return(&iotclient.ArduinoSeriesBatch{
+		Responses: []iotclient.ArduinoSeriesResponse{
+			{
+				Query:       fmt.Sprintf("property.%s", propertyId),
+				Times:       []time.Time{now.Add(-time.Minute * 1), now},
+				Values:      []float64{1.0, 2.0},
+				CountValues: 2,
+			},
+		},
+	}, nil).Once()
+	arclient.On("GetTimeSeriesSampling", ctx, []string{propertyIdString}, mock.Anything, mock.Anything, int32(300)).Return(&iotclient.ArduinoSeriesBatchSampled{
+		Responses: []iotclient.ArduinoSeriesSampledResponse{
+			{
+				Query:       fmt.Sprintf("property.%s", propertyIdString),
+				Times:       []time.Time{now.Add(-time.Minute * 1), now},
+				Values:      []any{"msg1", "msg2"},
+				CountValues: 2,
+			},
+		},
+	}, nil).Once()
+	swclient.On("PopulateTimeSeriesByAlias", ctx, "/"+thingId+"/temperature", mock.Anything, mock.Anything).Return(nil).Once()
+	swclient.On("PopulateSampledSamplesTimeSeriesByAlias", ctx, "/"+thingId+"/msg", mock.Anything, mock.Anything).Return(nil).Once()
+
+	mapped := &mappedProperties{
+		PropertiesToImport:     []string{propertyId},
+		CharPropertiesToImport: []string{propertyIdString},
+		PropertiesToImportAliases: map[string]string{
+			propertyId:       "/" + thingId + "/temperature",
+			propertyIdString: "/" + thingId + "/msg",
+		},
+	}
+
+	tsAligner := New(swclient, arclient, logger, WithParallelPropertyImport(true))
+	from, to := computeTimeAlignment(time.Now(), 300, 60, 0)
+	imported, err := tsAligner.populateThingTSDataIntoSiteWise(ctx, thingId, mapped, 300, from, to)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{propertyId, propertyIdString}, imported)
+}
+
+func TestParseLocation(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   any
+		want    string
+		wantErr bool
+	}{
+		{name: "object", value: map[string]any{"lat": 45.4642, "lon": 9.19}, want: "45.4642,9.19"},
+		{name: "JSON encoded", value: `{"lat":-33.8688,"lon":151.2093}`, want: "-33.8688,151.2093"},
+		{name: "string coordinates", value: map[string]any{"lat": "10.5", "lon": "-20"}, want: "10.5,-20"},
+		{name: "missing longitude", value: map[string]any{"lat": 45.0}, wantErr: true},
+		{name: "latitude out of range", value: map[string]any{"lat": 91.0, "lon": 9.0}, wantErr: true},
+		{name: "not an object", value: 12.0, wantErr: true},
+		{name: "invalid JSON", value: "45.0,9.0", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lat, lng, err := parseLocation(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, formatLocation(lat, lng))
+		})
+	}
+}
+
+func TestTSExtraction_locationProperties(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	thingId := "bb831f04-0940-4ea6-9c24-83668e372919"
+	propertyId := "c86f4ed9-7f52-4bd3-bdc6-b2936bec68ac"
+
+	swclient := sitewiseMocks.NewAPI(t)
+	arclient := iotapiMocks.NewAPI(t)
+
+	thing := iotclient.ArduinoThing{
+		Id:         thingId,
+		Properties: []iotclient.ArduinoProperty{{Id: propertyId, Name: "position", Type: "LOCATION"}},
+	}
+	asset := &iotsitewise.DescribeAssetOutput{
+		AssetProperties: []types.AssetProperty{
+			{Name: toPtr("position")},
+			{Name: toPtr("position_lat")},
+			{Name: toPtr("position_lng")},
+		},
+	}
+
+	tsAligner := New(swclient, arclient, logger, WithLocationCoordinates(true))
+	mapped := tsAligner.mapPropertiesToImport(asset, thing, "test", nil)
+	assert.Equal(t, []string{propertyId}, mapped.CharPropertiesToImport)
+	assert.Equal(t, map[string]map[string]string{
+		propertyId: {
+			"lat": "/" + thingId + "/position_lat",
+			"lng": "/" + thingId + "/position_lng",
+		},
+	}, mapped.CoordinateAliases)
+
+	now := time.Now().Truncate(time.Second)
+	arclient.On("GetTimeSeriesSampling", ctx, []string{propertyId}, mock.Anything, mock.Anything, int32(300)).Return(&iotclient.ArduinoSeriesBatchSampled{
+		Responses: []iotclient.ArduinoSeriesSampledResponse{
+			{
+				Query: fmt.Sprintf("property.%s", propertyId),
+				Times: []time.Time{now.Add(-2 * time.Minute), now.Add(-time.Minute), now},
+				// The malformed sample in the middle is skipped
+				Values:      []any{map[string]any{"lat": 45.5, "lon": 9.25}, map[string]any{"lat": "north"}, map[string]any{"lat": 46.0, "lon": 9.5}},
+				CountValues: 3,
+			},
+		},
+	}, nil).Once()
+	ts := []int64{now.Add(-2 * time.Minute).Unix(), now.Unix()}
+	swclient.On("PopulateSampledSamplesTimeSeriesByAlias", ctx, "/"+thingId+"/position", ts, []any{"45.5,9.25", "46,9.5"}).Return(nil).Once()
+	swclient.On("PopulateTimeSeriesByAlias", ctx, "/"+thingId+"/position_lat", ts, []float64{45.5, 46.0}).Return(nil).Once()
+	swclient.On("PopulateTimeSeriesByAlias", ctx, "/"+thingId+"/position_lng", ts, []float64{9.25, 9.5}).Return(nil).Once()
+
+	from, to := computeTimeAlignment(time.Now(), 300, 60, 0)
+	imported, err := tsAligner.populateThingTSDataIntoSiteWise(ctx, thingId, mapped, 300, from, to)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{propertyId}, imported)
+	assert.Equal(t, ImportSummary{PointsWritten: 6, PointsSkipped: 1}, tsAligner.counters.summary())
+
+	// A thing property named like a coordinate keeps its alias
+	latId := "d86f4ed9-7f52-4bd3-bdc6-b2936bec68ad"
+	thing.Properties = append(thing.Properties, iotclient.ArduinoProperty{Id: latId, Name: "position_lat", Type: "FLOAT"})
+	mapped = tsAligner.mapPropertiesToImport(asset, thing, "test", nil)
+	assert.Equal(t, map[string]map[string]string{
+		propertyId: {"lng": "/" + thingId + "/position_lng"},
+	}, mapped.CoordinateAliases)
+	assert.Equal(t, "/"+thingId+"/position_lat", mapped.PropertiesToImportAliases[latId])
+}
+
+func TestDiscoveryCache_scanIntervalGating(t *testing.T) {
+	now := time.Now()
+	cache := NewDiscoveryCache()
+	cache.now = func() time.Time { return now }
+
+	_, ok := cache.get(10 * time.Minute)
+	assert.False(t, ok, "empty cache must force a scan")
+
+	cache.set([]*discoveredAsset{{assetId: "a1", thingId: "t1"}})
+	assets, ok := cache.get(10 * time.Minute)
+	assert.True(t, ok)
+	assert.Equal(t, 1, len(assets))
+
+	_, ok = cache.get(0)
+	assert.False(t, ok, "zero interval disables the cache")
+
+	now = now.Add(10 * time.Minute)
+	_, ok = cache.get(10 * time.Minute)
+	assert.False(t, ok, "scan interval elapsed")
+
+	cache.set([]*discoveredAsset{{assetId: "a1", thingId: "t1"}})
+	cache.Invalidate()
+	_, ok = cache.get(10 * time.Minute)
+	assert.False(t, ok, "invalidated cache must force a scan")
+}
+
+func TestTSExtraction_discoveryCacheReuse(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	thingId := "bb831f04-0940-4ea6-9c24-83668e372919"
+	modelId := "03ba45c2-eab3-44ed-a68f-94a26d41df4c"
+	assetId := "e9e11559-ceca-4c2f-875d-76c1068a45f4"
+
+	swclient := sitewiseMocks.NewAPI(t)
+	arclient := iotapiMocks.NewAPI(t)
+
+	thingsMap := map[string]iotclient.ArduinoThing{
+		thingId: {Id: thingId},
+	}
+
+	// Discovery calls are expected only once, second run is served by the cache
+	swclient.On("ListAllAssetModels", ctx).Return([]types.AssetModelSummary{{Id: &modelId}}, nil).Once()
+	swclient.On("ListAllAssetsForModel", ctx, &modelId).Return([]types.AssetSummary{{Id: &assetId, Name: toPtr("test"), ExternalId: &thingId}}, nil).Once()
+	swclient.On("DescribeAsset", ctx, assetId).Return(&iotsitewise.DescribeAssetOutput{
+		AssetId: &assetId,
+	}, nil).Once()
+
+	cache := NewDiscoveryCache()
+	tsAligner := New(swclient, arclient, logger, WithDiscoveryCache(cache, time.Hour))
+	_, errs := tsAligner.AlignTimeSeriesSamplesIntoSiteWise(ctx, 60, thingsMap, 300)
 	assert.Nil(t, errs)
+	_, errs = tsAligner.AlignTimeSeriesSamplesIntoSiteWise(ctx, 60, thingsMap, 300)
+	assert.Nil(t, errs)
+}
+
+func TestLastValue_nilLastValuePlaceholder(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	propertyId := "c86f4ed9-7f52-4bd3-bdc6-b2936bec68ac"
+	alias := "/bb831f04-0940-4ea6-9c24-83668e372919/temperature"
+	propertiesMap := map[string]iotclient.ArduinoProperty{
+		propertyId: {
+			Id:             propertyId,
+			Name:           "temperature",
+			Type:           "FLOAT",
+			UpdateStrategy: "ON_CHANGE",
+		},
+	}
+	aliases := map[string]string{propertyId: alias}
+
+	// Default behaviour: nil last values are skipped
+	swclient := sitewiseMocks.NewAPI(t)
+	tsAligner := New(swclient, iotapiMocks.NewAPI(t), logger)
+	err := tsAligner.populateLastValueForOnChangeProperties(ctx, propertiesMap, []string{}, aliases, nil, nil)
+	assert.Nil(t, err)
+	swclient.AssertNotCalled(t, "PopulateArbitrarySamplesByAlias", mock.Anything, mock.Anything)
+
+	// Placeholder enabled: a bad quality point is written
+	swclient = sitewiseMocks.NewAPI(t)
+	swclient.On("PopulateArbitrarySamplesByAlias", ctx, mock.MatchedBy(func(points []sitewiseclient.DataPoint) bool {
+		return len(points) == 1 &&
+			points[0].PropertyAlias == alias &&
+			points[0].Quality == types.QualityBad &&
+			points[0].Value == 0.0
+	})).Return(nil).Once()
+	tsAligner = New(swclient, iotapiMocks.NewAPI(t), logger, WithNilLastValuePlaceholder(true))
+	err = tsAligner.populateLastValueForOnChangeProperties(ctx, propertiesMap, []string{}, aliases, nil, nil)
+	assert.Nil(t, err)
+}
+
+func TestLastValue_periodicPolicy(t *testing.T) {
+	logger := logrus.NewEntry(logrus.New())
+
+	onChangeId := "c86f4ed9-7f52-4bd3-bdc6-b2936bec68ac"
+	periodicId := "d86f4ed9-7f52-4bd3-bdc6-b2936bec68ad"
+	reportedId := "e86f4ed9-7f52-4bd3-bdc6-b2936bec68ae"
+	propertiesMap := map[string]iotclient.ArduinoProperty{
+		onChangeId: {Id: onChangeId, Name: "relay", Type: "FLOAT", UpdateStrategy: "ON_CHANGE", LastValue: 1.0},
+		periodicId: {Id: periodicId, Name: "temperature", Type: "FLOAT", UpdateStrategy: "TIMED", LastValue: 21.5},
+		reportedId: {Id: reportedId, Name: "humidity", Type: "FLOAT", UpdateStrategy: "TIMED", LastValue: 40.0},
+	}
+	aliases := map[string]string{onChangeId: "/thing/relay", periodicId: "/thing/temperature", reportedId: "/thing/humidity"}
+	// humidity has samples in the time window
+	imported := []string{reportedId}
+
+	aliasesOf := func(points []sitewiseclient.DataPoint) []string {
+		result := []string{}
+		for _, p := range points {
+			result = append(result, p.PropertyAlias)
+		}
+		return result
+	}
+
+	// Default policy: only ON_CHANGE properties are carried
+	tsAligner := New(nil, nil, logger)
+	assert.ElementsMatch(t, []string{"/thing/relay"}, aliasesOf(tsAligner.lastValuePoints(propertiesMap, imported, aliases, nil)))
+
+	// Periodic policy: periodic properties without samples are carried too, the one with samples isn't overwritten
+	tsAligner = New(nil, nil, logger, WithLastValuePolicy(LastValuePeriodic))
+	assert.ElementsMatch(t, []string{"/thing/relay", "/thing/temperature"}, aliasesOf(tsAligner.lastValuePoints(propertiesMap, imported, aliases, nil)))
+}
+
+func TestLastValue_updatedAtProperties(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	thingId := "bb831f04-0940-4ea6-9c24-83668e372919"
+	propertyId := "c86f4ed9-7f52-4bd3-bdc6-b2936bec68ac"
+	updatedAt := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+	thing := iotclient.ArduinoThing{
+		Id: thingId,
+		Properties: []iotclient.ArduinoProperty{
+			{Id: propertyId, Name: "on", Type: "STATUS", UpdateStrategy: "ON_CHANGE", LastValue: true, ValueUpdatedAt: &updatedAt},
+		},
+	}
+	describedAsset := &iotsitewise.DescribeAssetOutput{
+		AssetProperties: []types.AssetProperty{{Name: toPtr("on")}, {Name: toPtr("on_updated_at")}},
+	}
+
+	swclient := sitewiseMocks.NewAPI(t)
+	tsAligner := New(swclient, iotapiMocks.NewAPI(t), logger, WithUpdatedAtProperties(true))
+	mapped := tsAligner.mapPropertiesToImport(describedAsset, thing, "test", nil)
+	assert.Equal(t, map[string]string{propertyId: "/" + thingId + "/on_updated_at"}, mapped.UpdatedAtAliases)
+
+	// Update time is written even when the property has samples in the time window
+	swclient.On("PopulateArbitrarySamplesByAlias", ctx, mock.MatchedBy(func(points []sitewiseclient.DataPoint) bool {
+		return len(points) == 1 &&
+			points[0].PropertyAlias == "/"+thingId+"/on_updated_at" &&
+			points[0].Value == float64(updatedAt.Unix())
+	})).Return(nil).Once()
+	propertiesMap := map[string]iotclient.ArduinoProperty{propertyId: thing.Properties[0]}
+	err := tsAligner.populateLastValueForOnChangeProperties(ctx, propertiesMap, []string{propertyId}, mapped.PropertiesToImportAliases, mapped.Transforms, mapped.UpdatedAtAliases)
+	assert.Nil(t, err)
+
+	// Not mapped when disabled
+	tsAligner = New(swclient, iotapiMocks.NewAPI(t), logger)
+	assert.Empty(t, tsAligner.mapPropertiesToImport(describedAsset, thing, "test", nil).UpdatedAtAliases)
+}
+
+func TestMapPropertiesToImport_categoryFilter(t *testing.T) {
+	logger := logrus.NewEntry(logrus.New())
+	thing := iotclient.ArduinoThing{
+		Id: "bb831f04-0940-4ea6-9c24-83668e372919",
+		Properties: []iotclient.ArduinoProperty{
+			{Id: "p1", Name: "temperature", Type: "FLOAT"},
+			{Id: "p2", Name: "msg", Type: "CHARSTRING"},
+		},
+	}
+	describedAsset := &iotsitewise.DescribeAssetOutput{
+		AssetProperties: []types.AssetProperty{
+			{Name: toPtr("temperature")},
+			{Name: toPtr("msg")},
+		},
+	}
+
+	tsAligner := New(sitewiseMocks.NewAPI(t), iotapiMocks.NewAPI(t), logger, WithPropertyFilter(propfilter.New([]propfilter.Category{propfilter.String})))
+	mapped := tsAligner.mapPropertiesToImport(describedAsset, thing, "test", nil)
+	assert.Empty(t, mapped.PropertiesToImport)
+	assert.Equal(t, []string{"p2"}, mapped.CharPropertiesToImport)
+	assert.Equal(t, 1, len(mapped.PropertiesToImportAliases))
 }
 
 func toPtr(val string) *string {
 	return &val
 }
+
+func TestComputeTimeAlignment_clockSkewTolerance(t *testing.T) {
+	now := time.Date(2024, 10, 1, 12, 1, 30, 0, time.UTC)
+
+	// No tolerance: truncated to 12:00, shifted back 5 minutes
+	from, to := computeTimeAlignment(now, 300, 60, 0)
+	assert.Equal(t, time.Date(2024, 10, 1, 11, 55, 0, 0, time.UTC), to)
+	assert.Equal(t, time.Date(2024, 10, 1, 10, 55, 0, 0, time.UTC), from)
+
+	// Tolerance smaller than the distance from the bucket start doesn't change the window
+	from, to = computeTimeAlignment(now, 300, 60, 30*time.Second)
+	assert.Equal(t, time.Date(2024, 10, 1, 11, 55, 0, 0, time.UTC), to)
+	assert.Equal(t, time.Date(2024, 10, 1, 10, 55, 0, 0, time.UTC), from)
+
+	// Tolerance crossing the bucket boundary moves the window back of one bucket
+	from, to = computeTimeAlignment(now, 300, 60, 2*time.Minute)
+	assert.Equal(t, time.Date(2024, 10, 1, 11, 50, 0, 0, time.UTC), to)
+	assert.Equal(t, time.Date(2024, 10, 1, 10, 50, 0, 0, time.UTC), from)
+
+	// Resolutions above 15 minutes are not shifted, but tolerance still applies
+	from, to = computeTimeAlignment(now, 3600, 120, 2*time.Minute)
+	assert.Equal(t, time.Date(2024, 10, 1, 11, 0, 0, 0, time.UTC), to)
+	assert.Equal(t, time.Date(2024, 10, 1, 9, 0, 0, 0, time.UTC), from)
+}
+
+func TestOverlapWindowStart(t *testing.T) {
+	from := time.Date(2024, 10, 1, 11, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, from, overlapWindowStart(from, 0, 5*time.Minute))
+	// Rounded up to whole buckets
+	assert.Equal(t, from.Add(-5*time.Minute), overlapWindowStart(from, time.Minute, 5*time.Minute))
+	assert.Equal(t, from.Add(-10*time.Minute), overlapWindowStart(from, 6*time.Minute, 5*time.Minute))
+	// Raw samples have no buckets
+	assert.Equal(t, from.Add(-time.Minute), overlapWindowStart(from, time.Minute, 0))
+}
+
+func TestTSExtraction_windowOverlapCoversSharedEdge(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	thingId := "bb831f04-0940-4ea6-9c24-83668e372919"
+	modelId := "03ba45c2-eab3-44ed-a68f-94a26d41df4c"
+	assetId := "e9e11559-ceca-4c2f-875d-76c1068a45f4"
+	propertyId := "c86f4ed9-7f52-4bd3-bdc6-b2936bec68ac"
+	thingsMap := map[string]iotclient.ArduinoThing{
+		thingId: {Id: thingId, Properties: []iotclient.ArduinoProperty{{Id: propertyId, Name: "temperature", Type: "FLOAT"}}},
+	}
+
+	run := func(t *testing.T, overlap time.Duration) (windows [][2]time.Time) {
+		swclient := sitewiseMocks.NewAPI(t)
+		arclient := iotapiMocks.NewAPI(t)
+		swclient.On("ListAllAssetModels", ctx).Return([]types.AssetModelSummary{{Id: &modelId}}, nil).Twice()
+		swclient.On("ListAllAssetsForModel", ctx, &modelId).Return([]types.AssetSummary{{Id: &assetId, Name: toPtr("test"), ExternalId: &thingId}}, nil).Twice()
+		swclient.On("DescribeAsset", ctx, assetId).Return(&iotsitewise.DescribeAssetOutput{
+			AssetId:         &assetId,
+			AssetProperties: []types.AssetProperty{{Name: toPtr("temperature")}},
+		}, nil).Twice()
+		arclient.On("GetTimeSeriesByThing", ctx, thingId, mock.Anything, mock.Anything, int64(300), "").Run(func(args mock.Arguments) {
+			windows = append(windows, [2]time.Time{args.Get(2).(time.Time), args.Get(3).(time.Time)})
+		}).Return(&iotclient.ArduinoSeriesBatch{}, nil).Twice()
+
+		// Back to back scheduled runs
+		now := time.Date(2024, 10, 1, 12, 1, 30, 0, time.UTC)
+		tsAligner := New(swclient, arclient, logger, WithWindowOverlap(overlap))
+		for range 2 {
+			tsAligner.now = func() time.Time { return now }
+			_, errs := tsAligner.AlignTimeSeriesSamplesIntoSiteWise(ctx, 30, thingsMap, 300)
+			assert.Nil(t, errs)
+			now = now.Add(30 * time.Minute)
+		}
+		return windows
+	}
+
+	// Without overlap, the second window starts where the first one ends
+	windows := run(t, 0)
+	if assert.Len(t, windows, 2) {
+		assert.Equal(t, windows[0][1], windows[1][0])
+	}
+
+	// With overlap, the point on the shared edge is strictly inside the second window
+	windows = run(t, time.Minute)
+	if assert.Len(t, windows, 2) {
+		edge := windows[0][1]
+		assert.True(t, windows[1][0].Before(edge) && edge.Before(windows[1][1]))
+		assert.Equal(t, 5*time.Minute, edge.Sub(windows[1][0]), "windows stay aligned to buckets")
+	}
+}
+
+func TestDisplayLocation(t *testing.T) {
+	logger := logrus.NewEntry(logrus.New())
+	// Across the end of daylight saving time in Rome
+	ts := []int64{time.Date(2024, 10, 27, 0, 30, 0, 0, time.UTC).Unix(), time.Date(2024, 10, 27, 1, 30, 0, 0, time.UTC).Unix()}
+
+	utc := New(nil, nil, logger)
+	assert.Equal(t, strconv.FormatInt(ts[0], 10)+","+strconv.FormatInt(ts[1], 10), utc.joinTs(ts))
+	assert.Equal(t, "2024-10-27 00:30:00 +0000 UTC", utc.displayTime(time.Unix(ts[0], 0)))
+
+	rome, err := time.LoadLocation("Europe/Rome")
+	assert.NoError(t, err)
+	local := New(nil, nil, logger, WithDisplayLocation(rome))
+	assert.Equal(t, "2024-10-27T02:30:00+02:00,2024-10-27T02:30:00+01:00", local.joinTs(ts))
+	assert.Equal(t, "2024-10-27 02:30:00 +0200 CEST", local.displayTime(time.Unix(ts[0], 0)))
+}
+
+func TestComputeRawTimeWindow(t *testing.T) {
+	now := time.Date(2024, 10, 1, 12, 1, 30, 500, time.UTC)
+
+	// Not aligned to any bucket, only to the second
+	from, to := computeRawTimeWindow(now, 15, 0)
+	assert.Equal(t, time.Date(2024, 10, 1, 12, 1, 30, 0, time.UTC), to)
+	assert.Equal(t, time.Date(2024, 10, 1, 11, 46, 30, 0, time.UTC), from)
+
+	from, to = computeRawTimeWindow(now, 15, 10*time.Second)
+	assert.Equal(t, time.Date(2024, 10, 1, 12, 1, 20, 0, time.UTC), to)
+	assert.Equal(t, time.Date(2024, 10, 1, 11, 46, 20, 0, time.UTC), from)
+}
+
+func TestTSExtraction_rawImport(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	thingId := "bb831f04-0940-4ea6-9c24-83668e372919"
+	modelId := "03ba45c2-eab3-44ed-a68f-94a26d41df4c"
+	assetId := "e9e11559-ceca-4c2f-875d-76c1068a45f4"
+	propertyId := "c86f4ed9-7f52-4bd3-bdc6-b2936bec68ac"
+	propertyIdBool := "b86f4ed9-7f52-4bd3-bdc6-b2936bec68ab"
+	propertyIdString := "a86f4ed9-7f52-4bd3-bdc6-b2936bec67de"
+
+	swclient := sitewiseMocks.NewAPI(t)
+	arclient := iotapiMocks.NewAPI(t)
+
+	thingsMap := map[string]iotclient.ArduinoThing{
+		thingId: {
+			Id: thingId,
+			Properties: []iotclient.ArduinoProperty{
+				{Id: propertyId, Name: "current", Type: "FLOAT"},
+				{Id: propertyIdBool, Name: "relay", Type: "STATUS"},
+				{Id: propertyIdString, Name: "msg", Type: "CHARSTRING"},
+			},
+		},
+	}
+
+	swclient.On("ListAllAssetModels", ctx).Return([]types.AssetModelSummary{{Id: &modelId}}, nil).Once()
+	swclient.On("ListAllAssetsForModel", ctx, &modelId).Return([]types.AssetSummary{{Id: &assetId, Name: toPtr("test"), ExternalId: &thingId}}, nil).Once()
+	swclient.On("DescribeAsset", ctx, assetId).Return(&iotsitewise.DescribeAssetOutput{
+		AssetId:         &assetId,
+		AssetProperties: []types.AssetProperty{{Name: toPtr("current")}, {Name: toPtr("relay")}, {Name: toPtr("msg")}},
+	}, nil).Once()
+
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+	arclient.On("GetRawTimeSeriesByProperties", ctx, []string{propertyId, propertyIdBool, propertyIdString}, mock.Anything, mock.Anything).Return(&iotclient.ArduinoSeriesRawBatch{
+		Responses: []iotclient.ArduinoSeriesRawResponse{
+			{
+				Query: fmt.Sprintf("property.%s", propertyId),
+				// Two samples in the same second: the last one is kept
+				Times:       []time.Time{base, base.Add(200 * time.Millisecond), base.Add(time.Second), base.Add(2 * time.Second)},
+				Values:      []any{1.0, 1.5, 2.0, 3.0},
+				CountValues: 4,
+			},
+			{
+				Query:       fmt.Sprintf("property.%s", propertyIdBool),
+				Times:       []time.Time{base, base.Add(time.Second)},
+				Values:      []any{true, false},
+				CountValues: 2,
+			},
+			{
+				Query:       fmt.Sprintf("property.%s", propertyIdString),
+				Times:       []time.Time{base},
+				Values:      []any{"msg1"},
+				CountValues: 1,
+			},
+		},
+	}, nil).Once()
+
+	unix := base.Unix()
+	swclient.On("PopulateTimeSeriesByAlias", ctx, "/"+thingId+"/current", []int64{unix, unix + 1, unix + 2}, []float64{1.5, 2.0, 3.0}).Return(nil).Once()
+	swclient.On("PopulateTimeSeriesByAlias", ctx, "/"+thingId+"/relay", []int64{unix, unix + 1}, []float64{1, 0}).Return(nil).Once()
+	swclient.On("PopulateSampledSamplesTimeSeriesByAlias", ctx, "/"+thingId+"/msg", []int64{unix}, []any{"msg1"}).Return(nil).Once()
+
+	tsAligner := New(swclient, arclient, logger, WithRawImport(true))
+	_, errs := tsAligner.AlignTimeSeriesSamplesIntoSiteWise(ctx, 15, thingsMap, 0)
+	assert.Nil(t, errs)
+	arclient.AssertNotCalled(t, "GetTimeSeriesByThing", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	arclient.AssertNotCalled(t, "GetTimeSeriesSampling", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestTSExtraction_minMaxAggregation(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	// Static id definitions
+	thingId := "bb831f04-0940-4ea6-9c24-83668e372919"
+	propertyId := "c86f4ed9-7f52-4bd3-bdc6-b2936bec68ac"
+	boolPropertyId := "d86f4ed9-7f52-4bd3-bdc6-b2936bec68ad"
+
+	// Mocks
+	swclient := sitewiseMocks.NewAPI(t)
+	arclient := iotapiMocks.NewAPI(t)
+
+	thing := iotclient.ArduinoThing{
+		Id: thingId,
+		Properties: []iotclient.ArduinoProperty{
+			{Id: propertyId, Name: "temperature", Type: "INT"},
+			{Id: boolPropertyId, Name: "on", Type: "STATUS"},
+		},
+	}
+	asset := &iotsitewise.DescribeAssetOutput{
+		AssetProperties: []types.AssetProperty{
+			{Name: toPtr("temperature")},
+			{Name: toPtr("temperature_min")},
+			{Name: toPtr("temperature_max")},
+			{Name: toPtr("on")},
+		},
+	}
+
+	tsAligner := New(swclient, arclient, logger, WithMinMaxAggregation(true))
+	mapped := tsAligner.mapPropertiesToImport(asset, thing, "test", nil)
+	assert.Equal(t, map[string]map[string]string{
+		propertyId: {
+			"MIN": "/" + thingId + "/temperature_min",
+			"MAX": "/" + thingId + "/temperature_max",
+		},
+	}, mapped.AggregateAliases)
+
+	now := time.Now()
+	ts := []time.Time{now.Add(-time.Minute), now}
+	arclient.On("GetAggregatedTimeSeriesByThing", ctx, thingId, mock.Anything, mock.Anything, int64(300), []string{"AVG", "MIN", "MAX"}).Return(&iotclient.ArduinoSeriesBatch{
+		Responses: []iotclient.ArduinoSeriesResponse{
+			{Aggregation: toPtr("AVG"), Query: "property." + propertyId, Times: ts, Values: []float64{2.0, 3.0}, CountValues: 2},
+			{Aggregation: toPtr("MIN"), Query: "property." + propertyId, Times: ts, Values: []float64{1.0, 2.0}, CountValues: 2},
+			{Aggregation: toPtr("MAX"), Query: "property." + propertyId, Times: ts, Values: []float64{4.0, 5.0}, CountValues: 2},
+			{Aggregation: toPtr("AVG"), Query: "property." + boolPropertyId, Times: ts, Values: []float64{0, 1}, CountValues: 2},
+			{Aggregation: toPtr("MIN"), Query: "property." + boolPropertyId, Times: ts, Values: []float64{0, 1}, CountValues: 2},
+		},
+	}, nil)
+	unix := []int64{ts[0].Unix(), ts[1].Unix()}
+	swclient.On("PopulateTimeSeriesByAlias", ctx, "/"+thingId+"/temperature", unix, []float64{2.0, 3.0}).Return(nil).Once()
+	swclient.On("PopulateTimeSeriesByAlias", ctx, "/"+thingId+"/temperature_min", unix, []float64{1.0, 2.0}).Return(nil).Once()
+	swclient.On("PopulateTimeSeriesByAlias", ctx, "/"+thingId+"/temperature_max", unix, []float64{4.0, 5.0}).Return(nil).Once()
+	swclient.On("PopulateTimeSeriesByAlias", ctx, "/"+thingId+"/on", unix, []float64{0, 1}).Return(nil).Once()
+
+	from, to := computeTimeAlignment(now, 300, 60, 0)
+	imported, err := tsAligner.populateTSDataIntoSiteWise(ctx, thingId, mapped, 300, from, to)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{propertyId, boolPropertyId}, imported)
+	// The min of the boolean property has no aggregate property on the asset
+	assert.Equal(t, int64(2), tsAligner.counters.skipped.Load())
+}
+
+func TestTSExtraction_minMaxAggregationNegativeScale(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	thingId := "bb831f04-0940-4ea6-9c24-83668e372919"
+	propertyId := "c86f4ed9-7f52-4bd3-bdc6-b2936bec68ac"
+
+	swclient := sitewiseMocks.NewAPI(t)
+	arclient := iotapiMocks.NewAPI(t)
+
+	thing := iotclient.ArduinoThing{
+		Id:         thingId,
+		Properties: []iotclient.ArduinoProperty{{Id: propertyId, Name: "level", Type: "FLOAT"}},
+	}
+	asset := &iotsitewise.DescribeAssetOutput{
+		AssetProperties: []types.AssetProperty{{Name: toPtr("level")}, {Name: toPtr("level_min")}, {Name: toPtr("level_max")}},
+	}
+
+	tsAligner := New(swclient, arclient, logger, WithMinMaxAggregation(true),
+		WithValueTransforms(map[string]Transform{"level": LinearTransform{Scale: -1}}))
+	mapped := tsAligner.mapPropertiesToImport(asset, thing, "test", nil)
+
+	now := time.Now()
+	ts := []time.Time{now.Add(-time.Minute), now}
+	arclient.On("GetAggregatedTimeSeriesByThing", ctx, thingId, mock.Anything, mock.Anything, int64(300), []string{"AVG", "MIN", "MAX"}).Return(&iotclient.ArduinoSeriesBatch{
+		Responses: []iotclient.ArduinoSeriesResponse{
+			{Aggregation: toPtr("AVG"), Query: "property." + propertyId, Times: ts, Values: []float64{2.0, 3.0}, CountValues: 2},
+			{Aggregation: toPtr("MIN"), Query: "property." + propertyId, Times: ts, Values: []float64{1.0, 2.0}, CountValues: 2},
+			{Aggregation: toPtr("MAX"), Query: "property." + propertyId, Times: ts, Values: []float64{4.0, 5.0}, CountValues: 2},
+		},
+	}, nil)
+	// The minimum of the values is the maximum of the transformed ones
+	unix := []int64{ts[0].Unix(), ts[1].Unix()}
+	swclient.On("PopulateTimeSeriesByAlias", ctx, "/"+thingId+"/level", unix, []float64{-2.0, -3.0}).Return(nil).Once()
+	swclient.On("PopulateTimeSeriesByAlias", ctx, "/"+thingId+"/level_max", unix, []float64{-1.0, -2.0}).Return(nil).Once()
+	swclient.On("PopulateTimeSeriesByAlias", ctx, "/"+thingId+"/level_min", unix, []float64{-4.0, -5.0}).Return(nil).Once()
+
+	from, to := computeTimeAlignment(now, 300, 60, 0)
+	_, err := tsAligner.populateTSDataIntoSiteWise(ctx, thingId, mapped, 300, from, to)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "MIN", transformedAggregation(LinearTransform{Scale: 1.8, Offset: 32}, "MIN"))
+	assert.Equal(t, "AVG", transformedAggregation(LinearTransform{Scale: -1}, "AVG"))
+	assert.Equal(t, "MAX", transformedAggregation(nil, "MAX"))
+}
+
+func TestParseAggregationOverrides(t *testing.T) {
+	overrides, err := ParseAggregationOverrides(" energy=max, alarm = LAST ,")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"energy": "MAX", "alarm": "LAST"}, overrides)
+
+	_, err = ParseAggregationOverrides("energy")
+	assert.Error(t, err)
+	_, err = ParseAggregationOverrides("energy=MEDIAN")
+	assert.Error(t, err)
+}
+
+func TestTSExtraction_aggregationOverrides(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	thingId := "bb831f04-0940-4ea6-9c24-83668e372919"
+	energyId := "c86f4ed9-7f52-4bd3-bdc6-b2936bec68ac"
+	temperatureId := "d86f4ed9-7f52-4bd3-bdc6-b2936bec68ad"
+
+	swclient := sitewiseMocks.NewAPI(t)
+	arclient := iotapiMocks.NewAPI(t)
+
+	thing := iotclient.ArduinoThing{
+		Id: thingId,
+		Properties: []iotclient.ArduinoProperty{
+			{Id: energyId, Name: "energy", Type: "FLOAT"},
+			{Id: temperatureId, Name: "temperature", Type: "FLOAT"},
+		},
+	}
+	asset := &iotsitewise.DescribeAssetOutput{
+		AssetProperties: []types.AssetProperty{{Name: toPtr("energy")}, {Name: toPtr("temperature")}},
+	}
+
+	tsAligner := New(swclient, arclient, logger, WithAggregationOverrides(map[string]string{"energy": "MAX"}))
+	mapped := tsAligner.mapPropertiesToImport(asset, thing, "test", nil)
+	assert.Equal(t, map[string]string{energyId: "MAX"}, mapped.AggregationOverrides)
+
+	now := time.Now()
+	ts := []time.Time{now.Add(-time.Minute), now}
+	unix := []int64{ts[0].Unix(), ts[1].Unix()}
+	arclient.On("GetTimeSeriesByThing", ctx, thingId, mock.Anything, mock.Anything, int64(300), "").Return(&iotclient.ArduinoSeriesBatch{
+		Responses: []iotclient.ArduinoSeriesResponse{
+			{Query: "property." + energyId, Times: ts, Values: []float64{2.0, 3.0}, CountValues: 2},
+			{Query: "property." + temperatureId, Times: ts, Values: []float64{20.0, 21.0}, CountValues: 2},
+		},
+	}, nil).Once()
+	arclient.On("GetTimeSeriesByProperties", ctx, map[string]string{energyId: "MAX"}, mock.Anything, mock.Anything, int64(300)).Return(&iotclient.ArduinoSeriesBatch{
+		Responses: []iotclient.ArduinoSeriesResponse{
+			{Aggregation: toPtr("MAX"), Query: "property." + energyId, Times: ts, Values: []float64{4.0, 5.0}, CountValues: 2},
+		},
+	}, nil).Once()
+	// Averages of the overridden property are not imported
+	swclient.On("PopulateTimeSeriesByAlias", ctx, "/"+thingId+"/temperature", unix, []float64{20.0, 21.0}).Return(nil).Once()
+	swclient.On("PopulateTimeSeriesByAlias", ctx, "/"+thingId+"/energy", unix, []float64{4.0, 5.0}).Return(nil).Once()
+
+	from, to := computeTimeAlignment(now, 300, 60, 0)
+	imported, err := tsAligner.populateTSDataIntoSiteWise(ctx, thingId, mapped, 300, from, to)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{energyId, temperatureId}, imported)
+}
+
+func TestDescribeAsset_partialAssetPolicy(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	assetId := "e9e11559-ceca-4c2f-875d-76c1068a45f4"
+	updating := &iotsitewise.DescribeAssetOutput{
+		AssetId:         &assetId,
+		AssetStatus:     &types.AssetStatus{State: types.AssetStateUpdating},
+		AssetProperties: []types.AssetProperty{{Name: toPtr("temperature")}},
+	}
+	active := &iotsitewise.DescribeAssetOutput{
+		AssetId:         &assetId,
+		AssetStatus:     &types.AssetStatus{State: types.AssetStateActive},
+		AssetProperties: []types.AssetProperty{{Name: toPtr("temperature")}, {Name: toPtr("pressure")}},
+	}
+
+	t.Run("import uses partial description without caching it", func(t *testing.T) {
+		swclient := sitewiseMocks.NewAPI(t)
+		swclient.On("DescribeAsset", ctx, assetId).Return(updating, nil).Once()
+
+		asset := &discoveredAsset{assetId: assetId}
+		description, ok, err := New(swclient, nil, logger).describeAsset(ctx, asset)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, updating, description)
+		assert.Nil(t, asset.description)
+	})
+
+	t.Run("defer waits for update completion", func(t *testing.T) {
+		swclient := sitewiseMocks.NewAPI(t)
+		swclient.On("DescribeAsset", ctx, assetId).Return(updating, nil).Once()
+		swclient.On("PollForAssetActiveStatusWithOptions", ctx, assetId, sitewiseclient.DefaultPollOptions).Return(nil).Once()
+		swclient.On("DescribeAsset", ctx, assetId).Return(active, nil).Once()
+
+		asset := &discoveredAsset{assetId: assetId}
+		description, ok, err := New(swclient, nil, logger, WithPartialAssetPolicy(PartialAssetDefer)).describeAsset(ctx, asset)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, active, description)
+		assert.Equal(t, active, asset.description)
+	})
+
+	t.Run("defer skips asset still being updated", func(t *testing.T) {
+		swclient := sitewiseMocks.NewAPI(t)
+		swclient.On("DescribeAsset", ctx, assetId).Return(updating, nil).Once()
+		swclient.On("PollForAssetActiveStatusWithOptions", ctx, assetId, sitewiseclient.DefaultPollOptions).Return(sitewiseclient.ErrPollTimeout).Once()
+
+		asset := &discoveredAsset{assetId: assetId}
+		description, ok, err := New(swclient, nil, logger, WithPartialAssetPolicy(PartialAssetDefer)).describeAsset(ctx, asset)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.Nil(t, description)
+		assert.Nil(t, asset.description)
+	})
+}
+
+func TestTSExtraction_batchedLastValuesAcrossThings(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	modelId := "03ba45c2-eab3-44ed-a68f-94a26d41df4c"
+	thingIds := []string{"bb831f04-0940-4ea6-9c24-83668e372919", "cc831f04-0940-4ea6-9c24-83668e372920"}
+	assetIds := []string{"e9e11559-ceca-4c2f-875d-76c1068a45f4", "f9e11559-ceca-4c2f-875d-76c1068a45f5"}
+	propertyIds := []string{"c86f4ed9-7f52-4bd3-bdc6-b2936bec68ac", "d86f4ed9-7f52-4bd3-bdc6-b2936bec68ad"}
+
+	swclient := sitewiseMocks.NewAPI(t)
+	arclient := iotapiMocks.NewAPI(t)
+
+	thingsMap := map[string]iotclient.ArduinoThing{}
+	summaries := []types.AssetSummary{}
+	for i := range thingIds {
+		thingsMap[thingIds[i]] = iotclient.ArduinoThing{
+			Id: thingIds[i],
+			Properties: []iotclient.ArduinoProperty{
+				{Id: propertyIds[i], Name: "temperature", Type: "FLOAT", UpdateStrategy: "ON_CHANGE", LastValue: 21.5},
+			},
+		}
+		summaries = append(summaries, types.AssetSummary{Id: &assetIds[i], Name: toPtr("test"), ExternalId: &thingIds[i]})
+		swclient.On("DescribeAsset", ctx, assetIds[i]).Return(&iotsitewise.DescribeAssetOutput{
+			AssetId:         &assetIds[i],
+			AssetProperties: []types.AssetProperty{{Name: toPtr("temperature")}},
+		}, nil).Once()
+		arclient.On("GetTimeSeriesByThing", ctx, thingIds[i], mock.Anything, mock.Anything, int64(300), "").Return(&iotclient.ArduinoSeriesBatch{}, nil).Once()
+	}
+	swclient.On("ListAllAssetModels", ctx).Return([]types.AssetModelSummary{{Id: &modelId}}, nil).Once()
+	swclient.On("ListAllAssetsForModel", ctx, &modelId).Return(summaries, nil).Once()
+
+	// Last values of both things are written with a single call
+	swclient.On("PopulateArbitrarySamplesByAlias", ctx, mock.MatchedBy(func(points []sitewiseclient.DataPoint) bool {
+		aliases := []string{}
+		for _, p := range points {
+			aliases = append(aliases, p.PropertyAlias)
+		}
+		return len(aliases) == 2 &&
+			slices.Contains(aliases, "/"+thingIds[0]+"/temperature") &&
+			slices.Contains(aliases, "/"+thingIds[1]+"/temperature")
+	})).Return(nil).Once()
+
+	tsAligner := New(swclient, arclient, logger, WithBatchedLastValues(true))
+	_, errs := tsAligner.AlignTimeSeriesSamplesIntoSiteWise(ctx, 60, thingsMap, 300)
+	assert.Nil(t, errs)
+}
+
+// describeFailureMocks sets up an import of two things, the asset of the first one failing to be described
+func describeFailureMocks(t *testing.T, ctx context.Context) (*sitewiseMocks.API, *iotapiMocks.API, map[string]iotclient.ArduinoThing) {
+	modelId := "03ba45c2-eab3-44ed-a68f-94a26d41df4c"
+	thingIds := []string{"bb831f04-0940-4ea6-9c24-83668e372919", "cc831f04-0940-4ea6-9c24-83668e372920"}
+	assetIds := []string{"e9e11559-ceca-4c2f-875d-76c1068a45f4", "f9e11559-ceca-4c2f-875d-76c1068a45f5"}
+
+	swclient := sitewiseMocks.NewAPI(t)
+	arclient := iotapiMocks.NewAPI(t)
+
+	thingsMap := map[string]iotclient.ArduinoThing{}
+	summaries := []types.AssetSummary{}
+	for i := range thingIds {
+		thingsMap[thingIds[i]] = iotclient.ArduinoThing{
+			Id:         thingIds[i],
+			Properties: []iotclient.ArduinoProperty{{Id: "c86f4ed9-7f52-4bd3-bdc6-b2936bec68ac", Name: "temperature", Type: "FLOAT"}},
+		}
+		summaries = append(summaries, types.AssetSummary{Id: &assetIds[i], Name: toPtr("test"), ExternalId: &thingIds[i]})
+	}
+	swclient.On("ListAllAssetModels", ctx).Return([]types.AssetModelSummary{{Id: &modelId}}, nil).Once()
+	swclient.On("ListAllAssetsForModel", ctx, &modelId).Return(summaries, nil).Once()
+	swclient.On("DescribeAsset", ctx, assetIds[0]).Return(nil, errors.New("access denied")).Once()
+	swclient.On("DescribeAsset", ctx, assetIds[1]).Return(&iotsitewise.DescribeAssetOutput{
+		AssetId:         &assetIds[1],
+		AssetProperties: []types.AssetProperty{{Name: toPtr("temperature")}},
+	}, nil).Once()
+	arclient.On("GetTimeSeriesByThing", ctx, thingIds[1], mock.Anything, mock.Anything, int64(300), "").Return(&iotclient.ArduinoSeriesBatch{}, nil).Once()
+	return swclient, arclient, thingsMap
+}
+
+func TestTSExtraction_describeFailureIsThingError(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+	swclient, arclient, thingsMap := describeFailureMocks(t, ctx)
+
+	sink := deadLetterMocks.NewAPI(t)
+	sink.On("Send", ctx, mock.MatchedBy(func(records []deadletter.Record) bool {
+		return len(records) == 1 && records[0].ThingID == "bb831f04-0940-4ea6-9c24-83668e372919" && strings.Contains(records[0].Error, "access denied")
+	})).Return(nil).Once()
+
+	tsAligner := New(swclient, arclient, logger, WithDeadLetters(sink))
+	_, errs := tsAligner.AlignTimeSeriesSamplesIntoSiteWise(ctx, 60, thingsMap, 300)
+	if assert.Len(t, errs, 1) {
+		var thingErr *ThingImportError
+		assert.ErrorAs(t, errs[0], &thingErr)
+		assert.Equal(t, "bb831f04-0940-4ea6-9c24-83668e372919", thingErr.ThingID)
+	}
+}
+
+func TestTSExtraction_importSummary(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	thingId := "bb831f04-0940-4ea6-9c24-83668e372919"
+	modelId := "03ba45c2-eab3-44ed-a68f-94a26d41df4c"
+	assetId := "e9e11559-ceca-4c2f-875d-76c1068a45f4"
+	temperatureId := "c86f4ed9-7f52-4bd3-bdc6-b2936bec68ac"
+	levelId := "d86f4ed9-7f52-4bd3-bdc6-b2936bec68ad"
+	unmappedId := "e86f4ed9-7f52-4bd3-bdc6-b2936bec68ae"
+
+	swclient := sitewiseMocks.NewAPI(t)
+	arclient := iotapiMocks.NewAPI(t)
+
+	thingsMap := map[string]iotclient.ArduinoThing{
+		thingId: {
+			Id: thingId,
+			Properties: []iotclient.ArduinoProperty{
+				{Id: temperatureId, Name: "temperature", Type: "FLOAT"},
+				{Id: levelId, Name: "level", Type: "FLOAT", UpdateStrategy: "ON_CHANGE", LastValue: 3.0},
+			},
+		},
+	}
+
+	swclient.On("ListAllAssetModels", ctx).Return([]types.AssetModelSummary{{Id: &modelId}}, nil).Once()
+	swclient.On("ListAllAssetsForModel", ctx, &modelId).Return([]types.AssetSummary{{Id: &assetId, Name: toPtr("test"), ExternalId: &thingId}}, nil).Once()
+	swclient.On("DescribeAsset", ctx, assetId).Return(&iotsitewise.DescribeAssetOutput{
+		AssetId:         &assetId,
+		AssetProperties: []types.AssetProperty{{Name: toPtr("temperature")}, {Name: toPtr("level")}},
+	}, nil).Once()
+
+	now := time.Now()
+	times := []time.Time{now.Add(-2 * time.Minute), now.Add(-time.Minute), now}
+	arclient.On("GetTimeSeriesByThing", ctx, thingId, mock.Anything, mock.Anything, int64(300), "").Return(&iotclient.ArduinoSeriesBatch{
+		Responses: []iotclient.ArduinoSeriesResponse{
+			{Query: "property." + temperatureId, Times: times[1:], Values: []float64{1.0, 2.0}, CountValues: 2},
+			{Query: "property." + unmappedId, Times: times, Values: []float64{1.0, 2.0, 3.0}, CountValues: 3},
+		},
+	}, nil).Once()
+	swclient.On("PopulateTimeSeriesByAlias", ctx, "/"+thingId+"/temperature", mock.Anything, []float64{1.0, 2.0}).Return(nil).Once()
+	swclient.On("PopulateArbitrarySamplesByAlias", ctx, mock.Anything).Return(nil).Once()
+
+	tsAligner := New(swclient, arclient, logger)
+	summary, errs := tsAligner.AlignTimeSeriesSamplesIntoSiteWise(ctx, 60, thingsMap, 300)
+	assert.Nil(t, errs)
+	assert.Equal(t, ImportSummary{ThingsProcessed: 1, PropertiesImported: 1, PointsWritten: 3, PointsSkipped: 3}, summary)
+}
+
+func TestTSExtraction_thingTimeout(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	stuckThingId := "bb831f04-0940-4ea6-9c24-83668e372919"
+	thingId := "cb831f04-0940-4ea6-9c24-83668e37291a"
+	modelId := "03ba45c2-eab3-44ed-a68f-94a26d41df4c"
+	stuckAssetId := "e9e11559-ceca-4c2f-875d-76c1068a45f4"
+	assetId := "f9e11559-ceca-4c2f-875d-76c1068a45f5"
+	propertyId := "c86f4ed9-7f52-4bd3-bdc6-b2936bec68ac"
+
+	swclient := sitewiseMocks.NewAPI(t)
+	arclient := iotapiMocks.NewAPI(t)
+
+	thingsMap := map[string]iotclient.ArduinoThing{
+		stuckThingId: {Id: stuckThingId, Properties: []iotclient.ArduinoProperty{{Id: propertyId, Name: "temperature", Type: "FLOAT"}}},
+		thingId:      {Id: thingId, Properties: []iotclient.ArduinoProperty{{Id: propertyId, Name: "temperature", Type: "FLOAT"}}},
+	}
+
+	swclient.On("ListAllAssetModels", ctx).Return([]types.AssetModelSummary{{Id: &modelId}}, nil).Once()
+	swclient.On("ListAllAssetsForModel", ctx, &modelId).Return([]types.AssetSummary{
+		{Id: &stuckAssetId, Name: toPtr("stuck"), ExternalId: &stuckThingId},
+		{Id: &assetId, Name: toPtr("test"), ExternalId: &thingId},
+	}, nil).Once()
+	swclient.On("DescribeAsset", mock.Anything, mock.Anything).Return(&iotsitewise.DescribeAssetOutput{
+		AssetProperties: []types.AssetProperty{{Name: toPtr("temperature")}},
+	}, nil).Twice()
+
+	// The series query of the stuck thing hangs until its context expires
+	arclient.On("GetTimeSeriesByThing", mock.Anything, stuckThingId, mock.Anything, mock.Anything, int64(300), "").Run(func(args mock.Arguments) {
+		<-args.Get(0).(context.Context).Done()
+	}).Return(nil, context.DeadlineExceeded).Once()
+	arclient.On("GetTimeSeriesByThing", mock.Anything, thingId, mock.Anything, mock.Anything, int64(300), "").Return(&iotclient.ArduinoSeriesBatch{}, nil).Once()
+
+	// A single import slot: the other thing is imported only if the stuck one releases it
+	tsAligner := New(swclient, arclient, logger, WithImportConcurrency(1), WithThingTimeout(20*time.Millisecond))
+	summary, errs := tsAligner.AlignTimeSeriesSamplesIntoSiteWise(ctx, 60, thingsMap, 300)
+	assert.Len(t, errs, 1)
+	var thingErr *ThingImportError
+	assert.ErrorAs(t, errs[0], &thingErr)
+	assert.Equal(t, stuckThingId, thingErr.ThingID)
+	assert.ErrorIs(t, errs[0], context.DeadlineExceeded)
+	assert.ErrorContains(t, errs[0], "timed out after 20ms")
+	assert.Equal(t, int64(1), summary.ThingsProcessed)
+}
+
+func TestTSExtraction_lastValueOnly(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	thingId := "bb831f04-0940-4ea6-9c24-83668e372919"
+	modelId := "03ba45c2-eab3-44ed-a68f-94a26d41df4c"
+	assetId := "e9e11559-ceca-4c2f-875d-76c1068a45f4"
+	temperatureId := "c86f4ed9-7f52-4bd3-bdc6-b2936bec68ac"
+	levelId := "d86f4ed9-7f52-4bd3-bdc6-b2936bec68ad"
+	updatedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+	// Older than the ingestion window
+	levelUpdatedAt := time.Now().Add(-30 * 24 * time.Hour)
+
+	swclient := sitewiseMocks.NewAPI(t)
+	// No time series is requested to Arduino IoT Cloud
+	arclient := iotapiMocks.NewAPI(t)
+
+	thingsMap := map[string]iotclient.ArduinoThing{
+		thingId: {
+			Id: thingId,
+			Properties: []iotclient.ArduinoProperty{
+				{Id: temperatureId, Name: "temperature", Type: "FLOAT", UpdateStrategy: "TIMED", LastValue: 21.5, ValueUpdatedAt: &updatedAt},
+				{Id: levelId, Name: "level", Type: "FLOAT", UpdateStrategy: "ON_CHANGE", LastValue: 3.0, ValueUpdatedAt: &levelUpdatedAt},
+			},
+		},
+	}
+
+	swclient.On("ListAllAssetModels", ctx).Return([]types.AssetModelSummary{{Id: &modelId}}, nil).Once()
+	swclient.On("ListAllAssetsForModel", ctx, &modelId).Return([]types.AssetSummary{{Id: &assetId, Name: toPtr("test"), ExternalId: &thingId}}, nil).Once()
+	swclient.On("DescribeAsset", ctx, assetId).Return(&iotsitewise.DescribeAssetOutput{
+		AssetId:         &assetId,
+		AssetProperties: []types.AssetProperty{{Name: toPtr("temperature")}, {Name: toPtr("level")}},
+	}, nil).Once()
+	swclient.On("PopulateArbitrarySamplesByAlias", ctx, mock.MatchedBy(func(points []sitewiseclient.DataPoint) bool {
+		if len(points) != 2 {
+			return false
+		}
+		for _, p := range points {
+			switch p.PropertyAlias {
+			case "/" + thingId + "/temperature":
+				if p.Value != 21.5 || p.Ts != updatedAt.Unix() {
+					return false
+				}
+			case "/" + thingId + "/level":
+				// Written at the current time, not to be dropped
+				if p.Value != 3.0 || p.Ts < updatedAt.Unix() {
+					return false
+				}
+			default:
+				return false
+			}
+		}
+		return true
+	})).Return(nil).Once()
+
+	tsAligner := New(swclient, arclient, logger, WithLastValueOnly(true))
+	summary, errs := tsAligner.AlignTimeSeriesSamplesIntoSiteWise(ctx, 60, thingsMap, 300)
+	assert.Nil(t, errs)
+	assert.Equal(t, ImportSummary{ThingsProcessed: 1, PointsWritten: 2}, summary)
+}
+
+func TestTSExtraction_bulkImport(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	thingId := "bb831f04-0940-4ea6-9c24-83668e372919"
+	modelId := "03ba45c2-eab3-44ed-a68f-94a26d41df4c"
+	assetId := "e9e11559-ceca-4c2f-875d-76c1068a45f4"
+	temperatureId := "c86f4ed9-7f52-4bd3-bdc6-b2936bec68ac"
+	statusId := "d86f4ed9-7f52-4bd3-bdc6-b2936bec68ad"
+	jobId := "7c2b0b6e-33f0-4bd4-9a4b-1d0c0b4a1f0e"
+	updatedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	swclient := sitewiseMocks.NewAPI(t)
+	arclient := iotapiMocks.NewAPI(t)
+	store := objectstoreMocks.NewAPI(t)
+
+	thingsMap := map[string]iotclient.ArduinoThing{
+		thingId: {
+			Id: thingId,
+			Properties: []iotclient.ArduinoProperty{
+				{Id: temperatureId, Name: "temperature", Type: "FLOAT", UpdateStrategy: "TIMED", LastValue: 21.5, ValueUpdatedAt: &updatedAt},
+				{Id: statusId, Name: "status", Type: "CHARSTRING", UpdateStrategy: "ON_CHANGE", LastValue: "running", ValueUpdatedAt: &updatedAt},
+			},
+		},
+	}
+
+	swclient.On("ListAllAssetModels", ctx).Return([]types.AssetModelSummary{{Id: &modelId}}, nil).Once()
+	swclient.On("ListAllAssetsForModel", ctx, &modelId).Return([]types.AssetSummary{{Id: &assetId, Name: toPtr("test"), ExternalId: &thingId}}, nil).Once()
+	swclient.On("DescribeAsset", ctx, assetId).Return(&iotsitewise.DescribeAssetOutput{
+		AssetId:         &assetId,
+		AssetProperties: []types.AssetProperty{{Name: toPtr("temperature")}, {Name: toPtr("status")}},
+	}, nil).Once()
+
+	// Data points are staged on S3 instead of being streamed
+	var key string
+	store.On("Put", ctx, "backfill", mock.MatchedBy(func(k string) bool {
+		key = k
+		return strings.HasPrefix(k, "sitewise/") && strings.HasSuffix(k, "/part-0000.csv")
+	}), mock.MatchedBy(func(body []byte) bool {
+		rows := strings.Split(strings.TrimSpace(string(body)), "\n")
+		slices.Sort(rows)
+		ts := strconv.FormatInt(updatedAt.Unix(), 10)
+		return slices.Equal(rows, []string{
+			"/" + thingId + "/status,STRING," + ts + ",0,GOOD,running",
+			"/" + thingId + "/temperature,DOUBLE," + ts + ",0,GOOD,21.5",
+		})
+	})).Return(nil).Once()
+	swclient.On("CreateDataBulkImportJob", ctx, mock.Anything, "backfill", mock.MatchedBy(func(files []string) bool {
+		return slices.Equal(files, []string{key})
+	}), "arn:aws:iam::123456789012:role/bulk-import").Return(&iotsitewise.CreateBulkImportJobOutput{JobId: &jobId}, nil).Once()
+	swclient.On("WaitForBulkImportJob", ctx, jobId, sitewiseclient.PollOptions{Interval: time.Millisecond, MaxWait: defaultBulkMaxWait}).Return(types.JobStatusCompleted, nil).Once()
+
+	tsAligner := New(swclient, arclient, logger, WithLastValueOnly(true), WithBulkImport(BulkImportConfig{
+		Store:        store,
+		Bucket:       "backfill",
+		Prefix:       "sitewise",
+		RoleArn:      "arn:aws:iam::123456789012:role/bulk-import",
+		PollInterval: time.Millisecond,
+	}))
+	summary, errs := tsAligner.AlignTimeSeriesSamplesIntoSiteWise(ctx, 60, thingsMap, 300)
+	assert.Nil(t, errs)
+	assert.Equal(t, ImportSummary{ThingsProcessed: 1, PointsWritten: 2}, summary)
+}
+
+func TestBulkImporter_jobFailures(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+	jobId := "7c2b0b6e-33f0-4bd4-9a4b-1d0c0b4a1f0e"
+
+	swclient := sitewiseMocks.NewAPI(t)
+	store := objectstoreMocks.NewAPI(t)
+	store.On("Put", ctx, "backfill", mock.Anything, mock.Anything).Return(nil).Times(2)
+	swclient.On("CreateDataBulkImportJob", ctx, mock.Anything, "backfill", mock.MatchedBy(func(files []string) bool {
+		return len(files) == 2
+	}), "role").Return(&iotsitewise.CreateBulkImportJobOutput{JobId: &jobId}, nil).Once()
+	swclient.On("WaitForBulkImportJob", ctx, jobId, mock.Anything).Return(types.JobStatusCompletedWithFailures, nil).Once()
+	store.On("List", ctx, "backfill", sitewiseclient.BulkImportErrorReportPrefix).Return([]string{
+		"error-reports/" + jobId + "/",
+		"error-reports/" + jobId + "/errors.csv",
+		"error-reports/another-job/errors.csv",
+	}, nil).Once()
+	store.On("Get", ctx, "backfill", "error-reports/"+jobId+"/errors.csv").Return([]byte(
+		"ALIAS,DATA_TYPE,TIMESTAMP_SECONDS,TIMESTAMP_NANO_OFFSET,QUALITY,VALUE,ERROR_CODE,ERROR_MESSAGE\n"+
+			"/thing/temperature,DOUBLE,1714917282,0,GOOD,20.5,ValidationException,\"Property alias not found\"\n"), nil).Once()
+
+	tsAligner := New(swclient, iotapiMocks.NewAPI(t), logger, WithBulkImport(BulkImportConfig{
+		Store: store, Bucket: "backfill", RoleArn: "role", RowsPerFile: 2,
+	}))
+	// Three data points are split in two files
+	err := tsAligner.sitewisecl.PopulateTimeSeriesByAlias(ctx, "/thing/temperature", []int64{1714916982, 1714917282, 1714917582}, []float64{20, 20.5, 21})
+	assert.NoError(t, err)
+
+	rejected, err := tsAligner.bulk.run(ctx, logger)
+	assert.Equal(t, int64(1), rejected)
+	assert.ErrorContains(t, err, "1 of 3 data points rejected")
+	assert.ErrorContains(t, err, "s3://backfill/error-reports")
+}
+
+func TestParseBulkImportErrors(t *testing.T) {
+	rows, err := parseBulkImportErrors([]byte(
+		"/thing/status,STRING,1714916982,0,GOOD,\"stopped, manual\",InvalidRequestException,Value too long\n\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, []BulkImportRowError{{
+		Row:          []string{"/thing/status", "STRING", "1714916982", "0", "GOOD", "stopped, manual"},
+		ErrorCode:    "InvalidRequestException",
+		ErrorMessage: "Value too long",
+	}}, rows)
+
+	_, err = parseBulkImportErrors([]byte("/thing/status,\"unterminated\n"))
+	assert.Error(t, err)
+}
+
+func TestBulkRecorder_row(t *testing.T) {
+	r := &bulkRecorder{}
+	r.setPropertyTypes(map[string]string{"p1": "/t/level", "p2": "/t/note"}, map[string]iotclient.ArduinoProperty{
+		"p1": {Id: "p1", Type: "INT"},
+		"p2": {Id: "p2", Type: "CHARSTRING"},
+	})
+	tests := []struct {
+		alias string
+		value any
+		want  []string
+	}{
+		{alias: "/t/p", value: 21.5, want: []string{"/t/p", "DOUBLE", "1714916982", "0", "GOOD", "21.5"}},
+		{alias: "/t/p", value: true, want: []string{"/t/p", "DOUBLE", "1714916982", "0", "GOOD", "1"}},
+		{alias: "/t/p", value: 42, want: []string{"/t/p", "DOUBLE", "1714916982", "0", "GOOD", "42"}},
+		{alias: "/t/p", value: "on, off", want: []string{"/t/p", "STRING", "1714916982", "0", "GOOD", "on, off"}},
+		{alias: "/t/p", value: map[string]any{"lat": 45.1}, want: []string{"/t/p", "STRING", "1714916982", "0", "GOOD", `{"lat":45.1}`}},
+		// Data types of imported properties follow the property type
+		{alias: "/t/level", value: "12", want: []string{"/t/level", "DOUBLE", "1714916982", "0", "GOOD", "12"}},
+		{alias: "/t/note", value: 12.5, want: []string{"/t/note", "STRING", "1714916982", "0", "GOOD", "12.5"}},
+	}
+	for _, tt := range tests {
+		row, ok := r.row(tt.alias, 1714916982, tt.value, types.QualityGood)
+		assert.True(t, ok)
+		assert.Equal(t, tt.want, row)
+	}
+
+	for _, value := range []any{[]int{1}, math.NaN(), "high"} {
+		_, ok := r.row("/t/level", 1714916982, value, types.QualityGood)
+		assert.False(t, ok, value)
+	}
+}
+
+func TestBulkRecorder_golden(t *testing.T) {
+	ctx := context.Background()
+	ts := time.Date(2024, 5, 5, 13, 49, 42, 0, time.UTC).Unix()
+	r := &bulkRecorder{}
+	r.setPropertyTypes(map[string]string{
+		"temperature": "/Compressor Car 1/temperature",
+		"switch":      "/Compressor Car 1/switch",
+		"status":      "/Compressor Car 1/status",
+		"position":    "/Compressor Car 1/position",
+		"level":       "/Compressor Car 1/level",
+	}, map[string]iotclient.ArduinoProperty{
+		"temperature": {Type: "TEMPERATURE_C"},
+		"switch":      {Type: "HOME_SWITCH"},
+		"status":      {Type: "CHARSTRING"},
+		"position":    {Type: "LOCATION"},
+		"level":       {Type: "INT"},
+	})
+
+	assert.NoError(t, r.PopulateTimeSeriesByAlias(ctx, "/Compressor Car 1/temperature",
+		[]int64{ts, ts + 300, ts + 600}, []float64{21.5, math.NaN(), 1e-7}))
+	assert.NoError(t, r.PopulateSampledSamplesTimeSeriesByAlias(ctx, "/Compressor Car 1/switch",
+		[]int64{ts, ts + 60}, []any{true, false}))
+	assert.NoError(t, r.PopulateSampledSamplesTimeSeriesByAlias(ctx, "/Compressor Car 1/status",
+		[]int64{ts, ts + 1, ts + 2}, []any{"running", `stopped, "manual"`, "line1\nline2"}))
+	assert.NoError(t, r.PopulateArbitrarySamplesByAlias(ctx, []sitewiseclient.DataPoint{
+		{PropertyAlias: "/Compressor Car 1/position", Ts: ts, Value: map[string]any{"lat": 45.07, "lon": 7.68}},
+		{PropertyAlias: "/Compressor Car 1/level", Ts: ts, Value: "12"},
+		{PropertyAlias: "/Compressor Car 1/level", Ts: ts + 1, Value: map[string]any{"value": 1}},
+	}))
+
+	got, err := encodeBulkRows(r.take())
+	assert.NoError(t, err)
+	want, err := os.ReadFile(filepath.Join("testdata", "bulk_rows.golden.csv"))
+	assert.NoError(t, err)
+	assert.Equal(t, string(want), string(got))
+}
+
+func TestTSExtraction_emptySeriesAdvanceCheckpoints(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	thingId := "bb831f04-0940-4ea6-9c24-83668e372919"
+	modelId := "03ba45c2-eab3-44ed-a68f-94a26d41df4c"
+	assetId := "e9e11559-ceca-4c2f-875d-76c1068a45f4"
+	propertyId := "c86f4ed9-7f52-4bd3-bdc6-b2936bec68ac"
+
+	swclient := sitewiseMocks.NewAPI(t)
+	arclient := iotapiMocks.NewAPI(t)
+
+	thingsMap := map[string]iotclient.ArduinoThing{
+		thingId: {
+			Id:         thingId,
+			Properties: []iotclient.ArduinoProperty{{Id: propertyId, Name: "temperature", Type: "FLOAT"}},
+		},
+	}
+
+	swclient.On("ListAllAssetModels", ctx).Return([]types.AssetModelSummary{{Id: &modelId}}, nil).Twice()
+	swclient.On("ListAllAssetsForModel", ctx, &modelId).Return([]types.AssetSummary{{Id: &assetId, Name: toPtr("test"), ExternalId: &thingId}}, nil).Twice()
+	swclient.On("DescribeAsset", ctx, assetId).Return(&iotsitewise.DescribeAssetOutput{
+		AssetId:         &assetId,
+		AssetProperties: []types.AssetProperty{{Name: toPtr("temperature")}},
+	}, nil).Twice()
+
+	// First run fails fetching the series, second one gets no data
+	arclient.On("GetTimeSeriesByThing", ctx, thingId, mock.Anything, mock.Anything, int64(300), "").Return(nil, errors.New("fetch failed")).Once()
+	arclient.On("GetTimeSeriesByThing", ctx, thingId, mock.Anything, mock.Anything, int64(300), "").Return(&iotclient.ArduinoSeriesBatch{
+		Responses: []iotclient.ArduinoSeriesResponse{{Query: "property." + propertyId, CountValues: 0}},
+	}, nil).Once()
+
+	checkpoints := NewCheckpoints()
+	tsAligner := New(swclient, arclient, logger, WithCheckpoints(checkpoints, true))
+
+	_, errs := tsAligner.AlignTimeSeriesSamplesIntoSiteWise(ctx, 60, thingsMap, 300)
+	assert.Len(t, errs, 1)
+	_, ok := checkpoints.Get(thingId)
+	assert.False(t, ok, "failed fetch must not advance the checkpoint")
+
+	_, errs = tsAligner.AlignTimeSeriesSamplesIntoSiteWise(ctx, 60, thingsMap, 300)
+	assert.Nil(t, errs)
+	_, expectedTo := computeTimeAlignment(time.Now(), 300, 60, 0)
+	checkpoint, ok := checkpoints.Get(thingId)
+	if assert.True(t, ok, "empty series must advance the checkpoint") {
+		assert.Equal(t, expectedTo, checkpoint)
+	}
+	swclient.AssertNotCalled(t, "PopulateTimeSeriesByAlias", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestTSExtraction_bulkImportFailureKeepsCheckpoints(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	thingId := "bb831f04-0940-4ea6-9c24-83668e372919"
+	modelId := "03ba45c2-eab3-44ed-a68f-94a26d41df4c"
+	assetId := "e9e11559-ceca-4c2f-875d-76c1068a45f4"
+	propertyId := "c86f4ed9-7f52-4bd3-bdc6-b2936bec68ac"
+	jobId := "7c2b0b6e-33f0-4bd4-9a4b-1d0c0b4a1f0e"
+
+	swclient := sitewiseMocks.NewAPI(t)
+	arclient := iotapiMocks.NewAPI(t)
+	store := objectstoreMocks.NewAPI(t)
+
+	thingsMap := map[string]iotclient.ArduinoThing{
+		thingId: {
+			Id:         thingId,
+			Properties: []iotclient.ArduinoProperty{{Id: propertyId, Name: "temperature", Type: "FLOAT"}},
+		},
+	}
+
+	_, to := computeTimeAlignment(time.Now(), 300, 60, 0)
+	swclient.On("ListAllAssetModels", ctx).Return([]types.AssetModelSummary{{Id: &modelId}}, nil).Twice()
+	swclient.On("ListAllAssetsForModel", ctx, &modelId).Return([]types.AssetSummary{{Id: &assetId, Name: toPtr("test"), ExternalId: &thingId}}, nil).Twice()
+	swclient.On("DescribeAsset", ctx, assetId).Return(&iotsitewise.DescribeAssetOutput{
+		AssetId:         &assetId,
+		AssetProperties: []types.AssetProperty{{Name: toPtr("temperature")}},
+	}, nil).Twice()
+	arclient.On("GetTimeSeriesByThing", ctx, thingId, mock.Anything, mock.Anything, int64(300), "").Return(&iotclient.ArduinoSeriesBatch{
+		Responses: []iotclient.ArduinoSeriesResponse{
+			{Query: "property." + propertyId, Times: []time.Time{to.Add(-10 * time.Minute)}, Values: []float64{21.5}, CountValues: 1},
+		},
+	}, nil).Twice()
+	store.On("Put", ctx, "backfill", mock.Anything, mock.Anything).Return(nil).Twice()
+	swclient.On("CreateDataBulkImportJob", ctx, mock.Anything, "backfill", mock.Anything, "role").Return(&iotsitewise.CreateBulkImportJobOutput{JobId: &jobId}, nil).Twice()
+
+	// First job fails, second one completes
+	swclient.On("WaitForBulkImportJob", ctx, jobId, mock.Anything).Return(types.JobStatusFailed, nil).Once()
+	swclient.On("WaitForBulkImportJob", ctx, jobId, mock.Anything).Return(types.JobStatusCompleted, nil).Once()
+
+	checkpoints := NewCheckpoints()
+	checkpointStore := statestore.NewMemory()
+	tsAligner := New(swclient, arclient, logger, WithCheckpoints(checkpoints, false), WithCheckpointStore(checkpointStore, 0),
+		WithBulkImport(BulkImportConfig{Store: store, Bucket: "backfill", RoleArn: "role"}))
+
+	_, errs := tsAligner.AlignTimeSeriesSamplesIntoSiteWise(ctx, 60, thingsMap, 300)
+	assert.Len(t, errs, 1)
+	_, ok := checkpoints.Get(thingId)
+	assert.False(t, ok, "failed bulk import job must not advance the checkpoint")
+	_, ok, _ = checkpointStore.GetState(ctx, "checkpoint/"+thingId)
+	assert.False(t, ok, "failed bulk import job must not store the checkpoint")
+
+	_, errs = tsAligner.AlignTimeSeriesSamplesIntoSiteWise(ctx, 60, thingsMap, 300)
+	assert.Nil(t, errs)
+	checkpoint, ok := checkpoints.Get(thingId)
+	if assert.True(t, ok) {
+		assert.Equal(t, to, checkpoint)
+	}
+	value, _, _ := checkpointStore.GetState(ctx, "checkpoint/"+thingId)
+	assert.Equal(t, to.UTC().Format(time.RFC3339Nano), value)
+}
+
+func TestCheckpoints_update(t *testing.T) {
+	thingId := "bb831f04-0940-4ea6-9c24-83668e372919"
+	from := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+
+	c := NewCheckpoints()
+	c.update(thingId, to, nil, nil, false)
+	_, ok := c.Get(thingId)
+	assert.False(t, ok, "empty series must not advance the checkpoint unless enabled")
+
+	c.update(thingId, to, []string{"p1"}, errors.New("fetch failed"), true)
+	_, ok = c.Get(thingId)
+	assert.False(t, ok, "errors must never advance the checkpoint")
+
+	c.update(thingId, to, []string{"p1"}, nil, false)
+	checkpoint, ok := c.Get(thingId)
+	assert.True(t, ok)
+	assert.Equal(t, to, checkpoint)
+
+	// Checkpoints never move back
+	c.update(thingId, from, nil, nil, true)
+	checkpoint, _ = c.Get(thingId)
+	assert.Equal(t, to, checkpoint)
+
+	// Next window starts from the checkpoint, if within the run window
+	f, tt := c.window(thingId, from, to.Add(time.Hour), 0)
+	assert.Equal(t, to, f)
+	assert.Equal(t, to.Add(time.Hour), tt)
+	f, _ = c.window(thingId, to.Add(time.Minute), to.Add(time.Hour), 0)
+	assert.Equal(t, to.Add(time.Minute), f)
+}
+
+// failingStateStore fails all reads and writes
+type failingStateStore struct {
+	statestore.Store
+}
+
+func (failingStateStore) GetState(ctx context.Context, key string) (string, bool, error) {
+	return "", false, errors.New("throttled")
+}
+
+func (failingStateStore) PutState(ctx context.Context, key, value string) error {
+	return errors.New("throttled")
+}
+
+func TestCheckpoints_windowCatchUp(t *testing.T) {
+	thingId := "bb831f04-0940-4ea6-9c24-83668e372919"
+	to := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	from := to.Add(-time.Hour)
+	c := NewCheckpoints()
+
+	// Without checkpoint, the run window
+	f, _ := c.window(thingId, from, to, 24*time.Hour)
+	assert.Equal(t, from, f)
+
+	// Missed runs are caught up from the checkpoint
+	c.advance(thingId, to.Add(-5*time.Hour))
+	f, tt := c.window(thingId, from, to, 24*time.Hour)
+	assert.Equal(t, to.Add(-5*time.Hour), f)
+	assert.Equal(t, to, tt)
+	f, _ = c.window(thingId, from, to, 0)
+	assert.Equal(t, from, f, "no catch up without a catch up window")
+
+	// Down to the catch up window
+	f, _ = c.window(thingId, from, to, 3*time.Hour)
+	assert.Equal(t, to.Add(-3*time.Hour), f)
+	// Never shorter than the run window
+	f, _ = c.window(thingId, from, to, 10*time.Minute)
+	assert.Equal(t, from, f)
+
+	// Recent checkpoints shorten the window as without catch up
+	c.advance(thingId, to.Add(-10*time.Minute))
+	f, _ = c.window(thingId, from, to, 24*time.Hour)
+	assert.Equal(t, to.Add(-10*time.Minute), f)
+}
+
+func TestCheckpointStore(t *testing.T) {
+	ctx := context.Background()
+	thingId := "bb831f04-0940-4ea6-9c24-83668e372919"
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	store := statestore.NewMemory()
+	assert.NoError(t, store.PutState(ctx, "checkpoint/"+thingId, "2024-05-01T12:00:00Z"))
+	logger := logrus.NewEntry(logrus.New())
+	a := New(nil, nil, logger, WithCheckpoints(NewCheckpoints(), false), WithCheckpointStore(store, 90*time.Minute))
+
+	a.loadCheckpoint(ctx, thingId)
+	checkpoint, ok := a.checkpoints.Get(thingId)
+	assert.True(t, ok)
+	assert.Equal(t, at, checkpoint)
+
+	a.storeCheckpoint(ctx, thingId, at.Add(time.Hour))
+	value, _, _ := store.GetState(ctx, "checkpoint/"+thingId)
+	assert.Equal(t, "2024-05-01T13:00:00Z", value)
+
+	// Invalid stored checkpoints are ignored
+	other := "cc831f04-0940-4ea6-9c24-83668e372920"
+	assert.NoError(t, store.PutState(ctx, "checkpoint/"+other, "yesterday"))
+	a.loadCheckpoint(ctx, other)
+	_, ok = a.checkpoints.Get(other)
+	assert.False(t, ok)
+
+	// Store errors leave in memory checkpoints untouched
+	a = New(nil, nil, logger, WithCheckpoints(a.checkpoints, false), WithCheckpointStore(failingStateStore{}, 90*time.Minute))
+	a.loadCheckpoint(ctx, thingId)
+	a.storeCheckpoint(ctx, thingId, at.Add(2*time.Hour))
+	checkpoint, _ = a.checkpoints.Get(thingId)
+	assert.Equal(t, at, checkpoint)
+
+	// Catch up windows are rounded down to whole buckets
+	assert.Equal(t, 90*time.Minute, a.catchUpWindow(300))
+	assert.Equal(t, time.Hour, a.catchUpWindow(3600))
+	assert.Zero(t, New(nil, nil, logger).catchUpWindow(300))
+}
+
+func TestTSExtraction_fetchOnlySkipsSiteWiseWrites(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	thingId := "bb831f04-0940-4ea6-9c24-83668e372919"
+	modelId := "03ba45c2-eab3-44ed-a68f-94a26d41df4c"
+	assetId := "e9e11559-ceca-4c2f-875d-76c1068a45f4"
+	propertyId := "c86f4ed9-7f52-4bd3-bdc6-b2936bec68ac"
+	propertyIdString := "a86f4ed9-7f52-4bd3-bdc6-b2936bec67de"
+
+	swclient := sitewiseMocks.NewAPI(t)
+	arclient := iotapiMocks.NewAPI(t)
+
+	thingsMap := map[string]iotclient.ArduinoThing{
+		thingId: {
+			Id: thingId,
+			Properties: []iotclient.ArduinoProperty{
+				{Id: propertyId, Name: "temperature", Type: "FLOAT", UpdateStrategy: "ON_CHANGE", LastValue: 21.5},
+				{Id: propertyIdString, Name: "msg", Type: "CHARSTRING"},
+			},
+		},
+	}
+
+	// Only reads are expected on SiteWise: any write would fail the test as an unexpected call
+	swclient.On("ListAllAssetModels", ctx).Return([]types.AssetModelSummary{{Id: &modelId}}, nil).Once()
+	swclient.On("ListAllAssetsForModel", ctx, &modelId).Return([]types.AssetSummary{{Id: &assetId, Name: toPtr("test"), ExternalId: &thingId}}, nil).Once()
+	swclient.On("DescribeAsset", ctx, assetId).Return(&iotsitewise.DescribeAssetOutput{
+		AssetId:         &assetId,
+		AssetProperties: []types.AssetProperty{{Name: toPtr("temperature")}, {Name: toPtr("msg")}},
+	}, nil).Once()
+
+	now := time.Now()
+	arclient.On("GetTimeSeriesByThing", ctx, thingId, mock.Anything, mock.Anything, int64(300), "").Return(&iotclient.ArduinoSeriesBatch{
+		Responses: []iotclient.ArduinoSeriesResponse{
+			{
+				Query:       fmt.Sprintf("property.%s", propertyId),
+				Times:       []time.Time{now.Add(-time.Minute), now},
+				Values:      []float64{1.0, 2.0},
+				CountValues: 2,
+			},
+		},
+	}, nil).Once()
+	arclient.On("GetTimeSeriesSampling", ctx, []string{propertyIdString}, mock.Anything, mock.Anything, int32(300)).Return(&iotclient.ArduinoSeriesBatchSampled{
+		Responses: []iotclient.ArduinoSeriesSampledResponse{
+			{
+				Query:       fmt.Sprintf("property.%s", propertyIdString),
+				Times:       []time.Time{now},
+				Values:      []any{"msg1"},
+				CountValues: 1,
+			},
+		},
+	}, nil).Once()
+
+	tsAligner := New(swclient, arclient, logger, WithFetchOnly(true), WithBatchedLastValues(true))
+	_, errs := tsAligner.AlignTimeSeriesSamplesIntoSiteWise(ctx, 60, thingsMap, 300)
+	assert.Nil(t, errs)
+
+	assert.Equal(t, int64(2), tsAligner.fetchStats.requests.Load())
+	assert.Equal(t, int64(3), tsAligner.fetchStats.points.Load())
+	swclient.AssertNotCalled(t, "PopulateTimeSeriesByAlias", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	swclient.AssertNotCalled(t, "PopulateSampledSamplesTimeSeriesByAlias", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	swclient.AssertNotCalled(t, "PopulateArbitrarySamplesByAlias", mock.Anything, mock.Anything)
+}
+
+func TestLastValueCollector_flush(t *testing.T) {
+	ctx := context.Background()
+	swclient := sitewiseMocks.NewAPI(t)
+
+	collector := &lastValueCollector{}
+
+	// Nothing collected, nothing written
+	assert.Nil(t, collector.flush(ctx, swclient))
+
+	collector.add([]sitewiseclient.DataPoint{{PropertyAlias: "/t1/a"}})
+	collector.add([]sitewiseclient.DataPoint{{PropertyAlias: "/t2/a"}, {PropertyAlias: "/t2/b"}})
+	swclient.On("PopulateArbitrarySamplesByAlias", ctx, mock.MatchedBy(func(points []sitewiseclient.DataPoint) bool {
+		return len(points) == 3
+	})).Return(errors.New("throttled")).Once()
+	assert.EqualError(t, collector.flush(ctx, swclient), "throttled")
+
+	// Points are flushed once
+	assert.Nil(t, collector.flush(ctx, swclient))
+}
+
+func TestNew_importConcurrency(t *testing.T) {
+	logger := logrus.NewEntry(logrus.New())
+
+	assert.Equal(t, defaultImportConcurrency, New(nil, nil, logger).importConcurrency)
+	assert.Equal(t, 25, New(nil, nil, logger, WithImportConcurrency(25)).importConcurrency)
+	assert.Equal(t, defaultImportConcurrency, New(nil, nil, logger, WithImportConcurrency(0)).importConcurrency)
+}
+
+func TestImportTokens_adaptiveConcurrency(t *testing.T) {
+	throttled := fmt.Errorf("writing samples: %w", &types.ThrottlingException{Message: toPtr("rate exceeded")})
+
+	tokens := newImportTokens(8, true)
+	assert.Equal(t, 8, tokens.current())
+
+	// Throttling halves the concurrency, down to one
+	for _, expected := range []int{4, 2, 1, 1} {
+		tokens.acquire()
+		tokens.release(throttled)
+		assert.Equal(t, expected, tokens.current())
+	}
+
+	// With a single slot, a second import waits for the first one to complete
+	tokens.acquire()
+	acquired := make(chan struct{})
+	go func() {
+		tokens.acquire()
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("acquired above the concurrency limit")
+	case <-time.After(50 * time.Millisecond):
+	}
+	tokens.release(nil)
+	<-acquired
+	tokens.release(nil)
+
+	// Successes recover the concurrency, up to the configured one
+	for i := 0; i < 100; i++ {
+		tokens.acquire()
+		tokens.release(nil)
+	}
+	assert.Equal(t, 8, tokens.current())
+
+	// Other errors don't reduce it
+	tokens.acquire()
+	tokens.release(errors.New("not found"))
+	assert.Equal(t, 8, tokens.current())
+}
+
+func TestImportTokens_fixedConcurrency(t *testing.T) {
+	tokens := newImportTokens(3, false)
+	tokens.acquire()
+	tokens.release(&types.ThrottlingException{})
+	assert.Equal(t, 3, tokens.current())
+}
+
+func TestRateLimitingDelay_exponentialBackoff(t *testing.T) {
+	base := 100 * time.Millisecond
+	for attempt, expected := range []time.Duration{100, 200, 400, 800, 1600, 3200, 3200} {
+		delay := rateLimitingDelay(base, attempt)
+		expected *= time.Millisecond
+		assert.GreaterOrEqual(t, delay, expected, "attempt %d", attempt)
+		assert.LessOrEqual(t, delay, expected+base/2, "attempt %d", attempt)
+	}
+}
+
+func TestTSExtraction_rateLimitRetries(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	thingId := "bb831f04-0940-4ea6-9c24-83668e372919"
+	propertyId := "c86f4ed9-7f52-4bd3-bdc6-b2936bec68ac"
+	mapped := &mappedProperties{
+		PropertiesToImport:        []string{propertyId},
+		PropertiesToImportAliases: map[string]string{propertyId: "/" + thingId + "/temperature"},
+	}
+	rateLimited := fmt.Errorf("retrieving time series: %w", iot.ErrRateLimited)
+
+	// Rate limited twice, then served
+	arclient := iotapiMocks.NewAPI(t)
+	arclient.On("GetTimeSeriesByThing", ctx, thingId, mock.Anything, mock.Anything, int64(300), "").Return(nil, rateLimited).Twice()
+	arclient.On("GetTimeSeriesByThing", ctx, thingId, mock.Anything, mock.Anything, int64(300), "").Return(&iotclient.ArduinoSeriesBatch{}, nil).Once()
+
+	tsAligner := New(nil, arclient, logger, WithRateLimitRetries(3), WithRateLimitBackoff(0))
+	from, to := computeTimeAlignment(time.Now(), 300, 60, 0)
+	_, err := tsAligner.populateTSDataIntoSiteWise(ctx, thingId, mapped, 300, from, to)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), tsAligner.counters.summary().ThrottleRetries)
+
+	// Attempts exhausted
+	arclient = iotapiMocks.NewAPI(t)
+	arclient.On("GetTimeSeriesByThing", ctx, thingId, mock.Anything, mock.Anything, int64(300), "").Return(nil, rateLimited).Times(2)
+
+	tsAligner = New(nil, arclient, logger, WithRateLimitRetries(2), WithRateLimitBackoff(0))
+	_, err = tsAligner.populateTSDataIntoSiteWise(ctx, thingId, mapped, 300, from, to)
+	assert.ErrorIs(t, err, iot.ErrRateLimited)
+
+	// Other errors are not retried
+	arclient = iotapiMocks.NewAPI(t)
+	arclient.On("GetTimeSeriesByThing", ctx, thingId, mock.Anything, mock.Anything, int64(300), "").Return(nil, errors.New("fetch failed")).Once()
+
+	tsAligner = New(nil, arclient, logger, WithRateLimitRetries(3), WithRateLimitBackoff(0))
+	_, err = tsAligner.populateTSDataIntoSiteWise(ctx, thingId, mapped, 300, from, to)
+	assert.EqualError(t, err, "fetch failed")
+}
+
+func TestMapPropertiesToImport_duplicatePropertyNames(t *testing.T) {
+	logger := logrus.NewEntry(logrus.New())
+	thingId := "bb831f04-0940-4ea6-9c24-83668e372919"
+
+	thing := iotclient.ArduinoThing{
+		Id: thingId,
+		Properties: []iotclient.ArduinoProperty{
+			{Id: "p2", Name: "temperature", Type: "INT"},
+			{Id: "p1", Name: "temperature", Type: "FLOAT"},
+		},
+	}
+	asset := &iotsitewise.DescribeAssetOutput{
+		AssetProperties: []types.AssetProperty{{Name: toPtr("temperature")}, {Name: toPtr("temperature_p2")}},
+	}
+
+	mapped := New(nil, nil, logger).mapPropertiesToImport(asset, thing, "test", nil)
+	assert.ElementsMatch(t, []string{"p1", "p2"}, mapped.PropertiesToImport)
+	assert.Equal(t, map[string]string{
+		"p1": "/" + thingId + "/temperature",
+		"p2": "/" + thingId + "/temperature_p2",
+	}, mapped.PropertiesToImportAliases)
+}
+
+func TestMapPropertiesToImport_unmappedProperties(t *testing.T) {
+	logger := logrus.NewEntry(logrus.New())
+	thingId := "bb831f04-0940-4ea6-9c24-83668e372919"
+
+	thing := iotclient.ArduinoThing{
+		Id: thingId,
+		Properties: []iotclient.ArduinoProperty{
+			{Id: "p1", Name: "temperature", Type: "FLOAT"},
+			{Id: "p2", Name: "humidity", Type: "FLOAT"},
+		},
+	}
+	// Property added to the thing after the last alignment
+	asset := &iotsitewise.DescribeAssetOutput{
+		AssetProperties: []types.AssetProperty{{Name: toPtr("temperature")}},
+	}
+
+	mapped := New(nil, nil, logger).mapPropertiesToImport(asset, thing, "test", nil)
+	assert.Equal(t, []string{"p1"}, mapped.PropertiesToImport)
+	assert.Equal(t, []string{"humidity"}, mapped.UnmappedProperties)
+
+	asset.AssetProperties = append(asset.AssetProperties, types.AssetProperty{Name: toPtr("humidity")})
+	assert.Empty(t, New(nil, nil, logger).mapPropertiesToImport(asset, thing, "test", nil).UnmappedProperties)
+}
+
+func TestPopulateAttributes(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+	thingId, assetId, modelId := "thing1", "asset1", "model1"
+
+	thing := iotclient.ArduinoThing{
+		Id: thingId,
+		Properties: []iotclient.ArduinoProperty{
+			{Id: "p1", Name: "temperature", Type: "FLOAT", UpdateStrategy: "ON_CHANGE", Persist: aws.Bool(true), LastValue: 20.0},
+			{Id: "p2", Name: "threshold", Type: "FLOAT", UpdateStrategy: "ON_CHANGE", Persist: aws.Bool(false), LastValue: 30.0},
+			{Id: "p3", Name: "mode", Type: "CHARSTRING", UpdateStrategy: "ON_CHANGE", Persist: aws.Bool(false), LastValue: "eco"},
+			{Id: "p4", Name: "label", Type: "CHARSTRING", UpdateStrategy: "ON_CHANGE", Persist: aws.Bool(false), LastValue: "kitchen"},
+			{Id: "p5", Name: "room", Type: "CHARSTRING", UpdateStrategy: "ON_CHANGE", Persist: aws.Bool(false), LastValue: "bedroom"},
+		},
+	}
+	asset := &iotsitewise.DescribeAssetOutput{
+		AssetId:      &assetId,
+		AssetModelId: &modelId,
+		AssetProperties: []types.AssetProperty{
+			{Id: toPtr("sw1"), Name: toPtr("temperature")},
+			{Id: toPtr("sw2"), Name: toPtr("threshold")},
+			{Id: toPtr("sw3"), Name: toPtr("mode")},
+			{Id: toPtr("sw4"), Name: toPtr("label")},
+			{Id: toPtr("sw5"), Name: toPtr("room")},
+		},
+	}
+	attribute := &types.PropertyType{Attribute: &types.Attribute{}}
+	measurement := &types.PropertyType{Measurement: &types.Measurement{}}
+	model := &iotsitewise.DescribeAssetModelOutput{
+		AssetModelId: &modelId,
+		AssetModelProperties: []types.AssetModelProperty{
+			{Name: toPtr("temperature"), Type: measurement},
+			{Name: toPtr("threshold"), Type: attribute},
+			{Name: toPtr("mode"), Type: attribute},
+			{Name: toPtr("label"), Type: attribute},
+			// Model created before enabling attributes
+			{Name: toPtr("room"), Type: measurement},
+		},
+	}
+	propertiesMap := make(map[string]iotclient.ArduinoProperty)
+	for _, p := range thing.Properties {
+		propertiesMap[p.Id] = p
+	}
+
+	swclient := sitewiseMocks.NewAPI(t)
+	// Models are described once per run
+	swclient.On("DescribeAssetModel", ctx, &modelId).Return(model, nil).Once()
+	// Unchanged
+	swclient.On("GetLatestAssetPropertyValue", ctx, assetId, "sw2").Return(&types.Variant{DoubleValue: aws.Float64(30.0)}, time.Now(), nil).Once()
+	// Changed
+	swclient.On("GetLatestAssetPropertyValue", ctx, assetId, "sw3").Return(&types.Variant{StringValue: toPtr("boost")}, time.Now(), nil).Once()
+	swclient.On("SetAssetPropertyAttribute", ctx, "/thing1/mode", "eco").Return(nil).Once()
+	// Never set
+	swclient.On("GetLatestAssetPropertyValue", ctx, assetId, "sw4").Return(nil, time.Time{}, sitewiseclient.ErrNoValue).Once()
+	swclient.On("SetAssetPropertyAttribute", ctx, "/thing1/label", "kitchen").Return(nil).Once()
+
+	tsAligner := New(swclient, iotapiMocks.NewAPI(t), logger, WithAttributeProperties(true))
+	attributeNames, err := tsAligner.modelAttributeNames(ctx, asset)
+	assert.NoError(t, err)
+	attributeNames, err = tsAligner.modelAttributeNames(ctx, asset)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"threshold", "mode", "label"}, attributeNames)
+
+	mapped := tsAligner.mapPropertiesToImport(asset, thing, "test", attributeNames)
+	// Attributes have no time series, properties that are measurements in the model keep theirs
+	assert.Equal(t, []string{"p1"}, mapped.PropertiesToImport)
+	assert.Equal(t, []string{"p5"}, mapped.CharPropertiesToImport)
+	assert.Equal(t, map[string]string{"p1": "/thing1/temperature", "p5": "/thing1/room"}, mapped.PropertiesToImportAliases)
+	assert.Len(t, mapped.Attributes, 3)
+	assert.Empty(t, mapped.UnmappedProperties)
+
+	assert.NoError(t, tsAligner.populateAttributes(ctx, assetId, propertiesMap, mapped.Attributes))
+	assert.Equal(t, int64(2), tsAligner.counters.summary().PointsWritten)
+}
+
+// alignedAliases aligns the asset of the thing with the given alias template, returning the aliases set on
+// its properties by property name.
+func alignedAliases(t *testing.T, thing iotclient.ArduinoThing, tmpl *entityalign.AliasTemplate) map[string]string {
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+	modelId := "03ba45c2-eab3-44ed-a68f-94a26d41df4c"
+	assetId := "e9e11559-ceca-4c2f-875d-76c1068a45f4"
+
+	swclient := sitewiseMocks.NewAPI(t)
+	swclient.On("DescribeAsset", ctx, "externalId:"+thing.Id).Return(&iotsitewise.DescribeAssetOutput{
+		AssetId: &assetId, AssetName: toPtr(thing.Name), AssetModelId: &modelId, AssetExternalId: &thing.Id,
+	}, nil)
+	modelProperties := make([]types.AssetModelProperty, 0, len(thing.Properties))
+	for _, p := range thing.Properties {
+		modelProperties = append(modelProperties, types.AssetModelProperty{
+			Name: toPtr(p.Name), DataType: types.PropertyDataTypeDouble, Type: &types.PropertyType{Measurement: &types.Measurement{}},
+		})
+	}
+	swclient.On("DescribeAssetModel", ctx, &modelId).Return(&iotsitewise.DescribeAssetModelOutput{
+		AssetModelId:         &modelId,
+		AssetModelProperties: modelProperties,
+	}, nil)
+	var aligned map[string]string
+	swclient.On("UpdateAssetProperties", ctx, assetId, mock.Anything).Run(func(args mock.Arguments) {
+		aligned = args.Get(2).(map[string]string)
+	}).Return(nil)
+	errs := entityalign.New(swclient, logger, entityalign.WithExternalIdLookup(true), entityalign.WithAliasTemplate(tmpl)).Align(ctx, []iotclient.ArduinoThing{thing}, nil)
+	assert.Empty(t, errs)
+	return aligned
+}
+
+func TestAliasTemplate_alignedAliasesMatchImportAliases(t *testing.T) {
+	logger := logrus.NewEntry(logrus.New())
+	thing := iotclient.ArduinoThing{
+		Id:   "bb831f04-0940-4ea6-9c24-83668e372919",
+		Name: "thing1",
+		Properties: []iotclient.ArduinoProperty{
+			{Id: "p1", Name: "temperature", Type: "FLOAT"},
+			{Id: "p2", Name: "humidity", Type: "FLOAT"},
+		},
+	}
+	tmpl, err := entityalign.ParseAliasTemplate("/factory-1/{{.ThingName}}/{{.PropertyName}}")
+	assert.NoError(t, err)
+
+	aligned := alignedAliases(t, thing, tmpl)
+	assert.Equal(t, map[string]string{
+		"temperature": "/factory-1/thing1/temperature",
+		"humidity":    "/factory-1/thing1/humidity",
+	}, aligned)
+
+	// Time series are imported to the same aliases
+	asset := &iotsitewise.DescribeAssetOutput{AssetProperties: []types.AssetProperty{{Name: toPtr("temperature")}, {Name: toPtr("humidity")}}}
+	mapped := New(nil, nil, logger, WithAliasTemplate(tmpl)).mapPropertiesToImport(asset, thing, "test", nil)
+	assert.Equal(t, map[string]string{"p1": aligned["temperature"], "p2": aligned["humidity"]}, mapped.PropertiesToImportAliases)
+}
+
+func TestAliasPrefix_alignedAliasesMatchImportAliases(t *testing.T) {
+	logger := logrus.NewEntry(logrus.New())
+	thingId := "bb831f04-0940-4ea6-9c24-83668e372919"
+	thing := iotclient.ArduinoThing{
+		Id:         thingId,
+		Name:       "thing1",
+		Properties: []iotclient.ArduinoProperty{{Id: "p1", Name: "temperature", Type: "FLOAT"}},
+	}
+	var noTemplate *entityalign.AliasTemplate
+	aliases := noTemplate.WithPrefix("/arduino/prod")
+
+	aligned := alignedAliases(t, thing, aliases)
+	assert.Equal(t, map[string]string{"temperature": "/arduino/prod/" + thingId + "/temperature"}, aligned)
+
+	// Time series are imported to the same aliases
+	asset := &iotsitewise.DescribeAssetOutput{AssetProperties: []types.AssetProperty{{Name: toPtr("temperature")}}}
+	mapped := New(nil, nil, logger, WithAliasTemplate(aliases)).mapPropertiesToImport(asset, thing, "test", nil)
+	assert.Equal(t, aligned["temperature"], mapped.PropertiesToImportAliases["p1"])
+}
+
+func TestSendDeadLetters(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+	errs := []error{
+		&ThingImportError{ThingID: "thing-1", Err: errors.New("fetch failed")},
+		errors.New("bulk import job failed"),
+		&ThingImportError{ThingID: "thing-2", Err: errors.New("timed out after 1m0s: context deadline exceeded")},
+	}
+
+	sink := deadLetterMocks.NewAPI(t)
+	sink.On("Send", ctx, mock.MatchedBy(func(records []deadletter.Record) bool {
+		return len(records) == 2 &&
+			records[0].ThingID == "thing-1" && records[0].Error == "fetch failed" && !records[0].Timestamp.IsZero() &&
+			records[1].ThingID == "thing-2" && records[1].Error == "timed out after 1m0s: context deadline exceeded"
+	})).Return(nil).Once()
+	New(nil, nil, logger, WithDeadLetters(sink)).sendDeadLetters(ctx, errs)
+
+	// Nothing to send without thing failures, or without sink
+	New(nil, nil, logger, WithDeadLetters(sink)).sendDeadLetters(ctx, errs[1:2])
+	New(nil, nil, logger).sendDeadLetters(ctx, errs)
+
+	// Sink errors don't fail the run
+	sink.On("Send", ctx, mock.Anything).Return(errors.New("queue does not exist")).Once()
+	New(nil, nil, logger, WithDeadLetters(sink)).sendDeadLetters(ctx, errs)
+}
+
+func TestProgress(t *testing.T) {
+	var out strings.Builder
+	logger := logrus.New()
+	logger.SetOutput(&out)
+	entry := logrus.NewEntry(logger)
+
+	assert.Nil(t, New(nil, nil, entry).startProgress(10), "disabled by default")
+
+	// Every 4 things, from concurrent imports
+	p := New(nil, nil, entry, WithProgressLog(4, 0)).startProgress(10)
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.thingDone(i == 3)
+		}()
+	}
+	wg.Wait()
+	p.finish()
+	assert.Equal(t, 2, strings.Count(out.String(), "Progress - processed"))
+	assert.Contains(t, out.String(), "processed 8 of 10 things")
+	assert.Contains(t, out.String(), "things_total=10")
+
+	// On a timer, also while no thing completes
+	out.Reset()
+	p = New(nil, nil, entry, WithProgressLog(0, 10*time.Millisecond)).startProgress(3)
+	p.thingDone(true)
+	time.Sleep(35 * time.Millisecond)
+	p.finish()
+	assert.Contains(t, out.String(), "processed 1 of 3 things (1 errors so far)")
+}
+
+func TestProgress_countsDescribeFailures(t *testing.T) {
+	ctx := context.Background()
+	var out strings.Builder
+	logger := logrus.New()
+	logger.SetOutput(&out)
+	swclient, arclient, thingsMap := describeFailureMocks(t, ctx)
+
+	tsAligner := New(swclient, arclient, logrus.NewEntry(logger), WithProgressLog(2, 0))
+	_, errs := tsAligner.AlignTimeSeriesSamplesIntoSiteWise(ctx, 60, thingsMap, 300)
+	assert.Len(t, errs, 1)
+	assert.Contains(t, out.String(), "processed 2 of 2 things (1 errors so far)")
+}
+
+func TestAliasBatch(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+	ts := time.Now().Add(-time.Hour).Unix()
+	series := func(n int) ([]int64, []float64) {
+		tss, values := make([]int64, n), make([]float64, n)
+		for i := range tss {
+			tss[i], values[i] = ts+int64(i), float64(i)
+		}
+		return tss, values
+	}
+
+	t.Run("series of several aliases are written together", func(t *testing.T) {
+		swclient := sitewiseMocks.NewAPI(t)
+		swclient.On("PopulateArbitrarySamplesByAlias", ctx, mock.MatchedBy(func(points []sitewiseclient.DataPoint) bool {
+			return len(points) == 4 && points[0].PropertyAlias == "/thing/temperature" && points[3].PropertyAlias == "/thing/status"
+		})).Return(nil).Once()
+
+		batch := New(swclient, iotapiMocks.NewAPI(t), logger, WithAliasBatching(true)).newAliasBatch()
+		tss, values := series(2)
+		assert.NoError(t, batch.addNumeric(ctx, "/thing/temperature", tss, values))
+		assert.NoError(t, batch.addNumeric(ctx, "/thing/humidity", tss[:1], values[:1]))
+		assert.NoError(t, batch.addSampled(ctx, "/thing/status", []int64{ts}, []any{"ok"}))
+		assert.NoError(t, batch.flush(ctx))
+	})
+
+	t.Run("batch is written when entries reach the threshold", func(t *testing.T) {
+		swclient := sitewiseMocks.NewAPI(t)
+		swclient.On("PopulateArbitrarySamplesByAlias", ctx, mock.MatchedBy(func(points []sitewiseclient.DataPoint) bool {
+			return len(points) == 5 && points[0].PropertyAlias == "/thing/temperature"
+		})).Return(nil).Once()
+		swclient.On("PopulateArbitrarySamplesByAlias", ctx, mock.MatchedBy(func(points []sitewiseclient.DataPoint) bool {
+			return len(points) == 100 && points[0].PropertyAlias == "/thing/humidity"
+		})).Return(nil).Once()
+
+		batch := New(swclient, iotapiMocks.NewAPI(t), logger, WithAliasBatching(true)).newAliasBatch()
+		tss, values := series(100)
+		assert.NoError(t, batch.addNumeric(ctx, "/thing/temperature", tss[:5], values[:5]))
+		// 100 points take AliasBatchEntries entries: pending points are written first
+		assert.NoError(t, batch.addNumeric(ctx, "/thing/humidity", tss, values))
+		assert.NoError(t, batch.flush(ctx))
+	})
+
+	t.Run("series are written right away without batching", func(t *testing.T) {
+		swclient := sitewiseMocks.NewAPI(t)
+		swclient.On("PopulateTimeSeriesByAlias", ctx, "/thing/temperature", []int64{ts}, []float64{1.0}).Return(nil).Once()
+
+		batch := New(swclient, iotapiMocks.NewAPI(t), logger).newAliasBatch()
+		assert.NoError(t, batch.addNumeric(ctx, "/thing/temperature", []int64{ts}, []float64{1.0}))
+		assert.NoError(t, batch.flush(ctx))
+	})
+}
+
+func TestIngestionWindowFilter(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+	now := time.Now()
+	old, current, future := now.Add(-8*24*time.Hour).Unix(), now.Add(-time.Hour).Unix(), now.Add(time.Hour).Unix()
+
+	t.Run("out of window points are dropped", func(t *testing.T) {
+		swclient := sitewiseMocks.NewAPI(t)
+		swclient.On("PopulateTimeSeriesByAlias", ctx, "/thing/temperature", []int64{current}, []float64{2.0}).Return(nil).Once()
+		swclient.On("PopulateArbitrarySamplesByAlias", ctx, []sitewiseclient.DataPoint{{PropertyAlias: "/thing/msg", Ts: current, Value: "b"}}).Return(nil).Once()
+
+		tsAligner := New(swclient, iotapiMocks.NewAPI(t), logger)
+		tsAligner.counters.written.Add(6)
+		assert.NoError(t, tsAligner.sitewisecl.PopulateTimeSeriesByAlias(ctx, "/thing/temperature", []int64{old, current, future}, []float64{1.0, 2.0, 3.0}))
+		assert.NoError(t, tsAligner.sitewisecl.PopulateSampledSamplesTimeSeriesByAlias(ctx, "/thing/msg", []int64{old}, []any{"a"}))
+		assert.NoError(t, tsAligner.sitewisecl.PopulateArbitrarySamplesByAlias(ctx, []sitewiseclient.DataPoint{
+			{PropertyAlias: "/thing/msg", Ts: future, Value: "a"},
+			{PropertyAlias: "/thing/msg", Ts: current, Value: "b"},
+		}))
+		summary := tsAligner.counters.summary()
+		assert.Equal(t, int64(4), summary.PointsSkipped)
+		assert.Equal(t, int64(2), summary.PointsWritten)
+	})
+
+	t.Run("old points are staged for bulk import", func(t *testing.T) {
+		swclient := sitewiseMocks.NewAPI(t)
+		swclient.On("PopulateTimeSeriesByAlias", ctx, "/thing/temperature", []int64{current}, []float64{2.0}).Return(nil).Once()
+
+		tsAligner := New(swclient, iotapiMocks.NewAPI(t), logger, WithBulkImport(BulkImportConfig{OutOfWindowOnly: true}))
+		assert.NoError(t, tsAligner.sitewisecl.PopulateTimeSeriesByAlias(ctx, "/thing/temperature", []int64{old, current, future}, []float64{1.0, 2.0, 3.0}))
+		assert.Equal(t, [][]string{{"/thing/temperature", "DOUBLE", strconv.FormatInt(old, 10), "0", "GOOD", "1"}}, tsAligner.bulk.staged.take())
+		assert.Equal(t, int64(1), tsAligner.counters.summary().PointsSkipped)
+	})
+}
+
+func TestImportSummary_Add(t *testing.T) {
+	summary := ImportSummary{ThingsProcessed: 1, PropertiesImported: 2, PointsWritten: 10, PointsSkipped: 1}
+	summary.Add(ImportSummary{ThingsProcessed: 2, PropertiesImported: 3, PointsWritten: 5, ThrottleRetries: 4, PointsRejected: 1})
+	assert.Equal(t, ImportSummary{ThingsProcessed: 3, PropertiesImported: 5, PointsWritten: 15, PointsSkipped: 1, ThrottleRetries: 4, PointsRejected: 1}, summary)
+}
+
+func TestParseValueTransforms(t *testing.T) {
+	transforms, err := ParseValueTransforms(" temperature=1.8*x+32, voltage = 0.0048 * x ,level=-x,offset=x-0.5,")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]Transform{
+		"temperature": LinearTransform{Scale: 1.8, Offset: 32},
+		"voltage":     LinearTransform{Scale: 0.0048},
+		"level":       LinearTransform{Scale: -1},
+		"offset":      LinearTransform{Scale: 1, Offset: -0.5},
+	}, transforms)
+	assert.Equal(t, 212.0, transforms["temperature"].Apply(100))
+
+	for _, invalid := range []string{"temperature", "=2*x", "temperature=2*y", "temperature=2x", "temperature=x*x", "temperature=x32", "temperature=NaN*x"} {
+		_, err := ParseValueTransforms(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestTSExtraction_valueTransforms(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	thingId := "bb831f04-0940-4ea6-9c24-83668e372919"
+	thing := iotclient.ArduinoThing{
+		Id: thingId,
+		Properties: []iotclient.ArduinoProperty{
+			{Id: "p1", Name: "temperature", Type: "TEMPERATURE_C"},
+			{Id: "p2", Name: "msg", Type: "CHARSTRING"},
+		},
+	}
+	describedAsset := &iotsitewise.DescribeAssetOutput{
+		AssetProperties: []types.AssetProperty{{Name: toPtr("temperature")}, {Name: toPtr("msg")}},
+	}
+	transforms := map[string]Transform{
+		"temperature": LinearTransform{Scale: 1.8, Offset: 32},
+		"msg":         LinearTransform{Scale: 2},
+	}
+
+	bucket := time.Now().Truncate(5 * time.Minute).Add(-10 * time.Minute)
+	arclient := iotapiMocks.NewAPI(t)
+	arclient.On("GetTimeSeriesByThing", ctx, thingId, mock.Anything, mock.Anything, int64(300), "").Return(&iotclient.ArduinoSeriesBatch{
+		Responses: []iotclient.ArduinoSeriesResponse{
+			{Query: "property.p1", Times: []time.Time{bucket}, Values: []float64{100}, CountValues: 1},
+		},
+	}, nil).Once()
+	swclient := sitewiseMocks.NewAPI(t)
+	swclient.On("PopulateTimeSeriesByAlias", ctx, "/"+thingId+"/temperature", []int64{bucket.Unix()}, []float64{212}).Return(nil).Once()
+
+	tsAligner := New(swclient, arclient, logger, WithValueTransforms(transforms))
+	mapped := tsAligner.mapPropertiesToImport(describedAsset, thing, "test", nil)
+	// Only numeric properties are transformed
+	assert.Equal(t, map[string]Transform{"p1": transforms["temperature"]}, mapped.Transforms)
+
+	from, to := computeTimeAlignment(time.Now(), 300, 60, 0)
+	_, err := tsAligner.populateTSDataIntoSiteWise(ctx, thingId, mapped, 300, from, to)
+	assert.NoError(t, err)
+}
+
+func TestVerifyImport(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+	thingId, modelId, assetId := "thing1", "model1", "asset1"
+	now := time.Now().UTC()
+	updatedAt := now.Add(-time.Minute)
+
+	thing := iotclient.ArduinoThing{Id: thingId, Properties: []iotclient.ArduinoProperty{
+		{Id: "p1", Name: "temperature", Type: "FLOAT", LastValue: 21.5, ValueUpdatedAt: &updatedAt},
+		{Id: "p2", Name: "count", Type: "FLOAT", LastValue: 3.7, ValueUpdatedAt: &updatedAt},
+		{Id: "p3", Name: "on", Type: "STATUS", LastValue: true, ValueUpdatedAt: &updatedAt},
+		{Id: "p4", Name: "msg", Type: "CHARSTRING", LastValue: "hello", ValueUpdatedAt: &updatedAt},
+	}}
+	swclient := sitewiseMocks.NewAPI(t)
+	swclient.On("ListAllAssetModels", ctx).Return([]types.AssetModelSummary{{Id: &modelId}}, nil).Once()
+	swclient.On("ListAllAssetsForModel", ctx, &modelId).Return([]types.AssetSummary{{Id: &assetId, Name: toPtr("test"), ExternalId: &thingId}}, nil).Once()
+	swclient.On("DescribeAsset", ctx, assetId).Return(&iotsitewise.DescribeAssetOutput{
+		AssetId: &assetId,
+		AssetProperties: []types.AssetProperty{
+			{Id: toPtr("sw1"), Name: toPtr("temperature")},
+			{Id: toPtr("sw2"), Name: toPtr("count")},
+			{Id: toPtr("sw3"), Name: toPtr("on")},
+			{Id: toPtr("sw4"), Name: toPtr("msg")},
+		},
+	}, nil).Once()
+	swclient.On("GetLatestAssetPropertyValue", ctx, assetId, "sw1").Return(&types.Variant{DoubleValue: aws.Float64(21.5)}, now, nil).Once()
+	// Coerced to integer
+	swclient.On("GetLatestAssetPropertyValue", ctx, assetId, "sw2").Return(&types.Variant{IntegerValue: aws.Int32(4)}, now, nil).Once()
+	// Booleans are written as doubles
+	swclient.On("GetLatestAssetPropertyValue", ctx, assetId, "sw3").Return(&types.Variant{DoubleValue: aws.Float64(1.0)}, now, nil).Once()
+	// Not imported yet
+	swclient.On("GetLatestAssetPropertyValue", ctx, assetId, "sw4").Return(&types.Variant{StringValue: toPtr("bye")}, now.Add(-time.Hour), nil).Once()
+
+	tsAligner := New(swclient, iotapiMocks.NewAPI(t), logger)
+	result, err := tsAligner.VerifyImport(ctx, map[string]iotclient.ArduinoThing{thingId: thing}, 10, DefaultVerifyTolerance)
+	assert.NoError(t, err)
+	assert.Equal(t, VerifyResult{ThingsChecked: 1, PropertiesChecked: 4, Mismatches: 1, Stale: 1}, result)
+}
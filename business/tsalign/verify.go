@@ -0,0 +1,193 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package tsalign
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/arduino/aws-sitewise-integration/internal/iot"
+	"github.com/arduino/aws-sitewise-integration/internal/sitewiseclient"
+	iotclient "github.com/arduino/iot-client-go/v2"
+	"github.com/aws/aws-sdk-go-v2/service/iotsitewise/types"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultVerifyTolerance is the relative difference allowed between numeric values read back from SiteWise
+// and the last values of Arduino properties.
+const DefaultVerifyTolerance = 1e-6
+
+// VerifyResult reports the comparison of imported values against the Arduino source.
+type VerifyResult struct {
+	ThingsChecked     int `json:"thingsChecked"`
+	PropertiesChecked int `json:"propertiesChecked"`
+	// Properties whose latest SiteWise value differs from the Arduino last value
+	Mismatches int `json:"mismatches"`
+	// Properties whose latest SiteWise value is older than the Arduino last value, not imported yet
+	Stale int `json:"stale"`
+}
+
+// Add accumulates the result of another verification
+func (r *VerifyResult) Add(other VerifyResult) {
+	r.ThingsChecked += other.ThingsChecked
+	r.PropertiesChecked += other.PropertiesChecked
+	r.Mismatches += other.Mismatches
+	r.Stale += other.Stale
+}
+
+// VerifyImport reads back the latest values of the properties of a random sample of things, comparing them
+// to the last values of their Arduino properties. Mismatches beyond the given relative tolerance are logged.
+// Properties with no value in SiteWise are skipped.
+func (a *TsAligner) VerifyImport(ctx context.Context, thingsMap map[string]iotclient.ArduinoThing, sampleSize int, tolerance float64) (VerifyResult, error) {
+	var result VerifyResult
+	assets, err := a.discoverAssets(ctx)
+	if err != nil {
+		return result, err
+	}
+	assets = slices.DeleteFunc(slices.Clone(assets), func(asset *discoveredAsset) bool {
+		_, ok := thingsMap[asset.thingId]
+		return !ok
+	})
+	rand.Shuffle(len(assets), func(i, j int) { assets[i], assets[j] = assets[j], assets[i] })
+
+	for _, asset := range assets[:min(sampleSize, len(assets))] {
+		description, ok, err := a.describeAsset(ctx, asset)
+		if err != nil {
+			a.logger.WithFields(logrus.Fields{logFieldThingID: asset.thingId, logFieldAssetID: asset.assetId}).Warnln("Error describing asset, skipping verification:", err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		attributeNames, err := a.modelAttributeNames(ctx, description)
+		if err != nil {
+			a.logger.WithFields(logrus.Fields{logFieldThingID: asset.thingId, logFieldAssetID: asset.assetId}).Warnln("Error describing asset model, skipping verification:", err)
+			continue
+		}
+		thing := thingsMap[asset.thingId]
+		mapped := a.mapPropertiesToImport(description, thing, asset.assetName, attributeNames)
+		propertyIds := make(map[string]string)
+		for _, prop := range sitewiseclient.AssetProperties(description) {
+			propertyIds[*prop.Name] = *prop.Id
+		}
+		result.ThingsChecked++
+
+		for _, property := range thing.Properties {
+			alias, ok := mapped.PropertiesToImportAliases[property.Id]
+			transform := mapped.Transforms[property.Id]
+			if attribute, isAttribute := mapped.Attributes[property.Id]; isAttribute {
+				alias, ok, transform = attribute.alias, true, attribute.transform
+			}
+			if !ok || property.LastValue == nil {
+				continue
+			}
+			logger := a.logger.WithFields(logrus.Fields{logFieldThingID: thing.Id, logFieldAssetID: asset.assetId, logFieldPropertyAlias: alias})
+			value, ts, err := a.sitewisecl.GetLatestAssetPropertyValue(ctx, asset.assetId, propertyIds[property.Name])
+			if errors.Is(err, sitewiseclient.ErrNoValue) {
+				logger.Debugln("No value in SiteWise, skipping verification of property", property.Name)
+				continue
+			}
+			if err != nil {
+				return result, err
+			}
+			result.PropertiesChecked++
+
+			if property.ValueUpdatedAt != nil && ts.Before(property.ValueUpdatedAt.Truncate(time.Second)) {
+				logger.Infoln("SiteWise value of property", property.Name, "at", ts, "is older than Arduino value at", *property.ValueUpdatedAt)
+				result.Stale++
+				continue
+			}
+			expected := writtenValue(property, transform)
+			if !variantMatches(expected, value, tolerance) {
+				logger.Warnln("Value mismatch for property", property.Name, "- Arduino:", expected, "- SiteWise:", formatVariant(value), "at", ts)
+				result.Mismatches++
+			}
+		}
+	}
+	a.logger.Infof("=====> Verified %d properties of %d things: %d mismatches, %d stale", result.PropertiesChecked, result.ThingsChecked, result.Mismatches, result.Stale)
+	return result, nil
+}
+
+// writtenValue returns the Arduino last value of the property, as written into SiteWise
+func writtenValue(property iotclient.ArduinoProperty, transform Transform) any {
+	value := property.LastValue
+	if iot.IsPropertyLocation(property.Type) {
+		if lat, lng, err := parseLocation(value); err == nil {
+			return formatLocation(lat, lng)
+		}
+	}
+	if v, ok := value.(float64); ok && transform != nil {
+		return transform.Apply(v)
+	}
+	return value
+}
+
+// variantMatches tells if the SiteWise value matches the expected one. Numbers are compared with the given
+// relative tolerance, whatever their SiteWise data type. Composite values are compared as JSON.
+func variantMatches(expected any, value *types.Variant, tolerance float64) bool {
+	switch v := expected.(type) {
+	case float64:
+		var actual float64
+		switch {
+		case value.DoubleValue != nil:
+			actual = *value.DoubleValue
+		case value.IntegerValue != nil:
+			actual = float64(*value.IntegerValue)
+		default:
+			return false
+		}
+		return math.Abs(actual-v) <= tolerance*math.Max(1, math.Abs(v))
+	case bool:
+		// Booleans are written as doubles
+		if value.DoubleValue != nil {
+			return *value.DoubleValue == boolToFloat(v)
+		}
+		return value.BooleanValue != nil && *value.BooleanValue == v
+	case string:
+		return value.StringValue != nil && *value.StringValue == v
+	default:
+		encoded, err := json.Marshal(v)
+		return err == nil && value.StringValue != nil && *value.StringValue == string(encoded)
+	}
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func formatVariant(value *types.Variant) string {
+	switch {
+	case value.DoubleValue != nil:
+		return fmt.Sprintf("%v (double)", *value.DoubleValue)
+	case value.IntegerValue != nil:
+		return fmt.Sprintf("%v (integer)", *value.IntegerValue)
+	case value.BooleanValue != nil:
+		return fmt.Sprintf("%v (boolean)", *value.BooleanValue)
+	case value.StringValue != nil:
+		return fmt.Sprintf("%q (string)", *value.StringValue)
+	default:
+		return "empty"
+	}
+}
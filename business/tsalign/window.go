@@ -0,0 +1,165 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package tsalign
+
+import (
+	"context"
+	"time"
+
+	"github.com/arduino/aws-sitewise-integration/internal/sitewiseclient"
+	"github.com/sirupsen/logrus"
+)
+
+// SiteWise rejects whole BatchPutAssetPropertyValue entries holding timestamps out of its ingestion window.
+// The future bound is conservative, to cope with clock skew between hosts.
+const (
+	ingestionMaxAge    = 7 * 24 * time.Hour
+	ingestionMaxFuture = 5 * time.Minute
+)
+
+// ingestionWindowFilter keeps data points written to SiteWise within its ingestion window. Points too old
+// to be streamed go to the backfill client, if any, otherwise they are dropped like points in the future.
+// Dropped points are logged and counted as skipped rather than written.
+type ingestionWindowFilter struct {
+	sitewiseclient.API
+	backfill sitewiseclient.API
+	logger   *logrus.Entry
+	skipped  func(int64)
+	now      func() time.Time
+}
+
+// windowPosition tells whether a timestamp is older than the ingestion window (-1), within it (0) or in the future (1).
+type windowPosition int
+
+func position(ts int64, now time.Time) windowPosition {
+	switch {
+	case ts < now.Add(-ingestionMaxAge).Unix():
+		return -1
+	case ts > now.Add(ingestionMaxFuture).Unix():
+		return 1
+	}
+	return 0
+}
+
+func (a *TsAligner) ingestionWindow(sitewisecl, backfill sitewiseclient.API) *ingestionWindowFilter {
+	return &ingestionWindowFilter{
+		API:      sitewisecl,
+		backfill: backfill,
+		logger:   a.logger,
+		skipped: func(n int64) {
+			a.counters.written.Add(-n)
+			a.counters.skipped.Add(n)
+		},
+		now: time.Now,
+	}
+}
+
+func (f *ingestionWindowFilter) PopulateTimeSeriesByAlias(ctx context.Context, propertyAlias string, ts []int64, values []float64) error {
+	current, old, dropped := splitByWindow(ts, values, f.now(), f.backfill != nil)
+	f.dropped(propertyAlias, dropped)
+	if f.backfill != nil && len(old.ts) > 0 {
+		if err := f.backfill.PopulateTimeSeriesByAlias(ctx, propertyAlias, old.ts, old.values); err != nil {
+			return err
+		}
+	}
+	if len(current.ts) == 0 {
+		return nil
+	}
+	return f.API.PopulateTimeSeriesByAlias(ctx, propertyAlias, current.ts, current.values)
+}
+
+func (f *ingestionWindowFilter) PopulateSampledSamplesTimeSeriesByAlias(ctx context.Context, propertyAlias string, ts []int64, values []any) error {
+	current, old, dropped := splitByWindow(ts, values, f.now(), f.backfill != nil)
+	f.dropped(propertyAlias, dropped)
+	if f.backfill != nil && len(old.ts) > 0 {
+		if err := f.backfill.PopulateSampledSamplesTimeSeriesByAlias(ctx, propertyAlias, old.ts, old.values); err != nil {
+			return err
+		}
+	}
+	if len(current.ts) == 0 {
+		return nil
+	}
+	return f.API.PopulateSampledSamplesTimeSeriesByAlias(ctx, propertyAlias, current.ts, current.values)
+}
+
+func (f *ingestionWindowFilter) PopulateArbitrarySamplesByAlias(ctx context.Context, points []sitewiseclient.DataPoint) error {
+	now := f.now()
+	var current, old []sitewiseclient.DataPoint
+	dropped := map[string]int{}
+	for _, p := range points {
+		switch position(p.Ts, now) {
+		case 0:
+			current = append(current, p)
+		case -1:
+			if f.backfill != nil {
+				old = append(old, p)
+				continue
+			}
+			dropped[p.PropertyAlias]++
+		default:
+			dropped[p.PropertyAlias]++
+		}
+	}
+	for alias, n := range dropped {
+		f.dropped(alias, n)
+	}
+	if len(old) > 0 {
+		if err := f.backfill.PopulateArbitrarySamplesByAlias(ctx, old); err != nil {
+			return err
+		}
+	}
+	if len(current) == 0 {
+		return nil
+	}
+	return f.API.PopulateArbitrarySamplesByAlias(ctx, current)
+}
+
+func (f *ingestionWindowFilter) dropped(alias string, n int) {
+	if n == 0 {
+		return
+	}
+	f.logger.WithField(logFieldPropertyAlias, alias).Warnf("Dropped %d data points out of the SiteWise ingestion window (older than 7 days or more than 5 minutes in the future)", n)
+	f.skipped(int64(n))
+}
+
+type windowedPoints[V any] struct {
+	ts     []int64
+	values []V
+}
+
+// splitByWindow splits points in the ones within the ingestion window and the ones older than it, if kept,
+// returning the number of dropped points.
+func splitByWindow[V any](ts []int64, values []V, now time.Time, keepOld bool) (windowedPoints[V], windowedPoints[V], int) {
+	var current, old windowedPoints[V]
+	dropped := 0
+	for i := 0; i < len(ts) && i < len(values); i++ {
+		switch position(ts[i], now) {
+		case 0:
+			current.ts = append(current.ts, ts[i])
+			current.values = append(current.values, values[i])
+		case -1:
+			if keepOld {
+				old.ts = append(old.ts, ts[i])
+				old.values = append(old.values, values[i])
+				continue
+			}
+			dropped++
+		default:
+			dropped++
+		}
+	}
+	return current, old, dropped
+}
@@ -0,0 +1,99 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package aliasindex
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Attribute names of the index table. The table partition key must be 'alias' (string).
+const (
+	attrAlias        = "alias"
+	attrThingId      = "thingId"
+	attrPropertyName = "propertyName"
+	attrAssetId      = "assetId"
+	attrUpdatedAt    = "updatedAt"
+)
+
+// Entry maps a SiteWise property alias to the Arduino thing property and SiteWise asset it belongs to.
+type Entry struct {
+	Alias        string
+	ThingId      string
+	PropertyName string
+	AssetId      string
+}
+
+//go:generate mockery --name API --filename alias_index_api.go
+type API interface {
+	Put(ctx context.Context, entries []Entry) error
+}
+
+type dynamoAPI interface {
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+}
+
+// DynamoIndex writes alias index entries to a DynamoDB table.
+type DynamoIndex struct {
+	svc   dynamoAPI
+	table string
+	now   func() time.Time
+}
+
+func New(table string) (*DynamoIndex, error) {
+	awsOpts := []func(*config.LoadOptions) error{}
+
+	cfg, err := config.LoadDefaultConfig(
+		context.Background(),
+		awsOpts...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DynamoIndex{
+		svc:   dynamodb.NewFromConfig(cfg),
+		table: table,
+		now:   time.Now,
+	}, nil
+}
+
+// Put upserts the given entries, keyed by alias.
+func (i *DynamoIndex) Put(ctx context.Context, entries []Entry) error {
+	updatedAt := i.now().UTC().Format(time.RFC3339)
+	for _, e := range entries {
+		_, err := i.svc.PutItem(ctx, &dynamodb.PutItemInput{
+			TableName: aws.String(i.table),
+			Item: map[string]types.AttributeValue{
+				attrAlias:        &types.AttributeValueMemberS{Value: e.Alias},
+				attrThingId:      &types.AttributeValueMemberS{Value: e.ThingId},
+				attrPropertyName: &types.AttributeValueMemberS{Value: e.PropertyName},
+				attrAssetId:      &types.AttributeValueMemberS{Value: e.AssetId},
+				attrUpdatedAt:    &types.AttributeValueMemberS{Value: updatedAt},
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("writing alias %s to index: %w", e.Alias, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package aliasindex
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDynamo records items written to the table
+type fakeDynamo struct {
+	puts []*dynamodb.PutItemInput
+	err  error
+}
+
+func (f *fakeDynamo) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.puts = append(f.puts, params)
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func TestPut_writesOneItemPerAlias(t *testing.T) {
+	svc := &fakeDynamo{}
+	now := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+	index := &DynamoIndex{svc: svc, table: "alias-index", now: func() time.Time { return now }}
+
+	err := index.Put(context.Background(), []Entry{
+		{Alias: "/thing-1/temperature", ThingId: "thing-1", PropertyName: "temperature", AssetId: "asset-1"},
+		{Alias: "/thing-1/humidity", ThingId: "thing-1", PropertyName: "humidity", AssetId: "asset-1"},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, svc.puts, 2)
+
+	put := svc.puts[0]
+	assert.Equal(t, "alias-index", *put.TableName)
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "/thing-1/temperature"}, put.Item[attrAlias])
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "thing-1"}, put.Item[attrThingId])
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "temperature"}, put.Item[attrPropertyName])
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "asset-1"}, put.Item[attrAssetId])
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "2024-10-01T12:00:00Z"}, put.Item[attrUpdatedAt])
+}
+
+func TestPut_returnsStoreError(t *testing.T) {
+	svc := &fakeDynamo{err: errors.New("throttled")}
+	index := &DynamoIndex{svc: svc, table: "alias-index", now: time.Now}
+
+	err := index.Put(context.Background(), []Entry{{Alias: "/thing-1/temperature"}})
+	assert.ErrorContains(t, err, "/thing-1/temperature")
+	assert.ErrorContains(t, err, "throttled")
+}
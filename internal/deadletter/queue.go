@@ -0,0 +1,99 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// SQS accepts at most 10 messages per SendMessageBatch call
+const maxBatchEntries = 10
+
+// Record is the message sent for a thing whose import failed. Its thingId field makes it a valid message
+// of the SQS import trigger, so that a queue of records can feed a lambda importing just the failures.
+type Record struct {
+	ThingID   string    `json:"thingId"`
+	Error     string    `json:"error"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+//go:generate mockery --name API --filename dead_letter_api.go
+type API interface {
+	Send(ctx context.Context, records []Record) error
+}
+
+type sqsAPI interface {
+	SendMessageBatch(ctx context.Context, params *sqs.SendMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageBatchOutput, error)
+}
+
+// SQSQueue sends records to an SQS queue.
+type SQSQueue struct {
+	svc      sqsAPI
+	queueURL string
+}
+
+func New(queueURL string) (*SQSQueue, error) {
+	awsOpts := []func(*config.LoadOptions) error{}
+
+	cfg, err := config.LoadDefaultConfig(
+		context.Background(),
+		awsOpts...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SQSQueue{
+		svc:      sqs.NewFromConfig(cfg),
+		queueURL: queueURL,
+	}, nil
+}
+
+// Send sends a message per record, in batches.
+func (q *SQSQueue) Send(ctx context.Context, records []Record) error {
+	for start := 0; start < len(records); start += maxBatchEntries {
+		end := min(start+maxBatchEntries, len(records))
+		entries := make([]types.SendMessageBatchRequestEntry, 0, end-start)
+		for i, record := range records[start:end] {
+			body, err := json.Marshal(record)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, types.SendMessageBatchRequestEntry{Id: aws.String(strconv.Itoa(i)), MessageBody: aws.String(string(body))})
+		}
+		out, err := q.svc.SendMessageBatch(ctx, &sqs.SendMessageBatchInput{
+			QueueUrl: aws.String(q.queueURL),
+			Entries:  entries,
+		})
+		if err != nil {
+			return fmt.Errorf("sending dead letter records: %w", err)
+		}
+		if len(out.Failed) > 0 {
+			failed := out.Failed[0]
+			return fmt.Errorf("sending dead letter records: %d of %d messages failed, first: %s %s", len(out.Failed), len(entries), aws.ToString(failed.Code), aws.ToString(failed.Message))
+		}
+	}
+	return nil
+}
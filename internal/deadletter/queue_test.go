@@ -0,0 +1,77 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package deadletter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSQS records the batches sent to the queue
+type fakeSQS struct {
+	batches []*sqs.SendMessageBatchInput
+	failed  []types.BatchResultErrorEntry
+	err     error
+}
+
+func (f *fakeSQS) SendMessageBatch(ctx context.Context, params *sqs.SendMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageBatchOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.batches = append(f.batches, params)
+	return &sqs.SendMessageBatchOutput{Failed: f.failed}, nil
+}
+
+func TestSend_batchesMessages(t *testing.T) {
+	svc := &fakeSQS{}
+	queue := &SQSQueue{svc: svc, queueURL: "https://sqs.eu-west-1.amazonaws.com/123456789012/failures"}
+
+	at := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+	var records []Record
+	for i := range 12 {
+		records = append(records, Record{ThingID: fmt.Sprintf("thing-%d", i), Error: "timed out", Timestamp: at})
+	}
+	assert.NoError(t, queue.Send(context.Background(), records))
+
+	if assert.Len(t, svc.batches, 2) {
+		assert.Len(t, svc.batches[0].Entries, 10)
+		assert.Len(t, svc.batches[1].Entries, 2)
+		assert.Equal(t, "https://sqs.eu-west-1.amazonaws.com/123456789012/failures", aws.ToString(svc.batches[0].QueueUrl))
+		assert.JSONEq(t, `{"thingId": "thing-10", "error": "timed out", "timestamp": "2024-10-01T12:00:00Z"}`, aws.ToString(svc.batches[1].Entries[0].MessageBody))
+	}
+}
+
+func TestSend_errors(t *testing.T) {
+	records := []Record{{ThingID: "thing-1", Error: "failed"}}
+
+	svc := &fakeSQS{err: &types.QueueDoesNotExist{}}
+	queue := &SQSQueue{svc: svc, queueURL: "failures"}
+	err := queue.Send(context.Background(), records)
+	var notExist *types.QueueDoesNotExist
+	assert.True(t, errors.As(err, &notExist))
+
+	svc = &fakeSQS{failed: []types.BatchResultErrorEntry{{Id: aws.String("0"), Code: aws.String("InternalError"), Message: aws.String("retry")}}}
+	queue = &SQSQueue{svc: svc, queueURL: "failures"}
+	assert.ErrorContains(t, queue.Send(context.Background(), records), "1 of 1 messages failed, first: InternalError retry")
+}
@@ -0,0 +1,65 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package iot
+
+import (
+	"sync"
+	"time"
+
+	iotclient "github.com/arduino/iot-client-go/v2"
+)
+
+type propertyTypesEntry struct {
+	types     map[string]iotclient.ArduinoPropertytype
+	fetchedAt time.Time
+}
+
+// PropertyTypesCache keeps property types definitions by organization, as they rarely change.
+// It is meant to live across invocations of a warm Lambda.
+type PropertyTypesCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]propertyTypesEntry
+	now     func() time.Time
+}
+
+// NewPropertyTypesCache returns a cache keeping definitions for the given time.
+func NewPropertyTypesCache(ttl time.Duration) *PropertyTypesCache {
+	return &PropertyTypesCache{ttl: ttl, entries: make(map[string]propertyTypesEntry), now: time.Now}
+}
+
+// Invalidate drops the definitions of the organization, forcing a fetch on next use.
+func (c *PropertyTypesCache) Invalidate(organization string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, organization)
+}
+
+func (c *PropertyTypesCache) get(organization string) (map[string]iotclient.ArduinoPropertytype, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[organization]
+	if !ok || c.now().Sub(entry.fetchedAt) >= c.ttl {
+		return nil, false
+	}
+	return entry.types, true
+}
+
+func (c *PropertyTypesCache) set(organization string, types map[string]iotclient.ArduinoPropertytype) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[organization] = propertyTypesEntry{types: types, fetchedAt: c.now()}
+}
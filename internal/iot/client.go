@@ -17,33 +17,74 @@ package iot
 import (
 	"context"
 	"fmt"
+	"slices"
 	"time"
 
+	"github.com/arduino/aws-sitewise-integration/internal/tracing"
 	iotclient "github.com/arduino/iot-client-go/v2"
 	"golang.org/x/oauth2"
 )
 
 var ErrOtaAlreadyInProgress = fmt.Errorf("ota already in progress")
 
+// DefaultRequestTimeout bounds each call to Arduino IoT Cloud, not to hang on a stuck connection
+const DefaultRequestTimeout = time.Minute
+
+// RawSeriesLimit is the maximum number of raw samples returned per property by a raw query
+const RawSeriesLimit = 1000
+
 //go:generate mockery --name API --filename iot_api.go
 type API interface {
-	ThingList(ctx context.Context, ids []string, device *string, props bool, tags map[string]string) ([]iotclient.ArduinoThing, error)
-	GetTimeSeriesByThing(ctx context.Context, thingID string, from, to time.Time, interval int64) (*iotclient.ArduinoSeriesBatch, bool, error)
-	GetTimeSeriesSampling(ctx context.Context, propertiesToImport []string, from, to time.Time, interval int32) (*iotclient.ArduinoSeriesBatchSampled, bool, error)
+	ThingList(ctx context.Context, ids []string, device *string, props bool, tags map[string][]string) ([]iotclient.ArduinoThing, error)
+	GetTimeSeriesByThing(ctx context.Context, thingID string, from, to time.Time, interval int64, aggregation string) (*iotclient.ArduinoSeriesBatch, error)
+	GetTimeSeriesByProperties(ctx context.Context, aggregations map[string]string, from, to time.Time, interval int64) (*iotclient.ArduinoSeriesBatch, error)
+	GetAggregatedTimeSeriesByThing(ctx context.Context, thingID string, from, to time.Time, interval int64, aggregations []string) (*iotclient.ArduinoSeriesBatch, error)
+	GetRawTimeSeriesByProperties(ctx context.Context, propertyIDs []string, from, to time.Time) (*iotclient.ArduinoSeriesRawBatch, error)
+	GetTimeSeriesSampling(ctx context.Context, propertiesToImport []string, from, to time.Time, interval int32) (*iotclient.ArduinoSeriesBatchSampled, error)
 	PropertiesDefinition(ctx context.Context) (map[string]iotclient.ArduinoPropertytype, error)
 }
 
 // Client can perform actions on Arduino IoT Cloud.
 type Client struct {
-	api   *iotclient.APIClient
-	token oauth2.TokenSource
+	api            *iotclient.APIClient
+	token          oauth2.TokenSource
+	organization   string
+	typesCache     *PropertyTypesCache
+	requestTimeout time.Duration
+}
+
+// ClientOption configures optional behaviours of the client.
+type ClientOption func(*Client)
+
+// WithPropertyTypesCache makes the client reuse property types definitions kept by the cache.
+func WithPropertyTypesCache(cache *PropertyTypesCache) ClientOption {
+	return func(cl *Client) {
+		cl.typesCache = cache
+	}
+}
+
+// WithRequestTimeout sets the maximum duration of each call to Arduino IoT Cloud. Values below 1 disable the timeout.
+func WithRequestTimeout(timeout time.Duration) ClientOption {
+	return func(cl *Client) {
+		cl.requestTimeout = timeout
+	}
 }
 
 // NewClient returns a new client implementing the Client interface.
 // It needs client Credentials for cloud authentication.
-func NewClient(key, secret, organization string) (*Client, error) {
-	cl := &Client{}
-	err := cl.setup(key, secret, organization)
+func NewClient(key, secret, organization string, opts ...ClientOption) (*Client, error) {
+	token := NewUserTokenSource(key, secret, GetArduinoAPIBaseURL(), organization)
+	return NewClientWithTokenSource(token, organization, opts...)
+}
+
+// NewClientWithTokenSource returns a new client authenticating with the tokens of the given source,
+// for callers already holding a token.
+func NewClientWithTokenSource(token oauth2.TokenSource, organization string, opts ...ClientOption) (*Client, error) {
+	cl := &Client{token: token, organization: organization, requestTimeout: DefaultRequestTimeout}
+	for _, opt := range opts {
+		opt(cl)
+	}
+	err := cl.setup(organization)
 	if err != nil {
 		err = fmt.Errorf("instantiate new iot client: %w", err)
 		return nil, err
@@ -52,11 +93,39 @@ func NewClient(key, secret, organization string) (*Client, error) {
 }
 
 // ThingList returns a list of things on Arduino IoT Cloud.
-func (cl *Client) ThingList(ctx context.Context, ids []string, device *string, extractProperties bool, tags map[string]string) ([]iotclient.ArduinoThing, error) {
-	ctx, err := ctxWithToken(ctx, cl.token)
+// The things v2 list endpoint is not paginated: it has no offset, limit or cursor parameters and
+// returns all the things matching the filters in a single response.
+// Tags are values by tag key: values of the same key are OR'd, different keys are AND'd. As the backend
+// ANDs all the tags of a request, things are listed once per combination of values and merged.
+func (cl *Client) ThingList(ctx context.Context, ids []string, device *string, extractProperties bool, tags map[string][]string) ([]iotclient.ArduinoThing, error) {
+	combinations := tagCombinations(tags)
+	if len(combinations) == 0 {
+		return cl.thingList(ctx, ids, device, extractProperties, nil)
+	}
+	var things []iotclient.ArduinoThing
+	found := make(map[string]bool)
+	for _, t := range combinations {
+		matching, err := cl.thingList(ctx, ids, device, extractProperties, t)
+		if err != nil {
+			return nil, err
+		}
+		for _, thing := range matching {
+			if !found[thing.Id] {
+				found[thing.Id] = true
+				things = append(things, thing)
+			}
+		}
+	}
+	return things, nil
+}
+
+// thingList runs a single list request, bounded by the request timeout.
+func (cl *Client) thingList(ctx context.Context, ids []string, device *string, extractProperties bool, tags []string) ([]iotclient.ArduinoThing, error) {
+	ctx, cancel, err := cl.requestContext(ctx)
 	if err != nil {
 		return nil, err
 	}
+	defer cancel()
 
 	request := cl.api.ThingsV2Api.ThingsV2List(ctx)
 	request = request.ShowProperties(extractProperties)
@@ -70,12 +139,7 @@ func (cl *Client) ThingList(ctx context.Context, ids []string, device *string, e
 	}
 
 	if tags != nil {
-		t := make([]string, 0, len(tags))
-		for key, val := range tags {
-			// Use the 'key:value' format required from the backend
-			t = append(t, key+":"+val)
-		}
-		request = request.Tags(t)
+		request = request.Tags(tags)
 	}
 
 	things, _, err := cl.api.ThingsV2Api.ThingsV2ListExecute(request)
@@ -86,27 +150,123 @@ func (cl *Client) ThingList(ctx context.Context, ids []string, device *string, e
 	return things, nil
 }
 
-func (cl *Client) GetTimeSeriesByThing(ctx context.Context, thingID string, from, to time.Time, interval int64) (*iotclient.ArduinoSeriesBatch, bool, error) {
+// tagCombinations returns the tag filters of the requests listing things, one per combination of
+// the values of each key, in the 'key:value' format required from the backend.
+func tagCombinations(tags map[string][]string) [][]string {
+	keys := make([]string, 0, len(tags))
+	for key, values := range tags {
+		if len(values) > 0 {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	slices.Sort(keys)
+
+	combinations := [][]string{{}}
+	for _, key := range keys {
+		next := make([][]string, 0, len(combinations)*len(tags[key]))
+		for _, combination := range combinations {
+			for _, value := range tags[key] {
+				next = append(next, append(slices.Clone(combination), key+":"+value))
+			}
+		}
+		combinations = next
+	}
+	return combinations
+}
+
+// GetTimeSeriesByThing queries time series of all thing properties, aggregated over buckets of interval seconds
+// with the given aggregation (e.g. AVG, MIN, MAX, LAST), or the backend default one (AVG) if empty.
+// Samples finer than interval are aggregated: use GetRawTimeSeriesByProperties to get them as stored.
+func (cl *Client) GetTimeSeriesByThing(ctx context.Context, thingID string, from, to time.Time, interval int64, aggregation string) (*iotclient.ArduinoSeriesBatch, error) {
+	var aggregations []string
+	if aggregation != "" {
+		aggregations = []string{aggregation}
+	}
+	return cl.GetAggregatedTimeSeriesByThing(ctx, thingID, from, to, interval, aggregations)
+}
+
+// GetTimeSeriesByProperties queries time series of single properties, each one aggregated with its own
+// aggregation. Aggregations are keyed by property id.
+func (cl *Client) GetTimeSeriesByProperties(ctx context.Context, aggregations map[string]string, from, to time.Time, interval int64) (*iotclient.ArduinoSeriesBatch, error) {
+	ctx, cancel, err := cl.requestContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+
+	requests := []iotclient.BatchQueryRequestMediaV1{}
+	for propId, aggregation := range aggregations {
+		requests = append(requests, iotclient.BatchQueryRequestMediaV1{
+			Aggregation: &aggregation,
+			From:        from,
+			Interval:    &interval,
+			Q:           fmt.Sprintf("property.%s", propId),
+			To:          to,
+		})
+	}
+
+	if len(requests) == 0 {
+		return nil, fmt.Errorf("no valid properties provided")
+	}
+
+	batchQueryRequestsMediaV1 := iotclient.BatchQueryRequestsMediaV1{
+		Requests: requests,
+	}
+
+	request := cl.api.SeriesV2Api.SeriesV2BatchQuery(ctx)
+	request = request.BatchQueryRequestsMediaV1(batchQueryRequestsMediaV1)
+	ts, httpResponse, err := cl.api.SeriesV2Api.SeriesV2BatchQueryExecute(request)
+	if err != nil {
+		return nil, withStatus(fmt.Errorf("retrieving time series: %w", errorDetail(err)), httpResponse)
+	}
+	return ts, nil
+}
+
+// GetAggregatedTimeSeriesByThing queries time series of all thing properties once per requested aggregation
+// (e.g. AVG, MIN, MAX). Each response reports its aggregation. With no aggregations, the backend default is used.
+func (cl *Client) GetAggregatedTimeSeriesByThing(ctx context.Context, thingID string, from, to time.Time, interval int64, aggregations []string) (ts *iotclient.ArduinoSeriesBatch, err error) {
+	err = tracing.Capture(ctx, "IoT.GetTimeSeriesByThing", func(ctx context.Context) error {
+		ts, err = cl.getAggregatedTimeSeriesByThing(ctx, thingID, from, to, interval, aggregations)
+		return err
+	})
+	return ts, err
+}
+
+func (cl *Client) getAggregatedTimeSeriesByThing(ctx context.Context, thingID string, from, to time.Time, interval int64, aggregations []string) (*iotclient.ArduinoSeriesBatch, error) {
 	if thingID == "" {
-		return nil, false, fmt.Errorf("no thing provided")
+		return nil, fmt.Errorf("no thing provided")
 	}
 
-	ctx, err := ctxWithToken(ctx, cl.token)
+	ctx, cancel, err := cl.requestContext(ctx)
 	if err != nil {
-		return nil, false, err
+		return nil, err
 	}
+	defer cancel()
 
-	requests := []iotclient.BatchQueryRequestMediaV1{
-		{
+	requests := []iotclient.BatchQueryRequestMediaV1{}
+	if len(aggregations) == 0 {
+		requests = append(requests, iotclient.BatchQueryRequestMediaV1{
 			From:     from,
 			Interval: &interval,
 			Q:        fmt.Sprintf("thing.%s", thingID),
 			To:       to,
-		},
+		})
+	}
+	for _, aggregation := range aggregations {
+		requests = append(requests, iotclient.BatchQueryRequestMediaV1{
+			Aggregation: &aggregation,
+			From:        from,
+			Interval:    &interval,
+			Q:           fmt.Sprintf("thing.%s", thingID),
+			To:          to,
+		})
 	}
 
 	if len(requests) == 0 {
-		return nil, false, fmt.Errorf("no valid properties provided")
+		return nil, fmt.Errorf("no valid properties provided")
 	}
 
 	batchQueryRequestsMediaV1 := iotclient.BatchQueryRequestsMediaV1{
@@ -117,21 +277,58 @@ func (cl *Client) GetTimeSeriesByThing(ctx context.Context, thingID string, from
 	request = request.BatchQueryRequestsMediaV1(batchQueryRequestsMediaV1)
 	ts, httpResponse, err := cl.api.SeriesV2Api.SeriesV2BatchQueryExecute(request)
 	if err != nil {
-		err = fmt.Errorf("retrieving time series: %w", errorDetail(err))
-		if httpResponse != nil && httpResponse.StatusCode == 429 { // Retry if rate limited
-			return nil, true, err
-		}
-		return nil, false, err
+		return nil, withStatus(fmt.Errorf("retrieving time series: %w", errorDetail(err)), httpResponse)
 	}
-	return ts, false, nil
+	return ts, nil
 }
 
-func (cl *Client) GetTimeSeriesSampling(ctx context.Context, propertiesToImport []string, from, to time.Time, interval int32) (*iotclient.ArduinoSeriesBatchSampled, bool, error) {
+// GetRawTimeSeriesByProperties queries the samples of the given properties as stored, without any aggregation,
+// sorted by time. At most RawSeriesLimit samples are returned per property.
+func (cl *Client) GetRawTimeSeriesByProperties(ctx context.Context, propertyIDs []string, from, to time.Time) (*iotclient.ArduinoSeriesRawBatch, error) {
+	ctx, cancel, err := cl.requestContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+
+	limit := int64(RawSeriesLimit)
+	sort := "ASC"
+	requests := []iotclient.BatchQueryRawRequestMediaV1{}
+	for _, propId := range propertyIDs {
+		requests = append(requests, iotclient.BatchQueryRawRequestMediaV1{
+			From:        &from,
+			Q:           fmt.Sprintf("property.%s", propId),
+			SeriesLimit: &limit,
+			Sort:        &sort,
+			To:          &to,
+		})
+	}
+
+	if len(requests) == 0 {
+		return nil, fmt.Errorf("no valid properties provided")
+	}
+
+	batchQueryRawRequestsMediaV1 := iotclient.BatchQueryRawRequestsMediaV1{
+		Requests:    requests,
+		RespVersion: 1,
+	}
 
-	ctx, err := ctxWithToken(ctx, cl.token)
+	request := cl.api.SeriesV2Api.SeriesV2BatchQueryRaw(ctx)
+	request = request.BatchQueryRawRequestsMediaV1(batchQueryRawRequestsMediaV1)
+	ts, httpResponse, err := cl.api.SeriesV2Api.SeriesV2BatchQueryRawExecute(request)
 	if err != nil {
-		return nil, false, err
+		return nil, withStatus(fmt.Errorf("retrieving raw time series: %w", errorDetail(err)), httpResponse)
 	}
+	return ts, nil
+}
+
+func (cl *Client) GetTimeSeriesSampling(ctx context.Context, propertiesToImport []string, from, to time.Time, interval int32) (*iotclient.ArduinoSeriesBatchSampled, error) {
+
+	ctx, cancel, err := cl.requestContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
 
 	requests := []iotclient.BatchQuerySampledRequestMediaV1{}
 
@@ -145,7 +342,7 @@ func (cl *Client) GetTimeSeriesSampling(ctx context.Context, propertiesToImport
 	}
 
 	if len(requests) == 0 {
-		return nil, false, fmt.Errorf("no valid properties provided")
+		return nil, fmt.Errorf("no valid properties provided")
 	}
 
 	batchQueryRequestsMediaV1 := iotclient.BatchQuerySampledRequestsMediaV1{
@@ -156,20 +353,27 @@ func (cl *Client) GetTimeSeriesSampling(ctx context.Context, propertiesToImport
 	request = request.BatchQuerySampledRequestsMediaV1(batchQueryRequestsMediaV1)
 	ts, httpResponse, err := cl.api.SeriesV2Api.SeriesV2BatchQuerySamplingExecute(request)
 	if err != nil {
-		err = fmt.Errorf("retrieving time series: %w", errorDetail(err))
-		if httpResponse != nil && httpResponse.StatusCode == 429 { // Retry if rate limited
-			return nil, true, err
-		}
-		return nil, false, err
+		return nil, withStatus(fmt.Errorf("retrieving time series: %w", errorDetail(err)), httpResponse)
 	}
-	return ts, false, nil
+	return ts, nil
 }
 
-func (cl *Client) setup(client, secret, organizationId string) error {
-	baseURL := GetArduinoAPIBaseURL()
+// requestContext returns the context of a call, carrying the client token and bounded by the request timeout.
+// The returned cancel func must be called when the call is done.
+func (cl *Client) requestContext(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	ctx, err := ctxWithToken(ctx, cl.token)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cl.requestTimeout <= 0 {
+		return ctx, func() {}, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, cl.requestTimeout)
+	return ctx, cancel, nil
+}
 
-	// Configure a token source given the user's credentials.
-	cl.token = NewUserTokenSource(client, secret, baseURL, organizationId)
+func (cl *Client) setup(organizationId string) error {
+	baseURL := GetArduinoAPIBaseURL()
 
 	config := iotclient.NewConfiguration()
 	if organizationId != "" {
@@ -186,12 +390,20 @@ func (cl *Client) setup(client, secret, organizationId string) error {
 	return nil
 }
 
-// PropertiesDefinition returns properties definition from Arduino IoT Cloud.
+// PropertiesDefinition returns properties definition from Arduino IoT Cloud, or from the property types
+// cache if any.
 func (cl *Client) PropertiesDefinition(ctx context.Context) (map[string]iotclient.ArduinoPropertytype, error) {
-	ctx, err := ctxWithToken(ctx, cl.token)
+	if cl.typesCache != nil {
+		if pTypes, ok := cl.typesCache.get(cl.organization); ok {
+			return pTypes, nil
+		}
+	}
+
+	ctx, cancel, err := cl.requestContext(ctx)
 	if err != nil {
 		return nil, err
 	}
+	defer cancel()
 
 	request := cl.api.PropertyTypesV1Api.PropertyTypesV1ListTypes(ctx)
 	types, _, err := cl.api.PropertyTypesV1Api.PropertyTypesV1ListTypesExecute(request)
@@ -204,5 +416,21 @@ func (cl *Client) PropertiesDefinition(ctx context.Context) (map[string]iotclien
 	for _, t := range types {
 		pTypes[t.Type] = t
 	}
+	if cl.typesCache != nil {
+		cl.typesCache.set(cl.organization, pTypes)
+	}
 	return pTypes, nil
 }
+
+// CheckToken fetches a token with the client credentials, without calling any API.
+func (cl *Client) CheckToken(ctx context.Context) error {
+	_, err := ctxWithToken(ctx, cl.token)
+	return err
+}
+
+// InvalidatePropertiesDefinition drops cached properties definition, if any, so that they are fetched again.
+func (cl *Client) InvalidatePropertiesDefinition() {
+	if cl.typesCache != nil {
+		cl.typesCache.Invalidate(cl.organization)
+	}
+}
@@ -0,0 +1,168 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package iot
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	iotclient "github.com/arduino/iot-client-go/v2"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func TestTagCombinations(t *testing.T) {
+	// Same key values are OR'd, different keys are AND'd
+	assert.Equal(t, [][]string{
+		{"env:prod", "region:eu"},
+		{"env:staging", "region:eu"},
+	}, tagCombinations(map[string][]string{"env": {"prod", "staging"}, "region": {"eu"}}))
+
+	assert.Equal(t, [][]string{
+		{"env:prod", "region:eu"},
+		{"env:prod", "region:us"},
+		{"env:staging", "region:eu"},
+		{"env:staging", "region:us"},
+	}, tagCombinations(map[string][]string{"env": {"prod", "staging"}, "region": {"eu", "us"}}))
+
+	assert.Nil(t, tagCombinations(map[string][]string{}))
+	assert.Nil(t, tagCombinations(map[string][]string{"env": {}}))
+}
+
+func TestPropertyTypesCache(t *testing.T) {
+	now := time.Date(2024, 5, 5, 12, 0, 0, 0, time.UTC)
+	cache := NewPropertyTypesCache(time.Hour)
+	cache.now = func() time.Time { return now }
+	types := map[string]iotclient.ArduinoPropertytype{"FLOAT": {Type: "FLOAT"}}
+
+	cache.set("org", types)
+	cached, ok := cache.get("org")
+	assert.True(t, ok)
+	assert.Equal(t, types, cached)
+	_, ok = cache.get("other-org")
+	assert.False(t, ok)
+
+	now = now.Add(time.Hour)
+	_, ok = cache.get("org")
+	assert.False(t, ok, "stale definitions must be fetched again")
+
+	cache.set("org", types)
+	cache.Invalidate("org")
+	_, ok = cache.get("org")
+	assert.False(t, ok)
+}
+
+func TestNewClientWithTokenSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		assert.Equal(t, "org-id", r.Header.Get("X-Organization"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[{"type":"FLOAT","name":"Floating point number","declaration":"float","assistants":[],"tags":[]}]`))
+	}))
+	defer server.Close()
+	t.Setenv("IOT_API_URL", server.URL)
+
+	cl, err := NewClientWithTokenSource(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"}), "org-id")
+	assert.NoError(t, err)
+	types, err := cl.PropertiesDefinition(context.Background())
+	assert.NoError(t, err)
+	assert.Contains(t, types, "FLOAT")
+}
+
+func TestWithStatus(t *testing.T) {
+	err := errors.New("retrieving time series: 429 Too Many Requests")
+	rateLimited := withStatus(err, &http.Response{StatusCode: http.StatusTooManyRequests})
+	assert.ErrorIs(t, rateLimited, ErrRateLimited)
+	assert.ErrorIs(t, rateLimited, err)
+	assert.Equal(t, err.Error(), rateLimited.Error())
+
+	assert.ErrorIs(t, withStatus(err, &http.Response{StatusCode: http.StatusUnauthorized}), ErrUnauthorized)
+	assert.Equal(t, err, withStatus(err, &http.Response{StatusCode: http.StatusBadRequest}))
+	assert.Equal(t, err, withStatus(err, nil))
+}
+
+func TestClient_contextCancellation(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Stuck until the client gives up
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+	t.Setenv("IOT_API_URL", server.URL)
+	token := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"})
+
+	cl, err := NewClientWithTokenSource(token, "")
+	assert.NoError(t, err)
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls := map[string]func(ctx context.Context) error{
+		"ThingList": func(ctx context.Context) error {
+			_, err := cl.ThingList(ctx, nil, nil, true, nil)
+			return err
+		},
+		"GetTimeSeriesByThing": func(ctx context.Context) error {
+			_, err := cl.GetTimeSeriesByThing(ctx, "thing", time.Now().Add(-time.Hour), time.Now(), 300, "")
+			return err
+		},
+		"GetTimeSeriesSampling": func(ctx context.Context) error {
+			_, err := cl.GetTimeSeriesSampling(ctx, []string{"property"}, time.Now().Add(-time.Hour), time.Now(), 300)
+			return err
+		},
+		"PropertiesDefinition": func(ctx context.Context) error {
+			_, err := cl.PropertiesDefinition(ctx)
+			return err
+		},
+	}
+	for name, call := range calls {
+		assert.ErrorIs(t, call(cancelled), context.Canceled, name)
+	}
+
+	// In flight calls are bounded by the request timeout
+	cl, err = NewClientWithTokenSource(token, "", WithRequestTimeout(50*time.Millisecond))
+	assert.NoError(t, err)
+	start := time.Now()
+	_, err = cl.GetTimeSeriesByThing(context.Background(), "thing", time.Now().Add(-time.Hour), time.Now(), 300, "")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestClient_thingListRequestTimeout(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		time.Sleep(30 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("[]"))
+	}))
+	defer server.Close()
+	t.Setenv("IOT_API_URL", server.URL)
+	token := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"})
+
+	// The timeout bounds each request, not the listing of all tag combinations
+	cl, err := NewClientWithTokenSource(token, "", WithRequestTimeout(100*time.Millisecond))
+	assert.NoError(t, err)
+	_, err = cl.ThingList(context.Background(), nil, nil, true, map[string][]string{"site": {"a", "b", "c", "d", "e"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 5, requests)
+}
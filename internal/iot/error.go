@@ -17,11 +17,51 @@ package iot
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 
 	iotclient "github.com/arduino/iot-client-go/v2"
 )
 
+// Errors of Arduino IoT Cloud requests, to be checked with errors.Is
+var (
+	// ErrRateLimited is returned when requests exceed the API rate limit, they can be retried later
+	ErrRateLimited = errors.New("rate limited")
+	// ErrUnauthorized is returned when the API rejects the token of the request
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrWrongCredentials is returned when no token can be obtained with the client credentials
+	ErrWrongCredentials = errors.New("wrong credentials")
+)
+
+// statusError is an error of a request matching the typed error of its HTTP status, keeping its message.
+type statusError struct {
+	err    error
+	status error
+}
+
+func (e *statusError) Error() string {
+	return e.err.Error()
+}
+
+func (e *statusError) Unwrap() []error {
+	return []error{e.err, e.status}
+}
+
+// withStatus makes err match the typed error of the HTTP status of the response, if any.
+func withStatus(err error, resp *http.Response) error {
+	if resp == nil {
+		return err
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return &statusError{err: err, status: ErrRateLimited}
+	case http.StatusUnauthorized:
+		return &statusError{err: err, status: ErrUnauthorized}
+	}
+	return err
+}
+
 // errorDetail takes a generic iot-client-go error
 // and tries to return a more detailed error.
 func errorDetail(err error) error {
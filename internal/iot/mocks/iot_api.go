@@ -1,15 +1,14 @@
-// Code generated by mockery v2.46.0. DO NOT EDIT.
+// Code generated by mockery v2.53.3. DO NOT EDIT.
 
 package mocks
 
 import (
 	context "context"
 
-	mock "github.com/stretchr/testify/mock"
-
 	time "time"
 
 	v2 "github.com/arduino/iot-client-go/v2"
+	mock "github.com/stretchr/testify/mock"
 )
 
 // API is an autogenerated mock type for the API type
@@ -17,45 +16,128 @@ type API struct {
 	mock.Mock
 }
 
-// GetTimeSeriesByThing provides a mock function with given fields: ctx, thingID, from, to, interval
-func (_m *API) GetTimeSeriesByThing(ctx context.Context, thingID string, from time.Time, to time.Time, interval int64) (*v2.ArduinoSeriesBatch, bool, error) {
-	ret := _m.Called(ctx, thingID, from, to, interval)
+// GetAggregatedTimeSeriesByThing provides a mock function with given fields: ctx, thingID, from, to, interval, aggregations
+func (_m *API) GetAggregatedTimeSeriesByThing(ctx context.Context, thingID string, from time.Time, to time.Time, interval int64, aggregations []string) (*v2.ArduinoSeriesBatch, error) {
+	ret := _m.Called(ctx, thingID, from, to, interval, aggregations)
 
 	if len(ret) == 0 {
-		panic("no return value specified for GetTimeSeriesByThing")
+		panic("no return value specified for GetAggregatedTimeSeriesByThing")
 	}
 
 	var r0 *v2.ArduinoSeriesBatch
-	var r1 bool
-	var r2 error
-	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time, time.Time, int64) (*v2.ArduinoSeriesBatch, bool, error)); ok {
-		return rf(ctx, thingID, from, to, interval)
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time, time.Time, int64, []string) (*v2.ArduinoSeriesBatch, error)); ok {
+		return rf(ctx, thingID, from, to, interval, aggregations)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time, time.Time, int64) *v2.ArduinoSeriesBatch); ok {
-		r0 = rf(ctx, thingID, from, to, interval)
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time, time.Time, int64, []string) *v2.ArduinoSeriesBatch); ok {
+		r0 = rf(ctx, thingID, from, to, interval, aggregations)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*v2.ArduinoSeriesBatch)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, string, time.Time, time.Time, int64) bool); ok {
-		r1 = rf(ctx, thingID, from, to, interval)
+	if rf, ok := ret.Get(1).(func(context.Context, string, time.Time, time.Time, int64, []string) error); ok {
+		r1 = rf(ctx, thingID, from, to, interval, aggregations)
 	} else {
-		r1 = ret.Get(1).(bool)
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetRawTimeSeriesByProperties provides a mock function with given fields: ctx, propertyIDs, from, to
+func (_m *API) GetRawTimeSeriesByProperties(ctx context.Context, propertyIDs []string, from time.Time, to time.Time) (*v2.ArduinoSeriesRawBatch, error) {
+	ret := _m.Called(ctx, propertyIDs, from, to)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetRawTimeSeriesByProperties")
 	}
 
-	if rf, ok := ret.Get(2).(func(context.Context, string, time.Time, time.Time, int64) error); ok {
-		r2 = rf(ctx, thingID, from, to, interval)
+	var r0 *v2.ArduinoSeriesRawBatch
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []string, time.Time, time.Time) (*v2.ArduinoSeriesRawBatch, error)); ok {
+		return rf(ctx, propertyIDs, from, to)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []string, time.Time, time.Time) *v2.ArduinoSeriesRawBatch); ok {
+		r0 = rf(ctx, propertyIDs, from, to)
 	} else {
-		r2 = ret.Error(2)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*v2.ArduinoSeriesRawBatch)
+		}
 	}
 
-	return r0, r1, r2
+	if rf, ok := ret.Get(1).(func(context.Context, []string, time.Time, time.Time) error); ok {
+		r1 = rf(ctx, propertyIDs, from, to)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetTimeSeriesByProperties provides a mock function with given fields: ctx, aggregations, from, to, interval
+func (_m *API) GetTimeSeriesByProperties(ctx context.Context, aggregations map[string]string, from time.Time, to time.Time, interval int64) (*v2.ArduinoSeriesBatch, error) {
+	ret := _m.Called(ctx, aggregations, from, to, interval)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetTimeSeriesByProperties")
+	}
+
+	var r0 *v2.ArduinoSeriesBatch
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, map[string]string, time.Time, time.Time, int64) (*v2.ArduinoSeriesBatch, error)); ok {
+		return rf(ctx, aggregations, from, to, interval)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, map[string]string, time.Time, time.Time, int64) *v2.ArduinoSeriesBatch); ok {
+		r0 = rf(ctx, aggregations, from, to, interval)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*v2.ArduinoSeriesBatch)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, map[string]string, time.Time, time.Time, int64) error); ok {
+		r1 = rf(ctx, aggregations, from, to, interval)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetTimeSeriesByThing provides a mock function with given fields: ctx, thingID, from, to, interval, aggregation
+func (_m *API) GetTimeSeriesByThing(ctx context.Context, thingID string, from time.Time, to time.Time, interval int64, aggregation string) (*v2.ArduinoSeriesBatch, error) {
+	ret := _m.Called(ctx, thingID, from, to, interval, aggregation)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetTimeSeriesByThing")
+	}
+
+	var r0 *v2.ArduinoSeriesBatch
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time, time.Time, int64, string) (*v2.ArduinoSeriesBatch, error)); ok {
+		return rf(ctx, thingID, from, to, interval, aggregation)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time, time.Time, int64, string) *v2.ArduinoSeriesBatch); ok {
+		r0 = rf(ctx, thingID, from, to, interval, aggregation)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*v2.ArduinoSeriesBatch)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, time.Time, time.Time, int64, string) error); ok {
+		r1 = rf(ctx, thingID, from, to, interval, aggregation)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
 }
 
 // GetTimeSeriesSampling provides a mock function with given fields: ctx, propertiesToImport, from, to, interval
-func (_m *API) GetTimeSeriesSampling(ctx context.Context, propertiesToImport []string, from time.Time, to time.Time, interval int32) (*v2.ArduinoSeriesBatchSampled, bool, error) {
+func (_m *API) GetTimeSeriesSampling(ctx context.Context, propertiesToImport []string, from time.Time, to time.Time, interval int32) (*v2.ArduinoSeriesBatchSampled, error) {
 	ret := _m.Called(ctx, propertiesToImport, from, to, interval)
 
 	if len(ret) == 0 {
@@ -63,9 +145,8 @@ func (_m *API) GetTimeSeriesSampling(ctx context.Context, propertiesToImport []s
 	}
 
 	var r0 *v2.ArduinoSeriesBatchSampled
-	var r1 bool
-	var r2 error
-	if rf, ok := ret.Get(0).(func(context.Context, []string, time.Time, time.Time, int32) (*v2.ArduinoSeriesBatchSampled, bool, error)); ok {
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []string, time.Time, time.Time, int32) (*v2.ArduinoSeriesBatchSampled, error)); ok {
 		return rf(ctx, propertiesToImport, from, to, interval)
 	}
 	if rf, ok := ret.Get(0).(func(context.Context, []string, time.Time, time.Time, int32) *v2.ArduinoSeriesBatchSampled); ok {
@@ -76,19 +157,13 @@ func (_m *API) GetTimeSeriesSampling(ctx context.Context, propertiesToImport []s
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, []string, time.Time, time.Time, int32) bool); ok {
+	if rf, ok := ret.Get(1).(func(context.Context, []string, time.Time, time.Time, int32) error); ok {
 		r1 = rf(ctx, propertiesToImport, from, to, interval)
 	} else {
-		r1 = ret.Get(1).(bool)
-	}
-
-	if rf, ok := ret.Get(2).(func(context.Context, []string, time.Time, time.Time, int32) error); ok {
-		r2 = rf(ctx, propertiesToImport, from, to, interval)
-	} else {
-		r2 = ret.Error(2)
+		r1 = ret.Error(1)
 	}
 
-	return r0, r1, r2
+	return r0, r1
 }
 
 // PropertiesDefinition provides a mock function with given fields: ctx
@@ -122,7 +197,7 @@ func (_m *API) PropertiesDefinition(ctx context.Context) (map[string]v2.ArduinoP
 }
 
 // ThingList provides a mock function with given fields: ctx, ids, device, props, tags
-func (_m *API) ThingList(ctx context.Context, ids []string, device *string, props bool, tags map[string]string) ([]v2.ArduinoThing, error) {
+func (_m *API) ThingList(ctx context.Context, ids []string, device *string, props bool, tags map[string][]string) ([]v2.ArduinoThing, error) {
 	ret := _m.Called(ctx, ids, device, props, tags)
 
 	if len(ret) == 0 {
@@ -131,10 +206,10 @@ func (_m *API) ThingList(ctx context.Context, ids []string, device *string, prop
 
 	var r0 []v2.ArduinoThing
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, []string, *string, bool, map[string]string) ([]v2.ArduinoThing, error)); ok {
+	if rf, ok := ret.Get(0).(func(context.Context, []string, *string, bool, map[string][]string) ([]v2.ArduinoThing, error)); ok {
 		return rf(ctx, ids, device, props, tags)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, []string, *string, bool, map[string]string) []v2.ArduinoThing); ok {
+	if rf, ok := ret.Get(0).(func(context.Context, []string, *string, bool, map[string][]string) []v2.ArduinoThing); ok {
 		r0 = rf(ctx, ids, device, props, tags)
 	} else {
 		if ret.Get(0) != nil {
@@ -142,7 +217,7 @@ func (_m *API) ThingList(ctx context.Context, ids []string, device *string, prop
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, []string, *string, bool, map[string]string) error); ok {
+	if rf, ok := ret.Get(1).(func(context.Context, []string, *string, bool, map[string][]string) error); ok {
 		r1 = rf(ctx, ids, device, props, tags)
 	} else {
 		r1 = ret.Error(1)
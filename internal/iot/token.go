@@ -19,6 +19,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 	"os"
 	"strings"
@@ -38,31 +39,44 @@ func GetArduinoAPIBaseURL() string {
 
 // Build a new token source to forge api JWT tokens based on provided credentials
 func NewUserTokenSource(client, secret, baseURL, organizationId string) oauth2.TokenSource {
+	config := tokenConfig(client, secret, baseURL, organizationId)
+
+	// Retrieve a token source that allows to retrieve tokens
+	// with an automatic refresh mechanism.
+	return config.TokenSource(context.Background())
+}
+
+// tokenConfig builds the OAuth2 configuration. Token URL and audience both follow the base URL,
+// so that environments other than production (e.g. dev) work end to end.
+func tokenConfig(client, secret, baseURL, organizationId string) cc.Config {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+
 	// We need to pass the additional "audience" var to request an access token.
 	additionalValues := url.Values{}
-	additionalValues.Add("audience", "https://api2.arduino.cc/iot")
+	additionalValues.Add("audience", baseURL+"/iot")
 	if organizationId != "" {
 		additionalValues.Add("organization_id", organizationId)
 	}
 	// Set up OAuth2 configuration.
-	config := cc.Config{
+	return cc.Config{
 		ClientID:       client,
 		ClientSecret:   secret,
 		TokenURL:       baseURL + "/iot/v1/clients/token",
 		EndpointParams: additionalValues,
 	}
-
-	// Retrieve a token source that allows to retrieve tokens
-	// with an automatic refresh mechanism.
-	return config.TokenSource(context.Background())
 }
 
 func ctxWithToken(ctx context.Context, src oauth2.TokenSource) (context.Context, error) {
+	// Token retrieval can't be cancelled, don't start it for a call already cancelled
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	// Retrieve a valid token from the src.
 	_, err := src.Token()
 	if err != nil {
-		if strings.Contains(err.Error(), "401") {
-			return nil, errors.New("wrong credentials")
+		var retrieveErr *oauth2.RetrieveError
+		if errors.As(err, &retrieveErr) && retrieveErr.Response != nil && retrieveErr.Response.StatusCode == http.StatusUnauthorized {
+			return nil, ErrWrongCredentials
 		}
 		return nil, fmt.Errorf("cannot retrieve a valid token: %w", err)
 	}
@@ -0,0 +1,56 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package iot
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func TestTokenConfig_audienceTracksBaseURL(t *testing.T) {
+	config := tokenConfig("client", "secret", "https://api2.arduino.cc", "")
+	assert.Equal(t, "https://api2.arduino.cc/iot/v1/clients/token", config.TokenURL)
+	assert.Equal(t, "https://api2.arduino.cc/iot", config.EndpointParams.Get("audience"))
+	assert.False(t, config.EndpointParams.Has("organization_id"))
+
+	config = tokenConfig("client", "secret", "https://api2.oniudra.cc/", "org-id")
+	assert.Equal(t, "https://api2.oniudra.cc/iot/v1/clients/token", config.TokenURL)
+	assert.Equal(t, "https://api2.oniudra.cc/iot", config.EndpointParams.Get("audience"))
+	assert.Equal(t, "org-id", config.EndpointParams.Get("organization_id"))
+}
+
+type failingTokenSource struct {
+	err error
+}
+
+func (s failingTokenSource) Token() (*oauth2.Token, error) {
+	return nil, s.err
+}
+
+func TestCtxWithToken_wrongCredentials(t *testing.T) {
+	unauthorized := &oauth2.RetrieveError{Response: &http.Response{StatusCode: http.StatusUnauthorized}}
+	_, err := ctxWithToken(context.Background(), failingTokenSource{err: unauthorized})
+	assert.ErrorIs(t, err, ErrWrongCredentials)
+
+	_, err = ctxWithToken(context.Background(), failingTokenSource{err: errors.New("connection refused")})
+	assert.NotErrorIs(t, err, ErrWrongCredentials)
+	assert.EqualError(t, err, "cannot retrieve a valid token: connection refused")
+}
@@ -15,6 +15,8 @@
 
 package iot
 
+import iotclient "github.com/arduino/iot-client-go/v2"
+
 type Type string
 
 const (
@@ -274,6 +276,12 @@ func IsPropertyLocation(pType string) bool {
 	return Type(pType) == Location
 }
 
+// IsAttributeProperty tells if the property holds a static value, such as a device configuration, rather than
+// a time series: it changes only when set and Arduino IoT Cloud doesn't persist its history.
+func IsAttributeProperty(property iotclient.ArduinoProperty) bool {
+	return property.UpdateStrategy == "ON_CHANGE" && property.Persist != nil && !*property.Persist
+}
+
 func IsPropertyBool(pType string) bool {
 	for _, tpy := range booleanPropertyTypes {
 		if pType == string(tpy) {
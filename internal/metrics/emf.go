@@ -0,0 +1,110 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package metrics
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+)
+
+// Run holds the metrics of an import run
+type Run struct {
+	ThingsProcessed int64
+	PointsWritten   int64
+	ThrottleRetries int64
+	Errors          int64
+}
+
+// Emitter publishes the metrics of an import run
+type Emitter interface {
+	Emit(run Run) error
+}
+
+// EMFEmitter writes run metrics as CloudWatch Embedded Metric Format log lines.
+// Lambda sends them to CloudWatch Logs, which extracts the metrics without any API call.
+type EMFEmitter struct {
+	namespace string
+	stack     string
+	out       io.Writer
+	now       func() time.Time
+}
+
+// NewEMF returns an emitter writing to stdout the metrics of the given namespace, with the stack as dimension.
+func NewEMF(namespace, stack string) *EMFEmitter {
+	return &EMFEmitter{
+		namespace: namespace,
+		stack:     stack,
+		out:       os.Stdout,
+		now:       time.Now,
+	}
+}
+
+type emfMetric struct {
+	Name string `json:"Name"`
+	Unit string `json:"Unit"`
+}
+
+type emfDirective struct {
+	Namespace  string      `json:"Namespace"`
+	Dimensions [][]string  `json:"Dimensions"`
+	Metrics    []emfMetric `json:"Metrics"`
+}
+
+type emfMetadata struct {
+	Timestamp         int64          `json:"Timestamp"`
+	CloudWatchMetrics []emfDirective `json:"CloudWatchMetrics"`
+}
+
+type emfRecord struct {
+	AWS             emfMetadata `json:"_aws"`
+	Stack           string      `json:"Stack"`
+	ThingsProcessed int64       `json:"ThingsProcessed"`
+	PointsWritten   int64       `json:"PointsWritten"`
+	ThrottleRetries int64       `json:"ThrottleRetries"`
+	Errors          int64       `json:"Errors"`
+}
+
+// Emit writes the run metrics as a single EMF log line
+func (e *EMFEmitter) Emit(run Run) error {
+	record := emfRecord{
+		AWS: emfMetadata{
+			Timestamp: e.now().UnixMilli(),
+			CloudWatchMetrics: []emfDirective{{
+				Namespace:  e.namespace,
+				Dimensions: [][]string{{"Stack"}},
+				Metrics: []emfMetric{
+					{Name: "ThingsProcessed", Unit: "Count"},
+					{Name: "PointsWritten", Unit: "Count"},
+					{Name: "ThrottleRetries", Unit: "Count"},
+					{Name: "Errors", Unit: "Count"},
+				},
+			}},
+		},
+		Stack:           e.stack,
+		ThingsProcessed: run.ThingsProcessed,
+		PointsWritten:   run.PointsWritten,
+		ThrottleRetries: run.ThrottleRetries,
+		Errors:          run.Errors,
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = e.out.Write(append(line, '\n'))
+	return err
+}
@@ -0,0 +1,54 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package metrics
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEMFEmitter_Emit(t *testing.T) {
+	var out bytes.Buffer
+	emitter := NewEMF("Arduino/SiteWiseImporter", "prod")
+	emitter.out = &out
+	emitter.now = func() time.Time { return time.UnixMilli(1700000000000) }
+
+	err := emitter.Emit(Run{ThingsProcessed: 3, PointsWritten: 120, ThrottleRetries: 2, Errors: 1})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"_aws": {
+			"Timestamp": 1700000000000,
+			"CloudWatchMetrics": [{
+				"Namespace": "Arduino/SiteWiseImporter",
+				"Dimensions": [["Stack"]],
+				"Metrics": [
+					{"Name": "ThingsProcessed", "Unit": "Count"},
+					{"Name": "PointsWritten", "Unit": "Count"},
+					{"Name": "ThrottleRetries", "Unit": "Count"},
+					{"Name": "Errors", "Unit": "Count"}
+				]
+			}]
+		},
+		"Stack": "prod",
+		"ThingsProcessed": 3,
+		"PointsWritten": 120,
+		"ThrottleRetries": 2,
+		"Errors": 1
+	}`, out.String())
+}
@@ -0,0 +1,114 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+//go:generate mockery --name API --filename object_store_api.go
+type API interface {
+	Put(ctx context.Context, bucket, key string, body []byte) error
+	Get(ctx context.Context, bucket, key string) ([]byte, error)
+	List(ctx context.Context, bucket, prefix string) ([]string, error)
+}
+
+type s3API interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+}
+
+// S3Store writes objects to S3 buckets.
+type S3Store struct {
+	svc s3API
+}
+
+func New() (*S3Store, error) {
+	awsOpts := []func(*config.LoadOptions) error{}
+
+	cfg, err := config.LoadDefaultConfig(
+		context.Background(),
+		awsOpts...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &S3Store{
+		svc: s3.NewFromConfig(cfg),
+	}, nil
+}
+
+// Put writes the object with the given key, replacing it if already present.
+func (s *S3Store) Put(ctx context.Context, bucket, key string, body []byte) error {
+	_, err := s.svc.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(body),
+	})
+	if err != nil {
+		return fmt.Errorf("writing s3://%s/%s: %w", bucket, key, err)
+	}
+	return nil
+}
+
+// Get reads the object with the given key.
+func (s *S3Store) Get(ctx context.Context, bucket, key string) ([]byte, error) {
+	out, err := s.svc.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading s3://%s/%s: %w", bucket, key, err)
+	}
+	defer out.Body.Close()
+	body, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading s3://%s/%s: %w", bucket, key, err)
+	}
+	return body, nil
+}
+
+// List returns the keys of the objects whose key starts with prefix, following pagination.
+func (s *S3Store) List(ctx context.Context, bucket, prefix string) ([]string, error) {
+	var keys []string
+	var token *string
+	for {
+		out, err := s.svc.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+			Bucket:            aws.String(bucket),
+			Prefix:            aws.String(prefix),
+			ContinuationToken: token,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("listing s3://%s/%s: %w", bucket, prefix, err)
+		}
+		for _, object := range out.Contents {
+			keys = append(keys, aws.ToString(object.Key))
+		}
+		if out.NextContinuationToken == nil {
+			return keys, nil
+		}
+		token = out.NextContinuationToken
+	}
+}
@@ -0,0 +1,113 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeS3 records objects written to buckets, serving objects and listing pages
+type fakeS3 struct {
+	puts      []*s3.PutObjectInput
+	bodies    []string
+	objects   map[string]string
+	pages     []*s3.ListObjectsV2Output
+	listCalls []*s3.ListObjectsV2Input
+	err       error
+}
+
+func (f *fakeS3) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	body, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.puts = append(f.puts, params)
+	f.bodies = append(f.bodies, string(body))
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (f *fakeS3) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	body, ok := f.objects[*params.Key]
+	if !ok {
+		return nil, errors.New("no such key")
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(body))}, nil
+}
+
+func (f *fakeS3) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	f.listCalls = append(f.listCalls, params)
+	page := f.pages[0]
+	f.pages = f.pages[1:]
+	return page, nil
+}
+
+func TestPut_writesObject(t *testing.T) {
+	svc := &fakeS3{}
+	store := &S3Store{svc: svc}
+
+	err := store.Put(context.Background(), "backfill-bucket", "runs/part-0000.csv", []byte("a,b\n"))
+	assert.NoError(t, err)
+	assert.Len(t, svc.puts, 1)
+	assert.Equal(t, "backfill-bucket", *svc.puts[0].Bucket)
+	assert.Equal(t, "runs/part-0000.csv", *svc.puts[0].Key)
+	assert.Equal(t, "a,b\n", svc.bodies[0])
+}
+
+func TestPut_returnsStoreError(t *testing.T) {
+	store := &S3Store{svc: &fakeS3{err: errors.New("access denied")}}
+
+	err := store.Put(context.Background(), "backfill-bucket", "runs/part-0000.csv", []byte("a,b\n"))
+	assert.ErrorContains(t, err, "s3://backfill-bucket/runs/part-0000.csv")
+	assert.ErrorContains(t, err, "access denied")
+}
+
+func TestGet_readsObject(t *testing.T) {
+	store := &S3Store{svc: &fakeS3{objects: map[string]string{"error-reports/job-1/errors.csv": "row\n"}}}
+
+	body, err := store.Get(context.Background(), "backfill-bucket", "error-reports/job-1/errors.csv")
+	assert.NoError(t, err)
+	assert.Equal(t, "row\n", string(body))
+
+	_, err = store.Get(context.Background(), "backfill-bucket", "missing.csv")
+	assert.ErrorContains(t, err, "s3://backfill-bucket/missing.csv")
+}
+
+func TestList_followsContinuationToken(t *testing.T) {
+	svc := &fakeS3{pages: []*s3.ListObjectsV2Output{
+		{Contents: []types.Object{{Key: aws.String("error-reports/a.csv")}}, NextContinuationToken: aws.String("page-2")},
+		{Contents: []types.Object{{Key: aws.String("error-reports/b.csv")}}},
+	}}
+	store := &S3Store{svc: svc}
+
+	keys, err := store.List(context.Background(), "backfill-bucket", "error-reports")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"error-reports/a.csv", "error-reports/b.csv"}, keys)
+	assert.Len(t, svc.listCalls, 2)
+	assert.Nil(t, svc.listCalls[0].ContinuationToken)
+	assert.Equal(t, "page-2", *svc.listCalls[1].ContinuationToken)
+}
@@ -0,0 +1,197 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package parameters
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ArduinoPrefix = "/arduino/sitewise-importer/" + StackName
+	IoTApiKey     = ArduinoPrefix + "/iot/api-key"
+	IoTApiSecret  = ArduinoPrefix + "/iot/api-secret"
+	IoTApiOrgId   = ArduinoPrefix + "/iot/org-id"
+	IoTApiTags    = ArduinoPrefix + "/iot/filter/tags"
+	SamplesReso   = ArduinoPrefix + "/iot/samples-resolution"
+	Scheduling    = ArduinoPrefix + "/iot/scheduling"
+	LastModelSync = ArduinoPrefix + "/iot/last-model-sync"
+
+	SamplesResolutionSeconds           = 300
+	DefaultTimeExtractionWindowMinutes = 30
+	RawResolution                      = "raw"
+	MaxRawExtractionWindowMinutes      = 15
+	// SiteWise rejects data points older than 7 days
+	MaxExtractionWindowMinutes = 7 * 24 * 60
+)
+
+// ImporterParameters are the parameters parsed into ImporterConfig
+var ImporterParameters = []string{
+	IoTApiKey,
+	IoTApiSecret,
+	IoTApiOrgId,
+	IoTApiTags,
+	SamplesReso,
+	Scheduling,
+	LastModelSync,
+}
+
+// Reader reads parameters in batch. It is implemented by ParametersClient.
+type Reader interface {
+	ReadConfigs(params []string, stack string) (map[string]string, error)
+}
+
+// ImporterConfig is the configuration shared by all the importer entrypoints.
+type ImporterConfig struct {
+	ApiKey    string
+	ApiSecret string
+	// Arduino organizations swept by a run, from a comma separated list. Empty for the organization of the API key.
+	OrganizationIds []string
+	// Tags filter, nil if not configured
+	Tags                    *string
+	ResolutionSeconds       int
+	RawResolution           bool
+	ExtractionWindowMinutes int
+	// Last models and assets alignment, nil if never happened
+	LastSync *time.Time
+}
+
+// LoadImporterConfig reads and parses the importer configuration of the given stack.
+func LoadImporterConfig(reader Reader, stack string, logger *logrus.Entry) (*ImporterConfig, error) {
+	values, err := reader.ReadConfigs(ImporterParameters, stack)
+	if err != nil {
+		return nil, err
+	}
+	return ParseImporterConfig(values, logger)
+}
+
+// ParseImporterConfig parses the importer configuration from parameter values, keyed by parameter name.
+// Missing resolution and scheduling fall back to their defaults.
+func ParseImporterConfig(values map[string]string, logger *logrus.Entry) (*ImporterConfig, error) {
+	apikey, okKey := values[IoTApiKey]
+	apiSecret, okSecret := values[IoTApiSecret]
+	if !okKey || !okSecret {
+		return nil, errors.New("key and secret are required")
+	}
+	cfg := &ImporterConfig{
+		ApiKey:          apikey,
+		ApiSecret:       apiSecret,
+		OrganizationIds: parseOrganizationIds(values[IoTApiOrgId]),
+	}
+	if tags, ok := values[IoTApiTags]; ok {
+		cfg.Tags = &tags
+	}
+
+	schedule, ok := values[Scheduling]
+	if !ok {
+		logger.Warnf("Scheduling not available, using default time window of %d minutes", DefaultTimeExtractionWindowMinutes)
+	}
+	var err error
+	cfg.ExtractionWindowMinutes, err = parseExtractionWindow(schedule)
+	if err != nil {
+		return nil, fmt.Errorf("scheduling %s is invalid: %w", schedule, err)
+	}
+
+	resolution, ok := values[SamplesReso]
+	if !ok {
+		logger.Warnf("Samples resolution not available, using default of %d seconds", SamplesResolutionSeconds)
+	}
+	cfg.ResolutionSeconds, cfg.RawResolution, err = ParseResolution(resolution, cfg.ExtractionWindowMinutes)
+	if err != nil {
+		return nil, fmt.Errorf("resolution %s is invalid: %w", resolution, err)
+	}
+
+	if lastSync, err := strconv.ParseInt(values[LastModelSync], 10, 64); err == nil {
+		t := time.Unix(lastSync, 0).UTC()
+		cfg.LastSync = &t
+	}
+	return cfg, nil
+}
+
+// parseExtractionWindow converts the scheduling parameter to the extraction time window in minutes. Besides
+// "5 minutes", "15 minutes" and "1 hour", a number of minutes up to MaxExtractionWindowMinutes is accepted.
+// Other values fall back to DefaultTimeExtractionWindowMinutes.
+func parseExtractionWindow(schedule string) (int, error) {
+	switch schedule {
+	case "5 minutes":
+		return 5, nil
+	case "15 minutes":
+		return 15, nil
+	case "1 hour":
+		return 60, nil
+	}
+	minutes, err := strconv.Atoi(strings.TrimSpace(schedule))
+	if err != nil {
+		return DefaultTimeExtractionWindowMinutes, nil
+	}
+	if minutes <= 0 || minutes > MaxExtractionWindowMinutes {
+		return 0, fmt.Errorf("time window must be between 1 and %d minutes", MaxExtractionWindowMinutes)
+	}
+	return minutes, nil
+}
+
+// Resolutions accepted by ParseResolution, besides the raw keyword
+var resolutionSeconds = map[string]int{
+	"1 minute":   60,
+	"5 minutes":  300,
+	"15 minutes": 900,
+	"1 hour":     3600,
+}
+
+// IsValidResolution tells if the value is a known resolution or the raw keyword, rather than one falling back
+// to the default resolution.
+func IsValidResolution(value string) bool {
+	_, ok := resolutionSeconds[value]
+	return ok || value == RawResolution
+}
+
+// ParseResolution converts the samples resolution parameter to seconds. Unknown values fall back to
+// SamplesResolutionSeconds. The "raw" keyword selects the non-aggregated import mode: it bypasses the
+// 60-3600 seconds validation and requires an extraction window of at most MaxRawExtractionWindowMinutes,
+// as raw samples are not reduced by aggregation and raw queries return a limited number of samples.
+func ParseResolution(value string, extractionWindowMinutes int) (int, bool, error) {
+	if value == RawResolution {
+		if extractionWindowMinutes > MaxRawExtractionWindowMinutes {
+			return 0, false, fmt.Errorf("raw resolution requires a time window of at most %d minutes, got %d", MaxRawExtractionWindowMinutes, extractionWindowMinutes)
+		}
+		return 0, true, nil
+	}
+
+	resolution, ok := resolutionSeconds[value]
+	if !ok {
+		resolution = SamplesResolutionSeconds
+	}
+	if resolution < 60 || resolution > 3600 {
+		return 0, false, errors.New("resolution must be between 60 and 3600")
+	}
+	return resolution, false, nil
+}
+
+// parseOrganizationIds splits a comma separated list of organization ids, dropping blank ones.
+func parseOrganizationIds(value string) []string {
+	var ids []string
+	for _, id := range strings.Split(value, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,140 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package parameters
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseResolution(t *testing.T) {
+	cases := map[string]int{
+		"1 minute":   60,
+		"5 minutes":  300,
+		"15 minutes": 900,
+		"1 hour":     3600,
+		"unknown":    SamplesResolutionSeconds,
+	}
+	for value, expected := range cases {
+		resolution, raw, err := ParseResolution(value, DefaultTimeExtractionWindowMinutes)
+		assert.NoError(t, err, value)
+		assert.False(t, raw, value)
+		assert.Equal(t, expected, resolution, value)
+	}
+}
+
+func TestParseResolution_raw(t *testing.T) {
+	resolution, raw, err := ParseResolution(RawResolution, MaxRawExtractionWindowMinutes)
+	assert.NoError(t, err)
+	assert.True(t, raw)
+	assert.Equal(t, 0, resolution)
+
+	_, _, err = ParseResolution(RawResolution, MaxRawExtractionWindowMinutes+1)
+	assert.Error(t, err)
+}
+
+func TestIsValidResolution(t *testing.T) {
+	assert.True(t, IsValidResolution("5 minutes"))
+	assert.True(t, IsValidResolution(RawResolution))
+	assert.False(t, IsValidResolution("2 minutes"))
+}
+
+func TestParseExtractionWindow(t *testing.T) {
+	cases := map[string]int{
+		"5 minutes":  5,
+		"15 minutes": 15,
+		"1 hour":     60,
+		"10":         10,
+		" 90 ":       90,
+		"10080":      MaxExtractionWindowMinutes,
+		"":           DefaultTimeExtractionWindowMinutes,
+		"2 hours":    DefaultTimeExtractionWindowMinutes,
+	}
+	for value, expected := range cases {
+		minutes, err := parseExtractionWindow(value)
+		assert.NoError(t, err, value)
+		assert.Equal(t, expected, minutes, value)
+	}
+	for _, value := range []string{"0", "-10", "10081"} {
+		_, err := parseExtractionWindow(value)
+		assert.Error(t, err, value)
+	}
+}
+
+type fakeReader map[string]string
+
+func (r fakeReader) ReadConfigs(params []string, stack string) (map[string]string, error) {
+	values := map[string]string{}
+	for _, p := range params {
+		if v, ok := r[p]; ok {
+			values[p] = v
+		}
+	}
+	return values, nil
+}
+
+func TestLoadImporterConfig(t *testing.T) {
+	logger := logrus.NewEntry(logrus.New())
+
+	cfg, err := LoadImporterConfig(fakeReader{
+		IoTApiKey:     "key",
+		IoTApiSecret:  "secret",
+		IoTApiTags:    "env=prod",
+		SamplesReso:   "1 minute",
+		Scheduling:    "1 hour",
+		LastModelSync: "1700000000",
+	}, "stack", logger)
+	assert.NoError(t, err)
+	assert.Equal(t, "key", cfg.ApiKey)
+	assert.Equal(t, "secret", cfg.ApiSecret)
+	assert.Empty(t, cfg.OrganizationIds)
+	if assert.NotNil(t, cfg.Tags) {
+		assert.Equal(t, "env=prod", *cfg.Tags)
+	}
+	assert.Equal(t, 60, cfg.ResolutionSeconds)
+	assert.Equal(t, 60, cfg.ExtractionWindowMinutes)
+	if assert.NotNil(t, cfg.LastSync) {
+		assert.Equal(t, int64(1700000000), cfg.LastSync.Unix())
+	}
+}
+
+func TestLoadImporterConfig_defaults(t *testing.T) {
+	logger := logrus.NewEntry(logrus.New())
+
+	cfg, err := LoadImporterConfig(fakeReader{IoTApiKey: "key", IoTApiSecret: "secret"}, "stack", logger)
+	assert.NoError(t, err)
+	assert.Nil(t, cfg.Tags)
+	assert.Nil(t, cfg.LastSync)
+	assert.Equal(t, SamplesResolutionSeconds, cfg.ResolutionSeconds)
+	assert.Equal(t, DefaultTimeExtractionWindowMinutes, cfg.ExtractionWindowMinutes)
+
+	cfg, err = LoadImporterConfig(fakeReader{IoTApiKey: "key", IoTApiSecret: "secret", IoTApiOrgId: "org1, org2,"}, "stack", logger)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"org1", "org2"}, cfg.OrganizationIds)
+
+	_, err = LoadImporterConfig(fakeReader{IoTApiKey: "key"}, "stack", logger)
+	assert.EqualError(t, err, "key and secret are required")
+
+	// Raw resolution is not allowed with the default time window
+	_, err = LoadImporterConfig(fakeReader{IoTApiKey: "key", IoTApiSecret: "secret", SamplesReso: RawResolution}, "stack", logger)
+	assert.Error(t, err)
+
+	cfg, err = LoadImporterConfig(fakeReader{IoTApiKey: "key", IoTApiSecret: "secret", SamplesReso: RawResolution, Scheduling: "15 minutes"}, "stack", logger)
+	assert.NoError(t, err)
+	assert.True(t, cfg.RawResolution)
+}
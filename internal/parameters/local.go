@@ -0,0 +1,108 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package parameters
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LocalConfigEnv is the environment variable with the path of a local JSON or YAML configuration file
+const LocalConfigEnv = "LOCAL_CONFIG"
+
+// LocalReader reads parameters from environment variables and an optional local JSON or YAML file, for
+// local runs without SSM. File keys are parameter names without the stack prefix, e.g. 'iot/api-key'.
+// Environment variables are named after the same keys, uppercase with '_' as separator, e.g. IOT_API_KEY,
+// and take precedence over the file.
+type LocalReader struct {
+	values map[string]string
+	getenv func(string) string
+}
+
+// NewLocalReader returns a reader of environment variables and of the given file, if not empty.
+func NewLocalReader(path string) (*LocalReader, error) {
+	r := &LocalReader{values: map[string]string{}, getenv: os.Getenv}
+	if path == "" {
+		return r, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading local config: %w", err)
+	}
+	// JSON is valid YAML
+	var values map[string]any
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("parsing local config %s: %w", path, err)
+	}
+	for key, value := range values {
+		r.values[strings.Trim(key, "/")] = fmt.Sprint(value)
+	}
+	return r, nil
+}
+
+// NewReader returns the parameters reader of the test harnesses: a LocalReader when LOCAL_CONFIG is set
+// or no stack is given, SSM otherwise. The Lambda always reads SSM.
+func NewReader(stack string) (Reader, error) {
+	path := os.Getenv(LocalConfigEnv)
+	if path != "" || stack == "" {
+		return NewLocalReader(path)
+	}
+	return New()
+}
+
+// localKey returns the key of a parameter in local configurations, e.g. 'iot/api-key'.
+func localKey(param string) string {
+	return strings.Trim(strings.TrimPrefix(param, ArduinoPrefix), "/")
+}
+
+// localEnv returns the environment variable of a parameter, e.g. IOT_API_KEY.
+func localEnv(param string) string {
+	return strings.ToUpper(strings.NewReplacer("/", "_", "-", "_").Replace(localKey(param)))
+}
+
+func (r *LocalReader) lookup(param string) (string, bool) {
+	if value := r.getenv(localEnv(param)); value != "" {
+		return value, true
+	}
+	value, ok := r.values[localKey(param)]
+	if value == emptyValue {
+		value = ""
+	}
+	return value, ok
+}
+
+func (r *LocalReader) ReadConfig(param, stack string) (*string, error) {
+	value, ok := r.lookup(param)
+	if !ok {
+		return nil, fmt.Errorf("parameter %s not found in local config (key %s, environment variable %s)", param, localKey(param), localEnv(param))
+	}
+	return &value, nil
+}
+
+// ReadConfigs reads the given parameters, returning their values keyed by the parameter names as given.
+// Parameters not found are not part of the result.
+func (r *LocalReader) ReadConfigs(params []string, stack string) (map[string]string, error) {
+	values := make(map[string]string, len(params))
+	for _, param := range params {
+		if value, ok := r.lookup(param); ok {
+			values[param] = value
+		}
+	}
+	return values, nil
+}
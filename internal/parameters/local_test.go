@@ -0,0 +1,66 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package parameters
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalReader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := "iot/api-key: file-key\n/iot/api-secret: file-secret\niot/samples-resolution: 60\niot/filter/tags: <empty>\n"
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	r, err := NewLocalReader(path)
+	assert.NoError(t, err)
+	env := map[string]string{"IOT_API_KEY": "env-key", "IOT_ORG_ID": "org-1"}
+	r.getenv = func(name string) string { return env[name] }
+
+	values, err := r.ReadConfigs(ImporterParameters, "")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		IoTApiKey:    "env-key",
+		IoTApiSecret: "file-secret",
+		IoTApiOrgId:  "org-1",
+		IoTApiTags:   "",
+		SamplesReso:  "60",
+	}, values)
+
+	value, err := r.ReadConfig(IoTApiSecret, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "file-secret", *value)
+	_, err = r.ReadConfig(Scheduling, "")
+	assert.ErrorContains(t, err, "IOT_SCHEDULING")
+}
+
+func TestLocalReader_json(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"iot/api-key": "key", "iot/scheduling": 15}`), 0o600))
+
+	r, err := NewLocalReader(path)
+	assert.NoError(t, err)
+	r.getenv = func(string) string { return "" }
+	values, err := r.ReadConfigs([]string{IoTApiKey, Scheduling}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{IoTApiKey: "key", Scheduling: "15"}, values)
+
+	_, err = NewLocalReader(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
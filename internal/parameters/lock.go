@@ -0,0 +1,147 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package parameters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arduino/aws-sitewise-integration/internal/statestore"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// RunLockKey is the state key recording, as "<epoch seconds> <run id>", the start of the run in progress.
+// Released locks record the epoch, so they are always stale. Without a state table, it is kept in the
+// /arduino/sitewise-importer/{stack-name}/iot/run-lock parameter.
+const RunLockKey = "run-lock"
+
+// ErrRunInProgress is returned when acquiring the run lock held by another run
+var ErrRunInProgress = errors.New("another run is in progress")
+
+// Value of released run locks
+const releasedRunLock = "0"
+
+// RunLock is the run lock held by a run, to be released when the run completes.
+type RunLock struct {
+	store statestore.Store
+	value string
+}
+
+// AcquireRunLock records in the store that the run with the given id started at now is in progress, so that
+// overlapping runs can be detected. It fails with ErrRunInProgress if another run holds the lock since less
+// than staleAfter. Older locks are considered left by runs that didn't complete, and are taken over only if
+// unchanged in the meantime, so that a single one of concurrent runs takes them over.
+func AcquireRunLock(ctx context.Context, store statestore.Store, runID string, now time.Time, staleAfter time.Duration) (*RunLock, error) {
+	current, ok, err := store.GetState(ctx, RunLockKey)
+	if err != nil {
+		return nil, err
+	}
+	var expected *string
+	if ok {
+		startedAt, _, _ := strings.Cut(current, " ")
+		lockedAt, err := strconv.ParseInt(startedAt, 10, 64)
+		if err == nil && now.Sub(time.Unix(lockedAt, 0)) < staleAfter {
+			return nil, fmt.Errorf("%w, started at %s", ErrRunInProgress, time.Unix(lockedAt, 0).UTC().Format(time.RFC3339))
+		}
+		expected = &current
+	}
+	value := strconv.FormatInt(now.Unix(), 10) + " " + runID
+	err = store.PutStateIf(ctx, RunLockKey, value, expected)
+	if errors.Is(err, statestore.ErrConditionFailed) {
+		return nil, fmt.Errorf("%w, lock taken by a concurrent run", ErrRunInProgress)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &RunLock{store: store, value: value}, nil
+}
+
+// Release marks the run in progress as completed, unless its lock has been taken over by another run.
+func (l *RunLock) Release(ctx context.Context) error {
+	err := l.store.PutStateIf(ctx, RunLockKey, releasedRunLock, &l.value)
+	if errors.Is(err, statestore.ErrConditionFailed) {
+		return errors.New("run lock taken over by another run, not released")
+	}
+	return err
+}
+
+// ssmState keeps states in the parameters of a stack, named /arduino/sitewise-importer/{stack-name}/iot/<key>.
+type ssmState struct {
+	c     *ParametersClient
+	stack string
+}
+
+// StateStore returns a store keeping states in the parameters of the stack, for deployments with no state
+// table. Parameters can't be updated conditionally: only conditional puts of keys not set are atomic.
+func (c *ParametersClient) StateStore(stack string) statestore.Store {
+	return &ssmState{c: c, stack: stack}
+}
+
+func (s *ssmState) name(key string) *string {
+	return aws.String(s.c.ResolveParameter(ArduinoPrefix+"/iot/"+key, s.stack))
+}
+
+func (s *ssmState) GetState(ctx context.Context, key string) (string, bool, error) {
+	out, err := s.c.ssmcl.GetParameter(ctx, &ssm.GetParameterInput{Name: s.name(key)})
+	var notFound *types.ParameterNotFound
+	if errors.As(err, &notFound) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return aws.ToString(out.Parameter.Value), true, nil
+}
+
+func (s *ssmState) PutState(ctx context.Context, key, value string) error {
+	return s.put(ctx, key, value, true)
+}
+
+func (s *ssmState) PutStateIf(ctx context.Context, key, value string, expected *string) error {
+	if expected == nil {
+		err := s.put(ctx, key, value, false)
+		var exists *types.ParameterAlreadyExists
+		if errors.As(err, &exists) {
+			return statestore.ErrConditionFailed
+		}
+		return err
+	}
+	current, ok, err := s.GetState(ctx, key)
+	if err != nil {
+		return err
+	}
+	if !ok || current != *expected {
+		return statestore.ErrConditionFailed
+	}
+	return s.put(ctx, key, value, true)
+}
+
+func (s *ssmState) put(ctx context.Context, key, value string, overwrite bool) error {
+	_, err := s.c.ssmcl.PutParameter(ctx, &ssm.PutParameterInput{
+		Name:      s.name(key),
+		Value:     aws.String(value),
+		Overwrite: aws.Bool(overwrite),
+		Type:      types.ParameterTypeString,
+		DataType:  aws.String("text"),
+	})
+	return err
+}
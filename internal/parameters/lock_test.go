@@ -0,0 +1,120 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package parameters
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/arduino/aws-sitewise-integration/internal/statestore"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSSM keeps parameters in memory
+type fakeSSM struct {
+	ssmAPI
+	values map[string]string
+}
+
+func (f *fakeSSM) GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error) {
+	value, ok := f.values[aws.ToString(params.Name)]
+	if !ok {
+		return nil, &types.ParameterNotFound{}
+	}
+	return &ssm.GetParameterOutput{Parameter: &types.Parameter{Name: params.Name, Value: aws.String(value)}}, nil
+}
+
+func (f *fakeSSM) GetParameters(ctx context.Context, params *ssm.GetParametersInput, optFns ...func(*ssm.Options)) (*ssm.GetParametersOutput, error) {
+	out := &ssm.GetParametersOutput{}
+	for _, name := range params.Names {
+		if value, ok := f.values[name]; ok {
+			out.Parameters = append(out.Parameters, types.Parameter{Name: aws.String(name), Value: aws.String(value)})
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeSSM) PutParameter(ctx context.Context, params *ssm.PutParameterInput, optFns ...func(*ssm.Options)) (*ssm.PutParameterOutput, error) {
+	name := aws.ToString(params.Name)
+	if _, ok := f.values[name]; ok && !aws.ToBool(params.Overwrite) {
+		return nil, &types.ParameterAlreadyExists{}
+	}
+	f.values[name] = aws.ToString(params.Value)
+	return &ssm.PutParameterOutput{}, nil
+}
+
+func TestRunLock(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeSSM{values: map[string]string{}}
+	cl := &ParametersClient{ssmcl: fake}
+	for name, store := range map[string]statestore.Store{"parameters": cl.StateStore("test"), "table": statestore.NewMemory()} {
+		t.Run(name, func(t *testing.T) {
+			start := time.Now()
+			lock, err := AcquireRunLock(ctx, store, "run1", start, 15*time.Minute)
+			assert.NoError(t, err)
+			value, _, _ := store.GetState(ctx, RunLockKey)
+			assert.Equal(t, strconv.FormatInt(start.Unix(), 10)+" run1", value)
+
+			// An overlapping run is rejected while the lock is held
+			_, err = AcquireRunLock(ctx, store, "run2", start.Add(5*time.Minute), 15*time.Minute)
+			assert.True(t, errors.Is(err, ErrRunInProgress))
+
+			// A stale lock is taken over, and not released by the run that left it
+			later := start.Add(20 * time.Minute)
+			taken, err := AcquireRunLock(ctx, store, "run3", later, 15*time.Minute)
+			assert.NoError(t, err)
+			assert.Error(t, lock.Release(ctx))
+			value, _, _ = store.GetState(ctx, RunLockKey)
+			assert.Equal(t, strconv.FormatInt(later.Unix(), 10)+" run3", value)
+
+			// Once released, the next run can start
+			assert.NoError(t, taken.Release(ctx))
+			_, err = AcquireRunLock(ctx, store, "run4", later.Add(time.Minute), 15*time.Minute)
+			assert.NoError(t, err)
+		})
+	}
+	assert.Contains(t, fake.values, "/arduino/sitewise-importer/test/iot/run-lock")
+}
+
+// racingStore reports the lock as stale to all the runs, like concurrent reads before any takeover
+type racingStore struct {
+	*statestore.Memory
+	stale string
+}
+
+func (r *racingStore) GetState(ctx context.Context, key string) (string, bool, error) {
+	return r.stale, true, nil
+}
+
+func TestRunLock_concurrentTakeover(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	stale := strconv.FormatInt(now.Add(-time.Hour).Unix(), 10) + " run1"
+	store := &racingStore{Memory: statestore.NewMemory(), stale: stale}
+	assert.NoError(t, store.PutState(ctx, RunLockKey, stale))
+
+	_, err := AcquireRunLock(ctx, store, "run2", now, 15*time.Minute)
+	assert.NoError(t, err)
+	// The second run saw the same stale lock, but the takeover is conditional
+	_, err = AcquireRunLock(ctx, store, "run3", now, 15*time.Minute)
+	assert.True(t, errors.Is(err, ErrRunInProgress))
+}
@@ -17,6 +17,7 @@ package parameters
 
 import (
 	"context"
+	"slices"
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -27,8 +28,22 @@ import (
 
 const StackName = "<stack-name>"
 
+// Value of parameters explicitly left unset
+const emptyValue = "<empty>"
+
+// Maximum number of parameters that can be read with a single GetParameters call
+const maxParametersPerRead = 10
+
+type ssmAPI interface {
+	GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error)
+	GetParameters(ctx context.Context, params *ssm.GetParametersInput, optFns ...func(*ssm.Options)) (*ssm.GetParametersOutput, error)
+	PutParameter(ctx context.Context, params *ssm.PutParameterInput, optFns ...func(*ssm.Options)) (*ssm.PutParameterOutput, error)
+}
+
 type ParametersClient struct {
-	ssmcl *ssm.Client
+	ssmcl ssmAPI
+	// Backend of secret parameters referencing a secret
+	secrets SecretBackend
 }
 
 func New() (*ParametersClient, error) {
@@ -46,7 +61,8 @@ func New() (*ParametersClient, error) {
 	cl := ssm.NewFromConfig(cfg)
 
 	return &ParametersClient{
-		ssmcl: cl,
+		ssmcl:   cl,
+		secrets: &secretsManagerBackend{ssmcl: cl},
 	}, nil
 }
 
@@ -55,22 +71,84 @@ func (c *ParametersClient) ResolveParameter(param, stack string) string {
 }
 
 func (c *ParametersClient) ReadConfig(param, stack string) (*string, error) {
-	param = c.ResolveParameter(param, stack)
 	value, err := c.ssmcl.GetParameter(context.Background(), &ssm.GetParameterInput{
-		Name:           aws.String(param),
+		Name:           aws.String(c.ResolveParameter(param, stack)),
 		WithDecryption: aws.Bool(true),
 	})
 	if err != nil {
 		return nil, err
 	}
 	paramValue := value.Parameter.Value
-	if paramValue == nil || *paramValue == "<empty>" {
+	if paramValue == nil || *paramValue == emptyValue {
 		defaultValue := ""
 		return &defaultValue, nil
 	}
+	if slices.Contains(SecretParameters, param) {
+		secret, err := c.resolveSecret(*paramValue)
+		if err != nil {
+			return nil, err
+		}
+		return &secret, nil
+	}
 	return paramValue, nil
 }
 
+// ReadConfigs reads the given parameters with as few calls as possible, returning their values keyed by
+// the parameter names as given. Parameters not found are not part of the result. Parameters set to '<empty>'
+// are returned as empty strings. Secret parameters referencing a secret are returned with the secret value.
+func (c *ParametersClient) ReadConfigs(params []string, stack string) (map[string]string, error) {
+	names := make([]string, 0, len(params))
+	resolved := make(map[string]string, len(params))
+	for _, param := range params {
+		name := c.ResolveParameter(param, stack)
+		names = append(names, name)
+		resolved[name] = param
+	}
+
+	values := make(map[string]string, len(params))
+	for start := 0; start < len(names); start += maxParametersPerRead {
+		end := min(start+maxParametersPerRead, len(names))
+		out, err := c.ssmcl.GetParameters(context.Background(), &ssm.GetParametersInput{
+			Names:          names[start:end],
+			WithDecryption: aws.Bool(true),
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range out.Parameters {
+			param, ok := resolved[aws.ToString(p.Name)]
+			if !ok {
+				continue
+			}
+			value := aws.ToString(p.Value)
+			if value == emptyValue {
+				value = ""
+			}
+			values[param] = value
+		}
+	}
+	for _, param := range SecretParameters {
+		value, ok := values[param]
+		if !ok {
+			continue
+		}
+		secret, err := c.resolveSecret(value)
+		if err != nil {
+			return nil, err
+		}
+		values[param] = secret
+	}
+	return values, nil
+}
+
+func (c *ParametersClient) resolveSecret(value string) (string, error) {
+	backend := c.secrets
+	if backend == nil {
+		backend = &secretsManagerBackend{ssmcl: c.ssmcl}
+	}
+	return resolveSecret(context.Background(), backend, value)
+}
+
 func (c *ParametersClient) UpdateParameterValue(param, stack, value string) error {
 	param = c.ResolveParameter(param, stack)
 	_, err := c.ssmcl.PutParameter(context.Background(), &ssm.PutParameterInput{
@@ -0,0 +1,86 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package parameters
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+// SecretsManagerPrefix marks values of secret parameters that reference a Secrets Manager secret instead
+// of holding the value, e.g. 'secretsmanager:arduino-api' or 'secretsmanager:arduino-api#key' to pick a
+// key of a JSON secret.
+const SecretsManagerPrefix = "secretsmanager:"
+
+// Parameter Store path exposing Secrets Manager secrets
+const secretsManagerReference = "/aws/reference/secretsmanager/"
+
+// SecretParameters are the parameters whose value can be a reference to a secret backend
+var SecretParameters = []string{IoTApiKey, IoTApiSecret}
+
+// SecretBackend reads secrets referenced by parameter values.
+type SecretBackend interface {
+	// ReadSecret returns the value of the secret with the given id.
+	ReadSecret(ctx context.Context, id string) (string, error)
+}
+
+// secretsManagerBackend reads Secrets Manager secrets through their Parameter Store reference, so that
+// secrets rotated by Secrets Manager are read with the same client and on every run.
+type secretsManagerBackend struct {
+	ssmcl ssmAPI
+}
+
+func (b *secretsManagerBackend) ReadSecret(ctx context.Context, id string) (string, error) {
+	out, err := b.ssmcl.GetParameter(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(secretsManagerReference + id),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(out.Parameter.Value), nil
+}
+
+// resolveSecret returns the value of the secret referenced by value, or value itself when it is not a
+// reference.
+func resolveSecret(ctx context.Context, backend SecretBackend, value string) (string, error) {
+	ref, ok := strings.CutPrefix(value, SecretsManagerPrefix)
+	if !ok {
+		return value, nil
+	}
+	id, key, hasKey := strings.Cut(ref, "#")
+	secret, err := backend.ReadSecret(ctx, id)
+	if err != nil {
+		return "", fmt.Errorf("reading secret %s: %w", id, err)
+	}
+	if !hasKey {
+		return secret, nil
+	}
+	var fields map[string]string
+	if err := json.Unmarshal([]byte(secret), &fields); err != nil {
+		return "", fmt.Errorf("secret %s is not a JSON object: %w", id, err)
+	}
+	field, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("secret %s has no key %s", id, key)
+	}
+	return field, nil
+}
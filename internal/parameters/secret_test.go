@@ -0,0 +1,75 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package parameters
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadConfigs_secretsManagerReferences(t *testing.T) {
+	fake := &fakeSSM{values: map[string]string{
+		"/arduino/sitewise-importer/test/iot/api-key":            "secretsmanager:arduino-api#key",
+		"/arduino/sitewise-importer/test/iot/api-secret":         "secretsmanager:arduino-api#secret",
+		"/arduino/sitewise-importer/test/iot/samples-resolution": "secretsmanager:not-a-secret-parameter",
+		"/aws/reference/secretsmanager/arduino-api":              `{"key": "the-key", "secret": "the-secret"}`,
+	}}
+	cl := &ParametersClient{ssmcl: fake, secrets: &secretsManagerBackend{ssmcl: fake}}
+
+	values, err := cl.ReadConfigs([]string{IoTApiKey, IoTApiSecret, SamplesReso}, "test")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		IoTApiKey:    "the-key",
+		IoTApiSecret: "the-secret",
+		SamplesReso:  "secretsmanager:not-a-secret-parameter",
+	}, values)
+
+	value, err := cl.ReadConfig(IoTApiSecret, "test")
+	assert.NoError(t, err)
+	assert.Equal(t, "the-secret", *value)
+}
+
+func TestReadConfigs_plainSecretValues(t *testing.T) {
+	fake := &fakeSSM{values: map[string]string{
+		"/arduino/sitewise-importer/test/iot/api-key":    "secretsmanager:arduino-api-key",
+		"/arduino/sitewise-importer/test/iot/api-secret": "plain-secret",
+		"/aws/reference/secretsmanager/arduino-api-key":  "the-key",
+	}}
+	cl := &ParametersClient{ssmcl: fake}
+
+	values, err := cl.ReadConfigs([]string{IoTApiKey, IoTApiSecret}, "test")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{IoTApiKey: "the-key", IoTApiSecret: "plain-secret"}, values)
+}
+
+func TestReadConfigs_secretErrors(t *testing.T) {
+	fake := &fakeSSM{values: map[string]string{
+		"/arduino/sitewise-importer/test/iot/api-key": "secretsmanager:missing",
+	}}
+	cl := &ParametersClient{ssmcl: fake}
+	_, err := cl.ReadConfigs([]string{IoTApiKey}, "test")
+	assert.ErrorContains(t, err, "reading secret missing")
+
+	fake.values["/arduino/sitewise-importer/test/iot/api-key"] = "secretsmanager:arduino-api#other"
+	fake.values["/aws/reference/secretsmanager/arduino-api"] = `{"key": "the-key"}`
+	_, err = cl.ReadConfigs([]string{IoTApiKey}, "test")
+	assert.ErrorContains(t, err, "has no key other")
+
+	fake.values["/aws/reference/secretsmanager/arduino-api"] = "not json"
+	_, err = cl.ReadConfigs([]string{IoTApiKey}, "test")
+	assert.ErrorContains(t, err, "is not a JSON object")
+}
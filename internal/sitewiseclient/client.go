@@ -18,11 +18,14 @@ package sitewiseclient
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/arduino/aws-sitewise-integration/internal/iot"
 	"github.com/arduino/aws-sitewise-integration/internal/utils"
@@ -34,38 +37,151 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+const (
+	// SiteWise BatchPutAssetPropertyValue limits
+	maxEntriesPerBatch   = 10
+	maxValuesPerEntry    = 10
+	maxStringValueLength = 1024
+
+	// Interval between status checks while waiting for models and assets to become active
+	pollInterval = 1 * time.Second
+)
+
+// BulkImportErrorReportPrefix is the prefix of the keys of error reports written by bulk import jobs to their bucket
+const BulkImportErrorReportPrefix = "error-reports"
+
+// ErrPollTimeout is returned when a model or asset doesn't become active, or a bulk import job doesn't end, within the poll wait budget
+var ErrPollTimeout = errors.New("not active within poll wait time")
+
+// ErrNoValue is returned when an asset property holds no value
+var ErrNoValue = errors.New("no value available")
+
+// PollOptions defines how to wait for models and assets to become active, and for bulk import jobs to end.
+type PollOptions struct {
+	// Interval is the wait before the first re-check. Defaults to one second.
+	Interval time.Duration
+	// MaxWait is the total time to wait between checks before giving up.
+	MaxWait time.Duration
+	// Backoff multiplies the interval after each check. Values <= 1 keep it constant.
+	Backoff float64
+}
+
+// DefaultPollOptions waits up to 15 seconds, checking once per second.
+var DefaultPollOptions = PollOptions{
+	Interval: pollInterval,
+	MaxWait:  15 * time.Second,
+	Backoff:  1,
+}
+
+// StringLimitPolicy defines how string values exceeding the SiteWise length limit are handled
+type StringLimitPolicy string
+
+const (
+	// StringLimitTruncate truncates oversized values, logging a warning
+	StringLimitTruncate StringLimitPolicy = "truncate"
+	// StringLimitSkip drops oversized values, logging a warning
+	StringLimitSkip StringLimitPolicy = "skip"
+)
+
+// sitewiseAPI is the subset of the SiteWise SDK client used by IotSiteWiseClient
+type sitewiseAPI interface {
+	ListAssetModels(ctx context.Context, params *iotsitewise.ListAssetModelsInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.ListAssetModelsOutput, error)
+	DescribeAssetModel(ctx context.Context, params *iotsitewise.DescribeAssetModelInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.DescribeAssetModelOutput, error)
+	DeleteAssetModel(ctx context.Context, params *iotsitewise.DeleteAssetModelInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.DeleteAssetModelOutput, error)
+	ListAssets(ctx context.Context, params *iotsitewise.ListAssetsInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.ListAssetsOutput, error)
+	CreateBulkImportJob(ctx context.Context, params *iotsitewise.CreateBulkImportJobInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.CreateBulkImportJobOutput, error)
+	ListBulkImportJobs(ctx context.Context, params *iotsitewise.ListBulkImportJobsInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.ListBulkImportJobsOutput, error)
+	DescribeBulkImportJob(ctx context.Context, params *iotsitewise.DescribeBulkImportJobInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.DescribeBulkImportJobOutput, error)
+	CreateAssetModel(ctx context.Context, params *iotsitewise.CreateAssetModelInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.CreateAssetModelOutput, error)
+	CreateAsset(ctx context.Context, params *iotsitewise.CreateAssetInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.CreateAssetOutput, error)
+	DescribeAsset(ctx context.Context, params *iotsitewise.DescribeAssetInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.DescribeAssetOutput, error)
+	UpdateAssetModel(ctx context.Context, params *iotsitewise.UpdateAssetModelInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.UpdateAssetModelOutput, error)
+	UpdateAssetProperty(ctx context.Context, params *iotsitewise.UpdateAssetPropertyInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.UpdateAssetPropertyOutput, error)
+	BatchPutAssetPropertyValue(ctx context.Context, params *iotsitewise.BatchPutAssetPropertyValueInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.BatchPutAssetPropertyValueOutput, error)
+	GetAssetPropertyValue(ctx context.Context, params *iotsitewise.GetAssetPropertyValueInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.GetAssetPropertyValueOutput, error)
+	AssociateAssets(ctx context.Context, params *iotsitewise.AssociateAssetsInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.AssociateAssetsOutput, error)
+	ListAssociatedAssets(ctx context.Context, params *iotsitewise.ListAssociatedAssetsInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.ListAssociatedAssetsOutput, error)
+	DisassociateAssets(ctx context.Context, params *iotsitewise.DisassociateAssetsInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.DisassociateAssetsOutput, error)
+	DeleteAsset(ctx context.Context, params *iotsitewise.DeleteAssetInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.DeleteAssetOutput, error)
+	UpdateAsset(ctx context.Context, params *iotsitewise.UpdateAssetInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.UpdateAssetOutput, error)
+}
+
+const (
+	defaultUpdateConflictRetries = 3
+	defaultUpdateConflictBackoff = 2 * time.Second
+)
+
 type IotSiteWiseClient struct {
-	svc    *iotsitewise.Client
+	svc    sitewiseAPI
 	logger *logrus.Entry
+
+	updateConflictRetries int
+	updateConflictBackoff time.Duration
+	stringLimitPolicy     StringLimitPolicy
+	dryRun                bool
+}
+
+// Option configures optional behaviours of the SiteWise client.
+type Option func(*IotSiteWiseClient)
+
+// WithUpdateConflictRetry configures how many times a model update is retried when SiteWise reports
+// a conflicting operation on the model. Wait between attempts starts from backoff and doubles on each retry.
+func WithUpdateConflictRetry(maxRetries int, backoff time.Duration) Option {
+	return func(c *IotSiteWiseClient) {
+		c.updateConflictRetries = maxRetries
+		c.updateConflictBackoff = backoff
+	}
 }
 
 //go:generate mockery --name API --filename sitewise_api.go
 type API interface {
-	ListAssetModels(ctx context.Context) (*iotsitewise.ListAssetModelsOutput, error)
-	ListAssetModelsNext(ctx context.Context, nextToken *string) (*iotsitewise.ListAssetModelsOutput, error)
-	ListAssets(ctx context.Context, assetModelId *string) (*iotsitewise.ListAssetsOutput, error)
-	ListAssetsNext(ctx context.Context, assetModelId *string, nextToken *string) (*iotsitewise.ListAssetsOutput, error)
+	ListAssetModels(ctx context.Context, nextToken *string) (*iotsitewise.ListAssetModelsOutput, error)
+	ListAssets(ctx context.Context, assetModelId *string, nextToken *string) (*iotsitewise.ListAssetsOutput, error)
+	ListAllAssetModels(ctx context.Context) ([]types.AssetModelSummary, error)
+	ListAllAssetsForModel(ctx context.Context, assetModelId *string) ([]types.AssetSummary, error)
 	DescribeAssetModel(ctx context.Context, assetModelId *string) (*iotsitewise.DescribeAssetModelOutput, error)
 	DeleteAssetModel(ctx context.Context, assetModelId *string) (*iotsitewise.DeleteAssetModelOutput, error)
 	CreateDataBulkImportJob(ctx context.Context, jobNumber int, bucket string, filesToImport []string, roleArn string) (*iotsitewise.CreateBulkImportJobOutput, error)
 	ListBulkImportJobs(ctx context.Context, nextToken *string) (*iotsitewise.ListBulkImportJobsOutput, error)
 	GetBulkImportJobStatus(ctx context.Context, jobId *string) (*iotsitewise.DescribeBulkImportJobOutput, error)
-	CreateAssetModel(ctx context.Context, name string, properties map[string]string, uomMap map[string][]string) (*iotsitewise.CreateAssetModelOutput, error)
-	CreateAsset(ctx context.Context, name string, assetModelId string, thingId string) (*iotsitewise.CreateAssetOutput, error)
+	WaitForBulkImportJob(ctx context.Context, jobId string, opts PollOptions) (types.JobStatus, error)
+	CreateAssetModel(ctx context.Context, name, externalId string, properties map[string]string, uomMap map[string][]string) (*iotsitewise.CreateAssetModelOutput, error)
+	CreateSplitAssetModel(ctx context.Context, name, externalId string, properties map[string]string, maxProperties int, uomMap map[string][]string) (*iotsitewise.CreateAssetModelOutput, error)
+	CreateAsset(ctx context.Context, name string, assetModelId string, thingId string, tags map[string]string) (*iotsitewise.CreateAssetOutput, error)
 	DescribeModel(ctx context.Context, assetModelId string) (*iotsitewise.DescribeAssetModelOutput, error)
-	PollForModelActiveStatus(ctx context.Context, modelId string, maxRetry int) bool
+	PollForModelActiveStatus(ctx context.Context, modelId string, maxRetry int) error
+	PollForModelActiveStatusWithOptions(ctx context.Context, modelId string, opts PollOptions) error
 	IsModelActive(ctx context.Context, model *iotsitewise.DescribeAssetModelOutput) bool
 	DescribeAsset(ctx context.Context, assetId string) (*iotsitewise.DescribeAssetOutput, error)
 	IsAssetActive(ctx context.Context, asset *iotsitewise.DescribeAssetOutput) bool
-	PollForAssetActiveStatus(ctx context.Context, assetId string, maxRetry int) bool
+	PollForAssetActiveStatus(ctx context.Context, assetId string, maxRetry int) error
+	PollForAssetActiveStatusWithOptions(ctx context.Context, assetId string, opts PollOptions) error
 	UpdateAssetModelProperties(ctx context.Context, assetModel *iotsitewise.DescribeAssetModelOutput, thingProperties map[string]string, uomMap map[string][]string) error
+	RemoveAssetModelProperties(ctx context.Context, assetModel *iotsitewise.DescribeAssetModelOutput, propertyNames []string) error
 	UpdateAssetProperties(ctx context.Context, assetId string, thingProperties map[string]string) error
 	PopulateTimeSeriesByAlias(ctx context.Context, propertyAlias string, ts []int64, values []float64) error
 	PopulateSampledSamplesTimeSeriesByAlias(ctx context.Context, propertyAlias string, ts []int64, values []any) error
 	PopulateArbitrarySamplesByAlias(ctx context.Context, points []DataPoint) error
+	GetLatestAssetPropertyValue(ctx context.Context, assetId, propertyId string) (*types.Variant, time.Time, error)
+	SetAssetPropertyAttribute(ctx context.Context, propertyAlias string, value any) error
+	CreateHierarchyAssetModel(ctx context.Context, name, externalId string, hierarchies []types.AssetModelHierarchyDefinition) (*iotsitewise.CreateAssetModelOutput, error)
+	AddAssetModelHierarchies(ctx context.Context, assetModel *iotsitewise.DescribeAssetModelOutput, hierarchies []types.AssetModelHierarchyDefinition) error
+	AssociateAssets(ctx context.Context, parentAssetId, hierarchyId, childAssetId string) error
+	GetParentAssetId(ctx context.Context, assetId string) (*string, error)
+	DisassociateFromParent(ctx context.Context, assetId, assetModelId string) error
+	DeleteAsset(ctx context.Context, assetId string) error
+	UpdateAssetName(ctx context.Context, assetId, name string) error
+}
+
+// WithStringLimitPolicy sets how string values longer than the SiteWise limit are handled.
+// Default is StringLimitTruncate.
+func WithStringLimitPolicy(policy StringLimitPolicy) Option {
+	return func(c *IotSiteWiseClient) {
+		c.stringLimitPolicy = policy
+	}
 }
 
-func New(logger *logrus.Entry) (*IotSiteWiseClient, error) {
+func New(logger *logrus.Entry, opts ...Option) (*IotSiteWiseClient, error) {
 	awsOpts := []func(*config.LoadOptions) error{}
 
 	config.WithRetryer(func() aws.Retryer {
@@ -81,22 +197,26 @@ func New(logger *logrus.Entry) (*IotSiteWiseClient, error) {
 	if err != nil {
 		return nil, err
 	}
-	svc := iotsitewise.NewFromConfig(cfg)
+	svc := &tracedSiteWise{iotsitewise.NewFromConfig(cfg)}
 
-	return &IotSiteWiseClient{
-		svc:    svc,
-		logger: logger,
-	}, nil
-}
-
-func (c *IotSiteWiseClient) ListAssetModels(ctx context.Context) (*iotsitewise.ListAssetModelsOutput, error) {
-	maxRes := int32(100)
-	return c.svc.ListAssetModels(ctx, &iotsitewise.ListAssetModelsInput{
-		MaxResults: &maxRes,
-	})
+	cl := &IotSiteWiseClient{
+		svc:                   svc,
+		logger:                logger,
+		updateConflictRetries: defaultUpdateConflictRetries,
+		updateConflictBackoff: defaultUpdateConflictBackoff,
+		stringLimitPolicy:     StringLimitTruncate,
+	}
+	for _, opt := range opts {
+		opt(cl)
+	}
+	if cl.dryRun {
+		cl.svc = newDryRunSiteWise(svc, logger)
+	}
+	return cl, nil
 }
 
-func (c *IotSiteWiseClient) ListAssetModelsNext(ctx context.Context, nextToken *string) (*iotsitewise.ListAssetModelsOutput, error) {
+// ListAssetModels lists a page of asset models. The first page is requested with a nil nextToken.
+func (c *IotSiteWiseClient) ListAssetModels(ctx context.Context, nextToken *string) (*iotsitewise.ListAssetModelsOutput, error) {
 	maxRes := int32(100)
 	return c.svc.ListAssetModels(ctx, &iotsitewise.ListAssetModelsInput{
 		MaxResults: &maxRes,
@@ -116,21 +236,48 @@ func (c *IotSiteWiseClient) DeleteAssetModel(ctx context.Context, assetModelId *
 	})
 }
 
-func (c *IotSiteWiseClient) ListAssets(ctx context.Context, assetModelId *string) (*iotsitewise.ListAssetsOutput, error) {
+// ListAssets lists a page of the assets of the given model. The first page is requested with a nil nextToken.
+func (c *IotSiteWiseClient) ListAssets(ctx context.Context, assetModelId *string, nextToken *string) (*iotsitewise.ListAssetsOutput, error) {
 	maxRes := int32(100)
 	return c.svc.ListAssets(ctx, &iotsitewise.ListAssetsInput{
 		MaxResults:   &maxRes,
+		NextToken:    nextToken,
 		AssetModelId: assetModelId,
 	})
 }
 
-func (c *IotSiteWiseClient) ListAssetsNext(ctx context.Context, assetModelId *string, nextToken *string) (*iotsitewise.ListAssetsOutput, error) {
-	maxRes := int32(100)
-	return c.svc.ListAssets(ctx, &iotsitewise.ListAssetsInput{
-		MaxResults:   &maxRes,
-		NextToken:    nextToken,
-		AssetModelId: assetModelId,
-	})
+// ListAllAssetModels lists the asset models, following pagination until the last page.
+func (c *IotSiteWiseClient) ListAllAssetModels(ctx context.Context) ([]types.AssetModelSummary, error) {
+	var summaries []types.AssetModelSummary
+	var nextToken *string
+	for {
+		out, err := c.ListAssetModels(ctx, nextToken)
+		if err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, out.AssetModelSummaries...)
+		if out.NextToken == nil {
+			return summaries, nil
+		}
+		nextToken = out.NextToken
+	}
+}
+
+// ListAllAssetsForModel lists the assets of the given model, following pagination until the last page.
+func (c *IotSiteWiseClient) ListAllAssetsForModel(ctx context.Context, assetModelId *string) ([]types.AssetSummary, error) {
+	var summaries []types.AssetSummary
+	var nextToken *string
+	for {
+		out, err := c.ListAssets(ctx, assetModelId, nextToken)
+		if err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, out.AssetSummaries...)
+		if out.NextToken == nil {
+			return summaries, nil
+		}
+		nextToken = out.NextToken
+	}
 }
 
 func (c *IotSiteWiseClient) CreateDataBulkImportJob(ctx context.Context, jobNumber int, bucket string, filesToImport []string, roleArn string) (*iotsitewise.CreateBulkImportJobOutput, error) {
@@ -150,7 +297,7 @@ func (c *IotSiteWiseClient) CreateDataBulkImportJob(ctx context.Context, jobNumb
 	return c.svc.CreateBulkImportJob(ctx, &iotsitewise.CreateBulkImportJobInput{
 		ErrorReportLocation: &types.ErrorReportLocation{
 			Bucket: &bucket,
-			Prefix: utils.StringPointer("error-reports"),
+			Prefix: utils.StringPointer(BulkImportErrorReportPrefix),
 		},
 		Files:             files,
 		JobName:           utils.StringPointer(fmt.Sprintf("bulk-import-job-%d", jobNumber)),
@@ -187,8 +334,57 @@ func (c *IotSiteWiseClient) GetBulkImportJobStatus(ctx context.Context, jobId *s
 	})
 }
 
+// WaitForBulkImportJob waits for the bulk import job to end, according to the given poll options, returning its
+// terminal status: completed, completed with failures, failed or cancelled.
+func (c *IotSiteWiseClient) WaitForBulkImportJob(ctx context.Context, jobId string, opts PollOptions) (types.JobStatus, error) {
+	var status types.JobStatus
+	err := poll(ctx, opts, func() (bool, error) {
+		job, err := c.GetBulkImportJobStatus(ctx, &jobId)
+		if err != nil {
+			return false, fmt.Errorf("describing bulk import job %s: %w", jobId, err)
+		}
+		status = job.JobStatus
+		return isTerminalJobStatus(status), nil
+	})
+	if errors.Is(err, ErrPollTimeout) {
+		return status, fmt.Errorf("bulk import job %s, last status %s: %w", jobId, status, err)
+	}
+	return status, err
+}
+
+func isTerminalJobStatus(status types.JobStatus) bool {
+	switch status {
+	case types.JobStatusCompleted, types.JobStatusCompletedWithFailures, types.JobStatusFailed, types.JobStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// attributeTypePrefix marks, among the property types of a model, the thing properties defined as attributes
+const attributeTypePrefix = "attribute:"
+
+// AttributeType returns the property type defining the model property of a thing property as an attribute,
+// holding a value set once rather than a time series of measurements.
+func AttributeType(ptype string) string {
+	return attributeTypePrefix + ptype
+}
+
+// modelPropertyType returns the measurement or attribute definition of a model property, along with the
+// type of its thing property.
+func modelPropertyType(ptype string) (*types.PropertyType, string) {
+	if thingType, ok := strings.CutPrefix(ptype, attributeTypePrefix); ok {
+		return &types.PropertyType{Attribute: &types.Attribute{}}, thingType
+	}
+	return &types.PropertyType{Measurement: &types.Measurement{}}, ptype
+}
+
+// PropertyDataType returns the data type of the model property of a thing property type.
+func PropertyDataType(ptype string) types.PropertyDataType {
+	return mapType(ptype)
+}
+
 func mapType(ptype string) types.PropertyDataType {
-	ptype = strings.ToUpper(ptype)
+	ptype = strings.ToUpper(strings.TrimPrefix(ptype, attributeTypePrefix))
 
 	if iot.IsPropertyNumberType(ptype) || iot.IsPropertyBool(ptype) {
 		return types.PropertyDataTypeDouble
@@ -199,57 +395,80 @@ func mapType(ptype string) types.PropertyDataType {
 	return types.PropertyDataTypeString
 }
 
-func (c *IotSiteWiseClient) CreateAssetModel(ctx context.Context, name string, properties map[string]string, uomMap map[string][]string) (*iotsitewise.CreateAssetModelOutput, error) {
+// CreateAssetModel creates an asset model with the given properties. The model has no external id if externalId is empty.
+func (c *IotSiteWiseClient) CreateAssetModel(ctx context.Context, name, externalId string, properties map[string]string, uomMap map[string][]string) (*iotsitewise.CreateAssetModelOutput, error) {
+	return c.svc.CreateAssetModel(ctx, &iotsitewise.CreateAssetModelInput{
+		AssetModelName:       &name,
+		AssetModelExternalId: optionalExternalId(externalId),
+		AssetModelProperties: modelPropertyDefinitions(properties, uomMap),
+	})
+}
+
+func optionalExternalId(externalId string) *string {
+	if externalId == "" {
+		return nil
+	}
+	return &externalId
+}
+
+func modelPropertyDefinitions(properties map[string]string, uomMap map[string][]string) []types.AssetModelPropertyDefinition {
 	var modelProperties []types.AssetModelPropertyDefinition
 	for property, ptype := range properties {
 		mappedType := mapType(ptype)
-		var uom *string
-		if u, ok := uomMap[ptype]; ok {
-			if len(u) > 0 {
-				uom = &u[0]
-			}
-		}
+		propertyType, _ := modelPropertyType(ptype)
 		modelProperties = append(modelProperties, types.AssetModelPropertyDefinition{
 			Name:     &property,
 			DataType: mappedType,
-			Type: &types.PropertyType{
-				Measurement: &types.Measurement{},
-			},
-			Unit: uom,
+			Type:     propertyType,
+			Unit:     propertyUnit(ptype, uomMap),
 		})
 	}
-	return c.svc.CreateAssetModel(ctx, &iotsitewise.CreateAssetModelInput{
-		AssetModelName:       &name,
-		AssetModelProperties: modelProperties,
-	})
+	return modelProperties
 }
 
-func (c *IotSiteWiseClient) CreateAsset(ctx context.Context, name string, assetModelId string, thingId string) (*iotsitewise.CreateAssetOutput, error) {
+// CreateAsset creates an asset with the thing ID as external id. Tags are optional.
+func (c *IotSiteWiseClient) CreateAsset(ctx context.Context, name string, assetModelId string, thingId string, tags map[string]string) (*iotsitewise.CreateAssetOutput, error) {
 	return c.svc.CreateAsset(ctx, &iotsitewise.CreateAssetInput{
 		AssetModelId:    &assetModelId,
 		AssetName:       &name,
 		AssetExternalId: &thingId,
+		Tags:            tags,
 	})
 }
 
+func (c *IotSiteWiseClient) UpdateAssetName(ctx context.Context, assetId, name string) error {
+	_, err := c.svc.UpdateAsset(ctx, &iotsitewise.UpdateAssetInput{
+		AssetId:   &assetId,
+		AssetName: &name,
+	})
+	return err
+}
+
 func (c *IotSiteWiseClient) DescribeModel(ctx context.Context, assetModelId string) (*iotsitewise.DescribeAssetModelOutput, error) {
 	return c.svc.DescribeAssetModel(ctx, &iotsitewise.DescribeAssetModelInput{
 		AssetModelId: &assetModelId,
 	})
 }
 
-func (c *IotSiteWiseClient) PollForModelActiveStatus(ctx context.Context, modelId string, maxRetry int) bool {
-	for i := 0; i < maxRetry; i++ {
+// PollForModelActiveStatus waits for the model to become active, checking once per second up to maxRetry times.
+func (c *IotSiteWiseClient) PollForModelActiveStatus(ctx context.Context, modelId string, maxRetry int) error {
+	return c.PollForModelActiveStatusWithOptions(ctx, modelId, retryPollOptions(maxRetry))
+}
+
+// PollForModelActiveStatusWithOptions waits for the model to become active, according to the given poll options.
+// It returns early with the context error if ctx is cancelled, or with the describe error if the model can't be read.
+func (c *IotSiteWiseClient) PollForModelActiveStatusWithOptions(ctx context.Context, modelId string, opts PollOptions) error {
+	err := poll(ctx, opts, func() (bool, error) {
 		model, err := c.DescribeModel(ctx, modelId)
 		if err != nil {
-			return false
-		}
-		if c.IsModelActive(ctx, model) {
-			return true
+			return false, fmt.Errorf("describing model %s: %w", modelId, err)
 		}
-		time.Sleep(1 * time.Second)
+		return c.IsModelActive(ctx, model), nil
+	})
+	if errors.Is(err, ErrPollTimeout) {
+		return fmt.Errorf("model %s: %w", modelId, err)
 	}
-	return false
+	return err
 }
 
 func (c *IotSiteWiseClient) IsModelActive(ctx context.Context, model *iotsitewise.DescribeAssetModelOutput) bool {
@@ -267,21 +486,122 @@ func (c *IotSiteWiseClient) IsAssetActive(ctx context.Context, asset *iotsitewis
 	return asset != nil && asset.AssetStatus.State == types.AssetStateActive
 }
 
-func (c *IotSiteWiseClient) PollForAssetActiveStatus(ctx context.Context, assetId string, maxRetry int) bool {
-	for i := 0; i < maxRetry; i++ {
+// PollForAssetActiveStatus waits for the asset to become active, checking once per second up to maxRetry times.
+func (c *IotSiteWiseClient) PollForAssetActiveStatus(ctx context.Context, assetId string, maxRetry int) error {
+	return c.PollForAssetActiveStatusWithOptions(ctx, assetId, retryPollOptions(maxRetry))
+}
+
+// PollForAssetActiveStatusWithOptions waits for the asset to become active, according to the given poll options.
+// It returns early with the context error if ctx is cancelled, or with the describe error if the asset can't be read.
+func (c *IotSiteWiseClient) PollForAssetActiveStatusWithOptions(ctx context.Context, assetId string, opts PollOptions) error {
+	err := poll(ctx, opts, func() (bool, error) {
 		asset, err := c.DescribeAsset(ctx, assetId)
 		if err != nil {
-			return false
+			return false, fmt.Errorf("describing asset %s: %w", assetId, err)
 		}
-		if c.IsAssetActive(ctx, asset) {
-			return true
+		return c.IsAssetActive(ctx, asset), nil
+	})
+	if errors.Is(err, ErrPollTimeout) {
+		return fmt.Errorf("asset %s: %w", assetId, err)
+	}
+	return err
+}
+
+// retryPollOptions maps the legacy retry count to poll options: maxRetry checks, one second apart.
+func retryPollOptions(maxRetry int) PollOptions {
+	return PollOptions{
+		Interval: pollInterval,
+		MaxWait:  time.Duration(max(maxRetry-1, 0)) * pollInterval,
+	}
+}
+
+// poll runs check until it reports done, fails, or the poll options wait budget is exhausted.
+// The budget is accounted on the time spent waiting between checks.
+func poll(ctx context.Context, opts PollOptions, check func() (bool, error)) error {
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = pollInterval
+	}
+	var waited time.Duration
+	for {
+		done, err := check()
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+		remaining := opts.MaxWait - waited
+		if remaining <= 0 {
+			return ErrPollTimeout
+		}
+		wait := min(interval, remaining)
+		if err := sleepCtx(ctx, wait); err != nil {
+			return err
+		}
+		waited += wait
+		if opts.Backoff > 1 {
+			interval = time.Duration(float64(interval) * opts.Backoff)
 		}
-		time.Sleep(1 * time.Second)
 	}
-	return false
+}
+
+// sleepCtx waits for d to elapse, returning the context error if ctx is done first.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
 }
 
 func (c *IotSiteWiseClient) UpdateAssetModelProperties(ctx context.Context, assetModel *iotsitewise.DescribeAssetModelOutput, thingProperties map[string]string, uomMap map[string][]string) error {
+	return c.updateAssetModel(ctx, assetModel, func(model *iotsitewise.DescribeAssetModelOutput) (*iotsitewise.UpdateAssetModelInput, bool) {
+		return buildAssetModelUpdate(model, thingProperties, uomMap)
+	})
+}
+
+// RemoveAssetModelProperties removes the properties with the given names from the model, and from its composite models.
+func (c *IotSiteWiseClient) RemoveAssetModelProperties(ctx context.Context, assetModel *iotsitewise.DescribeAssetModelOutput, propertyNames []string) error {
+	return c.updateAssetModel(ctx, assetModel, func(model *iotsitewise.DescribeAssetModelOutput) (*iotsitewise.UpdateAssetModelInput, bool) {
+		return buildAssetModelRemoval(model, propertyNames)
+	})
+}
+
+// updateAssetModel applies the update built from the model, retrying from the current model state on conflicting operations.
+func (c *IotSiteWiseClient) updateAssetModel(ctx context.Context, assetModel *iotsitewise.DescribeAssetModelOutput, build func(*iotsitewise.DescribeAssetModelOutput) (*iotsitewise.UpdateAssetModelInput, bool)) error {
+	backoff := c.updateConflictBackoff
+	for attempt := 0; ; attempt++ {
+		assetModelInput, modified := build(assetModel)
+		if !modified {
+			return nil
+		}
+
+		_, err := c.svc.UpdateAssetModel(ctx, assetModelInput)
+		if err == nil {
+			return nil
+		}
+		var errConflict *types.ConflictingOperationException
+		if !errors.As(err, &errConflict) || attempt >= c.updateConflictRetries {
+			return err
+		}
+
+		c.logger.Warnf("Conflicting operation on model %s, retrying update in %s\n", *assetModel.AssetModelId, backoff)
+		if err := sleepCtx(ctx, backoff); err != nil {
+			return err
+		}
+		backoff *= 2
+
+		// Model has been changed by someone else, start again from its current state
+		assetModel, err = c.DescribeAssetModel(ctx, assetModel.AssetModelId)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func buildAssetModelUpdate(assetModel *iotsitewise.DescribeAssetModelOutput, thingProperties map[string]string, uomMap map[string][]string) (*iotsitewise.UpdateAssetModelInput, bool) {
 	assetModelInput := iotsitewise.UpdateAssetModelInput{
 		AssetModelId:              assetModel.AssetModelId,
 		AssetModelName:            assetModel.AssetModelName,
@@ -293,11 +613,22 @@ func (c *IotSiteWiseClient) UpdateAssetModelProperties(ctx context.Context, asse
 	}
 
 	assetModelProperties := make(map[string]string, len(assetModel.AssetModelProperties))
-	for _, prop := range assetModel.AssetModelProperties {
+	for _, prop := range ModelProperties(assetModel) {
 		assetModelProperties[*prop.Name] = *prop.Id
 	}
 
+	// Units of existing properties follow the ones of their Arduino property type
 	modified := false
+	if AssetModelUnitsChanged(assetModel, thingProperties, uomMap) {
+		modified = true
+		assetModelInput.AssetModelProperties = withPropertyUnits(assetModel.AssetModelProperties, thingProperties, uomMap)
+		assetModelInput.AssetModelCompositeModels = make([]types.AssetModelCompositeModel, 0, len(assetModel.AssetModelCompositeModels))
+		for _, composite := range assetModel.AssetModelCompositeModels {
+			composite.Properties = withPropertyUnits(composite.Properties, thingProperties, uomMap)
+			assetModelInput.AssetModelCompositeModels = append(assetModelInput.AssetModelCompositeModels, composite)
+		}
+	}
+
 	for propertyName, ptype := range thingProperties {
 		_, ok := assetModelProperties[propertyName]
 		if !ok {
@@ -306,33 +637,100 @@ func (c *IotSiteWiseClient) UpdateAssetModelProperties(ctx context.Context, asse
 				assetModelInput.AssetModelProperties = []types.AssetModelProperty{}
 			}
 			mappedType := mapType(ptype)
-			var uom *string
-			if u, ok := uomMap[ptype]; ok {
-				if len(u) > 0 {
-					uom = &u[0]
-				}
-			}
+			propertyType, _ := modelPropertyType(ptype)
 			assetModelInput.AssetModelProperties = append(assetModelInput.AssetModelProperties, types.AssetModelProperty{
 				Name:     &propertyName,
 				DataType: mappedType,
-				Type: &types.PropertyType{
-					Measurement: &types.Measurement{},
-				},
-				Unit: uom,
+				Type:     propertyType,
+				Unit:     propertyUnit(ptype, uomMap),
 			})
 		}
 	}
 
-	if modified {
-		_, err := c.svc.UpdateAssetModel(ctx, &assetModelInput)
-		if err != nil {
-			return err
+	return &assetModelInput, modified
+}
+
+// PropertyTypeMismatches returns the names, sorted, of the model measurements whose data type differs from
+// the one of their thing property type, as when a thing property changed type after the model creation.
+func PropertyTypeMismatches(assetModel *iotsitewise.DescribeAssetModelOutput, thingProperties map[string]string) []string {
+	mismatches := []string{}
+	for _, prop := range ModelProperties(assetModel) {
+		if prop.Name == nil || prop.Type == nil || prop.Type.Measurement == nil {
+			continue
+		}
+		if ptype, ok := thingProperties[*prop.Name]; ok && ptype != "" && prop.DataType != mapType(ptype) {
+			mismatches = append(mismatches, *prop.Name)
 		}
 	}
+	slices.Sort(mismatches)
+	return mismatches
+}
 
+// AssetModelUnitsChanged reports whether the unit of any model property differs from the one of its thing property type
+func AssetModelUnitsChanged(assetModel *iotsitewise.DescribeAssetModelOutput, thingProperties map[string]string, uomMap map[string][]string) bool {
+	for _, prop := range ModelProperties(assetModel) {
+		if prop.Name == nil {
+			continue
+		}
+		if ptype, ok := thingProperties[*prop.Name]; ok && aws.ToString(prop.Unit) != aws.ToString(propertyUnit(ptype, uomMap)) {
+			return true
+		}
+	}
+	return false
+}
+
+func withPropertyUnits(properties []types.AssetModelProperty, thingProperties map[string]string, uomMap map[string][]string) []types.AssetModelProperty {
+	updated := slices.Clone(properties)
+	for i, prop := range updated {
+		if prop.Name == nil {
+			continue
+		}
+		if ptype, ok := thingProperties[*prop.Name]; ok {
+			updated[i].Unit = propertyUnit(ptype, uomMap)
+		}
+	}
+	return updated
+}
+
+func propertyUnit(ptype string, uomMap map[string][]string) *string {
+	_, ptype = modelPropertyType(ptype)
+	if u, ok := uomMap[ptype]; ok && len(u) > 0 {
+		return &u[0]
+	}
 	return nil
 }
 
+func buildAssetModelRemoval(assetModel *iotsitewise.DescribeAssetModelOutput, propertyNames []string) (*iotsitewise.UpdateAssetModelInput, bool) {
+	modified := false
+	keep := func(props []types.AssetModelProperty) []types.AssetModelProperty {
+		kept := make([]types.AssetModelProperty, 0, len(props))
+		for _, prop := range props {
+			if prop.Name != nil && slices.Contains(propertyNames, *prop.Name) {
+				modified = true
+				continue
+			}
+			kept = append(kept, prop)
+		}
+		return kept
+	}
+
+	compositeModels := make([]types.AssetModelCompositeModel, 0, len(assetModel.AssetModelCompositeModels))
+	for _, composite := range assetModel.AssetModelCompositeModels {
+		composite.Properties = keep(composite.Properties)
+		compositeModels = append(compositeModels, composite)
+	}
+
+	return &iotsitewise.UpdateAssetModelInput{
+		AssetModelId:              assetModel.AssetModelId,
+		AssetModelName:            assetModel.AssetModelName,
+		AssetModelDescription:     assetModel.AssetModelDescription,
+		AssetModelHierarchies:     assetModel.AssetModelHierarchies,
+		AssetModelProperties:      keep(assetModel.AssetModelProperties),
+		AssetModelExternalId:      assetModel.AssetModelExternalId,
+		AssetModelCompositeModels: compositeModels,
+	}, modified
+}
+
 type propertyDefinition struct {
 	ArduinoPropertyId string
 	AssetProperty     *types.AssetProperty
@@ -345,10 +743,12 @@ func (c *IotSiteWiseClient) UpdateAssetProperties(ctx context.Context, assetId s
 		return err
 	}
 	assetPropertiesMap := make(map[string]propertyDefinition, len(thingProperties))
-	for _, prop := range assetDescribed.AssetProperties {
-		assetPropertiesMap[*prop.Name] = propertyDefinition{
-			ArduinoPropertyId: *prop.Id,
-			AssetProperty:     &prop,
+	for _, prop := range AssetProperties(assetDescribed) {
+		// Each definition points to its own copy, not to the loop variable
+		p := prop
+		assetPropertiesMap[*p.Name] = propertyDefinition{
+			ArduinoPropertyId: *p.Id,
+			AssetProperty:     &p,
 		}
 	}
 
@@ -383,9 +783,7 @@ func (c *IotSiteWiseClient) PopulateTimeSeriesByAlias(ctx context.Context, prope
 	if len(ts) == 0 {
 		return fmt.Errorf("no data to populate")
 	}
-	var data []types.PutAssetPropertyValueEntry
 	var pvalues []types.AssetPropertyValue
-	entry := "1"
 
 	for i := 0; i < len(ts); i++ {
 		pvalues = append(pvalues, types.AssetPropertyValue{
@@ -399,12 +797,36 @@ func (c *IotSiteWiseClient) PopulateTimeSeriesByAlias(ctx context.Context, prope
 		})
 	}
 
-	data = append(data, types.PutAssetPropertyValueEntry{
-		EntryId:        &entry,
-		PropertyAlias:  &propertyAlias,
-		PropertyValues: pvalues,
-	})
+	return c.putPropertyValuesByAlias(ctx, propertyAlias, pvalues, "[Error]")
+}
+
+// putPropertyValuesByAlias writes values of a single alias, splitting them into entries and batches
+// that respect SiteWise BatchPutAssetPropertyValue limits.
+func (c *IotSiteWiseClient) putPropertyValuesByAlias(ctx context.Context, propertyAlias string, pvalues []types.AssetPropertyValue, errLabel string) error {
+	var data []types.PutAssetPropertyValueEntry
+	for i := 0; i < len(pvalues); i += maxValuesPerEntry {
+		end := i + maxValuesPerEntry
+		if end > len(pvalues) {
+			end = len(pvalues)
+		}
+		entry := strconv.Itoa(len(data) + 1)
+		data = append(data, types.PutAssetPropertyValueEntry{
+			EntryId:        &entry,
+			PropertyAlias:  &propertyAlias,
+			PropertyValues: pvalues[i:end],
+		})
+
+		if len(data) == maxEntriesPerBatch || end == len(pvalues) {
+			if err := c.batchPut(ctx, data, errLabel); err != nil {
+				return err
+			}
+			data = nil
+		}
+	}
+	return nil
+}
 
+func (c *IotSiteWiseClient) batchPut(ctx context.Context, data []types.PutAssetPropertyValueEntry, errLabel string) error {
 	out, err := c.svc.BatchPutAssetPropertyValue(ctx, &iotsitewise.BatchPutAssetPropertyValueInput{
 		Entries: data,
 	})
@@ -416,7 +838,7 @@ func (c *IotSiteWiseClient) PopulateTimeSeriesByAlias(ctx context.Context, prope
 			c.logger.Error("Error on entry: ", *entry.EntryId)
 			if entry.Errors != nil {
 				for _, err := range entry.Errors {
-					c.logger.Error("		[Error] ", err.ErrorCode, *err.ErrorMessage)
+					c.logger.Error("		"+errLabel+" ", err.ErrorCode, *err.ErrorMessage)
 				}
 			}
 		}
@@ -434,17 +856,65 @@ func interfaceToString(value interface{}) string {
 		return strconv.FormatFloat(v, 'f', -1, 64)
 	case bool:
 		return strconv.FormatBool(v)
-	case map[string]any:
+	default:
+		if !isComposite(v) {
+			return fmt.Sprintf("%v", v)
+		}
+		// Composite values (e.g. locations, arrays) are stored as JSON, so they can be parsed back
 		encoded, err := json.Marshal(v)
 		if err != nil {
 			return fmt.Sprintf("%v", v)
 		}
 		return string(encoded)
+	}
+}
+
+// isComposite reports whether value is a slice, array, map or struct, or a pointer to one of them.
+func isComposite(value interface{}) bool {
+	rv := reflect.ValueOf(value)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return false
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Struct:
+		return true
 	default:
-		return fmt.Sprintf("%v", v)
+		return false
 	}
 }
 
+// applyStringLimit enforces the SiteWise maximum length on string values according to the configured policy.
+// It returns false if the value has to be skipped.
+func (c *IotSiteWiseClient) applyStringLimit(propertyAlias string, variant *types.Variant) bool {
+	if variant.StringValue == nil || len(*variant.StringValue) <= maxStringValueLength {
+		return true
+	}
+	value := *variant.StringValue
+	if c.stringLimitPolicy == StringLimitSkip {
+		c.logger.Warnf("String value of %d bytes for %s exceeds SiteWise limit of %d bytes, skipping it\n", len(value), propertyAlias, maxStringValueLength)
+		return false
+	}
+	c.logger.Warnf("String value of %d bytes for %s exceeds SiteWise limit of %d bytes, truncating it\n", len(value), propertyAlias, maxStringValueLength)
+	truncated := truncateString(value, maxStringValueLength)
+	variant.StringValue = &truncated
+	return true
+}
+
+// truncateString cuts value to at most maxBytes, without breaking multi-byte characters
+func truncateString(value string, maxBytes int) string {
+	if len(value) <= maxBytes {
+		return value
+	}
+	cut := maxBytes
+	for cut > 0 && !utf8.RuneStart(value[cut]) {
+		cut--
+	}
+	return value[:cut]
+}
+
 func (c *IotSiteWiseClient) PopulateSampledSamplesTimeSeriesByAlias(ctx context.Context, propertyAlias string, ts []int64, values []any) error {
 	if len(ts) != len(values) {
 		return fmt.Errorf("timestamps and values must have the same length")
@@ -452,9 +922,7 @@ func (c *IotSiteWiseClient) PopulateSampledSamplesTimeSeriesByAlias(ctx context.
 	if len(ts) == 0 {
 		return fmt.Errorf("no data to populate")
 	}
-	var data []types.PutAssetPropertyValueEntry
 	var pvalues []types.AssetPropertyValue
-	entry := "1"
 
 	for i := 0; i < len(ts); i++ {
 		variant := types.Variant{}
@@ -474,6 +942,9 @@ func (c *IotSiteWiseClient) PopulateSampledSamplesTimeSeriesByAlias(ctx context.
 			c.logger.Warn("Unsupported type: ", reflect.TypeOf(v))
 			continue
 		}
+		if !c.applyStringLimit(propertyAlias, &variant) {
+			continue
+		}
 
 		pvalues = append(pvalues, types.AssetPropertyValue{
 			Timestamp: &types.TimeInNanos{
@@ -484,45 +955,29 @@ func (c *IotSiteWiseClient) PopulateSampledSamplesTimeSeriesByAlias(ctx context.
 		})
 	}
 
-	data = append(data, types.PutAssetPropertyValueEntry{
-		EntryId:        &entry,
-		PropertyAlias:  &propertyAlias,
-		PropertyValues: pvalues,
-	})
-
-	out, err := c.svc.BatchPutAssetPropertyValue(ctx, &iotsitewise.BatchPutAssetPropertyValueInput{
-		Entries: data,
-	})
-	if err != nil {
-		return err
+	if len(pvalues) == 0 {
+		return nil
 	}
-	if out.ErrorEntries != nil {
-		for _, entry := range out.ErrorEntries {
-			c.logger.Error("Error on entry: ", *entry.EntryId)
-			if entry.Errors != nil {
-				for _, err := range entry.Errors {
-					c.logger.Error("		[Error sampling] ", err.ErrorCode, *err.ErrorMessage)
-				}
-			}
-		}
-	}
-	return nil
+
+	return c.putPropertyValuesByAlias(ctx, propertyAlias, pvalues, "[Error sampling]")
 }
 
 type DataPoint struct {
 	PropertyAlias string
 	Ts            int64
 	Value         any
+	// Quality of the data point, defaults to GOOD when not set
+	Quality types.Quality
 }
 
+// PopulateArbitrarySamplesByAlias writes data points of any alias, in requests of up to 10 entries.
+// Consecutive points of the same alias are grouped in entries of up to 10 values.
 func (c *IotSiteWiseClient) PopulateArbitrarySamplesByAlias(ctx context.Context, points []DataPoint) error {
 	if len(points) == 0 {
 		return fmt.Errorf("no data to populate")
 	}
 
 	var data []types.PutAssetPropertyValueEntry
-	entry := 1
-
 	for i := 0; i < len(points); i++ {
 		variant := types.Variant{}
 
@@ -556,64 +1011,169 @@ func (c *IotSiteWiseClient) PopulateArbitrarySamplesByAlias(ctx context.Context,
 			c.logger.Warn("Unsupported type: ", reflect.TypeOf(v))
 			continue
 		}
+		if !c.applyStringLimit(points[i].PropertyAlias, &variant) {
+			continue
+		}
 
-		entryIdStringValue := strconv.Itoa(entry)
-		data = append(data, types.PutAssetPropertyValueEntry{
-			EntryId:       &entryIdStringValue,
-			PropertyAlias: &points[i].PropertyAlias,
-			PropertyValues: []types.AssetPropertyValue{
-				{
-					Timestamp: &types.TimeInNanos{
-						TimeInSeconds: &points[i].Ts,
-					},
-					Value:   &variant,
-					Quality: types.QualityGood,
-				},
-			},
-		})
-
-		entry++
+		quality := types.QualityGood
+		if points[i].Quality != "" {
+			quality = points[i].Quality
+		}
 
-		if len(data) == 10 {
-			out, err := c.svc.BatchPutAssetPropertyValue(ctx, &iotsitewise.BatchPutAssetPropertyValueInput{
-				Entries: data,
-			})
-			if err != nil {
+		value := types.AssetPropertyValue{
+			Timestamp: &types.TimeInNanos{
+				TimeInSeconds: &points[i].Ts,
+			},
+			Value:   &variant,
+			Quality: quality,
+		}
+		// Consecutive points of the same alias share an entry, up to the per entry limit
+		if n := len(data); n > 0 && *data[n-1].PropertyAlias == points[i].PropertyAlias && len(data[n-1].PropertyValues) < maxValuesPerEntry {
+			data[n-1].PropertyValues = append(data[n-1].PropertyValues, value)
+			continue
+		}
+		if len(data) == maxEntriesPerBatch {
+			if err := c.batchPut(ctx, data, "[Error sampling]"); err != nil {
 				return err
 			}
-			if out.ErrorEntries != nil {
-				for _, entry := range out.ErrorEntries {
-					c.logger.Error("Error on entry: ", *entry.EntryId)
-					if entry.Errors != nil {
-						for _, err := range entry.Errors {
-							c.logger.Error("		[Error last value] ", err.ErrorCode, *err.ErrorMessage)
-						}
-					}
-				}
-			}
-			data = []types.PutAssetPropertyValueEntry{}
-			entry = 1
+			data = nil
 		}
+		entryId := strconv.Itoa(len(data) + 1)
+		data = append(data, types.PutAssetPropertyValueEntry{
+			EntryId:        &entryId,
+			PropertyAlias:  &points[i].PropertyAlias,
+			PropertyValues: []types.AssetPropertyValue{value},
+		})
 	}
 
 	if len(data) > 0 {
-		out, err := c.svc.BatchPutAssetPropertyValue(ctx, &iotsitewise.BatchPutAssetPropertyValueInput{
-			Entries: data,
+		return c.batchPut(ctx, data, "[Error sampling]")
+	}
+	return nil
+}
+
+// GetLatestAssetPropertyValue returns the latest value stored in SiteWise for the given asset property, along with its timestamp.
+func (c *IotSiteWiseClient) GetLatestAssetPropertyValue(ctx context.Context, assetId, propertyId string) (*types.Variant, time.Time, error) {
+	out, err := c.svc.GetAssetPropertyValue(ctx, &iotsitewise.GetAssetPropertyValueInput{
+		AssetId:    &assetId,
+		PropertyId: &propertyId,
+	})
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if out.PropertyValue == nil || out.PropertyValue.Value == nil {
+		return nil, time.Time{}, fmt.Errorf("%w for property %s of asset %s", ErrNoValue, propertyId, assetId)
+	}
+
+	var ts time.Time
+	if out.PropertyValue.Timestamp != nil && out.PropertyValue.Timestamp.TimeInSeconds != nil {
+		var nanos int64
+		if out.PropertyValue.Timestamp.OffsetInNanos != nil {
+			nanos = int64(*out.PropertyValue.Timestamp.OffsetInNanos)
+		}
+		ts = time.Unix(*out.PropertyValue.Timestamp.TimeInSeconds, nanos).UTC()
+	}
+	return out.PropertyValue.Value, ts, nil
+}
+
+// SetAssetPropertyAttribute sets the value of an attribute property, converted as done for data points.
+func (c *IotSiteWiseClient) SetAssetPropertyAttribute(ctx context.Context, propertyAlias string, value any) error {
+	return c.PopulateArbitrarySamplesByAlias(ctx, []DataPoint{{PropertyAlias: propertyAlias, Ts: time.Now().Unix(), Value: value}})
+}
+
+// CreateHierarchyAssetModel creates a model without properties, used to group assets of the given child models.
+func (c *IotSiteWiseClient) CreateHierarchyAssetModel(ctx context.Context, name, externalId string, hierarchies []types.AssetModelHierarchyDefinition) (*iotsitewise.CreateAssetModelOutput, error) {
+	return c.svc.CreateAssetModel(ctx, &iotsitewise.CreateAssetModelInput{
+		AssetModelName:        &name,
+		AssetModelExternalId:  &externalId,
+		AssetModelHierarchies: hierarchies,
+	})
+}
+
+// AddAssetModelHierarchies adds the given hierarchy definitions to an existing model, keeping its current definition.
+func (c *IotSiteWiseClient) AddAssetModelHierarchies(ctx context.Context, assetModel *iotsitewise.DescribeAssetModelOutput, hierarchies []types.AssetModelHierarchyDefinition) error {
+	if len(hierarchies) == 0 {
+		return nil
+	}
+	modelHierarchies := append([]types.AssetModelHierarchy{}, assetModel.AssetModelHierarchies...)
+	for _, h := range hierarchies {
+		modelHierarchies = append(modelHierarchies, types.AssetModelHierarchy{
+			Name:              h.Name,
+			ChildAssetModelId: h.ChildAssetModelId,
+			ExternalId:        h.ExternalId,
 		})
-		if err != nil {
+	}
+	_, err := c.svc.UpdateAssetModel(ctx, &iotsitewise.UpdateAssetModelInput{
+		AssetModelId:              assetModel.AssetModelId,
+		AssetModelName:            assetModel.AssetModelName,
+		AssetModelDescription:     assetModel.AssetModelDescription,
+		AssetModelHierarchies:     modelHierarchies,
+		AssetModelProperties:      assetModel.AssetModelProperties,
+		AssetModelExternalId:      assetModel.AssetModelExternalId,
+		AssetModelCompositeModels: assetModel.AssetModelCompositeModels,
+	})
+	return err
+}
+
+// AssociateAssets links a child asset to a parent asset, through the given hierarchy of the parent model.
+func (c *IotSiteWiseClient) AssociateAssets(ctx context.Context, parentAssetId, hierarchyId, childAssetId string) error {
+	_, err := c.svc.AssociateAssets(ctx, &iotsitewise.AssociateAssetsInput{
+		AssetId:      &parentAssetId,
+		HierarchyId:  &hierarchyId,
+		ChildAssetId: &childAssetId,
+	})
+	return err
+}
+
+// GetParentAssetId returns the id of the asset the given asset is associated to, or nil if it has no parent.
+func (c *IotSiteWiseClient) GetParentAssetId(ctx context.Context, assetId string) (*string, error) {
+	out, err := c.svc.ListAssociatedAssets(ctx, &iotsitewise.ListAssociatedAssetsInput{
+		AssetId:            &assetId,
+		TraversalDirection: types.TraversalDirectionParent,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(out.AssetSummaries) == 0 {
+		return nil, nil
+	}
+	return out.AssetSummaries[0].Id, nil
+}
+
+// DisassociateFromParent removes the association of the given asset with its parent, if any.
+// SiteWise doesn't allow deleting assets that are still associated.
+func (c *IotSiteWiseClient) DisassociateFromParent(ctx context.Context, assetId, assetModelId string) error {
+	out, err := c.svc.ListAssociatedAssets(ctx, &iotsitewise.ListAssociatedAssetsInput{
+		AssetId:            &assetId,
+		TraversalDirection: types.TraversalDirectionParent,
+	})
+	if err != nil {
+		return err
+	}
+	if len(out.AssetSummaries) == 0 {
+		return nil
+	}
+	parent := out.AssetSummaries[0]
+	parentModel, err := c.DescribeAssetModel(ctx, parent.AssetModelId)
+	if err != nil {
+		return err
+	}
+	for _, h := range parentModel.AssetModelHierarchies {
+		if h.ChildAssetModelId != nil && *h.ChildAssetModelId == assetModelId {
+			_, err = c.svc.DisassociateAssets(ctx, &iotsitewise.DisassociateAssetsInput{
+				AssetId:      parent.Id,
+				HierarchyId:  h.Id,
+				ChildAssetId: &assetId,
+			})
 			return err
 		}
-		if out.ErrorEntries != nil {
-			for _, entry := range out.ErrorEntries {
-				c.logger.Error("Error on entry: ", *entry.EntryId)
-				if entry.Errors != nil {
-					for _, err := range entry.Errors {
-						c.logger.Error("		[Error sampling] ", err.ErrorCode, *err.ErrorMessage)
-					}
-				}
-			}
-		}
 	}
+	return fmt.Errorf("no hierarchy for model %s found in parent asset %s", assetModelId, *parent.Id)
+}
 
-	return nil
+func (c *IotSiteWiseClient) DeleteAsset(ctx context.Context, assetId string) error {
+	_, err := c.svc.DeleteAsset(ctx, &iotsitewise.DeleteAssetInput{
+		AssetId: &assetId,
+	})
+	return err
 }
@@ -0,0 +1,671 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package sitewiseclient
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/iotsitewise"
+	"github.com/aws/aws-sdk-go-v2/service/iotsitewise/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSiteWise records calls performed against the SiteWise SDK client
+type fakeSiteWise struct {
+	sitewiseAPI
+	batchPuts []*iotsitewise.BatchPutAssetPropertyValueInput
+
+	updateModelErrors []error
+	updateModelInputs []*iotsitewise.UpdateAssetModelInput
+	describedModel    *iotsitewise.DescribeAssetModelOutput
+	describeModels    int
+
+	parents       []types.AssociatedAssetsSummary
+	disassociated []*iotsitewise.DisassociateAssetsInput
+
+	createdModels []*iotsitewise.CreateAssetModelInput
+
+	assetPages       []*iotsitewise.ListAssetsOutput
+	listAssetsInputs []*iotsitewise.ListAssetsInput
+
+	modelPages            []*iotsitewise.ListAssetModelsOutput
+	listAssetModelsInputs []*iotsitewise.ListAssetModelsInput
+
+	jobStatuses  []types.JobStatus
+	describeJobs int
+
+	describedAsset         *iotsitewise.DescribeAssetOutput
+	updatedAssetProperties []*iotsitewise.UpdateAssetPropertyInput
+}
+
+func (f *fakeSiteWise) DescribeAsset(ctx context.Context, params *iotsitewise.DescribeAssetInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.DescribeAssetOutput, error) {
+	return f.describedAsset, nil
+}
+
+func (f *fakeSiteWise) UpdateAssetProperty(ctx context.Context, params *iotsitewise.UpdateAssetPropertyInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.UpdateAssetPropertyOutput, error) {
+	f.updatedAssetProperties = append(f.updatedAssetProperties, params)
+	return &iotsitewise.UpdateAssetPropertyOutput{}, nil
+}
+
+func (f *fakeSiteWise) CreateAssetModel(ctx context.Context, params *iotsitewise.CreateAssetModelInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.CreateAssetModelOutput, error) {
+	f.createdModels = append(f.createdModels, params)
+	return &iotsitewise.CreateAssetModelOutput{}, nil
+}
+
+func (f *fakeSiteWise) UpdateAssetModel(ctx context.Context, params *iotsitewise.UpdateAssetModelInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.UpdateAssetModelOutput, error) {
+	f.updateModelInputs = append(f.updateModelInputs, params)
+	if len(f.updateModelErrors) > 0 {
+		err := f.updateModelErrors[0]
+		f.updateModelErrors = f.updateModelErrors[1:]
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &iotsitewise.UpdateAssetModelOutput{}, nil
+}
+
+func (f *fakeSiteWise) DescribeAssetModel(ctx context.Context, params *iotsitewise.DescribeAssetModelInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.DescribeAssetModelOutput, error) {
+	f.describeModels++
+	return f.describedModel, nil
+}
+
+func (f *fakeSiteWise) BatchPutAssetPropertyValue(ctx context.Context, params *iotsitewise.BatchPutAssetPropertyValueInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.BatchPutAssetPropertyValueOutput, error) {
+	f.batchPuts = append(f.batchPuts, params)
+	return &iotsitewise.BatchPutAssetPropertyValueOutput{}, nil
+}
+
+func (f *fakeSiteWise) ListAssociatedAssets(ctx context.Context, params *iotsitewise.ListAssociatedAssetsInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.ListAssociatedAssetsOutput, error) {
+	return &iotsitewise.ListAssociatedAssetsOutput{AssetSummaries: f.parents}, nil
+}
+
+func (f *fakeSiteWise) DisassociateAssets(ctx context.Context, params *iotsitewise.DisassociateAssetsInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.DisassociateAssetsOutput, error) {
+	f.disassociated = append(f.disassociated, params)
+	return &iotsitewise.DisassociateAssetsOutput{}, nil
+}
+
+func (f *fakeSiteWise) ListAssets(ctx context.Context, params *iotsitewise.ListAssetsInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.ListAssetsOutput, error) {
+	page := f.assetPages[len(f.listAssetsInputs)]
+	f.listAssetsInputs = append(f.listAssetsInputs, params)
+	return page, nil
+}
+
+func (f *fakeSiteWise) ListAssetModels(ctx context.Context, params *iotsitewise.ListAssetModelsInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.ListAssetModelsOutput, error) {
+	page := f.modelPages[len(f.listAssetModelsInputs)]
+	f.listAssetModelsInputs = append(f.listAssetModelsInputs, params)
+	return page, nil
+}
+
+func (f *fakeSiteWise) DescribeBulkImportJob(ctx context.Context, params *iotsitewise.DescribeBulkImportJobInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.DescribeBulkImportJobOutput, error) {
+	status := f.jobStatuses[min(f.describeJobs, len(f.jobStatuses)-1)]
+	f.describeJobs++
+	return &iotsitewise.DescribeBulkImportJobOutput{JobId: params.JobId, JobStatus: status}, nil
+}
+
+func newTestClient(svc sitewiseAPI) *IotSiteWiseClient {
+	return &IotSiteWiseClient{svc: svc, logger: logrus.NewEntry(logrus.New()), stringLimitPolicy: StringLimitTruncate}
+}
+
+func TestPopulateTimeSeriesByAlias_chunksEntries(t *testing.T) {
+	fake := &fakeSiteWise{}
+	cl := newTestClient(fake)
+
+	ts := make([]int64, 250)
+	values := make([]float64, 250)
+	for i := range ts {
+		ts[i] = int64(1700000000 + i)
+		values[i] = float64(i)
+	}
+
+	err := cl.PopulateTimeSeriesByAlias(context.Background(), "/thing/temperature", ts, values)
+	assert.Nil(t, err)
+
+	// 250 points -> 25 entries of 10 values -> 3 batches (10, 10, 5 entries)
+	assert.Equal(t, 3, len(fake.batchPuts))
+	assert.Equal(t, 10, len(fake.batchPuts[0].Entries))
+	assert.Equal(t, 10, len(fake.batchPuts[1].Entries))
+	assert.Equal(t, 5, len(fake.batchPuts[2].Entries))
+
+	written := 0
+	for _, batch := range fake.batchPuts {
+		entryIds := map[string]bool{}
+		for _, entry := range batch.Entries {
+			assert.LessOrEqual(t, len(entry.PropertyValues), maxValuesPerEntry)
+			assert.False(t, entryIds[*entry.EntryId], "entry ids must be unique within a batch")
+			entryIds[*entry.EntryId] = true
+			for _, v := range entry.PropertyValues {
+				assert.Equal(t, int64(1700000000+written), *v.Timestamp.TimeInSeconds)
+				written++
+			}
+		}
+	}
+	assert.Equal(t, 250, written)
+}
+
+func TestPopulateTimeSeriesByAlias_smallInputSingleEntry(t *testing.T) {
+	fake := &fakeSiteWise{}
+	cl := newTestClient(fake)
+
+	err := cl.PopulateTimeSeriesByAlias(context.Background(), "/thing/temperature", []int64{1, 2, 3}, []float64{1, 2, 3})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(fake.batchPuts))
+	assert.Equal(t, 1, len(fake.batchPuts[0].Entries))
+	assert.Equal(t, 3, len(fake.batchPuts[0].Entries[0].PropertyValues))
+}
+
+func TestPopulateArbitrarySamplesByAlias_groupsEntriesAcrossAliases(t *testing.T) {
+	fake := &fakeSiteWise{}
+	cl := newTestClient(fake)
+
+	var points []DataPoint
+	for i := range 12 {
+		points = append(points, DataPoint{PropertyAlias: "/thing/temperature", Ts: int64(1700000000 + i), Value: float64(i)})
+	}
+	for i := range 3 {
+		points = append(points, DataPoint{PropertyAlias: "/thing/status", Ts: int64(1700000000 + i), Value: "ok"})
+	}
+	for i := range 85 {
+		points = append(points, DataPoint{PropertyAlias: fmt.Sprintf("/thing/p%d", i), Ts: 1700000000, Value: true})
+	}
+
+	err := cl.PopulateArbitrarySamplesByAlias(context.Background(), points)
+	assert.Nil(t, err)
+
+	// temperature -> 2 entries, status -> 1 entry, 85 single point aliases -> 85 entries: 88 entries in 9 batches
+	assert.Equal(t, 9, len(fake.batchPuts))
+	assert.Equal(t, 8, len(fake.batchPuts[8].Entries))
+	first := fake.batchPuts[0].Entries
+	assert.Equal(t, "/thing/temperature", *first[0].PropertyAlias)
+	assert.Equal(t, 10, len(first[0].PropertyValues))
+	assert.Equal(t, "/thing/temperature", *first[1].PropertyAlias)
+	assert.Equal(t, 2, len(first[1].PropertyValues))
+	assert.Equal(t, "/thing/status", *first[2].PropertyAlias)
+	assert.Equal(t, 3, len(first[2].PropertyValues))
+}
+
+func TestUpdateAssetModelProperties_retryOnConflict(t *testing.T) {
+	modelId := "03ba45c2-eab3-44ed-a68f-94a26d41df4c"
+	initialModel := &iotsitewise.DescribeAssetModelOutput{
+		AssetModelId: &modelId,
+		AssetModelProperties: []types.AssetModelProperty{
+			{Id: toPtr("p1"), Name: toPtr("temperature")},
+		},
+	}
+	// Concurrent update added a new property in the meantime
+	currentModel := &iotsitewise.DescribeAssetModelOutput{
+		AssetModelId: &modelId,
+		AssetModelProperties: []types.AssetModelProperty{
+			{Id: toPtr("p1"), Name: toPtr("temperature")},
+			{Id: toPtr("p2"), Name: toPtr("humidity")},
+		},
+	}
+
+	fake := &fakeSiteWise{
+		updateModelErrors: []error{&types.ConflictingOperationException{Message: toPtr("conflict")}, nil},
+		describedModel:    currentModel,
+	}
+	cl := newTestClient(fake)
+	cl.updateConflictRetries = 3
+	cl.updateConflictBackoff = time.Millisecond
+
+	err := cl.UpdateAssetModelProperties(context.Background(), initialModel, map[string]string{
+		"temperature": "FLOAT",
+		"humidity":    "FLOAT",
+		"pressure":    "FLOAT",
+	}, map[string][]string{})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(fake.updateModelInputs))
+	assert.Equal(t, 1, fake.describeModels)
+	// Retried update is based on the re-described model
+	assert.Equal(t, 3, len(fake.updateModelInputs[1].AssetModelProperties))
+}
+
+func TestUpdateAssetModelProperties_conflictRetriesExhausted(t *testing.T) {
+	modelId := "03ba45c2-eab3-44ed-a68f-94a26d41df4c"
+	model := &iotsitewise.DescribeAssetModelOutput{AssetModelId: &modelId}
+	conflict := &types.ConflictingOperationException{Message: toPtr("conflict")}
+
+	fake := &fakeSiteWise{
+		updateModelErrors: []error{conflict, conflict, conflict},
+		describedModel:    model,
+	}
+	cl := newTestClient(fake)
+	cl.updateConflictRetries = 2
+	cl.updateConflictBackoff = time.Millisecond
+
+	err := cl.UpdateAssetModelProperties(context.Background(), model, map[string]string{"temperature": "FLOAT"}, map[string][]string{})
+	assert.ErrorAs(t, err, &conflict)
+	assert.Equal(t, 3, len(fake.updateModelInputs))
+}
+
+func TestRemoveAssetModelProperties(t *testing.T) {
+	modelId := "03ba45c2-eab3-44ed-a68f-94a26d41df4c"
+	model := &iotsitewise.DescribeAssetModelOutput{
+		AssetModelId: &modelId,
+		AssetModelProperties: []types.AssetModelProperty{
+			{Id: toPtr("p1"), Name: toPtr("temperature")},
+			{Id: toPtr("p2"), Name: toPtr("humidity")},
+		},
+		AssetModelCompositeModels: []types.AssetModelCompositeModel{
+			{
+				Name: toPtr("properties_1"),
+				Properties: []types.AssetModelProperty{
+					{Id: toPtr("p3"), Name: toPtr("pressure")},
+					{Id: toPtr("p4"), Name: toPtr("voltage")},
+				},
+			},
+		},
+	}
+
+	fake := &fakeSiteWise{}
+	cl := newTestClient(fake)
+
+	err := cl.RemoveAssetModelProperties(context.Background(), model, []string{"humidity", "voltage"})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(fake.updateModelInputs))
+	update := fake.updateModelInputs[0]
+	assert.Equal(t, 1, len(update.AssetModelProperties))
+	assert.Equal(t, "temperature", *update.AssetModelProperties[0].Name)
+	assert.Equal(t, 1, len(update.AssetModelCompositeModels[0].Properties))
+	assert.Equal(t, "pressure", *update.AssetModelCompositeModels[0].Properties[0].Name)
+	// Described model is left untouched
+	assert.Equal(t, 2, len(model.AssetModelCompositeModels[0].Properties))
+
+	// Nothing to remove, no update
+	err = cl.RemoveAssetModelProperties(context.Background(), model, []string{"current"})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(fake.updateModelInputs))
+}
+
+func TestPopulateSampledSamples_stringLimitPolicy(t *testing.T) {
+	oversized := strings.Repeat("a", 1500)
+	longJson := map[string]any{"note": strings.Repeat("b", 1100)}
+
+	// Default policy truncates
+	fake := &fakeSiteWise{}
+	cl := newTestClient(fake)
+	err := cl.PopulateSampledSamplesTimeSeriesByAlias(context.Background(), "/thing/msg", []int64{1, 2, 3}, []any{"short", oversized, longJson})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(fake.batchPuts))
+	values := fake.batchPuts[0].Entries[0].PropertyValues
+	assert.Equal(t, 3, len(values))
+	assert.Equal(t, "short", *values[0].Value.StringValue)
+	assert.Equal(t, maxStringValueLength, len(*values[1].Value.StringValue))
+	assert.Equal(t, maxStringValueLength, len(*values[2].Value.StringValue))
+
+	// Skip policy drops oversized values only
+	fake = &fakeSiteWise{}
+	cl = newTestClient(fake)
+	cl.stringLimitPolicy = StringLimitSkip
+	err = cl.PopulateArbitrarySamplesByAlias(context.Background(), []DataPoint{
+		{PropertyAlias: "/thing/msg", Ts: 1, Value: "short"},
+		{PropertyAlias: "/thing/other", Ts: 1, Value: oversized},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(fake.batchPuts))
+	assert.Equal(t, 1, len(fake.batchPuts[0].Entries))
+	assert.Equal(t, "/thing/msg", *fake.batchPuts[0].Entries[0].PropertyAlias)
+}
+
+func TestTruncateString_keepsRunesIntact(t *testing.T) {
+	value := strings.Repeat("a", 1023) + "è"
+	truncated := truncateString(value, 1024)
+	assert.Equal(t, strings.Repeat("a", 1023), truncated)
+	assert.Equal(t, "abc", truncateString("abc", 1024))
+}
+
+func toPtr(val string) *string {
+	return &val
+}
+
+func TestPollForModelActiveStatus_contextCancelled(t *testing.T) {
+	svc := &fakeSiteWise{describedModel: &iotsitewise.DescribeAssetModelOutput{
+		AssetModelId:     toPtr("model-id"),
+		AssetModelStatus: &types.AssetModelStatus{State: types.AssetModelStateCreating},
+	}}
+	cl := newTestClient(svc)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	err := cl.PollForModelActiveStatus(ctx, "model-id", 10)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, time.Since(start), pollInterval)
+	assert.Equal(t, 1, svc.describeModels)
+}
+
+func TestPollForModelActiveStatus_active(t *testing.T) {
+	svc := &fakeSiteWise{describedModel: &iotsitewise.DescribeAssetModelOutput{
+		AssetModelId:     toPtr("model-id"),
+		AssetModelStatus: &types.AssetModelStatus{State: types.AssetModelStateActive},
+	}}
+	cl := newTestClient(svc)
+
+	assert.NoError(t, cl.PollForModelActiveStatus(context.Background(), "model-id", 10))
+}
+
+func TestWaitForBulkImportJob_terminalStatus(t *testing.T) {
+	svc := &fakeSiteWise{jobStatuses: []types.JobStatus{types.JobStatusPending, types.JobStatusRunning, types.JobStatusCompletedWithFailures}}
+	cl := newTestClient(svc)
+
+	status, err := cl.WaitForBulkImportJob(context.Background(), "job-id", PollOptions{Interval: time.Millisecond, MaxWait: time.Second})
+	assert.NoError(t, err)
+	assert.Equal(t, types.JobStatusCompletedWithFailures, status)
+	assert.Equal(t, 3, svc.describeJobs)
+}
+
+func TestWaitForBulkImportJob_timeout(t *testing.T) {
+	svc := &fakeSiteWise{jobStatuses: []types.JobStatus{types.JobStatusRunning}}
+	cl := newTestClient(svc)
+
+	status, err := cl.WaitForBulkImportJob(context.Background(), "job-id", PollOptions{Interval: time.Millisecond, MaxWait: 3 * time.Millisecond})
+	assert.ErrorIs(t, err, ErrPollTimeout)
+	assert.ErrorContains(t, err, "job-id")
+	assert.Equal(t, types.JobStatusRunning, status)
+}
+
+func TestInterfaceToString_compositeValuesAreJSON(t *testing.T) {
+	type location struct {
+		Lat float64 `json:"lat"`
+		Lon float64 `json:"lon"`
+	}
+
+	assert.Equal(t, "hello", interfaceToString("hello"))
+	assert.Equal(t, "42", interfaceToString(42))
+	assert.Equal(t, "1.5", interfaceToString(1.5))
+	assert.Equal(t, "true", interfaceToString(true))
+	assert.Equal(t, "7", interfaceToString(int64(7)))
+
+	assert.Equal(t, `[1,2,3]`, interfaceToString([]any{1, 2, 3}))
+	assert.Equal(t, `["a","b"]`, interfaceToString([]string{"a", "b"}))
+	assert.Equal(t, `{"a":{"b":[1,2]},"c":"d"}`, interfaceToString(map[string]any{"a": map[string]any{"b": []int{1, 2}}, "c": "d"}))
+	assert.Equal(t, `{"lat":45.1,"lon":7.6}`, interfaceToString(location{Lat: 45.1, Lon: 7.6}))
+	assert.Equal(t, `{"lat":45.1,"lon":7.6}`, interfaceToString(&location{Lat: 45.1, Lon: 7.6}))
+}
+
+func TestPollForModelActiveStatusWithOptions_backoffWithinMaxWait(t *testing.T) {
+	svc := &fakeSiteWise{describedModel: &iotsitewise.DescribeAssetModelOutput{
+		AssetModelId:     toPtr("model-id"),
+		AssetModelStatus: &types.AssetModelStatus{State: types.AssetModelStateCreating},
+	}}
+	cl := newTestClient(svc)
+
+	// Waits 10ms, 20ms, then the remaining 5ms: 4 checks in total
+	err := cl.PollForModelActiveStatusWithOptions(context.Background(), "model-id", PollOptions{
+		Interval: 10 * time.Millisecond,
+		MaxWait:  35 * time.Millisecond,
+		Backoff:  2,
+	})
+	assert.ErrorIs(t, err, ErrPollTimeout)
+	assert.Equal(t, 4, svc.describeModels)
+}
+
+func TestPollForModelActiveStatus_legacyRetryCount(t *testing.T) {
+	svc := &fakeSiteWise{describedModel: &iotsitewise.DescribeAssetModelOutput{
+		AssetModelId:     toPtr("model-id"),
+		AssetModelStatus: &types.AssetModelStatus{State: types.AssetModelStateCreating},
+	}}
+	cl := newTestClient(svc)
+
+	err := cl.PollForModelActiveStatus(context.Background(), "model-id", 1)
+	assert.ErrorIs(t, err, ErrPollTimeout)
+	assert.Equal(t, 1, svc.describeModels)
+}
+
+func TestDisassociateFromParent(t *testing.T) {
+	svc := &fakeSiteWise{
+		parents: []types.AssociatedAssetsSummary{{Id: toPtr("device-asset"), AssetModelId: toPtr("device-model")}},
+		describedModel: &iotsitewise.DescribeAssetModelOutput{
+			AssetModelId: toPtr("device-model"),
+			AssetModelHierarchies: []types.AssetModelHierarchy{
+				{Id: toPtr("other-hierarchy"), ChildAssetModelId: toPtr("other-model")},
+				{Id: toPtr("things-hierarchy"), ChildAssetModelId: toPtr("thing-model")},
+			},
+		},
+	}
+	cl := newTestClient(svc)
+
+	assert.NoError(t, cl.DisassociateFromParent(context.Background(), "thing-asset", "thing-model"))
+	assert.Len(t, svc.disassociated, 1)
+	assert.Equal(t, "device-asset", *svc.disassociated[0].AssetId)
+	assert.Equal(t, "things-hierarchy", *svc.disassociated[0].HierarchyId)
+	assert.Equal(t, "thing-asset", *svc.disassociated[0].ChildAssetId)
+}
+
+func TestDisassociateFromParent_noParent(t *testing.T) {
+	svc := &fakeSiteWise{}
+	cl := newTestClient(svc)
+
+	assert.NoError(t, cl.DisassociateFromParent(context.Background(), "thing-asset", "thing-model"))
+	assert.Empty(t, svc.disassociated)
+	assert.Equal(t, 0, svc.describeModels)
+}
+
+func TestListAllAssetsForModel_followsNextToken(t *testing.T) {
+	fake := &fakeSiteWise{
+		assetPages: []*iotsitewise.ListAssetsOutput{
+			{AssetSummaries: []types.AssetSummary{{Id: toPtr("a1")}}, NextToken: toPtr("page2")},
+			{AssetSummaries: []types.AssetSummary{{Id: toPtr("a2")}}, NextToken: toPtr("page3")},
+			{AssetSummaries: []types.AssetSummary{{Id: toPtr("a3")}}},
+		},
+	}
+	cl := newTestClient(fake)
+
+	assets, err := cl.ListAllAssetsForModel(context.Background(), toPtr("model"))
+	assert.NoError(t, err)
+	assert.Len(t, assets, 3)
+	assert.Equal(t, "a3", *assets[2].Id)
+
+	assert.Len(t, fake.listAssetsInputs, 3)
+	assert.Nil(t, fake.listAssetsInputs[0].NextToken)
+	assert.Equal(t, "page2", *fake.listAssetsInputs[1].NextToken)
+	assert.Equal(t, "page3", *fake.listAssetsInputs[2].NextToken)
+	for _, call := range fake.listAssetsInputs {
+		assert.Equal(t, "model", *call.AssetModelId)
+	}
+}
+
+func TestListAllAssetModels_followsNextToken(t *testing.T) {
+	fake := &fakeSiteWise{
+		modelPages: []*iotsitewise.ListAssetModelsOutput{
+			{AssetModelSummaries: []types.AssetModelSummary{{Id: toPtr("model1")}}, NextToken: toPtr("page2")},
+			{AssetModelSummaries: []types.AssetModelSummary{{Id: toPtr("model2")}}},
+		},
+	}
+	cl := newTestClient(fake)
+
+	models, err := cl.ListAllAssetModels(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, models, 2)
+	assert.Equal(t, "model1", *models[0].Id)
+	assert.Equal(t, "model2", *models[1].Id)
+
+	// Second page must be requested with the token returned by the first one
+	assert.Len(t, fake.listAssetModelsInputs, 2)
+	assert.Nil(t, fake.listAssetModelsInputs[0].NextToken)
+	assert.Equal(t, "page2", *fake.listAssetModelsInputs[1].NextToken)
+}
+
+func TestCreateSplitAssetModel_compositeModels(t *testing.T) {
+	fake := &fakeSiteWise{}
+	client := newTestClient(fake)
+
+	properties := map[string]string{"e": "FLOAT", "d": "INT", "c": "CHARSTRING", "b": "FLOAT", "a": "BOOL"}
+	_, err := client.CreateSplitAssetModel(context.Background(), "model", "thing-model-1", properties, 2, nil)
+	assert.NoError(t, err)
+
+	propertyNames := func(definitions []types.AssetModelPropertyDefinition) []string {
+		names := []string{}
+		for _, d := range definitions {
+			names = append(names, *d.Name)
+		}
+		return names
+	}
+	if assert.Len(t, fake.createdModels, 1) {
+		input := fake.createdModels[0]
+		assert.Equal(t, "thing-model-1", *input.AssetModelExternalId)
+		assert.ElementsMatch(t, []string{"a", "b"}, propertyNames(input.AssetModelProperties))
+		if assert.Len(t, input.AssetModelCompositeModels, 2) {
+			assert.Equal(t, "properties_1", *input.AssetModelCompositeModels[0].Name)
+			assert.ElementsMatch(t, []string{"c", "d"}, propertyNames(input.AssetModelCompositeModels[0].Properties))
+			assert.Equal(t, "properties_2", *input.AssetModelCompositeModels[1].Name)
+			assert.ElementsMatch(t, []string{"e"}, propertyNames(input.AssetModelCompositeModels[1].Properties))
+		}
+	}
+}
+
+func TestCreateAssetModel_attributeProperties(t *testing.T) {
+	fake := &fakeSiteWise{}
+	client := newTestClient(fake)
+
+	properties := map[string]string{"temperature": "TEMPERATURE_C", "threshold": AttributeType("TEMPERATURE_C")}
+	uomMap := map[string][]string{"TEMPERATURE_C": {"Cel"}}
+	_, err := client.CreateAssetModel(context.Background(), "model", "", properties, uomMap)
+	assert.NoError(t, err)
+
+	if assert.Len(t, fake.createdModels, 1) {
+		for _, definition := range fake.createdModels[0].AssetModelProperties {
+			assert.Equal(t, types.PropertyDataTypeDouble, definition.DataType)
+			assert.Equal(t, "Cel", *definition.Unit)
+			if *definition.Name == "threshold" {
+				assert.NotNil(t, definition.Type.Attribute)
+				assert.Nil(t, definition.Type.Measurement)
+			} else {
+				assert.NotNil(t, definition.Type.Measurement)
+			}
+		}
+	}
+}
+
+func TestPropertyTypeMismatches(t *testing.T) {
+	measurement := &types.PropertyType{Measurement: &types.Measurement{}}
+	model := &iotsitewise.DescribeAssetModelOutput{
+		AssetModelProperties: []types.AssetModelProperty{
+			{Name: toPtr("temperature"), DataType: types.PropertyDataTypeDouble, Type: measurement},
+			{Name: toPtr("status"), DataType: types.PropertyDataTypeDouble, Type: measurement},
+			{Name: toPtr("message"), DataType: types.PropertyDataTypeString, Type: measurement},
+		},
+		AssetModelCompositeModels: []types.AssetModelCompositeModel{{
+			Properties: []types.AssetModelProperty{{Name: toPtr("counter"), DataType: types.PropertyDataTypeString, Type: measurement}},
+		}},
+	}
+	mismatches := PropertyTypeMismatches(model, map[string]string{
+		"temperature": "FLOAT",
+		"status":      "CHARSTRING",
+		"message":     "CHARSTRING",
+		"counter":     "INT",
+	})
+	assert.Equal(t, []string{"counter", "status"}, mismatches)
+}
+
+func TestBuildAssetModelUpdate_compositeModelPropertiesNotAdded(t *testing.T) {
+	name, id, compositeName := "temperature", "p1", "properties_1"
+	model := &iotsitewise.DescribeAssetModelOutput{
+		AssetModelId:   &id,
+		AssetModelName: &name,
+		AssetModelCompositeModels: []types.AssetModelCompositeModel{
+			{Name: &compositeName, Properties: []types.AssetModelProperty{{Name: &name, Id: &id}}},
+		},
+	}
+	_, modified := buildAssetModelUpdate(model, map[string]string{"temperature": "FLOAT"}, nil)
+	assert.False(t, modified)
+}
+
+func TestBuildAssetModelUpdate_unitChanged(t *testing.T) {
+	id := "03ba45c2-eab3-44ed-a68f-94a26d41df4c"
+	model := &iotsitewise.DescribeAssetModelOutput{
+		AssetModelId: &id,
+		AssetModelProperties: []types.AssetModelProperty{
+			{Id: toPtr("p1"), Name: toPtr("temperature")},
+			{Id: toPtr("p2"), Name: toPtr("humidity"), Unit: toPtr("%")},
+		},
+	}
+	uomMap := map[string][]string{"TEMPERATURE_C": {"Cel"}, "HUMIDITY": {"%"}}
+
+	// Unit assigned to a property already in the model
+	update, modified := buildAssetModelUpdate(model, map[string]string{"temperature": "TEMPERATURE_C", "humidity": "HUMIDITY"}, uomMap)
+	assert.True(t, modified)
+	assert.Equal(t, 2, len(update.AssetModelProperties))
+	assert.Equal(t, "Cel", *update.AssetModelProperties[0].Unit)
+	assert.Equal(t, "%", *update.AssetModelProperties[1].Unit)
+	// Described model is left untouched
+	assert.Nil(t, model.AssetModelProperties[0].Unit)
+
+	// Same units, nothing to update
+	model.AssetModelProperties[0].Unit = toPtr("Cel")
+	_, modified = buildAssetModelUpdate(model, map[string]string{"temperature": "TEMPERATURE_C", "humidity": "HUMIDITY"}, uomMap)
+	assert.False(t, modified)
+}
+
+func TestDryRun_mutatingCallsShortCircuited(t *testing.T) {
+	ctx := context.Background()
+	// Mutating calls not overridden by the fake would panic on the nil SDK client
+	fake := &fakeSiteWise{describedModel: &iotsitewise.DescribeAssetModelOutput{AssetModelName: toPtr("existing")}}
+	client := newTestClient(newDryRunSiteWise(fake, logrus.NewEntry(logrus.New())))
+
+	model, err := client.CreateAssetModel(ctx, "Thing Model from (thing1)", "", map[string]string{"temperature": "FLOAT"}, nil)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(*model.AssetModelId, dryRunIdPrefix))
+	assert.Empty(t, fake.createdModels)
+	assert.NoError(t, client.PollForModelActiveStatusWithOptions(ctx, *model.AssetModelId, DefaultPollOptions))
+
+	asset, err := client.CreateAsset(ctx, "thing1", *model.AssetModelId, "bb831f04-0940-4ea6-9c24-83668e372919", nil)
+	assert.NoError(t, err)
+	described, err := client.DescribeAsset(ctx, *asset.AssetId)
+	assert.NoError(t, err)
+	if assert.Len(t, described.AssetProperties, 1) {
+		assert.Equal(t, "temperature", *described.AssetProperties[0].Name)
+	}
+	assert.NoError(t, client.UpdateAssetProperties(ctx, *asset.AssetId, map[string]string{"temperature": "/thing/temperature"}))
+
+	assert.NoError(t, client.PopulateTimeSeriesByAlias(ctx, "/thing/temperature", []int64{1700000000}, []float64{1.0}))
+	assert.Empty(t, fake.batchPuts)
+
+	// Reads of existing entities go to SiteWise
+	existing, err := client.DescribeAssetModel(ctx, toPtr("03ba45c2-eab3-44ed-a68f-94a26d41df4c"))
+	assert.NoError(t, err)
+	assert.Equal(t, "existing", *existing.AssetModelName)
+	assert.Equal(t, 1, fake.describeModels)
+}
+
+func TestUpdateAssetProperties_distinctAliases(t *testing.T) {
+	fake := &fakeSiteWise{describedAsset: &iotsitewise.DescribeAssetOutput{
+		AssetProperties: []types.AssetProperty{
+			{Id: toPtr("p1"), Name: toPtr("temperature"), Alias: toPtr("/thing/temperature")},
+			{Id: toPtr("p2"), Name: toPtr("pressure"), Alias: toPtr("/thing/pressure_old")},
+			{Id: toPtr("p3"), Name: toPtr("humidity")},
+		},
+	}}
+	client := newTestClient(fake)
+
+	// Properties already having their alias are not updated, whatever the order of the asset properties
+	err := client.UpdateAssetProperties(context.Background(), "asset", map[string]string{
+		"temperature": "/thing/temperature",
+		"pressure":    "/thing/pressure",
+		"humidity":    "/thing/humidity",
+	})
+	assert.NoError(t, err)
+	updated := map[string]string{}
+	for _, input := range fake.updatedAssetProperties {
+		updated[*input.PropertyId] = *input.PropertyAlias
+	}
+	assert.Equal(t, map[string]string{"p2": "/thing/pressure", "p3": "/thing/humidity"}, updated)
+}
@@ -0,0 +1,86 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package sitewiseclient
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iotsitewise"
+	"github.com/aws/aws-sdk-go-v2/service/iotsitewise/types"
+)
+
+// Type of the composite models holding thing properties exceeding the per model limit
+const propertiesCompositeModelType = "CUSTOM"
+
+// CreateSplitAssetModel creates an asset model holding at most maxProperties properties, moving the
+// remaining ones, in name order, into composite models of at most maxProperties properties each.
+func (c *IotSiteWiseClient) CreateSplitAssetModel(ctx context.Context, name, externalId string, properties map[string]string, maxProperties int, uomMap map[string][]string) (*iotsitewise.CreateAssetModelOutput, error) {
+	groups := splitProperties(properties, maxProperties)
+	compositeModels := make([]types.AssetModelCompositeModelDefinition, 0, len(groups)-1)
+	for i, group := range groups[1:] {
+		compositeModels = append(compositeModels, types.AssetModelCompositeModelDefinition{
+			Name:       aws.String(fmt.Sprintf("properties_%d", i+1)),
+			Type:       aws.String(propertiesCompositeModelType),
+			Properties: modelPropertyDefinitions(group, uomMap),
+		})
+	}
+	return c.svc.CreateAssetModel(ctx, &iotsitewise.CreateAssetModelInput{
+		AssetModelName:            &name,
+		AssetModelExternalId:      optionalExternalId(externalId),
+		AssetModelProperties:      modelPropertyDefinitions(groups[0], uomMap),
+		AssetModelCompositeModels: compositeModels,
+	})
+}
+
+// splitProperties splits properties, sorted by name, in groups of at most maxProperties.
+func splitProperties(properties map[string]string, maxProperties int) []map[string]string {
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	maxProperties = max(maxProperties, 1)
+	groups := []map[string]string{{}}
+	for _, name := range names {
+		if len(groups[len(groups)-1]) == maxProperties {
+			groups = append(groups, map[string]string{})
+		}
+		groups[len(groups)-1][name] = properties[name]
+	}
+	return groups
+}
+
+// ModelProperties returns the properties of the model, including the ones of its composite models.
+func ModelProperties(model *iotsitewise.DescribeAssetModelOutput) []types.AssetModelProperty {
+	properties := slices.Clone(model.AssetModelProperties)
+	for _, composite := range model.AssetModelCompositeModels {
+		properties = append(properties, composite.Properties...)
+	}
+	return properties
+}
+
+// AssetProperties returns the properties of the asset, including the ones of its composite models.
+func AssetProperties(asset *iotsitewise.DescribeAssetOutput) []types.AssetProperty {
+	properties := slices.Clone(asset.AssetProperties)
+	for _, composite := range asset.AssetCompositeModels {
+		properties = append(properties, composite.Properties...)
+	}
+	return properties
+}
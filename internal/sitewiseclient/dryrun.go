@@ -0,0 +1,229 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package sitewiseclient
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iotsitewise"
+	"github.com/aws/aws-sdk-go-v2/service/iotsitewise/types"
+	"github.com/sirupsen/logrus"
+)
+
+// Prefix of the ids returned for models and assets that would have been created in dry run
+const dryRunIdPrefix = "dry-run-"
+
+// WithDryRun makes the client log the changes it would apply to SiteWise, instead of applying them.
+// Read calls are still performed, so that the logged plan is accurate.
+func WithDryRun(enabled bool) Option {
+	return func(c *IotSiteWiseClient) {
+		c.dryRun = enabled
+	}
+}
+
+// dryRunSiteWise short-circuits mutating SiteWise calls, logging them. Models and assets that would have
+// been created get placeholder ids, which are described from their definitions.
+type dryRunSiteWise struct {
+	sitewiseAPI
+	logger *logrus.Entry
+
+	mu     sync.Mutex
+	next   int
+	models map[string]*iotsitewise.CreateAssetModelInput
+	assets map[string]*iotsitewise.CreateAssetInput
+}
+
+func newDryRunSiteWise(svc sitewiseAPI, logger *logrus.Entry) *dryRunSiteWise {
+	return &dryRunSiteWise{
+		sitewiseAPI: svc,
+		logger:      logger,
+		models:      map[string]*iotsitewise.CreateAssetModelInput{},
+		assets:      map[string]*iotsitewise.CreateAssetInput{},
+	}
+}
+
+func isDryRunId(id *string) bool {
+	return strings.HasPrefix(aws.ToString(id), dryRunIdPrefix)
+}
+
+func (d *dryRunSiteWise) placeholderId() string {
+	d.next++
+	return fmt.Sprintf("%s%d", dryRunIdPrefix, d.next)
+}
+
+func (d *dryRunSiteWise) CreateAssetModel(ctx context.Context, params *iotsitewise.CreateAssetModelInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.CreateAssetModelOutput, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	id := d.placeholderId()
+	d.models[id] = params
+	d.logger.Infof("[dry run] Create asset model %s with %d properties, %d composite models, %d hierarchies\n",
+		aws.ToString(params.AssetModelName), len(params.AssetModelProperties), len(params.AssetModelCompositeModels), len(params.AssetModelHierarchies))
+	return &iotsitewise.CreateAssetModelOutput{AssetModelId: aws.String(id)}, nil
+}
+
+func (d *dryRunSiteWise) CreateAsset(ctx context.Context, params *iotsitewise.CreateAssetInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.CreateAssetOutput, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	id := d.placeholderId()
+	d.assets[id] = params
+	d.logger.Infof("[dry run] Create asset %s (external id %s) of model %s\n",
+		aws.ToString(params.AssetName), aws.ToString(params.AssetExternalId), aws.ToString(params.AssetModelId))
+	return &iotsitewise.CreateAssetOutput{AssetId: aws.String(id)}, nil
+}
+
+func (d *dryRunSiteWise) UpdateAssetModel(ctx context.Context, params *iotsitewise.UpdateAssetModelInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.UpdateAssetModelOutput, error) {
+	d.logger.Infof("[dry run] Update asset model %s: %d properties, %d hierarchies\n",
+		aws.ToString(params.AssetModelId), len(params.AssetModelProperties), len(params.AssetModelHierarchies))
+	return &iotsitewise.UpdateAssetModelOutput{}, nil
+}
+
+func (d *dryRunSiteWise) UpdateAssetProperty(ctx context.Context, params *iotsitewise.UpdateAssetPropertyInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.UpdateAssetPropertyOutput, error) {
+	d.logger.Infof("[dry run] Set alias %s on property %s of asset %s\n",
+		aws.ToString(params.PropertyAlias), aws.ToString(params.PropertyId), aws.ToString(params.AssetId))
+	return &iotsitewise.UpdateAssetPropertyOutput{}, nil
+}
+
+func (d *dryRunSiteWise) UpdateAsset(ctx context.Context, params *iotsitewise.UpdateAssetInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.UpdateAssetOutput, error) {
+	d.logger.Infof("[dry run] Rename asset %s to %s\n", aws.ToString(params.AssetId), aws.ToString(params.AssetName))
+	return &iotsitewise.UpdateAssetOutput{}, nil
+}
+
+func (d *dryRunSiteWise) BatchPutAssetPropertyValue(ctx context.Context, params *iotsitewise.BatchPutAssetPropertyValueInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.BatchPutAssetPropertyValueOutput, error) {
+	for _, entry := range params.Entries {
+		d.logger.Infof("[dry run] Write %d values to %s\n", len(entry.PropertyValues), aws.ToString(entry.PropertyAlias))
+	}
+	return &iotsitewise.BatchPutAssetPropertyValueOutput{}, nil
+}
+
+func (d *dryRunSiteWise) AssociateAssets(ctx context.Context, params *iotsitewise.AssociateAssetsInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.AssociateAssetsOutput, error) {
+	d.logger.Infof("[dry run] Associate asset %s to %s\n", aws.ToString(params.ChildAssetId), aws.ToString(params.AssetId))
+	return &iotsitewise.AssociateAssetsOutput{}, nil
+}
+
+func (d *dryRunSiteWise) DisassociateAssets(ctx context.Context, params *iotsitewise.DisassociateAssetsInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.DisassociateAssetsOutput, error) {
+	d.logger.Infof("[dry run] Disassociate asset %s from %s\n", aws.ToString(params.ChildAssetId), aws.ToString(params.AssetId))
+	return &iotsitewise.DisassociateAssetsOutput{}, nil
+}
+
+func (d *dryRunSiteWise) DeleteAsset(ctx context.Context, params *iotsitewise.DeleteAssetInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.DeleteAssetOutput, error) {
+	d.logger.Infof("[dry run] Delete asset %s\n", aws.ToString(params.AssetId))
+	return &iotsitewise.DeleteAssetOutput{}, nil
+}
+
+func (d *dryRunSiteWise) DeleteAssetModel(ctx context.Context, params *iotsitewise.DeleteAssetModelInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.DeleteAssetModelOutput, error) {
+	d.logger.Infof("[dry run] Delete asset model %s\n", aws.ToString(params.AssetModelId))
+	return &iotsitewise.DeleteAssetModelOutput{}, nil
+}
+
+func (d *dryRunSiteWise) CreateBulkImportJob(ctx context.Context, params *iotsitewise.CreateBulkImportJobInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.CreateBulkImportJobOutput, error) {
+	d.logger.Infof("[dry run] Create bulk import job %s with %d files\n", aws.ToString(params.JobName), len(params.Files))
+	return &iotsitewise.CreateBulkImportJobOutput{}, nil
+}
+
+// DescribeAssetModel describes models that would have been created from their definition
+func (d *dryRunSiteWise) DescribeAssetModel(ctx context.Context, params *iotsitewise.DescribeAssetModelInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.DescribeAssetModelOutput, error) {
+	if !isDryRunId(params.AssetModelId) {
+		return d.sitewiseAPI.DescribeAssetModel(ctx, params, optFns...)
+	}
+	d.mu.Lock()
+	definition, ok := d.models[*params.AssetModelId]
+	d.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("dry run model %s not found", *params.AssetModelId)
+	}
+
+	model := &iotsitewise.DescribeAssetModelOutput{
+		AssetModelId:     params.AssetModelId,
+		AssetModelName:   definition.AssetModelName,
+		AssetModelStatus: &types.AssetModelStatus{State: types.AssetModelStateActive},
+	}
+	for _, p := range definition.AssetModelProperties {
+		model.AssetModelProperties = append(model.AssetModelProperties, types.AssetModelProperty{
+			Id: aws.String(dryRunIdPrefix + aws.ToString(p.Name)), Name: p.Name, DataType: p.DataType, Type: p.Type, Unit: p.Unit,
+		})
+	}
+	for _, c := range definition.AssetModelCompositeModels {
+		composite := types.AssetModelCompositeModel{Name: c.Name, Type: c.Type}
+		for _, p := range c.Properties {
+			composite.Properties = append(composite.Properties, types.AssetModelProperty{
+				Id: aws.String(dryRunIdPrefix + aws.ToString(p.Name)), Name: p.Name, DataType: p.DataType, Type: p.Type, Unit: p.Unit,
+			})
+		}
+		model.AssetModelCompositeModels = append(model.AssetModelCompositeModels, composite)
+	}
+	return model, nil
+}
+
+// DescribeAsset describes assets that would have been created from their model
+func (d *dryRunSiteWise) DescribeAsset(ctx context.Context, params *iotsitewise.DescribeAssetInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.DescribeAssetOutput, error) {
+	if !isDryRunId(params.AssetId) {
+		return d.sitewiseAPI.DescribeAsset(ctx, params, optFns...)
+	}
+	d.mu.Lock()
+	definition, ok := d.assets[*params.AssetId]
+	d.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("dry run asset %s not found", *params.AssetId)
+	}
+	model, err := d.DescribeAssetModel(ctx, &iotsitewise.DescribeAssetModelInput{AssetModelId: definition.AssetModelId})
+	if err != nil {
+		return nil, err
+	}
+
+	asset := &iotsitewise.DescribeAssetOutput{
+		AssetId:         params.AssetId,
+		AssetName:       definition.AssetName,
+		AssetExternalId: definition.AssetExternalId,
+		AssetModelId:    definition.AssetModelId,
+		AssetStatus:     &types.AssetStatus{State: types.AssetStateActive},
+	}
+	for _, p := range model.AssetModelProperties {
+		asset.AssetProperties = append(asset.AssetProperties, types.AssetProperty{Id: p.Id, Name: p.Name, DataType: p.DataType})
+	}
+	for _, c := range model.AssetModelCompositeModels {
+		composite := types.AssetCompositeModel{Name: c.Name, Type: c.Type}
+		for _, p := range c.Properties {
+			composite.Properties = append(composite.Properties, types.AssetProperty{Id: p.Id, Name: p.Name, DataType: p.DataType})
+		}
+		asset.AssetCompositeModels = append(asset.AssetCompositeModels, composite)
+	}
+	return asset, nil
+}
+
+func (d *dryRunSiteWise) ListAssets(ctx context.Context, params *iotsitewise.ListAssetsInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.ListAssetsOutput, error) {
+	if isDryRunId(params.AssetModelId) {
+		return &iotsitewise.ListAssetsOutput{}, nil
+	}
+	return d.sitewiseAPI.ListAssets(ctx, params, optFns...)
+}
+
+func (d *dryRunSiteWise) ListAssociatedAssets(ctx context.Context, params *iotsitewise.ListAssociatedAssetsInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.ListAssociatedAssetsOutput, error) {
+	if isDryRunId(params.AssetId) {
+		return &iotsitewise.ListAssociatedAssetsOutput{}, nil
+	}
+	return d.sitewiseAPI.ListAssociatedAssets(ctx, params, optFns...)
+}
+
+func (d *dryRunSiteWise) GetAssetPropertyValue(ctx context.Context, params *iotsitewise.GetAssetPropertyValueInput, optFns ...func(*iotsitewise.Options)) (*iotsitewise.GetAssetPropertyValueOutput, error) {
+	if isDryRunId(params.AssetId) {
+		return &iotsitewise.GetAssetPropertyValueOutput{}, nil
+	}
+	return d.sitewiseAPI.GetAssetPropertyValue(ctx, params, optFns...)
+}
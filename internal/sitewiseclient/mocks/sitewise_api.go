@@ -1,14 +1,15 @@
-// Code generated by mockery v2.46.0. DO NOT EDIT.
+// Code generated by mockery v2.53.3. DO NOT EDIT.
 
 package mocks
 
 import (
 	context "context"
+	time "time"
 
+	sitewiseclient "github.com/arduino/aws-sitewise-integration/internal/sitewiseclient"
 	iotsitewise "github.com/aws/aws-sdk-go-v2/service/iotsitewise"
+	types "github.com/aws/aws-sdk-go-v2/service/iotsitewise/types"
 	mock "github.com/stretchr/testify/mock"
-
-	sitewiseclient "github.com/arduino/aws-sitewise-integration/internal/sitewiseclient"
 )
 
 // API is an autogenerated mock type for the API type
@@ -16,9 +17,45 @@ type API struct {
 	mock.Mock
 }
 
-// CreateAsset provides a mock function with given fields: ctx, name, assetModelId, thingId
-func (_m *API) CreateAsset(ctx context.Context, name string, assetModelId string, thingId string) (*iotsitewise.CreateAssetOutput, error) {
-	ret := _m.Called(ctx, name, assetModelId, thingId)
+// AddAssetModelHierarchies provides a mock function with given fields: ctx, assetModel, hierarchies
+func (_m *API) AddAssetModelHierarchies(ctx context.Context, assetModel *iotsitewise.DescribeAssetModelOutput, hierarchies []types.AssetModelHierarchyDefinition) error {
+	ret := _m.Called(ctx, assetModel, hierarchies)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AddAssetModelHierarchies")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *iotsitewise.DescribeAssetModelOutput, []types.AssetModelHierarchyDefinition) error); ok {
+		r0 = rf(ctx, assetModel, hierarchies)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// AssociateAssets provides a mock function with given fields: ctx, parentAssetId, hierarchyId, childAssetId
+func (_m *API) AssociateAssets(ctx context.Context, parentAssetId string, hierarchyId string, childAssetId string) error {
+	ret := _m.Called(ctx, parentAssetId, hierarchyId, childAssetId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AssociateAssets")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) error); ok {
+		r0 = rf(ctx, parentAssetId, hierarchyId, childAssetId)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CreateAsset provides a mock function with given fields: ctx, name, assetModelId, thingId, tags
+func (_m *API) CreateAsset(ctx context.Context, name string, assetModelId string, thingId string, tags map[string]string) (*iotsitewise.CreateAssetOutput, error) {
+	ret := _m.Called(ctx, name, assetModelId, thingId, tags)
 
 	if len(ret) == 0 {
 		panic("no return value specified for CreateAsset")
@@ -26,19 +63,19 @@ func (_m *API) CreateAsset(ctx context.Context, name string, assetModelId string
 
 	var r0 *iotsitewise.CreateAssetOutput
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) (*iotsitewise.CreateAssetOutput, error)); ok {
-		return rf(ctx, name, assetModelId, thingId)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string, map[string]string) (*iotsitewise.CreateAssetOutput, error)); ok {
+		return rf(ctx, name, assetModelId, thingId, tags)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) *iotsitewise.CreateAssetOutput); ok {
-		r0 = rf(ctx, name, assetModelId, thingId)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string, map[string]string) *iotsitewise.CreateAssetOutput); ok {
+		r0 = rf(ctx, name, assetModelId, thingId, tags)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*iotsitewise.CreateAssetOutput)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, string, string, string) error); ok {
-		r1 = rf(ctx, name, assetModelId, thingId)
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, string, map[string]string) error); ok {
+		r1 = rf(ctx, name, assetModelId, thingId, tags)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -46,9 +83,9 @@ func (_m *API) CreateAsset(ctx context.Context, name string, assetModelId string
 	return r0, r1
 }
 
-// CreateAssetModel provides a mock function with given fields: ctx, name, properties, uomMap
-func (_m *API) CreateAssetModel(ctx context.Context, name string, properties map[string]string, uomMap map[string][]string) (*iotsitewise.CreateAssetModelOutput, error) {
-	ret := _m.Called(ctx, name, properties, uomMap)
+// CreateAssetModel provides a mock function with given fields: ctx, name, externalId, properties, uomMap
+func (_m *API) CreateAssetModel(ctx context.Context, name string, externalId string, properties map[string]string, uomMap map[string][]string) (*iotsitewise.CreateAssetModelOutput, error) {
+	ret := _m.Called(ctx, name, externalId, properties, uomMap)
 
 	if len(ret) == 0 {
 		panic("no return value specified for CreateAssetModel")
@@ -56,19 +93,19 @@ func (_m *API) CreateAssetModel(ctx context.Context, name string, properties map
 
 	var r0 *iotsitewise.CreateAssetModelOutput
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, string, map[string]string, map[string][]string) (*iotsitewise.CreateAssetModelOutput, error)); ok {
-		return rf(ctx, name, properties, uomMap)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, map[string]string, map[string][]string) (*iotsitewise.CreateAssetModelOutput, error)); ok {
+		return rf(ctx, name, externalId, properties, uomMap)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, string, map[string]string, map[string][]string) *iotsitewise.CreateAssetModelOutput); ok {
-		r0 = rf(ctx, name, properties, uomMap)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, map[string]string, map[string][]string) *iotsitewise.CreateAssetModelOutput); ok {
+		r0 = rf(ctx, name, externalId, properties, uomMap)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*iotsitewise.CreateAssetModelOutput)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, string, map[string]string, map[string][]string) error); ok {
-		r1 = rf(ctx, name, properties, uomMap)
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, map[string]string, map[string][]string) error); ok {
+		r1 = rf(ctx, name, externalId, properties, uomMap)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -106,6 +143,84 @@ func (_m *API) CreateDataBulkImportJob(ctx context.Context, jobNumber int, bucke
 	return r0, r1
 }
 
+// CreateHierarchyAssetModel provides a mock function with given fields: ctx, name, externalId, hierarchies
+func (_m *API) CreateHierarchyAssetModel(ctx context.Context, name string, externalId string, hierarchies []types.AssetModelHierarchyDefinition) (*iotsitewise.CreateAssetModelOutput, error) {
+	ret := _m.Called(ctx, name, externalId, hierarchies)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateHierarchyAssetModel")
+	}
+
+	var r0 *iotsitewise.CreateAssetModelOutput
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, []types.AssetModelHierarchyDefinition) (*iotsitewise.CreateAssetModelOutput, error)); ok {
+		return rf(ctx, name, externalId, hierarchies)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, []types.AssetModelHierarchyDefinition) *iotsitewise.CreateAssetModelOutput); ok {
+		r0 = rf(ctx, name, externalId, hierarchies)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*iotsitewise.CreateAssetModelOutput)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, []types.AssetModelHierarchyDefinition) error); ok {
+		r1 = rf(ctx, name, externalId, hierarchies)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateSplitAssetModel provides a mock function with given fields: ctx, name, externalId, properties, maxProperties, uomMap
+func (_m *API) CreateSplitAssetModel(ctx context.Context, name string, externalId string, properties map[string]string, maxProperties int, uomMap map[string][]string) (*iotsitewise.CreateAssetModelOutput, error) {
+	ret := _m.Called(ctx, name, externalId, properties, maxProperties, uomMap)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateSplitAssetModel")
+	}
+
+	var r0 *iotsitewise.CreateAssetModelOutput
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, map[string]string, int, map[string][]string) (*iotsitewise.CreateAssetModelOutput, error)); ok {
+		return rf(ctx, name, externalId, properties, maxProperties, uomMap)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, map[string]string, int, map[string][]string) *iotsitewise.CreateAssetModelOutput); ok {
+		r0 = rf(ctx, name, externalId, properties, maxProperties, uomMap)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*iotsitewise.CreateAssetModelOutput)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, map[string]string, int, map[string][]string) error); ok {
+		r1 = rf(ctx, name, externalId, properties, maxProperties, uomMap)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteAsset provides a mock function with given fields: ctx, assetId
+func (_m *API) DeleteAsset(ctx context.Context, assetId string) error {
+	ret := _m.Called(ctx, assetId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteAsset")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, assetId)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // DeleteAssetModel provides a mock function with given fields: ctx, assetModelId
 func (_m *API) DeleteAssetModel(ctx context.Context, assetModelId *string) (*iotsitewise.DeleteAssetModelOutput, error) {
 	ret := _m.Called(ctx, assetModelId)
@@ -226,6 +341,24 @@ func (_m *API) DescribeModel(ctx context.Context, assetModelId string) (*iotsite
 	return r0, r1
 }
 
+// DisassociateFromParent provides a mock function with given fields: ctx, assetId, assetModelId
+func (_m *API) DisassociateFromParent(ctx context.Context, assetId string, assetModelId string) error {
+	ret := _m.Called(ctx, assetId, assetModelId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DisassociateFromParent")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, assetId, assetModelId)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // GetBulkImportJobStatus provides a mock function with given fields: ctx, jobId
 func (_m *API) GetBulkImportJobStatus(ctx context.Context, jobId *string) (*iotsitewise.DescribeBulkImportJobOutput, error) {
 	ret := _m.Called(ctx, jobId)
@@ -256,6 +389,73 @@ func (_m *API) GetBulkImportJobStatus(ctx context.Context, jobId *string) (*iots
 	return r0, r1
 }
 
+// GetLatestAssetPropertyValue provides a mock function with given fields: ctx, assetId, propertyId
+func (_m *API) GetLatestAssetPropertyValue(ctx context.Context, assetId string, propertyId string) (*types.Variant, time.Time, error) {
+	ret := _m.Called(ctx, assetId, propertyId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetLatestAssetPropertyValue")
+	}
+
+	var r0 *types.Variant
+	var r1 time.Time
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (*types.Variant, time.Time, error)); ok {
+		return rf(ctx, assetId, propertyId)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) *types.Variant); ok {
+		r0 = rf(ctx, assetId, propertyId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*types.Variant)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) time.Time); ok {
+		r1 = rf(ctx, assetId, propertyId)
+	} else {
+		r1 = ret.Get(1).(time.Time)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, string, string) error); ok {
+		r2 = rf(ctx, assetId, propertyId)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// GetParentAssetId provides a mock function with given fields: ctx, assetId
+func (_m *API) GetParentAssetId(ctx context.Context, assetId string) (*string, error) {
+	ret := _m.Called(ctx, assetId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetParentAssetId")
+	}
+
+	var r0 *string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*string, error)); ok {
+		return rf(ctx, assetId)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *string); ok {
+		r0 = rf(ctx, assetId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*string)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, assetId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // IsAssetActive provides a mock function with given fields: ctx, asset
 func (_m *API) IsAssetActive(ctx context.Context, asset *iotsitewise.DescribeAssetOutput) bool {
 	ret := _m.Called(ctx, asset)
@@ -292,24 +492,24 @@ func (_m *API) IsModelActive(ctx context.Context, model *iotsitewise.DescribeAss
 	return r0
 }
 
-// ListAssetModels provides a mock function with given fields: ctx
-func (_m *API) ListAssetModels(ctx context.Context) (*iotsitewise.ListAssetModelsOutput, error) {
+// ListAllAssetModels provides a mock function with given fields: ctx
+func (_m *API) ListAllAssetModels(ctx context.Context) ([]types.AssetModelSummary, error) {
 	ret := _m.Called(ctx)
 
 	if len(ret) == 0 {
-		panic("no return value specified for ListAssetModels")
+		panic("no return value specified for ListAllAssetModels")
 	}
 
-	var r0 *iotsitewise.ListAssetModelsOutput
+	var r0 []types.AssetModelSummary
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context) (*iotsitewise.ListAssetModelsOutput, error)); ok {
+	if rf, ok := ret.Get(0).(func(context.Context) ([]types.AssetModelSummary, error)); ok {
 		return rf(ctx)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context) *iotsitewise.ListAssetModelsOutput); ok {
+	if rf, ok := ret.Get(0).(func(context.Context) []types.AssetModelSummary); ok {
 		r0 = rf(ctx)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*iotsitewise.ListAssetModelsOutput)
+			r0 = ret.Get(0).([]types.AssetModelSummary)
 		}
 	}
 
@@ -322,29 +522,29 @@ func (_m *API) ListAssetModels(ctx context.Context) (*iotsitewise.ListAssetModel
 	return r0, r1
 }
 
-// ListAssetModelsNext provides a mock function with given fields: ctx, nextToken
-func (_m *API) ListAssetModelsNext(ctx context.Context, nextToken *string) (*iotsitewise.ListAssetModelsOutput, error) {
-	ret := _m.Called(ctx, nextToken)
+// ListAllAssetsForModel provides a mock function with given fields: ctx, assetModelId
+func (_m *API) ListAllAssetsForModel(ctx context.Context, assetModelId *string) ([]types.AssetSummary, error) {
+	ret := _m.Called(ctx, assetModelId)
 
 	if len(ret) == 0 {
-		panic("no return value specified for ListAssetModelsNext")
+		panic("no return value specified for ListAllAssetsForModel")
 	}
 
-	var r0 *iotsitewise.ListAssetModelsOutput
+	var r0 []types.AssetSummary
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, *string) (*iotsitewise.ListAssetModelsOutput, error)); ok {
-		return rf(ctx, nextToken)
+	if rf, ok := ret.Get(0).(func(context.Context, *string) ([]types.AssetSummary, error)); ok {
+		return rf(ctx, assetModelId)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, *string) *iotsitewise.ListAssetModelsOutput); ok {
-		r0 = rf(ctx, nextToken)
+	if rf, ok := ret.Get(0).(func(context.Context, *string) []types.AssetSummary); ok {
+		r0 = rf(ctx, assetModelId)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*iotsitewise.ListAssetModelsOutput)
+			r0 = ret.Get(0).([]types.AssetSummary)
 		}
 	}
 
 	if rf, ok := ret.Get(1).(func(context.Context, *string) error); ok {
-		r1 = rf(ctx, nextToken)
+		r1 = rf(ctx, assetModelId)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -352,29 +552,29 @@ func (_m *API) ListAssetModelsNext(ctx context.Context, nextToken *string) (*iot
 	return r0, r1
 }
 
-// ListAssets provides a mock function with given fields: ctx, assetModelId
-func (_m *API) ListAssets(ctx context.Context, assetModelId *string) (*iotsitewise.ListAssetsOutput, error) {
-	ret := _m.Called(ctx, assetModelId)
+// ListAssetModels provides a mock function with given fields: ctx, nextToken
+func (_m *API) ListAssetModels(ctx context.Context, nextToken *string) (*iotsitewise.ListAssetModelsOutput, error) {
+	ret := _m.Called(ctx, nextToken)
 
 	if len(ret) == 0 {
-		panic("no return value specified for ListAssets")
+		panic("no return value specified for ListAssetModels")
 	}
 
-	var r0 *iotsitewise.ListAssetsOutput
+	var r0 *iotsitewise.ListAssetModelsOutput
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, *string) (*iotsitewise.ListAssetsOutput, error)); ok {
-		return rf(ctx, assetModelId)
+	if rf, ok := ret.Get(0).(func(context.Context, *string) (*iotsitewise.ListAssetModelsOutput, error)); ok {
+		return rf(ctx, nextToken)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, *string) *iotsitewise.ListAssetsOutput); ok {
-		r0 = rf(ctx, assetModelId)
+	if rf, ok := ret.Get(0).(func(context.Context, *string) *iotsitewise.ListAssetModelsOutput); ok {
+		r0 = rf(ctx, nextToken)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*iotsitewise.ListAssetsOutput)
+			r0 = ret.Get(0).(*iotsitewise.ListAssetModelsOutput)
 		}
 	}
 
 	if rf, ok := ret.Get(1).(func(context.Context, *string) error); ok {
-		r1 = rf(ctx, assetModelId)
+		r1 = rf(ctx, nextToken)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -382,12 +582,12 @@ func (_m *API) ListAssets(ctx context.Context, assetModelId *string) (*iotsitewi
 	return r0, r1
 }
 
-// ListAssetsNext provides a mock function with given fields: ctx, assetModelId, nextToken
-func (_m *API) ListAssetsNext(ctx context.Context, assetModelId *string, nextToken *string) (*iotsitewise.ListAssetsOutput, error) {
+// ListAssets provides a mock function with given fields: ctx, assetModelId, nextToken
+func (_m *API) ListAssets(ctx context.Context, assetModelId *string, nextToken *string) (*iotsitewise.ListAssetsOutput, error) {
 	ret := _m.Called(ctx, assetModelId, nextToken)
 
 	if len(ret) == 0 {
-		panic("no return value specified for ListAssetsNext")
+		panic("no return value specified for ListAssets")
 	}
 
 	var r0 *iotsitewise.ListAssetsOutput
@@ -443,36 +643,72 @@ func (_m *API) ListBulkImportJobs(ctx context.Context, nextToken *string) (*iots
 }
 
 // PollForAssetActiveStatus provides a mock function with given fields: ctx, assetId, maxRetry
-func (_m *API) PollForAssetActiveStatus(ctx context.Context, assetId string, maxRetry int) bool {
+func (_m *API) PollForAssetActiveStatus(ctx context.Context, assetId string, maxRetry int) error {
 	ret := _m.Called(ctx, assetId, maxRetry)
 
 	if len(ret) == 0 {
 		panic("no return value specified for PollForAssetActiveStatus")
 	}
 
-	var r0 bool
-	if rf, ok := ret.Get(0).(func(context.Context, string, int) bool); ok {
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, int) error); ok {
 		r0 = rf(ctx, assetId, maxRetry)
 	} else {
-		r0 = ret.Get(0).(bool)
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// PollForAssetActiveStatusWithOptions provides a mock function with given fields: ctx, assetId, opts
+func (_m *API) PollForAssetActiveStatusWithOptions(ctx context.Context, assetId string, opts sitewiseclient.PollOptions) error {
+	ret := _m.Called(ctx, assetId, opts)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PollForAssetActiveStatusWithOptions")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, sitewiseclient.PollOptions) error); ok {
+		r0 = rf(ctx, assetId, opts)
+	} else {
+		r0 = ret.Error(0)
 	}
 
 	return r0
 }
 
 // PollForModelActiveStatus provides a mock function with given fields: ctx, modelId, maxRetry
-func (_m *API) PollForModelActiveStatus(ctx context.Context, modelId string, maxRetry int) bool {
+func (_m *API) PollForModelActiveStatus(ctx context.Context, modelId string, maxRetry int) error {
 	ret := _m.Called(ctx, modelId, maxRetry)
 
 	if len(ret) == 0 {
 		panic("no return value specified for PollForModelActiveStatus")
 	}
 
-	var r0 bool
-	if rf, ok := ret.Get(0).(func(context.Context, string, int) bool); ok {
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, int) error); ok {
 		r0 = rf(ctx, modelId, maxRetry)
 	} else {
-		r0 = ret.Get(0).(bool)
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// PollForModelActiveStatusWithOptions provides a mock function with given fields: ctx, modelId, opts
+func (_m *API) PollForModelActiveStatusWithOptions(ctx context.Context, modelId string, opts sitewiseclient.PollOptions) error {
+	ret := _m.Called(ctx, modelId, opts)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PollForModelActiveStatusWithOptions")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, sitewiseclient.PollOptions) error); ok {
+		r0 = rf(ctx, modelId, opts)
+	} else {
+		r0 = ret.Error(0)
 	}
 
 	return r0
@@ -532,6 +768,42 @@ func (_m *API) PopulateTimeSeriesByAlias(ctx context.Context, propertyAlias stri
 	return r0
 }
 
+// RemoveAssetModelProperties provides a mock function with given fields: ctx, assetModel, propertyNames
+func (_m *API) RemoveAssetModelProperties(ctx context.Context, assetModel *iotsitewise.DescribeAssetModelOutput, propertyNames []string) error {
+	ret := _m.Called(ctx, assetModel, propertyNames)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RemoveAssetModelProperties")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *iotsitewise.DescribeAssetModelOutput, []string) error); ok {
+		r0 = rf(ctx, assetModel, propertyNames)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetAssetPropertyAttribute provides a mock function with given fields: ctx, propertyAlias, value
+func (_m *API) SetAssetPropertyAttribute(ctx context.Context, propertyAlias string, value interface{}) error {
+	ret := _m.Called(ctx, propertyAlias, value)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetAssetPropertyAttribute")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, interface{}) error); ok {
+		r0 = rf(ctx, propertyAlias, value)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // UpdateAssetModelProperties provides a mock function with given fields: ctx, assetModel, thingProperties, uomMap
 func (_m *API) UpdateAssetModelProperties(ctx context.Context, assetModel *iotsitewise.DescribeAssetModelOutput, thingProperties map[string]string, uomMap map[string][]string) error {
 	ret := _m.Called(ctx, assetModel, thingProperties, uomMap)
@@ -550,6 +822,24 @@ func (_m *API) UpdateAssetModelProperties(ctx context.Context, assetModel *iotsi
 	return r0
 }
 
+// UpdateAssetName provides a mock function with given fields: ctx, assetId, name
+func (_m *API) UpdateAssetName(ctx context.Context, assetId string, name string) error {
+	ret := _m.Called(ctx, assetId, name)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateAssetName")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, assetId, name)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // UpdateAssetProperties provides a mock function with given fields: ctx, assetId, thingProperties
 func (_m *API) UpdateAssetProperties(ctx context.Context, assetId string, thingProperties map[string]string) error {
 	ret := _m.Called(ctx, assetId, thingProperties)
@@ -568,6 +858,34 @@ func (_m *API) UpdateAssetProperties(ctx context.Context, assetId string, thingP
 	return r0
 }
 
+// WaitForBulkImportJob provides a mock function with given fields: ctx, jobId, opts
+func (_m *API) WaitForBulkImportJob(ctx context.Context, jobId string, opts sitewiseclient.PollOptions) (types.JobStatus, error) {
+	ret := _m.Called(ctx, jobId, opts)
+
+	if len(ret) == 0 {
+		panic("no return value specified for WaitForBulkImportJob")
+	}
+
+	var r0 types.JobStatus
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, sitewiseclient.PollOptions) (types.JobStatus, error)); ok {
+		return rf(ctx, jobId, opts)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, sitewiseclient.PollOptions) types.JobStatus); ok {
+		r0 = rf(ctx, jobId, opts)
+	} else {
+		r0 = ret.Get(0).(types.JobStatus)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, sitewiseclient.PollOptions) error); ok {
+		r1 = rf(ctx, jobId, opts)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // NewAPI creates a new instance of API. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewAPI(t interface {
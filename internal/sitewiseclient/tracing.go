@@ -0,0 +1,45 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package sitewiseclient
+
+import (
+	"context"
+
+	"github.com/arduino/aws-sitewise-integration/internal/tracing"
+	"github.com/aws/aws-sdk-go-v2/service/iotsitewise"
+)
+
+// tracedSiteWise opens X-Ray subsegments around the SiteWise calls dominating import time.
+// Calls are not traced when the context carries no segment.
+type tracedSiteWise struct {
+	sitewiseAPI
+}
+
+func (t *tracedSiteWise) ListAssets(ctx context.Context, params *iotsitewise.ListAssetsInput, optFns ...func(*iotsitewise.Options)) (out *iotsitewise.ListAssetsOutput, err error) {
+	err = tracing.Capture(ctx, "SiteWise.ListAssets", func(ctx context.Context) error {
+		out, err = t.sitewiseAPI.ListAssets(ctx, params, optFns...)
+		return err
+	})
+	return out, err
+}
+
+func (t *tracedSiteWise) BatchPutAssetPropertyValue(ctx context.Context, params *iotsitewise.BatchPutAssetPropertyValueInput, optFns ...func(*iotsitewise.Options)) (out *iotsitewise.BatchPutAssetPropertyValueOutput, err error) {
+	err = tracing.Capture(ctx, "SiteWise.BatchPutAssetPropertyValue", func(ctx context.Context) error {
+		out, err = t.sitewiseAPI.BatchPutAssetPropertyValue(ctx, params, optFns...)
+		return err
+	})
+	return out, err
+}
@@ -0,0 +1,122 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package statestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Attribute names of the state table. The table partition key must be 'key' (string).
+const (
+	attrKey       = "key"
+	attrValue     = "value"
+	attrUpdatedAt = "updatedAt"
+)
+
+type dynamoAPI interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+}
+
+// DynamoStore keeps states in a DynamoDB table, one item per key.
+type DynamoStore struct {
+	svc   dynamoAPI
+	table string
+	now   func() time.Time
+}
+
+func New(table string) (*DynamoStore, error) {
+	awsOpts := []func(*config.LoadOptions) error{}
+
+	cfg, err := config.LoadDefaultConfig(
+		context.Background(),
+		awsOpts...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DynamoStore{
+		svc:   dynamodb.NewFromConfig(cfg),
+		table: table,
+		now:   time.Now,
+	}, nil
+}
+
+func (s *DynamoStore) GetState(ctx context.Context, key string) (string, bool, error) {
+	out, err := s.svc.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(s.table),
+		Key:            map[string]types.AttributeValue{attrKey: &types.AttributeValueMemberS{Value: key}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return "", false, fmt.Errorf("reading state %s: %w", key, err)
+	}
+	value, ok := out.Item[attrValue].(*types.AttributeValueMemberS)
+	if !ok {
+		return "", false, nil
+	}
+	return value.Value, true, nil
+}
+
+func (s *DynamoStore) PutState(ctx context.Context, key, value string) error {
+	_, err := s.svc.PutItem(ctx, s.putInput(key, value))
+	if err != nil {
+		return fmt.Errorf("writing state %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *DynamoStore) PutStateIf(ctx context.Context, key, value string, expected *string) error {
+	input := s.putInput(key, value)
+	// 'key' and 'value' are reserved words of DynamoDB expressions
+	if expected == nil {
+		input.ConditionExpression = aws.String("attribute_not_exists(#key)")
+		input.ExpressionAttributeNames = map[string]string{"#key": attrKey}
+	} else {
+		input.ConditionExpression = aws.String("#value = :expected")
+		input.ExpressionAttributeNames = map[string]string{"#value": attrValue}
+		input.ExpressionAttributeValues = map[string]types.AttributeValue{":expected": &types.AttributeValueMemberS{Value: *expected}}
+	}
+	_, err := s.svc.PutItem(ctx, input)
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		return ErrConditionFailed
+	}
+	if err != nil {
+		return fmt.Errorf("writing state %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *DynamoStore) putInput(key, value string) *dynamodb.PutItemInput {
+	return &dynamodb.PutItemInput{
+		TableName: aws.String(s.table),
+		Item: map[string]types.AttributeValue{
+			attrKey:       &types.AttributeValueMemberS{Value: key},
+			attrValue:     &types.AttributeValueMemberS{Value: value},
+			attrUpdatedAt: &types.AttributeValueMemberS{Value: s.now().UTC().Format(time.RFC3339)},
+		},
+	}
+}
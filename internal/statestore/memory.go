@@ -0,0 +1,56 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package statestore
+
+import (
+	"context"
+	"sync"
+)
+
+// Memory keeps states in memory, as a fake Store for tests.
+type Memory struct {
+	mu     sync.Mutex
+	states map[string]string
+}
+
+func NewMemory() *Memory {
+	return &Memory{states: map[string]string{}}
+}
+
+func (m *Memory) GetState(ctx context.Context, key string) (string, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	value, ok := m.states[key]
+	return value, ok, nil
+}
+
+func (m *Memory) PutState(ctx context.Context, key, value string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.states[key] = value
+	return nil
+}
+
+func (m *Memory) PutStateIf(ctx context.Context, key, value string, expected *string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current, ok := m.states[key]
+	if expected == nil && ok || expected != nil && (!ok || current != *expected) {
+		return ErrConditionFailed
+	}
+	m.states[key] = value
+	return nil
+}
@@ -0,0 +1,36 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package statestore
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrConditionFailed is returned by conditional puts when the current state is not the expected one.
+var ErrConditionFailed = errors.New("state condition failed")
+
+// Store reads and writes string states by key, for durable state that doesn't fit single SSM parameters
+// such as per thing cursors or locks. Features using it are disabled when no table is set.
+type Store interface {
+	// GetState returns the state of the key, false if not set.
+	GetState(ctx context.Context, key string) (string, bool, error)
+	// PutState sets the state of the key.
+	PutState(ctx context.Context, key, value string) error
+	// PutStateIf sets the state of the key if its current state is expected, or if it is not set when
+	// expected is nil, e.g. to take a lock. It returns ErrConditionFailed otherwise.
+	PutStateIf(ctx context.Context, key, value string, expected *string) error
+}
@@ -0,0 +1,115 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package statestore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDynamo keeps items in memory, evaluating the conditions written by DynamoStore
+type fakeDynamo struct {
+	items map[string]map[string]types.AttributeValue
+	err   error
+}
+
+func (f *fakeDynamo) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	key := params.Key[attrKey].(*types.AttributeValueMemberS).Value
+	return &dynamodb.GetItemOutput{Item: f.items[key]}, nil
+}
+
+func (f *fakeDynamo) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	key := params.Item[attrKey].(*types.AttributeValueMemberS).Value
+	current, exists := f.items[key]
+	switch aws.ToString(params.ConditionExpression) {
+	case "":
+	case "attribute_not_exists(#key)":
+		if exists {
+			return nil, &types.ConditionalCheckFailedException{}
+		}
+	case "#value = :expected":
+		expected := params.ExpressionAttributeValues[":expected"].(*types.AttributeValueMemberS).Value
+		if !exists || current[attrValue].(*types.AttributeValueMemberS).Value != expected {
+			return nil, &types.ConditionalCheckFailedException{}
+		}
+	default:
+		return nil, errors.New("unexpected condition")
+	}
+	f.items[key] = params.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+// testStore checks the Store contract
+func testStore(t *testing.T, store Store) {
+	ctx := context.Background()
+
+	_, ok, err := store.GetState(ctx, "cursor")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.NoError(t, store.PutState(ctx, "cursor", "10"))
+	value, ok, err := store.GetState(ctx, "cursor")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "10", value)
+
+	// Locks are taken once, and released by their owner only
+	assert.NoError(t, store.PutStateIf(ctx, "lock", "run-1", nil))
+	assert.ErrorIs(t, store.PutStateIf(ctx, "lock", "run-2", nil), ErrConditionFailed)
+	assert.ErrorIs(t, store.PutStateIf(ctx, "lock", "", aws.String("run-2")), ErrConditionFailed)
+	assert.NoError(t, store.PutStateIf(ctx, "lock", "", aws.String("run-1")))
+	assert.ErrorIs(t, store.PutStateIf(ctx, "missing", "x", aws.String("")), ErrConditionFailed)
+
+	value, _, _ = store.GetState(ctx, "lock")
+	assert.Equal(t, "", value)
+}
+
+func TestMemory(t *testing.T) {
+	testStore(t, NewMemory())
+}
+
+func TestDynamoStore(t *testing.T) {
+	svc := &fakeDynamo{items: map[string]map[string]types.AttributeValue{}}
+	now := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+	testStore(t, &DynamoStore{svc: svc, table: "state", now: func() time.Time { return now }})
+
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "2024-10-01T12:00:00Z"}, svc.items["cursor"][attrUpdatedAt])
+}
+
+func TestDynamoStore_errors(t *testing.T) {
+	ctx := context.Background()
+	store := &DynamoStore{svc: &fakeDynamo{err: errors.New("throttled")}, table: "state", now: time.Now}
+
+	_, _, err := store.GetState(ctx, "cursor")
+	assert.ErrorContains(t, err, "throttled")
+	assert.ErrorContains(t, store.PutState(ctx, "cursor", "1"), "throttled")
+	err = store.PutStateIf(ctx, "lock", "run-1", nil)
+	assert.ErrorContains(t, err, "throttled")
+	assert.NotErrorIs(t, err, ErrConditionFailed)
+}
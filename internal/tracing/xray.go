@@ -0,0 +1,32 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package tracing
+
+import (
+	"context"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+)
+
+// Capture runs fn within an X-Ray subsegment with the given name, when ctx is traced: it carries either
+// a segment or the trace header set by Lambda. Otherwise fn is just run, so that untraced runs don't log
+// X-Ray missing context errors.
+func Capture(ctx context.Context, name string, fn func(context.Context) error) error {
+	if ctx.Value(xray.LambdaTraceHeaderKey) == nil && xray.GetSegment(ctx) == nil {
+		return fn(ctx)
+	}
+	return xray.Capture(ctx, name, fn)
+}
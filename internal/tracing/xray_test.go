@@ -0,0 +1,49 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapture_noSegment(t *testing.T) {
+	failure := errors.New("failure")
+	err := Capture(context.Background(), "SiteWise.ListAssets", func(ctx context.Context) error {
+		assert.Nil(t, xray.GetSegment(ctx))
+		return failure
+	})
+	assert.ErrorIs(t, err, failure)
+}
+
+func TestCapture_subsegment(t *testing.T) {
+	ctx, seg := xray.BeginSegment(context.Background(), "import")
+	defer seg.Close(nil)
+
+	err := Capture(ctx, "SiteWise.ListAssets", func(ctx context.Context) error {
+		sub := xray.GetSegment(ctx)
+		if assert.NotNil(t, sub) {
+			assert.Equal(t, "SiteWise.ListAssets", sub.Name)
+			assert.Equal(t, seg, sub.ParentSegment)
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+}
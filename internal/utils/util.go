@@ -15,7 +15,12 @@
 
 package utils
 
-import "strings"
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
 
 func StringPointer(val string) *string {
 	return &val
@@ -25,18 +30,84 @@ func BoolPointer(val bool) *bool {
 	return &val
 }
 
-func ParseTags(tags *string) map[string]string {
-	tagsMap := make(map[string]string)
+// ParseTags parses a comma separated list of tag filters (e.g. "env=prod,env=staging,region=eu"), returning
+// the values by tag key. Values of the same key are OR'd, different keys are AND'd: the example selects things
+// tagged region=eu and either env=prod or env=staging.
+// Values can contain '=' after the first one (e.g. "note=hello=world"). Commas, and any other character, are
+// kept literally when escaped with a backslash (e.g. "note=a\,b") or within double quotes (e.g. `note="a,b"`).
+func ParseTags(tags *string) map[string][]string {
+	tagsMap := make(map[string][]string)
 	if tags == nil || *tags == "" {
 		println("No tags")
 		return tagsMap
 	}
-	tagsList := strings.Split(*tags, ",")
-	for _, tag := range tagsList {
-		parts := strings.Split(tag, "=")
-		if len(parts) == 2 && len(parts[0]) > 0 && len(parts[1]) > 0 {
-			tagsMap[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	pairs, _, _ := splitTags(*tags)
+	for _, tag := range pairs {
+		key, value := strings.TrimSpace(tag[0]), strings.TrimSpace(tag[1])
+		if len(key) > 0 && len(value) > 0 && !slices.Contains(tagsMap[key], value) {
+			tagsMap[key] = append(tagsMap[key], value)
 		}
 	}
 	return tagsMap
 }
+
+// ValidateTags returns an error if the tag filters hold filters without key or value, or an unterminated quote,
+// which ParseTags would discard.
+func ValidateTags(tags string) error {
+	pairs, invalid, quoted := splitTags(tags)
+	if quoted {
+		return errors.New("invalid tags: unterminated quote")
+	}
+	for _, tag := range pairs {
+		if strings.TrimSpace(tag[0]) == "" || strings.TrimSpace(tag[1]) == "" {
+			invalid = append(invalid, tag[0]+"="+tag[1])
+		}
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf("invalid tags, expected key=value filters: %s", strings.Join(invalid, ", "))
+	}
+	return nil
+}
+
+// splitTags splits tag filters into key and value pairs, on commas and on the first equals sign of each
+// filter, neither escaped nor quoted. Non blank filters without an equals sign are returned apart, as well
+// as whether a quote is left open.
+func splitTags(tags string) ([][2]string, []string, bool) {
+	var pairs [][2]string
+	var invalid []string
+	var current strings.Builder
+	var key string
+	hasKey, quoted, escaped := false, false, false
+	endTag := func() {
+		if hasKey {
+			pairs = append(pairs, [2]string{key, current.String()})
+		} else if filter := strings.TrimSpace(current.String()); filter != "" {
+			invalid = append(invalid, filter)
+		}
+		current.Reset()
+		hasKey = false
+	}
+	for _, r := range tags {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case quoted:
+			current.WriteRune(r)
+		case r == '=' && !hasKey:
+			key = current.String()
+			current.Reset()
+			hasKey = true
+		case r == ',':
+			endTag()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	endTag()
+	return pairs, invalid, quoted
+}
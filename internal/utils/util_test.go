@@ -0,0 +1,66 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTags(t *testing.T) {
+	tags := "env=prod, env=staging,region=eu,env=prod,invalid"
+	assert.Equal(t, map[string][]string{
+		"env":    {"prod", "staging"},
+		"region": {"eu"},
+	}, ParseTags(&tags))
+
+	empty := ""
+	assert.Empty(t, ParseTags(&empty))
+	assert.Empty(t, ParseTags(nil))
+}
+
+func TestParseTags_escapedAndQuotedValues(t *testing.T) {
+	tests := []struct {
+		name string
+		tags string
+		want map[string][]string
+	}{
+		{"simple", "k=v,k2=v2", map[string][]string{"k": {"v"}, "k2": {"v2"}}},
+		{"equals in value", "note=hello=world", map[string][]string{"note": {"hello=world"}}},
+		{"escaped comma", `note=a\,b,k=v`, map[string][]string{"note": {"a,b"}, "k": {"v"}}},
+		{"escaped equals in key", `a\=b=c`, map[string][]string{"a=b": {"c"}}},
+		{"escaped backslash", `path=c:\\tmp`, map[string][]string{"path": {`c:\tmp`}}},
+		{"quoted value", `note="a,b=c",k=v`, map[string][]string{"note": {"a,b=c"}, "k": {"v"}}},
+		{"quoted key", `"a,b"=c`, map[string][]string{"a,b": {"c"}}},
+		{"missing equals", "invalid,k=v", map[string][]string{"k": {"v"}}},
+		{"empty value", `k="",k2=v2`, map[string][]string{"k2": {"v2"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ParseTags(&tt.tags))
+		})
+	}
+}
+
+func TestValidateTags(t *testing.T) {
+	assert.NoError(t, ValidateTags(""))
+	assert.NoError(t, ValidateTags(`k=v, note="a,b=c"`))
+	assert.Error(t, ValidateTags("invalid,k=v"))
+	assert.Error(t, ValidateTags("k="))
+	assert.Error(t, ValidateTags("=v"))
+	assert.Error(t, ValidateTags(`note="a,b`))
+}
@@ -17,105 +17,565 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"math/big"
 	"os"
+	"slices"
 	"strconv"
 	"time"
+	// Timezones of logs are available whatever the runtime image
+	_ "time/tzdata"
 
 	"github.com/arduino/aws-sitewise-integration/app/align"
+	"github.com/arduino/aws-sitewise-integration/business/entityalign"
+	"github.com/arduino/aws-sitewise-integration/business/propfilter"
+	"github.com/arduino/aws-sitewise-integration/business/tsalign"
+	"github.com/arduino/aws-sitewise-integration/internal/aliasindex"
+	"github.com/arduino/aws-sitewise-integration/internal/deadletter"
+	"github.com/arduino/aws-sitewise-integration/internal/iot"
+	"github.com/arduino/aws-sitewise-integration/internal/metrics"
+	"github.com/arduino/aws-sitewise-integration/internal/objectstore"
 	"github.com/arduino/aws-sitewise-integration/internal/parameters"
+	"github.com/arduino/aws-sitewise-integration/internal/sitewiseclient"
+	"github.com/arduino/aws-sitewise-integration/internal/statestore"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-lambda-go/lambdacontext"
 	"github.com/sirupsen/logrus"
 )
 
 type SiteWiseImportTrigger struct {
 	Dev bool `json:"dev"`
+	// DryRun logs the changes that would be applied to SiteWise, without applying them
+	DryRun bool `json:"dryRun"`
+	// Verify compares, after the import, the latest SiteWise values of a sample of things with their Arduino last values
+	Verify bool `json:"verify"`
+	// SelfTest checks credentials and permissions, without importing anything
+	SelfTest bool `json:"selfTest"`
 }
 
+// importResult is the message returned by the handler, as JSON
+type importResult struct {
+	Message string                `json:"message"`
+	Summary tsalign.ImportSummary `json:"summary"`
+	// Errors of a partially successful run, with a sample of their messages
+	Errors      int      `json:"errors,omitempty"`
+	ErrorSample []string `json:"errorSample,omitempty"`
+}
+
+// Maximum number of error messages reported in the result of a partially successful run
+const maxErrorSample = 5
+
 const (
-	ArduinoPrefix                      = "/arduino/sitewise-importer/" + parameters.StackName
-	IoTApiKey                          = ArduinoPrefix + "/iot/api-key"
-	IoTApiSecret                       = ArduinoPrefix + "/iot/api-secret"
-	IoTApiOrgId                        = ArduinoPrefix + "/iot/org-id"
-	IoTApiTags                         = ArduinoPrefix + "/iot/filter/tags"
-	SamplesReso                        = ArduinoPrefix + "/iot/samples-resolution"
-	Scheduling                         = ArduinoPrefix + "/iot/scheduling"
-	LastModelSync                      = ArduinoPrefix + "/iot/last-model-sync"
-	SamplesResolutionSeconds           = 300
-	DefaultTimeExtractionWindowMinutes = 30
+	ArduinoPrefix             = parameters.ArduinoPrefix
+	ParallelPropertyImport    = ArduinoPrefix + "/iot/import/parallel-properties"
+	DiscoveryScanInterval     = ArduinoPrefix + "/iot/discovery/scan-interval-minutes"
+	NilLastValuePlaceholder   = ArduinoPrefix + "/iot/import/nil-last-value-placeholder"
+	ModelUpdateRetries        = ArduinoPrefix + "/iot/sitewise/model-update-retries"
+	StringLimitPolicy         = ArduinoPrefix + "/iot/sitewise/string-limit-policy"
+	PropertyCategories        = ArduinoPrefix + "/iot/filter/property-categories"
+	PropertyInclude           = ArduinoPrefix + "/iot/filter/property-include"
+	PropertyExclude           = ArduinoPrefix + "/iot/filter/property-exclude"
+	DeviceHierarchy           = ArduinoPrefix + "/iot/sitewise/device-hierarchy"
+	AliasIndexTable           = ArduinoPrefix + "/iot/sitewise/alias-index-table"
+	ActiveStatusMaxWait       = ArduinoPrefix + "/iot/sitewise/active-status-max-wait-seconds"
+	ClockSkewTolerance        = ArduinoPrefix + "/iot/import/clock-skew-tolerance-seconds"
+	WindowOverlap             = ArduinoPrefix + "/iot/import/window-overlap-seconds"
+	PruneOrphanAssets         = ArduinoPrefix + "/iot/sitewise/prune-orphan-assets"
+	DuplicateAssetPolicy      = ArduinoPrefix + "/iot/sitewise/duplicate-asset-policy"
+	MaxModelProperties        = ArduinoPrefix + "/iot/sitewise/max-model-properties"
+	AlignParallelism          = ArduinoPrefix + "/iot/sitewise/align-parallelism"
+	ImportConcurrency         = ArduinoPrefix + "/iot/import/concurrency"
+	AdaptiveConcurrency       = ArduinoPrefix + "/iot/import/adaptive-concurrency"
+	RateLimitRetries          = ArduinoPrefix + "/iot/import/rate-limit-retries"
+	ModelPropertyLimitPolicy  = ArduinoPrefix + "/iot/sitewise/model-property-limit-policy"
+	AssetNameTemplate         = ArduinoPrefix + "/iot/sitewise/asset-name-template"
+	PropertyAliasTemplate     = ArduinoPrefix + "/iot/sitewise/property-alias-template"
+	PropertyAliasPrefix       = ArduinoPrefix + "/iot/sitewise/property-alias-prefix"
+	ModelPropertyRemoval      = ArduinoPrefix + "/iot/sitewise/model-property-removal"
+	TypeMismatchPolicy        = ArduinoPrefix + "/iot/sitewise/type-mismatch-policy"
+	MinMaxAggregation         = ArduinoPrefix + "/iot/import/min-max-aggregation"
+	AttributeProperties       = ArduinoPrefix + "/iot/import/attribute-properties"
+	PartialAssetPolicy        = ArduinoPrefix + "/iot/import/partial-asset-policy"
+	BatchedLastValues         = ArduinoPrefix + "/iot/import/batched-last-values"
+	AliasBatching             = ArduinoPrefix + "/iot/import/alias-batching"
+	FetchOnly                 = ArduinoPrefix + "/iot/import/fetch-only"
+	LastValueOnly             = ArduinoPrefix + "/iot/import/last-value-only"
+	LastValuePolicy           = ArduinoPrefix + "/iot/import/last-value-policy"
+	UpdatedAtProperties       = ArduinoPrefix + "/iot/import/updated-at-properties"
+	LocationCoordinates       = ArduinoPrefix + "/iot/import/location-coordinates"
+	AggregationOverrides      = ArduinoPrefix + "/iot/import/aggregation-overrides"
+	ValueTransforms           = ArduinoPrefix + "/iot/import/value-transforms"
+	ThingCheckpoints          = ArduinoPrefix + "/iot/import/thing-checkpoints"
+	AdvanceEmptyCheckpoints   = ArduinoPrefix + "/iot/import/advance-empty-checkpoints"
+	StateTable                = ArduinoPrefix + "/iot/state-table"
+	CheckpointMaxCatchUp      = ArduinoPrefix + "/iot/import/checkpoint-max-catch-up-minutes"
+	DeadLetterQueueURL        = ArduinoPrefix + "/iot/import/dead-letter-queue-url"
+	ProgressLogThings         = ArduinoPrefix + "/iot/import/progress-log-things"
+	ProgressLogSeconds        = ArduinoPrefix + "/iot/import/progress-log-seconds"
+	EmfMetrics                = ArduinoPrefix + "/iot/import/emf-metrics"
+	LogFormat                 = ArduinoPrefix + "/iot/log-format"
+	LogTimezone               = ArduinoPrefix + "/iot/log-timezone"
+	RunLockStaleMinutes       = ArduinoPrefix + "/iot/run-lock-stale-minutes"
+	ModelSyncInterval         = ArduinoPrefix + "/iot/model-sync-interval-minutes"
+	ImportMode                = ArduinoPrefix + "/iot/import/mode"
+	BulkImportBucket          = ArduinoPrefix + "/iot/import/bulk-bucket"
+	BulkImportRoleArn         = ArduinoPrefix + "/iot/import/bulk-role-arn"
+	ThingTimeout              = ArduinoPrefix + "/iot/import/thing-timeout-seconds"
+	VerifySampleSize          = ArduinoPrefix + "/iot/import/verify-sample-size"
+	MaxThings                 = ArduinoPrefix + "/iot/import/max-things"
+	MetricsNamespace          = "Arduino/SiteWiseImporter"
+	DefaultModelUpdateRetries = 3
+	ModelUpdateRetryBackoff   = 2 * time.Second
+	ActiveStatusPollBackoff   = 1.5
+	// Property types rarely change, new types found on things force a refresh anyway
+	PropertyTypesCacheTTL = time.Hour
+	// Lambda executions can't last longer, so older locks are surely left by failed runs
+	DefaultRunLockStaleMinutes = 15
+	// Tuned for hourly schedules: models are aligned on every run
+	DefaultModelSyncIntervalMinutes = 55
+	DefaultVerifySampleSize         = 10
+	// Rounded up to a whole bucket, the overlap is one bucket at any resolution
+	DefaultWindowOverlapSeconds = 0
+	// A day of missed runs is caught up from stored checkpoints
+	DefaultCheckpointMaxCatchUpMinutes = 24 * 60
 )
 
+// Parameters read by the handler beside the importer ones, with a single batched read on every invocation
+var handlerParameters = []string{
+	ParallelPropertyImport,
+	DiscoveryScanInterval,
+	NilLastValuePlaceholder,
+	ModelUpdateRetries,
+	StringLimitPolicy,
+	PropertyCategories,
+	PropertyInclude,
+	PropertyExclude,
+	DeviceHierarchy,
+	AliasIndexTable,
+	ActiveStatusMaxWait,
+	ClockSkewTolerance,
+	WindowOverlap,
+	PruneOrphanAssets,
+	DuplicateAssetPolicy,
+	MaxModelProperties,
+	AlignParallelism,
+	ImportConcurrency,
+	AdaptiveConcurrency,
+	RateLimitRetries,
+	ModelPropertyLimitPolicy,
+	AssetNameTemplate,
+	PropertyAliasTemplate,
+	PropertyAliasPrefix,
+	ModelPropertyRemoval,
+	TypeMismatchPolicy,
+	MinMaxAggregation,
+	AttributeProperties,
+	PartialAssetPolicy,
+	BatchedLastValues,
+	AliasBatching,
+	FetchOnly,
+	LastValueOnly,
+	LastValuePolicy,
+	UpdatedAtProperties,
+	LocationCoordinates,
+	AggregationOverrides,
+	ValueTransforms,
+	ThingCheckpoints,
+	AdvanceEmptyCheckpoints,
+	StateTable,
+	CheckpointMaxCatchUp,
+	DeadLetterQueueURL,
+	ProgressLogThings,
+	ProgressLogSeconds,
+	EmfMetrics,
+	LogFormat,
+	LogTimezone,
+	RunLockStaleMinutes,
+	ModelSyncInterval,
+	ImportMode,
+	BulkImportBucket,
+	BulkImportRoleArn,
+	ThingTimeout,
+	VerifySampleSize,
+	MaxThings,
+}
+
+// Assets discovered in SiteWise are kept across invocations of a warm Lambda
+var discoveryCache = tsalign.NewDiscoveryCache()
+
+// Property types definitions are kept across invocations of a warm Lambda
+var propertyTypesCache = iot.NewPropertyTypesCache(PropertyTypesCacheTTL)
+
+// Per thing import checkpoints are kept across invocations of a warm Lambda
+var checkpoints = tsalign.NewCheckpoints()
+
+// StartupJitterEnv is the environment variable setting the maximum random delay, in seconds, before a
+// scheduled run starts, spreading the load of deployments scheduled at the same time. Zero by default.
+const StartupJitterEnv = "STARTUP_JITTER_SECONDS"
+
+// Bound of the startup delay, leaving most of the Lambda timeout to the import
+const maxStartupJitter = 5 * time.Minute
+
 func HandleRequest(ctx context.Context, event *SiteWiseImportTrigger) (*string, error) {
+	if event.SelfTest {
+		return HandleSelfTest(ctx)
+	}
+	if delay := startupJitter(os.Getenv(StartupJitterEnv)); delay > 0 {
+		logrus.Infoln("Delaying start by", delay)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+	summary, errs, err := runImport(ctx, event, runOptions{})
+	if errors.Is(err, parameters.ErrRunInProgress) {
+		return skippedResultMessage()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 && summary.ThingsProcessed == 0 {
+		// Nothing imported: fail the invocation
+		return nil, errs[0]
+	}
+	return importResultMessage(summary, errs)
+}
+
+// errInvalidOverride is returned when a run override can't be applied to the configuration
+var errInvalidOverride = errors.New("invalid override")
+
+// runOptions override the configuration read from SSM for a single run
+type runOptions struct {
+	// Scope of the run, all things if nil
+	thingIds      []string
+	tags          *string
+	resolution    *string
+	alignEntities *bool
+}
+
+// runImport aligns and imports things as configured by SSM parameters and the given options, returning the
+// summary and the errors of the import. A non nil error is returned if the run couldn't start.
+func runImport(ctx context.Context, event *SiteWiseImportTrigger, opts runOptions) (tsalign.ImportSummary, []error, error) {
 
 	logger := logrus.NewEntry(logrus.New())
 	stack := os.Getenv("STACK_NAME")
 
-	var tags *string
-
 	logger.Infoln("------ Reading parameters from SSM")
 	paramReader, err := parameters.New()
 	if err != nil {
-		return nil, err
+		return tsalign.ImportSummary{}, nil, err
 	}
-	apikey, err := paramReader.ReadConfig(IoTApiKey, stack)
+	config, err := paramReader.ReadConfigs(slices.Concat(parameters.ImporterParameters, handlerParameters), stack)
 	if err != nil {
-		logger.Error("Error reading parameter "+paramReader.ResolveParameter(IoTApiKey, stack), err)
+		logger.Error("Error reading parameters", err)
+		return tsalign.ImportSummary{}, nil, err
 	}
-	apiSecret, err := paramReader.ReadConfig(IoTApiSecret, stack)
+	setLogFormat(logger.Logger, configValue(config, LogFormat))
+	importerConfig, err := parameters.ParseImporterConfig(config, logger)
 	if err != nil {
-		logger.Error("Error reading parameter "+paramReader.ResolveParameter(IoTApiSecret, stack), err)
+		logger.Error(err)
+		return tsalign.ImportSummary{}, nil, err
 	}
-	origId, _ := paramReader.ReadConfig(IoTApiOrgId, stack)
-	organizationId := ""
-	if origId != nil {
-		organizationId = *origId
+	if opts.tags != nil {
+		importerConfig.Tags = opts.tags
 	}
-	if apikey == nil || apiSecret == nil {
-		return nil, errors.New("key and secret are required")
+	if opts.resolution != nil {
+		importerConfig.ResolutionSeconds, importerConfig.RawResolution, err = parameters.ParseResolution(*opts.resolution, importerConfig.ExtractionWindowMinutes)
+		if err != nil {
+			// E.g. raw resolution with the configured time window
+			err = fmt.Errorf("%w: %w", errInvalidOverride, err)
+			logger.Error(err)
+			return tsalign.ImportSummary{}, nil, err
+		}
 	}
-	tagsParam, _ := paramReader.ReadConfig(IoTApiTags, stack)
-	if tagsParam != nil {
-		tags = tagsParam
+	thingIds := opts.thingIds
+
+	// The state table, if any, keeps the run lock and thing checkpoints
+	stateTable := ""
+	stateStore := paramReader.StateStore(stack)
+	if table := configValue(config, StateTable); table != nil && *table != "" {
+		stateTable = *table
+		stateStore, err = statestore.New(stateTable)
+		if err != nil {
+			return tsalign.ImportSummary{}, nil, err
+		}
 	}
-	res, err := paramReader.ReadConfig(SamplesReso, stack)
-	if err != nil {
-		logger.Warn("Error reading parameter "+paramReader.ResolveParameter(SamplesReso, stack)+". Set resolution to default value", err)
-	}
-	resolution := int(SamplesResolutionSeconds)
-	switch *res {
-	case "1 minute":
-		resolution = 60
-	case "5 minutes":
-		resolution = 300
-	case "15 minutes":
-		resolution = 900
-	case "1 hour":
-		resolution = 3600
-	}
-	if resolution > 3600 {
-		logger.Errorf("Resolution %d is invalid", resolution)
-		return nil, errors.New("resolution must be between 60 and 3600")
-	}
-	if resolution < 60 || resolution > 3600 {
-		logger.Errorf("Resolution %d is invalid", resolution)
-		return nil, errors.New("resolution must be between 60 and 3600")
-	}
-	// Resolve scheduling
-	extractionWindowMinutes, err := configureDataExtractionTimeWindow(logger, paramReader, stack)
-	if err != nil {
-		return nil, err
+
+	// Scheduled runs may overlap when a run is slow: only one at a time imports data. Dry runs write nothing.
+	// Runs scoped to some things are event driven and don't take part in the schedule.
+	if !event.DryRun && thingIds == nil {
+		staleMinutes := readIntConfig(config, RunLockStaleMinutes, DefaultRunLockStaleMinutes)
+		lock, err := parameters.AcquireRunLock(ctx, stateStore, runID(ctx), time.Now(), time.Duration(staleMinutes)*time.Minute)
+		if err != nil {
+			if errors.Is(err, parameters.ErrRunInProgress) {
+				logger.Warnln("Skipping execution:", err)
+			} else {
+				logger.Error("Error acquiring run lock", err)
+			}
+			return tsalign.ImportSummary{}, nil, err
+		}
+		defer func() {
+			if err := lock.Release(ctx); err != nil {
+				logger.Error("Error releasing run lock", err)
+			}
+		}()
+	}
+	tags := importerConfig.Tags
+	resolution := importerConfig.ResolutionSeconds
+	extractionWindowMinutes := importerConfig.ExtractionWindowMinutes
+
+	importConcurrency := readIntConfig(config, ImportConcurrency, 0)
+	adaptiveConcurrency := readBoolConfig(config, AdaptiveConcurrency)
+	rateLimitRetries := readIntConfig(config, RateLimitRetries, 0)
+	thingTimeoutSeconds := readIntConfig(config, ThingTimeout, 0)
+	parallelPropertyImport := readBoolConfig(config, ParallelPropertyImport)
+	scanIntervalMinutes := readIntConfig(config, DiscoveryScanInterval, 0)
+	nilLastValuePlaceholder := readBoolConfig(config, NilLastValuePlaceholder)
+	clockSkewToleranceSeconds := readIntConfig(config, ClockSkewTolerance, 0)
+	windowOverlapSeconds := readIntConfig(config, WindowOverlap, DefaultWindowOverlapSeconds)
+	minMaxAggregation := readBoolConfig(config, MinMaxAggregation)
+	attributeProperties := readBoolConfig(config, AttributeProperties)
+	if minMaxAggregation && importerConfig.RawResolution {
+		logger.Warnln("Min/max aggregation is not supported with raw resolution, disabling it")
+		minMaxAggregation = false
+	}
+	batchedLastValues := readBoolConfig(config, BatchedLastValues)
+	aliasBatching := readBoolConfig(config, AliasBatching)
+	fetchOnly := readBoolConfig(config, FetchOnly)
+	lastValueOnly := readBoolConfig(config, LastValueOnly)
+	updatedAtProperties := readBoolConfig(config, UpdatedAtProperties)
+	locationCoordinates := readBoolConfig(config, LocationCoordinates)
+	thingCheckpoints := readBoolConfig(config, ThingCheckpoints)
+	advanceEmptyCheckpoints := readBoolConfig(config, AdvanceEmptyCheckpoints)
+	if stateTable != "" {
+		// Stored checkpoints imply thing checkpoints
+		thingCheckpoints = true
+	}
+	checkpointMaxCatchUpMinutes := readIntConfig(config, CheckpointMaxCatchUp, DefaultCheckpointMaxCatchUpMinutes)
+	if importerConfig.RawResolution {
+		checkpointMaxCatchUpMinutes = min(checkpointMaxCatchUpMinutes, parameters.MaxRawExtractionWindowMinutes)
+	}
+	checkpointMaxCatchUpMinutes = min(checkpointMaxCatchUpMinutes, parameters.MaxExtractionWindowMinutes)
+	if importerConfig.RawResolution {
+		// Raw samples are fetched at most MaxRawExtractionWindowMinutes at a time, overlap included
+		rawWindowMinutes := importerConfig.ExtractionWindowMinutes
+		if stateTable != "" {
+			rawWindowMinutes = max(rawWindowMinutes, checkpointMaxCatchUpMinutes)
+		}
+		if maxOverlapSeconds := (parameters.MaxRawExtractionWindowMinutes - rawWindowMinutes) * 60; windowOverlapSeconds > maxOverlapSeconds {
+			logger.Warnln("Window overlap exceeds the raw resolution time window limit, reducing it to", maxOverlapSeconds, "seconds")
+			windowOverlapSeconds = maxOverlapSeconds
+		}
+	}
+	// Event driven runs are retried by their own queue
+	deadLetterQueueURL := ""
+	if queueURL := configValue(config, DeadLetterQueueURL); queueURL != nil && *queueURL != "" && !event.DryRun && thingIds == nil {
+		deadLetterQueueURL = *queueURL
+	}
+	emfMetrics := readBoolConfig(config, EmfMetrics)
+	progressLogThings := readIntConfig(config, ProgressLogThings, 0)
+	progressLogSeconds := readIntConfig(config, ProgressLogSeconds, 0)
+	partialAssetPolicy := tsalign.PartialAssetImport
+	if policy := configValue(config, PartialAssetPolicy); policy != nil && *policy == string(tsalign.PartialAssetDefer) {
+		partialAssetPolicy = tsalign.PartialAssetDefer
+	}
+	lastValuePolicy := tsalign.LastValueOnChange
+	if policy := configValue(config, LastValuePolicy); policy != nil && *policy == string(tsalign.LastValuePeriodic) {
+		lastValuePolicy = tsalign.LastValuePeriodic
+	}
+	importMode := tsalign.ImportStreaming
+	if mode := configValue(config, ImportMode); mode != nil && *mode == string(tsalign.ImportBulk) {
+		importMode = tsalign.ImportBulk
+	}
+	if importMode == tsalign.ImportBulk && event.DryRun {
+		// Nothing must be staged on S3, logged writes are the same
+		logger.Warnln("Bulk import is not supported in dry run, streaming data points")
+		importMode = tsalign.ImportStreaming
+	}
+	var bulkImport *tsalign.BulkImportConfig
+	bucket, roleArn := configValue(config, BulkImportBucket), configValue(config, BulkImportRoleArn)
+	bulkConfigured := bucket != nil && *bucket != "" && roleArn != nil && *roleArn != ""
+	if importMode == tsalign.ImportBulk && !bulkConfigured {
+		err = fmt.Errorf("bulk import requires parameters %s and %s", BulkImportBucket, BulkImportRoleArn)
+		logger.Error(err)
+		return tsalign.ImportSummary{}, nil, err
+	}
+	if bulkConfigured && !event.DryRun {
+		store, err := objectstore.New()
+		if err != nil {
+			return tsalign.ImportSummary{}, nil, err
+		}
+		// When streaming, data points too old for SiteWise ingestion are backfilled with a bulk import job
+		bulkImport = &tsalign.BulkImportConfig{Store: store, Bucket: *bucket, Prefix: stack, RoleArn: *roleArn, OutOfWindowOnly: importMode == tsalign.ImportStreaming}
+	}
+	deviceHierarchy := readBoolConfig(config, DeviceHierarchy)
+	modelUpdateRetries := readIntConfig(config, ModelUpdateRetries, DefaultModelUpdateRetries)
+	stringLimitPolicy := sitewiseclient.StringLimitTruncate
+	if policy := configValue(config, StringLimitPolicy); policy != nil && *policy == string(sitewiseclient.StringLimitSkip) {
+		stringLimitPolicy = sitewiseclient.StringLimitSkip
 	}
 
 	executionTimeUtc := time.Now().UTC()
+	modelSyncIntervalMinutes := readIntConfig(config, ModelSyncInterval, DefaultModelSyncIntervalMinutes)
 	alignEntities := true
-	lastSync, _ := paramReader.ReadConfig(LastModelSync, stack)
-	if lastSync != nil {
-		if lastTimeSync, err := strconv.ParseInt(*lastSync, 10, 64); err == nil {
-			diffSeconds := executionTimeUtc.Unix() - lastTimeSync
-			logger.Debugf("Last sync was %d seconds ago - now %d, last %d - ", diffSeconds, executionTimeUtc.Unix(), lastTimeSync)
-			if diffSeconds < 55*60 { // 55 minutes
-				alignEntities = false // Skip aligning entities
-			}
+	if importerConfig.LastSync != nil {
+		lastTimeSync := importerConfig.LastSync.Unix()
+		diffSeconds := executionTimeUtc.Unix() - lastTimeSync
+		logger.Debugf("Last sync was %d seconds ago - now %d, last %d - ", diffSeconds, executionTimeUtc.Unix(), lastTimeSync)
+		if diffSeconds < int64(modelSyncIntervalMinutes)*60 {
+			alignEntities = false // Skip aligning entities
+		}
+	}
+	if opts.alignEntities != nil {
+		alignEntities = *opts.alignEntities
+	}
+	if fetchOnly {
+		alignEntities = false // Nothing is written to SiteWise
+	}
+
+	pruneOrphans := readBoolConfig(config, PruneOrphanAssets)
+	if pruneOrphans && tags != nil && *tags != "" {
+		// Things filtered out by tags would be seen as deleted
+		logger.Warnln("Pruning of orphan assets is not supported when filtering things by tags, disabling it")
+		pruneOrphans = false
+	}
+	if thingIds != nil {
+		// Things out of scope would be seen as deleted
+		pruneOrphans = false
+	}
+	maxThings := 0
+	if thingIds == nil {
+		maxThings = readIntConfig(config, MaxThings, 0)
+	}
+	if pruneOrphans && maxThings > 0 {
+		logger.Warnln("Pruning of orphan assets is not supported when capping things per run, disabling it")
+		pruneOrphans = false
+	}
+	thingsCursor := ""
+	if maxThings > 0 {
+		// Things skipped by a run catch up from their checkpoint when their turn comes
+		if stateTable == "" {
+			err = fmt.Errorf("capping things per run requires a state table, parameter %s", paramReader.ResolveParameter(StateTable, stack))
+			logger.Error(err)
+			return tsalign.ImportSummary{}, nil, err
+		}
+		if thingsCursor, _, err = stateStore.GetState(ctx, align.ThingsCursorKey); err != nil {
+			logger.Error("Error reading things cursor: ", err)
+			return tsalign.ImportSummary{}, nil, err
+		}
+	}
+	duplicateAssetPolicy := entityalign.DuplicateAssetKeepLast
+	if policy := configValue(config, DuplicateAssetPolicy); policy != nil && *policy == string(entityalign.DuplicateAssetPreferExpectedModel) {
+		duplicateAssetPolicy = entityalign.DuplicateAssetPreferExpectedModel
+	}
+	alignParallelism := readIntConfig(config, AlignParallelism, 0)
+	maxModelProperties := readIntConfig(config, MaxModelProperties, entityalign.DefaultMaxModelProperties)
+	modelPropertyLimitPolicy := entityalign.ModelPropertyLimitError
+	if policy := configValue(config, ModelPropertyLimitPolicy); policy != nil && *policy == string(entityalign.ModelPropertyLimitComposite) {
+		modelPropertyLimitPolicy = entityalign.ModelPropertyLimitComposite
+	}
+	modelPropertyRemoval := entityalign.ModelPropertyRemovalNone
+	if policy := configValue(config, ModelPropertyRemoval); policy != nil {
+		switch entityalign.ModelPropertyRemovalPolicy(*policy) {
+		case entityalign.ModelPropertyRemovalUnused, entityalign.ModelPropertyRemovalAll:
+			modelPropertyRemoval = entityalign.ModelPropertyRemovalPolicy(*policy)
+		}
+	}
+	typeMismatchPolicy := entityalign.TypeMismatchLog
+	if policy := configValue(config, TypeMismatchPolicy); policy != nil && *policy != "" {
+		typeMismatchPolicy, err = entityalign.ParseTypeMismatchPolicy(*policy)
+		if err != nil {
+			logger.Error(err)
+			return tsalign.ImportSummary{}, nil, err
+		}
+	}
+	var nameTemplate *entityalign.NameTemplate
+	if templateParam := configValue(config, AssetNameTemplate); templateParam != nil && *templateParam != "" {
+		nameTemplate, err = entityalign.ParseNameTemplate(*templateParam, stack)
+		if err != nil {
+			logger.Error(err)
+			return tsalign.ImportSummary{}, nil, err
+		}
+	}
+	var aliasTemplate *entityalign.AliasTemplate
+	if templateParam := configValue(config, PropertyAliasTemplate); templateParam != nil && *templateParam != "" {
+		aliasTemplate, err = entityalign.ParseAliasTemplate(*templateParam)
+		if err != nil {
+			logger.Error(err)
+			return tsalign.ImportSummary{}, nil, err
+		}
+	}
+	// Applied by the template, so that assets are aligned and series imported with the same aliases
+	if prefix := configValue(config, PropertyAliasPrefix); prefix != nil {
+		aliasTemplate = aliasTemplate.WithPrefix(*prefix)
+	}
+	alignOpts := []entityalign.Option{
+		entityalign.WithDeviceHierarchy(deviceHierarchy),
+		entityalign.WithPruneOrphans(pruneOrphans),
+		entityalign.WithDuplicateAssetPolicy(duplicateAssetPolicy),
+		entityalign.WithModelPropertyLimit(maxModelProperties, modelPropertyLimitPolicy),
+		entityalign.WithAlignParallelism(alignParallelism),
+		entityalign.WithNameTemplate(nameTemplate),
+		entityalign.WithAliasTemplate(aliasTemplate),
+		entityalign.WithModelPropertyRemoval(modelPropertyRemoval),
+		entityalign.WithTypeMismatchPolicy(typeMismatchPolicy),
+	}
+	activeStatusMaxWait := readIntConfig(config, ActiveStatusMaxWait, 0)
+	if activeStatusMaxWait > 0 {
+		alignOpts = append(alignOpts, entityalign.WithPollOptions(sitewiseclient.PollOptions{
+			Interval: time.Second,
+			MaxWait:  time.Duration(activeStatusMaxWait) * time.Second,
+			Backoff:  ActiveStatusPollBackoff,
+		}))
+	}
+	aliasIndexTable := ""
+	if table := configValue(config, AliasIndexTable); table != nil && *table != "" && !event.DryRun {
+		aliasIndexTable = *table
+		index, err := aliasindex.New(aliasIndexTable)
+		if err != nil {
+			return tsalign.ImportSummary{}, nil, err
+		}
+		alignOpts = append(alignOpts, entityalign.WithAliasIndex(index))
+	}
+
+	var aggregationOverrides map[string]string
+	if overridesParam := configValue(config, AggregationOverrides); overridesParam != nil {
+		aggregationOverrides, err = tsalign.ParseAggregationOverrides(*overridesParam)
+		if err != nil {
+			return tsalign.ImportSummary{}, nil, err
+		}
+	}
+	var valueTransforms map[string]tsalign.Transform
+	if transformsParam := configValue(config, ValueTransforms); transformsParam != nil {
+		valueTransforms, err = tsalign.ParseValueTransforms(*transformsParam)
+		if err != nil {
+			return tsalign.ImportSummary{}, nil, err
+		}
+	}
+
+	var categories []propfilter.Category
+	if categoriesParam := configValue(config, PropertyCategories); categoriesParam != nil {
+		categories, err = propfilter.ParseCategories(*categoriesParam)
+		if err != nil {
+			return tsalign.ImportSummary{}, nil, err
+		}
+	}
+	var includedNames, excludedNames []string
+	if includeParam := configValue(config, PropertyInclude); includeParam != nil {
+		includedNames, err = propfilter.ParseNamePatterns(*includeParam)
+		if err != nil {
+			return tsalign.ImportSummary{}, nil, err
+		}
+	}
+	if excludeParam := configValue(config, PropertyExclude); excludeParam != nil {
+		excludedNames, err = propfilter.ParseNamePatterns(*excludeParam)
+		if err != nil {
+			return tsalign.ImportSummary{}, nil, err
 		}
 	}
 
@@ -124,66 +584,327 @@ func HandleRequest(ctx context.Context, event *SiteWiseImportTrigger) (*string,
 		logger.Infoln("Running in dev mode")
 		os.Setenv("IOT_API_URL", "https://api2.oniudra.cc")
 	}
-	logger.Infoln("key:", *apikey)
+	if event.DryRun {
+		logger.Infoln("Running in dry run mode: changes to SiteWise are only logged")
+	}
+	logger.Infoln("key:", importerConfig.ApiKey)
 	logger.Infoln("secret:", "*********")
-	if organizationId != "" {
-		logger.Infoln("organizationId:", organizationId)
+	if len(importerConfig.OrganizationIds) > 0 {
+		logger.Infoln("organization ids:", importerConfig.OrganizationIds)
 	} else {
-		logger.Infoln("organizationId: not set")
+		logger.Infoln("organization ids: not set")
 	}
 	if tags != nil {
 		logger.Infoln("tags:", *tags)
 	}
 
-	logger.Infoln("resolution seconds:", resolution)
+	if importerConfig.RawResolution {
+		logger.Infoln("resolution: raw")
+	} else {
+		logger.Infoln("resolution seconds:", resolution)
+	}
 	logger.Infoln("time window minutes:", extractionWindowMinutes)
 	logger.Infoln("align entities and models:", alignEntities)
+	logger.Infoln("model sync interval minutes:", modelSyncIntervalMinutes)
+	logger.Infoln("parallel property import:", parallelPropertyImport)
+	logger.Infoln("adaptive import concurrency:", adaptiveConcurrency)
+	logger.Infoln("discovery scan interval minutes:", scanIntervalMinutes)
+	logger.Infoln("nil last value placeholder:", nilLastValuePlaceholder)
+	logger.Infoln("clock skew tolerance seconds:", clockSkewToleranceSeconds)
+	logger.Infoln("window overlap seconds:", windowOverlapSeconds)
+	logger.Infoln("min/max aggregation:", minMaxAggregation)
+	logger.Infoln("attribute properties:", attributeProperties)
+	logger.Infoln("partial asset policy:", partialAssetPolicy)
+	logger.Infoln("batched last values:", batchedLastValues)
+	logger.Infoln("alias batching:", aliasBatching)
+	logger.Infoln("last value policy:", lastValuePolicy)
+	logger.Infoln("updated at properties:", updatedAtProperties)
+	logger.Infoln("location coordinates:", locationCoordinates)
+	logger.Infoln("fetch only:", fetchOnly)
+	logger.Infoln("import mode:", importMode)
+	if bulkImport != nil {
+		logger.Infoln("bulk import bucket:", bulkImport.Bucket, "- role:", bulkImport.RoleArn, "- out of window data only:", bulkImport.OutOfWindowOnly)
+	}
+	logger.Infoln("last value only:", lastValueOnly)
+	logger.Infoln("thing checkpoints:", thingCheckpoints)
+	if thingCheckpoints {
+		logger.Infoln("advance checkpoints on empty series:", advanceEmptyCheckpoints)
+	}
+	if deadLetterQueueURL != "" {
+		logger.Infoln("dead letter queue:", deadLetterQueueURL)
+	}
+	if stateTable != "" {
+		logger.Infoln("state table:", stateTable, "- max catch up:", checkpointMaxCatchUpMinutes, "minutes")
+	}
+	logger.Infoln("EMF metrics:", emfMetrics)
+	if progressLogThings > 0 || progressLogSeconds > 0 {
+		logger.Infoln("progress log every things:", progressLogThings, "- every seconds:", progressLogSeconds)
+	}
+	logger.Infoln("model update conflict retries:", modelUpdateRetries)
+	logger.Infoln("device hierarchy:", deviceHierarchy)
+	logger.Infoln("prune orphan assets:", pruneOrphans)
+	logger.Infoln("duplicate asset policy:", duplicateAssetPolicy)
+	logger.Infoln("max model properties:", maxModelProperties, "- policy:", modelPropertyLimitPolicy)
+	logger.Infoln("model property removal:", modelPropertyRemoval)
+	logger.Infoln("type mismatch policy:", typeMismatchPolicy)
+	if templateParam := configValue(config, AssetNameTemplate); templateParam != nil && *templateParam != "" {
+		logger.Infoln("asset name template:", *templateParam)
+	}
+	if templateParam := configValue(config, PropertyAliasTemplate); templateParam != nil && *templateParam != "" {
+		logger.Infoln("property alias template:", *templateParam)
+	}
+	if prefix := configValue(config, PropertyAliasPrefix); prefix != nil && *prefix != "" {
+		logger.Infoln("property alias prefix:", *prefix)
+	}
+	logger.Infoln("string limit policy:", stringLimitPolicy)
+	if aliasIndexTable != "" {
+		logger.Infoln("alias index table:", aliasIndexTable)
+	}
+	if activeStatusMaxWait > 0 {
+		logger.Infoln("active status max wait seconds:", activeStatusMaxWait)
+	}
+	if importConcurrency > 0 {
+		logger.Infoln("import concurrency:", importConcurrency)
+	}
+	if alignParallelism > 0 {
+		logger.Infoln("align parallelism:", alignParallelism)
+	}
+	if rateLimitRetries > 0 {
+		logger.Infoln("rate limit retries:", rateLimitRetries)
+	}
+	if thingTimeoutSeconds > 0 {
+		logger.Infoln("thing timeout seconds:", thingTimeoutSeconds)
+	}
+	if maxThings > 0 {
+		logger.Infoln("max things:", maxThings)
+	}
+	if len(categories) > 0 {
+		logger.Infoln("property categories:", categories)
+	}
+	if len(includedNames) > 0 {
+		logger.Infoln("included property names:", includedNames)
+	}
+	if len(excludedNames) > 0 {
+		logger.Infoln("excluded property names:", excludedNames)
+	}
+	if len(aggregationOverrides) > 0 {
+		logger.Infoln("aggregation overrides:", aggregationOverrides)
+	}
+	if len(valueTransforms) > 0 {
+		logger.Infoln("value transforms:", valueTransforms)
+	}
 
-	aligner, errs := align.New(*apikey, *apiSecret, organizationId, logger)
-	if len(errs) > 0 {
-		for _, err := range errs {
-			logger.Error(err)
+	importOpts := []tsalign.Option{
+		tsalign.WithImportConcurrency(importConcurrency),
+		tsalign.WithAdaptiveConcurrency(adaptiveConcurrency),
+		tsalign.WithRateLimitRetries(rateLimitRetries),
+		tsalign.WithThingTimeout(time.Duration(thingTimeoutSeconds) * time.Second),
+		tsalign.WithParallelPropertyImport(parallelPropertyImport),
+		tsalign.WithNilLastValuePlaceholder(nilLastValuePlaceholder),
+		tsalign.WithClockSkewTolerance(time.Duration(clockSkewToleranceSeconds) * time.Second),
+		tsalign.WithWindowOverlap(time.Duration(windowOverlapSeconds) * time.Second),
+		tsalign.WithDisplayLocation(logTimezone(configValue(config, LogTimezone), logger)),
+		tsalign.WithPartialAssetPolicy(partialAssetPolicy),
+		tsalign.WithBatchedLastValues(batchedLastValues),
+		tsalign.WithAliasBatching(aliasBatching),
+		tsalign.WithRawImport(importerConfig.RawResolution),
+		tsalign.WithAggregationOverrides(aggregationOverrides),
+		tsalign.WithValueTransforms(valueTransforms),
+		tsalign.WithAliasTemplate(aliasTemplate),
+		tsalign.WithLastValueOnly(lastValueOnly),
+		tsalign.WithLastValuePolicy(lastValuePolicy),
+		tsalign.WithProgressLog(progressLogThings, time.Duration(progressLogSeconds)*time.Second),
+	}
+	if bulkImport != nil {
+		importOpts = append(importOpts, tsalign.WithBulkImport(*bulkImport))
+	}
+	if thingCheckpoints && !event.DryRun {
+		importOpts = append(importOpts, tsalign.WithCheckpoints(checkpoints, advanceEmptyCheckpoints))
+		if stateTable != "" {
+			importOpts = append(importOpts, tsalign.WithCheckpointStore(stateStore, time.Duration(checkpointMaxCatchUpMinutes)*time.Minute))
 		}
-		return nil, errs[0]
 	}
-	errs = aligner.StartAlignAndImport(ctx, tags, alignEntities, resolution, extractionWindowMinutes)
+	if deadLetterQueueURL != "" {
+		queue, err := deadletter.New(deadLetterQueueURL)
+		if err != nil {
+			return tsalign.ImportSummary{}, nil, err
+		}
+		importOpts = append(importOpts, tsalign.WithDeadLetters(queue))
+	}
+	var emitter metrics.Emitter
+	if emfMetrics {
+		emitter = metrics.NewEMF(MetricsNamespace, stack)
+	}
+
+	aligner, errs := align.New(importerConfig.ApiKey, importerConfig.ApiSecret, importerConfig.OrganizationIds, logger,
+		align.WithImportOptions(importOpts...),
+		align.WithAlignOptions(alignOpts...),
+		align.WithPropertyFilter(propfilter.New(categories, propfilter.WithIncludedNames(includedNames), propfilter.WithExcludedNames(excludedNames))),
+		align.WithMinMaxAggregation(minMaxAggregation),
+		align.WithAttributeProperties(attributeProperties),
+		align.WithUpdatedAtProperties(updatedAtProperties),
+		align.WithLocationCoordinates(locationCoordinates),
+		align.WithFetchOnly(fetchOnly),
+		align.WithDryRun(event.DryRun),
+		align.WithDiscoveryCache(discoveryCache, time.Duration(scanIntervalMinutes)*time.Minute),
+		align.WithPropertyTypesCache(propertyTypesCache),
+		align.WithMetrics(emitter),
+		align.WithMaxThings(maxThings, thingsCursor),
+		align.WithSiteWiseOptions(
+			sitewiseclient.WithUpdateConflictRetry(modelUpdateRetries, ModelUpdateRetryBackoff),
+			sitewiseclient.WithStringLimitPolicy(stringLimitPolicy),
+		))
 	if len(errs) > 0 {
 		for _, err := range errs {
 			logger.Error(err)
 		}
-		return nil, errs[0]
+		return tsalign.ImportSummary{}, nil, errs[0]
+	}
+	var summary tsalign.ImportSummary
+	if thingIds != nil {
+		summary, errs = aligner.StartAlignAndImportForThings(ctx, thingIds, tags, alignEntities, resolution, extractionWindowMinutes)
 	} else {
-		if alignEntities {
-			if err = paramReader.UpdateParameterValue(LastModelSync, stack, strconv.FormatInt(executionTimeUtc.Unix(), 10)); err != nil {
-				logger.Error("Error updating parameter "+paramReader.ResolveParameter(LastModelSync, stack), err)
-			}
+		summary, errs = aligner.StartAlignAndImport(ctx, tags, alignEntities, resolution, extractionWindowMinutes)
+	}
+	for _, err := range errs {
+		logger.Error(err)
+	}
+	if event.Verify {
+		sampleSize := readIntConfig(config, VerifySampleSize, DefaultVerifySampleSize)
+		logger.Infoln("------ Verifying import of", sampleSize, "things")
+		if _, err := aligner.VerifyImport(ctx, tags, sampleSize, tsalign.DefaultVerifyTolerance); err != nil {
+			logger.Error("Error verifying import: ", err)
+		}
+	}
+	// Capped runs move on to the next things even on errors, not to be stuck on failing ones
+	if maxThings > 0 && !event.DryRun {
+		if err = stateStore.PutState(ctx, align.ThingsCursorKey, aligner.ThingsCursor()); err != nil {
+			logger.Error("Error storing things cursor: ", err)
+		}
+	}
+	// Only a full run aligns all models and assets: capped runs keep aligning until all the things went through
+	if len(errs) == 0 && alignEntities && !event.DryRun && thingIds == nil && aligner.ThingsCursor() == "" {
+		if err = paramReader.UpdateParameterValue(parameters.LastModelSync, stack, strconv.FormatInt(executionTimeUtc.Unix(), 10)); err != nil {
+			logger.Error("Error updating parameter "+paramReader.ResolveParameter(parameters.LastModelSync, stack), err)
 		}
 	}
 
-	message := "Data aligned and imported successfully"
+	return summary, errs, nil
+}
+
+// importResultMessage returns the JSON message reporting an import with its summary. Errors of a partially
+// successful import are reported too, so that the invocation doesn't fail while most things are imported.
+func importResultMessage(summary tsalign.ImportSummary, errs []error) (*string, error) {
+	result, err := json.Marshal(newImportResult(summary, errs))
+	if err != nil {
+		return nil, err
+	}
+	message := string(result)
 	return &message, nil
 }
 
-func configureDataExtractionTimeWindow(logger *logrus.Entry, paramReader *parameters.ParametersClient, stack string) (int, error) {
-	var schedule *string
-	var err error
-	schedule, err = paramReader.ReadConfig(Scheduling, stack)
+func newImportResult(summary tsalign.ImportSummary, errs []error) importResult {
+	res := importResult{Message: "Data aligned and imported successfully", Summary: summary}
+	if len(errs) > 0 {
+		res.Message = "Data aligned and imported with errors"
+		res.Errors = len(errs)
+		for _, err := range errs[:min(len(errs), maxErrorSample)] {
+			res.ErrorSample = append(res.ErrorSample, err.Error())
+		}
+	}
+	return res
+}
+
+// skippedResultMessage returns the JSON message reporting an execution skipped because another run is in progress.
+func skippedResultMessage() (*string, error) {
+	result, err := json.Marshal(importResult{Message: "Execution skipped, another run is in progress"})
 	if err != nil {
-		logger.Error("Error reading parameter "+paramReader.ResolveParameter(Scheduling, stack), err)
-		return -1, err
+		return nil, err
 	}
-	extractionWindowMinutes := DefaultTimeExtractionWindowMinutes
-	switch *schedule {
-	case "5 minutes":
-		extractionWindowMinutes = 5
-	case "15 minutes":
-		extractionWindowMinutes = 15
-	case "1 hour":
-		extractionWindowMinutes = 60
+	message := string(result)
+	return &message, nil
+}
+
+// setLogFormat switches the logger to JSON logs, queryable by their fields in CloudWatch Logs Insights,
+// when the format is 'json'. Any other value keeps the default text format.
+func setLogFormat(logger *logrus.Logger, format *string) {
+	if format != nil && *format == "json" {
+		logger.SetFormatter(&logrus.JSONFormatter{})
 	}
-	return extractionWindowMinutes, nil
+}
+
+// logTimezone returns the timezone of the times written in logs, nil for UTC. Unknown timezones are ignored.
+func logTimezone(name *string, logger *logrus.Entry) *time.Location {
+	if name == nil || *name == "" {
+		return nil
+	}
+	loc, err := time.LoadLocation(*name)
+	if err != nil {
+		logger.Warnln("Unknown log timezone, logging times in UTC: ", *name)
+		return nil
+	}
+	logger.Infoln("log timezone:", loc)
+	return loc
+}
+
+// configValue returns the value of a parameter read from SSM, or nil when not found.
+func configValue(config map[string]string, param string) *string {
+	value, ok := config[param]
+	if !ok {
+		return nil
+	}
+	return &value
+}
+
+// startupJitter returns a random delay between zero and the given number of seconds, capped to maxStartupJitter.
+// Invalid or missing values mean no delay.
+func startupJitter(maxSeconds string) time.Duration {
+	seconds, err := strconv.Atoi(maxSeconds)
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	bound := min(time.Duration(seconds)*time.Second, maxStartupJitter)
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(bound)+1))
+	if err != nil {
+		return 0
+	}
+	return time.Duration(n.Int64())
+}
+
+// readBoolConfig reads an optional boolean parameter, defaulting to false when not set or invalid.
+func readBoolConfig(config map[string]string, param string) bool {
+	enabled, err := strconv.ParseBool(config[param])
+	return err == nil && enabled
+}
+
+// readIntConfig reads an optional integer parameter, returning defaultValue when not set or invalid.
+func readIntConfig(config map[string]string, param string, defaultValue int) int {
+	parsed, err := strconv.Atoi(config[param])
+	if err != nil {
+		return defaultValue
+	}
+	return parsed
 }
 
 func main() {
-	lambda.Start(HandleRequest)
+	switch os.Getenv(ImportTriggerEnv) {
+	case SQSImportTrigger:
+		lambda.Start(HandleSQSRequest)
+	case APIImportTrigger:
+		lambda.Start(HandleAPIGatewayRequest)
+	default:
+		lambda.Start(HandleRequest)
+	}
+}
+
+// runID identifies the run in the run lock: the Lambda request id, or a random one out of Lambda
+func runID(ctx context.Context) string {
+	if lc, ok := lambdacontext.FromContext(ctx); ok && lc.AwsRequestID != "" {
+		return lc.AwsRequestID
+	}
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "local"
+	}
+	return hex.EncodeToString(b)
 }
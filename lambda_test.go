@@ -0,0 +1,250 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/arduino/aws-sitewise-integration/business/tsalign"
+	"github.com/arduino/aws-sitewise-integration/internal/iot"
+	"github.com/arduino/aws-sitewise-integration/internal/parameters"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/service/iotsitewise"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadConfig_values(t *testing.T) {
+	config := map[string]string{
+		ParallelPropertyImport: "true",
+		ModelUpdateRetries:     "5",
+		ClockSkewTolerance:     "invalid",
+		parameters.IoTApiTags:  "",
+	}
+
+	assert.True(t, readBoolConfig(config, ParallelPropertyImport))
+	assert.False(t, readBoolConfig(config, DeviceHierarchy))
+	assert.Equal(t, 5, readIntConfig(config, ModelUpdateRetries, DefaultModelUpdateRetries))
+	assert.Equal(t, 0, readIntConfig(config, ClockSkewTolerance, 0))
+	assert.Equal(t, 3, readIntConfig(config, DiscoveryScanInterval, 3))
+
+	// Parameters set to '<empty>' are read as empty strings, missing ones as nil
+	tags := configValue(config, parameters.IoTApiTags)
+	if assert.NotNil(t, tags) {
+		assert.Equal(t, "", *tags)
+	}
+	assert.Nil(t, configValue(config, parameters.IoTApiOrgId))
+}
+
+func TestStartupJitter(t *testing.T) {
+	for _, value := range []string{"", "0", "-5", "abc"} {
+		assert.Zero(t, startupJitter(value), value)
+	}
+	for range 20 {
+		assert.LessOrEqual(t, startupJitter("2"), 2*time.Second)
+		assert.LessOrEqual(t, startupJitter("3600"), maxStartupJitter)
+	}
+}
+
+func TestLogTimezone(t *testing.T) {
+	logger := logrus.NewEntry(logrus.New())
+	name := func(s string) *string { return &s }
+
+	assert.Nil(t, logTimezone(nil, logger))
+	assert.Nil(t, logTimezone(name(""), logger))
+	assert.Nil(t, logTimezone(name("Mars/Olympus"), logger))
+	if loc := logTimezone(name("Europe/Rome"), logger); assert.NotNil(t, loc) {
+		assert.Equal(t, "Europe/Rome", loc.String())
+	}
+}
+
+type fakeTokenChecker struct{ err error }
+
+func (f fakeTokenChecker) CheckToken(ctx context.Context) error { return f.err }
+
+type fakeModelLister struct{ err error }
+
+func (f fakeModelLister) ListAssetModels(ctx context.Context, nextToken *string) (*iotsitewise.ListAssetModelsOutput, error) {
+	return &iotsitewise.ListAssetModelsOutput{}, f.err
+}
+
+func TestRunSelfTest(t *testing.T) {
+	logger := logrus.NewEntry(logrus.New())
+	var tokenOrgs []string
+	clients := selfTestClients{
+		arduino: func(key, secret, organization string) (tokenChecker, error) {
+			tokenOrgs = append(tokenOrgs, organization)
+			if organization == "org-2" {
+				return fakeTokenChecker{err: iot.ErrWrongCredentials}, nil
+			}
+			return fakeTokenChecker{}, nil
+		},
+		sitewise: func() (modelLister, error) { return fakeModelLister{}, nil },
+	}
+
+	config := map[string]string{parameters.IoTApiKey: "key", parameters.IoTApiSecret: "secret"}
+	report := runSelfTest(context.Background(), config, nil, clients, logger)
+	assert.True(t, report.OK)
+	assert.Equal(t, []selfTestCheck{{Name: "parameters", OK: true}, {Name: "arduino-token", OK: true}, {Name: "sitewise-list-models", OK: true}}, report.Checks)
+	assert.Equal(t, []string{""}, tokenOrgs)
+
+	tokenOrgs = nil
+	config[parameters.IoTApiOrgId] = "org-1,org-2"
+	report = runSelfTest(context.Background(), config, nil, clients, logger)
+	assert.False(t, report.OK)
+	assert.Equal(t, []string{"org-1", "org-2"}, tokenOrgs)
+	assert.Equal(t, selfTestCheck{Name: "arduino-token:org-2", Error: "wrong credentials"}, report.Checks[2])
+
+	// Token checks are skipped without credentials, SiteWise is checked anyway
+	tokenOrgs = nil
+	clients.sitewise = func() (modelLister, error) { return fakeModelLister{err: errors.New("access denied")}, nil }
+	report = runSelfTest(context.Background(), map[string]string{parameters.IoTApiKey: "key", parameters.IoTApiSecret: ""}, nil, clients, logger)
+	assert.False(t, report.OK)
+	assert.Empty(t, tokenOrgs)
+	assert.Len(t, report.Checks, 3)
+	assert.Contains(t, report.Checks[0].Error, parameters.IoTApiSecret)
+	assert.False(t, report.Checks[1].OK)
+	assert.Equal(t, selfTestCheck{Name: "sitewise-list-models", Error: "access denied"}, report.Checks[2])
+}
+
+func TestImportResultMessage(t *testing.T) {
+	message, err := importResultMessage(tsalign.ImportSummary{ThingsProcessed: 2, PropertiesImported: 5, PointsWritten: 120, PointsSkipped: 3}, nil)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"message": "Data aligned and imported successfully",
+		"summary": {"thingsProcessed": 2, "propertiesImported": 5, "pointsWritten": 120, "pointsSkipped": 3, "throttleRetries": 0}
+	}`, *message)
+}
+
+func TestImportResultMessage_partialSuccess(t *testing.T) {
+	errs := []error{}
+	for i := 0; i < 7; i++ {
+		errs = append(errs, fmt.Errorf("error %d", i))
+	}
+	message, err := importResultMessage(tsalign.ImportSummary{ThingsProcessed: 93, PropertiesImported: 200, PointsWritten: 4000}, errs)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"message": "Data aligned and imported with errors",
+		"summary": {"thingsProcessed": 93, "propertiesImported": 200, "pointsWritten": 4000, "pointsSkipped": 0, "throttleRetries": 0},
+		"errors": 7,
+		"errorSample": ["error 0", "error 1", "error 2", "error 3", "error 4"]
+	}`, *message)
+}
+
+func TestSetLogFormat(t *testing.T) {
+	var out bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&out)
+
+	// Text by default
+	setLogFormat(logger, nil)
+	logger.WithField("thing_id", "bb831f04").Info("Importing data points")
+	assert.Contains(t, out.String(), "thing_id=bb831f04")
+
+	out.Reset()
+	format := "json"
+	setLogFormat(logger, &format)
+	logger.WithField("thing_id", "bb831f04").Info("Importing data points")
+	var entry map[string]any
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+	assert.Equal(t, "bb831f04", entry["thing_id"])
+	assert.Equal(t, "Importing data points", entry["msg"])
+}
+
+func TestThingMessages(t *testing.T) {
+	records := []events.SQSMessage{
+		{MessageId: "m1", Body: "thing1"},
+		{MessageId: "m2", Body: `{"thingId": "thing2"}`},
+		{MessageId: "m3", Body: " thing1\n"},
+		{MessageId: "m4", Body: `{"other": "value"}`},
+		{MessageId: "m5", Body: ""},
+	}
+	messages := thingMessages(records, logrus.NewEntry(logrus.New()))
+	assert.Equal(t, map[string][]string{
+		"thing1": {"m1", "m3"},
+		"thing2": {"m2"},
+	}, messages)
+}
+
+func TestBatchResponse(t *testing.T) {
+	messages := map[string][]string{
+		"thing1": {"m1", "m3"},
+		"thing2": {"m2"},
+	}
+	failedIds := func(response events.SQSEventResponse) []string {
+		ids := []string{}
+		for _, failure := range response.BatchItemFailures {
+			ids = append(ids, failure.ItemIdentifier)
+		}
+		return ids
+	}
+
+	// All imported
+	assert.Empty(t, batchResponse(messages, nil).BatchItemFailures)
+
+	// Only messages of the failed thing are retried
+	errs := []error{&tsalign.ThingImportError{ThingID: "thing1", Err: errors.New("throttled")}}
+	assert.ElementsMatch(t, []string{"m1", "m3"}, failedIds(batchResponse(messages, errs)))
+
+	// Errors not related to a thing fail the whole batch
+	errs = append(errs, errors.New("listing things"))
+	assert.ElementsMatch(t, []string{"m1", "m2", "m3"}, failedIds(batchResponse(messages, errs)))
+}
+
+func TestParseAPIImportRequest(t *testing.T) {
+	req, err := parseAPIImportRequest(events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"tags": "env=prod", "resolution": "1 minute", "alignEntities": "true"},
+	})
+	assert.NoError(t, err)
+	if assert.NotNil(t, req.Tags) && assert.NotNil(t, req.Resolution) && assert.NotNil(t, req.AlignEntities) {
+		assert.Equal(t, "env=prod", *req.Tags)
+		assert.Equal(t, "1 minute", *req.Resolution)
+		assert.True(t, *req.AlignEntities)
+	}
+	assert.False(t, req.DryRun)
+
+	// Body takes precedence over query parameters
+	req, err = parseAPIImportRequest(events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"resolution": "1 minute"},
+		Body:                  `{"resolution": "1 hour", "dryRun": true}`,
+	})
+	assert.NoError(t, err)
+	if assert.NotNil(t, req.Resolution) {
+		assert.Equal(t, "1 hour", *req.Resolution)
+	}
+	assert.Nil(t, req.Tags)
+	assert.Nil(t, req.AlignEntities)
+	assert.True(t, req.DryRun)
+
+	_, err = parseAPIImportRequest(events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"alignEntities": "maybe"}})
+	assert.Error(t, err)
+	_, err = parseAPIImportRequest(events.APIGatewayProxyRequest{Body: "{"})
+	assert.Error(t, err)
+
+	// Invalid overrides are rejected before running the import
+	_, err = parseAPIImportRequest(events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"resolution": "2 minutes"}})
+	assert.Error(t, err)
+	_, err = parseAPIImportRequest(events.APIGatewayProxyRequest{Body: `{"tags": "env"}`})
+	assert.Error(t, err)
+	_, err = parseAPIImportRequest(events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"resolution": "raw", "tags": `note="a,b"`}})
+	assert.NoError(t, err)
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"errors"
 	"os"
 
 	"github.com/arduino/aws-sitewise-integration/internal/parameters"
@@ -10,49 +9,20 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-const (
-	ArduinoPrefix                      = "/arduino/sitewise-importer/" + parameters.StackName
-	IoTApiKey                          = ArduinoPrefix + "/iot/api-key"
-	IoTApiSecret                       = ArduinoPrefix + "/iot/api-secret"
-	IoTApiOrgId                        = ArduinoPrefix + "/iot/org-id"
-	IoTApiTags                         = ArduinoPrefix + "/iot/filter/tags"
-	SamplesReso                        = ArduinoPrefix + "/iot/samples-resolution"
-	Scheduling                         = ArduinoPrefix + "/iot/scheduling"
-	SamplesResolutionSeconds           = 300
-	DefaultTimeExtractionWindowMinutes = 60
-)
-
 func HandleRequest(ctx context.Context, dev bool) (*string, error) {
 
 	stack := os.Getenv("STACK_NAME")
 	logger := logrus.NewEntry(logrus.New())
 
-	var tags *string
-
-	logger.Infoln("------ Reading parameters from SSM")
-	paramReader, err := parameters.New()
+	// Without a stack, or with LOCAL_CONFIG set, parameters are read from the environment and a local file
+	logger.Infoln("------ Reading parameters")
+	paramReader, err := parameters.NewReader(stack)
 	if err != nil {
 		return nil, err
 	}
-	apikey, err := paramReader.ReadConfig(IoTApiKey, stack)
-	if err != nil {
-		logger.Error("Error reading parameter "+IoTApiKey, err)
-	}
-	apiSecret, err := paramReader.ReadConfig(IoTApiSecret, stack)
+	cfg, err := parameters.LoadImporterConfig(paramReader, stack, logger)
 	if err != nil {
-		logger.Error("Error reading parameter "+IoTApiSecret, err)
-	}
-	origId, _ := paramReader.ReadConfig(IoTApiOrgId, stack)
-	organizationId := ""
-	if origId != nil {
-		organizationId = *origId
-	}
-	if apikey == nil || apiSecret == nil {
-		return nil, errors.New("key and secret are required")
-	}
-	tagsParam, _ := paramReader.ReadConfig(IoTApiTags, stack)
-	if tagsParam != nil {
-		tags = tagsParam
+		return nil, err
 	}
 
 	logger.Infoln("------ Running import...")
@@ -60,11 +30,11 @@ func HandleRequest(ctx context.Context, dev bool) (*string, error) {
 		logger.Infoln("Running in dev mode")
 		os.Setenv("IOT_API_URL", "https://api2.oniudra.cc")
 	}
-	logger.Infoln("key:", *apikey)
-	logger.Infoln("secret:", *apiSecret)
-	logger.Infoln("organization-id:", organizationId)
-	if tags != nil {
-		logger.Infoln("tags:", *tags)
+	logger.Infoln("key:", cfg.ApiKey)
+	logger.Infoln("secret:", cfg.ApiSecret)
+	logger.Infoln("organization-ids:", cfg.OrganizationIds)
+	if cfg.Tags != nil {
+		logger.Infoln("tags:", *cfg.Tags)
 	}
 
 	sitewisecl, err := sitewiseclient.New(logger)
@@ -72,11 +42,11 @@ func HandleRequest(ctx context.Context, dev bool) (*string, error) {
 		return nil, err
 	}
 
-	out, err := sitewisecl.ListAssetModels(ctx)
+	models, err := sitewisecl.ListAllAssetModels(ctx)
 	if err != nil {
 		return nil, err
 	}
-	for _, model := range out.AssetModelSummaries {
+	for _, model := range models {
 		logger.Infoln("Model: ", *model.Name)
 		sitewisecl.DeleteAssetModel(ctx, model.Id)
 	}
@@ -0,0 +1,167 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/arduino/aws-sitewise-integration/internal/iot"
+	"github.com/arduino/aws-sitewise-integration/internal/parameters"
+	"github.com/arduino/aws-sitewise-integration/internal/sitewiseclient"
+	"github.com/aws/aws-sdk-go-v2/service/iotsitewise"
+	"github.com/sirupsen/logrus"
+)
+
+// SSM parameters that must be set with a non empty value, the others have a default
+var selfTestRequiredParameters = []string{parameters.IoTApiKey, parameters.IoTApiSecret}
+
+// selfTestCheck is the outcome of a single check of the self test
+type selfTestCheck struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// selfTestReport is the message returned by the self test, as JSON
+type selfTestReport struct {
+	OK     bool            `json:"ok"`
+	Checks []selfTestCheck `json:"checks"`
+}
+
+func (r *selfTestReport) add(name string, err error) {
+	check := selfTestCheck{Name: name, OK: err == nil}
+	if err != nil {
+		check.Error = err.Error()
+	}
+	r.Checks = append(r.Checks, check)
+	r.OK = r.OK && check.OK
+}
+
+type tokenChecker interface {
+	CheckToken(ctx context.Context) error
+}
+
+type modelLister interface {
+	ListAssetModels(ctx context.Context, nextToken *string) (*iotsitewise.ListAssetModelsOutput, error)
+}
+
+// selfTestClients builds the clients used by the self test, so that tests can replace them
+type selfTestClients struct {
+	arduino  func(key, secret, organization string) (tokenChecker, error)
+	sitewise func() (modelLister, error)
+}
+
+// HandleSelfTest checks that the lambda can read its parameters, get an Arduino IoT Cloud token for each
+// configured organization and list SiteWise models. Nothing is written.
+func HandleSelfTest(ctx context.Context) (*string, error) {
+	logger := logrus.NewEntry(logrus.New())
+	stack := os.Getenv("STACK_NAME")
+
+	paramReader, err := parameters.New()
+	if err != nil {
+		return nil, err
+	}
+	config, err := paramReader.ReadConfigs(slices.Concat(parameters.ImporterParameters, handlerParameters), stack)
+	clients := selfTestClients{
+		arduino: func(key, secret, organization string) (tokenChecker, error) {
+			return iot.NewClient(key, secret, organization)
+		},
+		sitewise: func() (modelLister, error) {
+			return sitewiseclient.New(logger)
+		},
+	}
+	report := runSelfTest(ctx, config, err, clients, logger)
+	if !report.OK {
+		logger.Errorf("Self test failed: %+v", report.Checks)
+	} else {
+		logger.Infoln("Self test passed")
+	}
+	result, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+	message := string(result)
+	return &message, nil
+}
+
+// runSelfTest runs the checks on the parameters read from SSM, or on the error reading them. Checks
+// depending on missing parameters are reported as failed.
+func runSelfTest(ctx context.Context, config map[string]string, readErr error, clients selfTestClients, logger *logrus.Entry) selfTestReport {
+	report := selfTestReport{OK: true}
+
+	var importerConfig *parameters.ImporterConfig
+	err := readErr
+	if err == nil {
+		err = checkRequiredParameters(config)
+	}
+	if err == nil {
+		importerConfig, err = parameters.ParseImporterConfig(config, logger)
+	}
+	report.add("parameters", err)
+
+	if importerConfig == nil {
+		report.add("arduino-token", fmt.Errorf("skipped: invalid parameters"))
+	} else {
+		orgids := importerConfig.OrganizationIds
+		if len(orgids) == 0 {
+			orgids = []string{""}
+		}
+		for _, orgid := range orgids {
+			name := "arduino-token"
+			if orgid != "" {
+				name += ":" + orgid
+			}
+			report.add(name, checkArduinoToken(ctx, clients, importerConfig, orgid))
+		}
+	}
+
+	report.add("sitewise-list-models", checkSiteWise(ctx, clients))
+	return report
+}
+
+func checkRequiredParameters(config map[string]string) error {
+	var missing []string
+	for _, param := range selfTestRequiredParameters {
+		if config[param] == "" {
+			missing = append(missing, param)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing or empty parameters: %v", missing)
+	}
+	return nil
+}
+
+func checkArduinoToken(ctx context.Context, clients selfTestClients, cfg *parameters.ImporterConfig, organization string) error {
+	cl, err := clients.arduino(cfg.ApiKey, cfg.ApiSecret, organization)
+	if err != nil {
+		return err
+	}
+	return cl.CheckToken(ctx)
+}
+
+func checkSiteWise(ctx context.Context, clients selfTestClients) error {
+	cl, err := clients.sitewise()
+	if err != nil {
+		return err
+	}
+	_, err = cl.ListAssetModels(ctx, nil)
+	return err
+}
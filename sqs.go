@@ -0,0 +1,115 @@
+// This file is part of arduino aws-sitewise-integration.
+//
+// Copyright 2024 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the Mozilla Public License Version 2.0,
+// which covers the main part of aws-sitewise-integration.
+// The terms of this license can be found at:
+// https://www.mozilla.org/media/MPL/2.0/index.815ca599c9df.txt
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/arduino/aws-sitewise-integration/business/tsalign"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/sirupsen/logrus"
+)
+
+// Environment variable selecting the event triggering the lambda: the schedule by default, otherwise
+// SQSImportTrigger or APIImportTrigger. With SQSImportTrigger, the lambda imports the things whose ids
+// are carried by SQS messages.
+const (
+	ImportTriggerEnv = "IMPORT_TRIGGER"
+	SQSImportTrigger = "sqs"
+)
+
+// thingMessage is the JSON body of an SQS message requesting the import of a thing. Plain thing ids are accepted too.
+type thingMessage struct {
+	ThingID string `json:"thingId"`
+}
+
+// HandleSQSRequest imports the things whose ids are carried by a batch of SQS messages. Messages of things whose
+// import failed are reported as batch item failures, so that only they are retried.
+func HandleSQSRequest(ctx context.Context, event events.SQSEvent) (events.SQSEventResponse, error) {
+	logger := logrus.NewEntry(logrus.New())
+	messages := thingMessages(event.Records, logger)
+	if len(messages) == 0 {
+		return events.SQSEventResponse{}, nil
+	}
+
+	ids := make([]string, 0, len(messages))
+	for id := range messages {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	_, errs, err := runImport(ctx, &SiteWiseImportTrigger{}, runOptions{thingIds: ids})
+	if err != nil {
+		// The whole batch is retried
+		return events.SQSEventResponse{}, err
+	}
+	return batchResponse(messages, errs), nil
+}
+
+// thingMessages returns the ids of the SQS messages by the thing id they carry. Messages without a thing id
+// can't ever be imported: they are dropped with a warning.
+func thingMessages(records []events.SQSMessage, logger *logrus.Entry) map[string][]string {
+	messages := make(map[string][]string)
+	for _, record := range records {
+		id := thingIDFromBody(record.Body)
+		if id == "" {
+			logger.Warnln("Dropping message without thing id:", record.MessageId)
+			continue
+		}
+		messages[id] = append(messages[id], record.MessageId)
+	}
+	return messages
+}
+
+func thingIDFromBody(body string) string {
+	body = strings.TrimSpace(body)
+	if strings.HasPrefix(body, "{") {
+		var msg thingMessage
+		if err := json.Unmarshal([]byte(body), &msg); err != nil {
+			return ""
+		}
+		return strings.TrimSpace(msg.ThingID)
+	}
+	return body
+}
+
+// batchResponse reports the messages of the things whose import failed. Errors not related to a single thing
+// fail all the messages.
+func batchResponse(messages map[string][]string, errs []error) events.SQSEventResponse {
+	failed := make(map[string]bool)
+	for _, err := range errs {
+		var thingErr *tsalign.ThingImportError
+		if !errors.As(err, &thingErr) {
+			failed = nil
+			break
+		}
+		failed[thingErr.ThingID] = true
+	}
+
+	response := events.SQSEventResponse{}
+	for id, messageIds := range messages {
+		if failed != nil && !failed[id] {
+			continue
+		}
+		for _, messageId := range messageIds {
+			response.BatchItemFailures = append(response.BatchItemFailures, events.SQSBatchItemFailure{ItemIdentifier: messageId})
+		}
+	}
+	return response
+}